@@ -0,0 +1,114 @@
+// Package bridge evicts cached users when other services announce changes on a message bus, such as the
+// "user updated" events of a user service, so the caches do not serve them stale until they are evicted.
+//
+// A Bridge reads the messages of a Source, maps each to the IDs of the users it changes with a Decoder,
+// and deletes those users from its caches. A Listener reads the change data capture events of a Source instead,
+// such as the Debezium events of the users table, and invalidates the users created, updated or deleted.
+// The sources read a Redis Stream, a NATS subject or a Kafka topic.
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Source delivers the messages of a message bus to handle until ctx is done or the bus fails.
+// A source that can redeliver a message should do so when handle returns an error.
+type Source interface {
+	Run(ctx context.Context, handle func(msg []byte) error) error
+}
+
+// Decoder returns the IDs of the users a message changes. A message that changes no cached user returns no ID.
+type Decoder func(msg []byte) ([]string, error)
+
+// Invalidator is a cache users can be deleted from. Every cache type of the cache package implements it,
+// as does cache.TieredCache.
+type Invalidator interface {
+	Key(id string) string
+	Delete(key string) error
+}
+
+// Bridge deletes the users announced by the messages of a Source from its caches.
+type Bridge struct {
+	source Source
+	decode Decoder
+	caches []Invalidator
+}
+
+// New creates a Bridge deleting the users decode finds in the messages of source from caches.
+func New(source Source, decode Decoder, caches ...Invalidator) *Bridge {
+	return &Bridge{source: source, decode: decode, caches: caches}
+}
+
+// Run invalidates the users announced by the messages of the source until ctx is done or the source fails.
+func (b *Bridge) Run(ctx context.Context) error {
+	return b.source.Run(ctx, b.handle)
+}
+
+// handle deletes the users announced by a message. A message that cannot be decoded is logged and skipped,
+// since redelivering it would fail again; a failed delete is returned, so the source can redeliver the message.
+func (b *Bridge) handle(msg []byte) error {
+	ids, err := b.decode(msg)
+	if err != nil {
+		log.Printf("Skipping undecodable invalidation message: %q: %v", msg, err)
+		return nil
+	}
+
+	var errs []error
+	for _, id := range ids {
+		for _, c := range b.caches {
+			key := c.Key(id)
+			log.Printf("Invalidating key: %s on message from bus", key)
+			if err := c.Delete(key); err != nil {
+				log.Printf("Error invalidating key: %s: %v", key, err)
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// JSONField returns a Decoder reading the user IDs from field of a JSON object message, such as "id" in
+// {"event": "user.updated", "id": "42"}. The field may hold a string, a number or an array of them.
+func JSONField(field string) Decoder {
+	return func(msg []byte) ([]string, error) {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(msg, &object); err != nil {
+			return nil, err
+		}
+		raw, ok := object[field]
+		if !ok {
+			return nil, fmt.Errorf("no field: %s in message", field)
+		}
+
+		var values []json.RawMessage
+		if err := json.Unmarshal(raw, &values); err != nil {
+			values = []json.RawMessage{raw}
+		}
+		ids := make([]string, 0, len(values))
+		for _, value := range values {
+			id, err := jsonID(value)
+			if err != nil {
+				return nil, fmt.Errorf("field: %s: %w", field, err)
+			}
+			ids = append(ids, id)
+		}
+		return ids, nil
+	}
+}
+
+// jsonID reads a user ID from a JSON string or number.
+func jsonID(value json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s, nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(value, &n); err == nil {
+		return n.String(), nil
+	}
+	return "", fmt.Errorf("not a string or a number: %s", value)
+}
@@ -0,0 +1,188 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrTruncated is returned by a ChangeDecoder for an event truncating the table of the users, which changes every
+// user at once. The Listener purges its caches on it.
+var ErrTruncated = errors.New("table truncated")
+
+// Change is a change of a user in the database, read from a change data capture event.
+type Change struct {
+	ID string
+	// Deleted is true if the user was deleted, false if it was created or updated.
+	Deleted bool
+}
+
+// ChangeDecoder returns the changes of users a change data capture event carries. An event that changes no user,
+// such as a change of another table, returns no change, and an event truncating the table returns ErrTruncated.
+type ChangeDecoder func(msg []byte) ([]Change, error)
+
+// Notifier is a cache told about the changes to the database. Every cache type of the cache package implements it,
+// as does cache.TieredCache.
+type Notifier interface {
+	NotifyUpdated(id string) error
+	NotifyDeleted(id string) error
+}
+
+// Flusher is a cache the Listener can purge when the table is truncated. Every cache type of the cache package
+// implements it, but cache.TieredCache.
+type Flusher interface {
+	Flush() (int, error)
+}
+
+// Listener invalidates the users changed by the change data capture events of a Source, such as the events
+// Debezium captures from the replication log of the database, so the caches stay coherent with the database
+// without the application notifying them on its write paths.
+type Listener struct {
+	source Source
+	decode ChangeDecoder
+	caches []Notifier
+}
+
+// NewListener creates a Listener invalidating the users decode finds changed in the events of source in caches,
+// with NotifyUpdated or NotifyDeleted. The caches implementing Flusher are purged when the table is truncated.
+func NewListener(source Source, decode ChangeDecoder, caches ...Notifier) *Listener {
+	return &Listener{source: source, decode: decode, caches: caches}
+}
+
+// Run invalidates the users changed by the events of the source until ctx is done or the source fails.
+func (l *Listener) Run(ctx context.Context) error {
+	return l.source.Run(ctx, l.handle)
+}
+
+// handle invalidates the users changed by an event. An event that cannot be decoded is logged and skipped,
+// since redelivering it would fail again; a failed invalidation is returned, so the source can redeliver the event.
+func (l *Listener) handle(msg []byte) error {
+	changes, err := l.decode(msg)
+	if errors.Is(err, ErrTruncated) {
+		return l.purge()
+	}
+	if err != nil {
+		log.Printf("Skipping undecodable change event: %q: %v", msg, err)
+		return nil
+	}
+
+	var errs []error
+	for _, change := range changes {
+		for _, c := range l.caches {
+			notify := c.NotifyUpdated
+			if change.Deleted {
+				notify = c.NotifyDeleted
+			}
+			if err := notify(change.ID); err != nil {
+				log.Printf("Error invalidating user: %s on change event: %v", change.ID, err)
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// purge empties the caches after the table was truncated. A cache that cannot be purged is logged and left as is,
+// since redelivering the event would not help; a failed purge is returned, so the source can redeliver the event.
+func (l *Listener) purge() error {
+	var errs []error
+	for _, c := range l.caches {
+		f, ok := c.(Flusher)
+		if !ok {
+			log.Printf("Table truncated, but cache: %T cannot be purged. Its users may be stale.", c)
+			continue
+		}
+		n, err := f.Flush()
+		if err != nil {
+			log.Printf("Error purging cache on truncate event: %v", err)
+			errs = append(errs, err)
+			continue
+		}
+		log.Printf("Table truncated. Purged %d keys of cache: %T", n, c)
+	}
+	return errors.Join(errs...)
+}
+
+// debeziumEvent is the value of a Debezium change event, alone or as the payload of an envelope with its schema.
+type debeziumEvent struct {
+	Payload *debeziumEvent             `json:"payload"`
+	Before  map[string]json.RawMessage `json:"before"`
+	After   map[string]json.RawMessage `json:"after"`
+	Op      string                     `json:"op"`
+	Source  struct {
+		Table string `json:"table"`
+	} `json:"source"`
+}
+
+// Debezium returns a ChangeDecoder reading the Debezium change events of table, with or without their schema,
+// and the user IDs from column of the rows. Creates and updates are changes of the row after the event, and
+// deletes of the row before it; an update changing the ID also deletes the old ID, if the database captures the
+// row before updates, such as a Postgres table with REPLICA IDENTITY FULL. With an empty table, the events of every
+// table are read. Truncates return ErrTruncated, and snapshot reads, messages and the tombstones following deletes
+// change no user.
+func Debezium(table, column string) ChangeDecoder {
+	return func(msg []byte) ([]Change, error) {
+		if len(bytes.TrimSpace(msg)) == 0 {
+			return nil, nil
+		}
+		var event *debeziumEvent
+		if err := json.Unmarshal(msg, &event); err != nil {
+			return nil, err
+		}
+		if event != nil && event.Payload != nil {
+			event = event.Payload
+		}
+		if event == nil || event.Op == "" {
+			return nil, nil
+		}
+		if table != "" && event.Source.Table != table {
+			return nil, nil
+		}
+
+		switch event.Op {
+		case "c", "u":
+			id, err := rowID(event.After, column)
+			if err != nil {
+				return nil, fmt.Errorf("row after: %w", err)
+			}
+			changes := []Change{{ID: id}}
+			if event.Before != nil {
+				if old, err := rowID(event.Before, column); err == nil && old != id {
+					changes = append(changes, Change{ID: old, Deleted: true})
+				}
+			}
+			return changes, nil
+		case "d":
+			id, err := rowID(event.Before, column)
+			if err != nil {
+				return nil, fmt.Errorf("row before: %w", err)
+			}
+			return []Change{{ID: id, Deleted: true}}, nil
+		case "t":
+			return nil, ErrTruncated
+		case "r", "m":
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("unknown operation: %q", event.Op)
+		}
+	}
+}
+
+// rowID reads a user ID from column of a row of a Debezium event.
+func rowID(row map[string]json.RawMessage, column string) (string, error) {
+	if row == nil {
+		return "", errors.New("no row in event")
+	}
+	value, ok := row[column]
+	if !ok {
+		return "", fmt.Errorf("no column: %s in row", column)
+	}
+	id, err := jsonID(value)
+	if err != nil {
+		return "", fmt.Errorf("column: %s: %w", column, err)
+	}
+	return id, nil
+}
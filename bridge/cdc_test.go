@@ -0,0 +1,114 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+)
+
+func TestMain(m *testing.M) {
+	// Silence the per-operation logs of the caches and the bridge.
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestClient returns a client of a miniredis server stopped at the end of the test.
+func newTestClient(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+	m := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: m.Addr(), Protocol: 2})
+	t.Cleanup(func() { client.Close() })
+	return client, m
+}
+
+func TestDebezium(t *testing.T) {
+	decode := Debezium("users", "id")
+	for name, tc := range map[string]struct {
+		msg  string
+		want []Change
+		err  error
+	}{
+		"create":           {msg: `{"op":"c","after":{"id":1},"source":{"table":"users"}}`, want: []Change{{ID: "1"}}},
+		"update":           {msg: `{"op":"u","before":{"id":"1"},"after":{"id":"1"},"source":{"table":"users"}}`, want: []Change{{ID: "1"}}},
+		"update of the ID": {msg: `{"op":"u","before":{"id":1},"after":{"id":2},"source":{"table":"users"}}`, want: []Change{{ID: "2"}, {ID: "1", Deleted: true}}},
+		"delete":           {msg: `{"op":"d","before":{"id":1},"source":{"table":"users"}}`, want: []Change{{ID: "1", Deleted: true}}},
+		"with its schema":  {msg: `{"schema":{},"payload":{"op":"d","before":{"id":1},"source":{"table":"users"}}}`, want: []Change{{ID: "1", Deleted: true}}},
+		"truncate":         {msg: `{"op":"t","source":{"table":"users"}}`, err: ErrTruncated},
+		"snapshot read":    {msg: `{"op":"r","after":{"id":1},"source":{"table":"users"}}`},
+		"other table":      {msg: `{"op":"c","after":{"id":1},"source":{"table":"orders"}}`},
+		"tombstone":        {msg: ``},
+		"null tombstone":   {msg: `null`},
+	} {
+		changes, err := decode([]byte(tc.msg))
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: error %v, want %v", name, err, tc.err)
+		}
+		if !slices.Equal(changes, tc.want) {
+			t.Errorf("%s: changes %+v, want %+v", name, changes, tc.want)
+		}
+	}
+
+	for name, msg := range map[string]string{
+		"unknown operation": `{"op":"x","source":{"table":"users"}}`,
+		"missing column":    `{"op":"c","after":{"name":"a"},"source":{"table":"users"}}`,
+		"invalid JSON":      `{`,
+	} {
+		if _, err := decode([]byte(msg)); err == nil || errors.Is(err, ErrTruncated) {
+			t.Errorf("%s: error %v", name, err)
+		}
+	}
+}
+
+// waitFor waits for cond to hold, failing the test after a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestListenerInvalidatesChangedUsers(t *testing.T) {
+	client, m := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := cache.NewLRU(ctx, client, 10, "lru")
+	for i := 1; i <= 3; i++ {
+		if err := c.Set(cache.User{Id: strconv.Itoa(i), Name: "User " + strconv.Itoa(i)}); err != nil {
+			t.Fatalf("Set(%d): %v", i, err)
+		}
+	}
+
+	source := NewRedisStreamSource(client, "cdc", "listeners", "a")
+	listener := NewListener(source, Debezium("users", "id"), &c)
+	go listener.Run(ctx)
+
+	for _, event := range []string{
+		`{"op":"u","after":{"id":1},"source":{"table":"users"}}`,
+		`{"op":"d","before":{"id":2},"source":{"table":"users"}}`,
+		`{"op":"c","after":{"id":9},"source":{"table":"orders"}}`,
+	} {
+		m.XAdd("cdc", "*", []string{"value", event})
+	}
+	waitFor(t, "the invalidations", func() bool {
+		return !m.Exists(c.Key("1")) && !m.Exists(c.Key("2"))
+	})
+	if !m.Exists(c.Key("3")) {
+		t.Error("an unchanged user was invalidated")
+	}
+
+	m.XAdd("cdc", "*", []string{"value", `{"op":"t","source":{"table":"users"}}`})
+	waitFor(t, "the purge", func() bool { return !m.Exists(c.Key("3")) })
+}
@@ -0,0 +1,55 @@
+package bridge
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaRetryDelay is the delay between the attempts at handling a message whose invalidation failed.
+const kafkaRetryDelay = time.Second
+
+// KafkaSource is a Source reading the messages of a Kafka topic. The offset of a message is only committed once
+// it is handled, and a message whose invalidation fails is retried until it succeeds, since committing a later
+// offset would skip it.
+type KafkaSource struct {
+	reader *kafka.Reader
+}
+
+// NewKafkaSource creates a Source reading with reader, which must have a GroupID for the offsets to be committed.
+// The partitions of the topic are spread across the members of the group, so one delete per message is made,
+// which suits caches every instance shares in Redis.
+func NewKafkaSource(reader *kafka.Reader) *KafkaSource {
+	return &KafkaSource{reader: reader}
+}
+
+// Run delivers the messages of the topic to handle until ctx is done or the reader fails.
+func (s *KafkaSource) Run(ctx context.Context, handle func(msg []byte) error) error {
+	topic := s.reader.Config().Topic
+	log.Printf("Reading invalidations from Kafka topic: %s", topic)
+	for {
+		msg, err := s.reader.FetchMessage(ctx)
+		if err != nil {
+			return err
+		}
+
+		for {
+			err := handle(msg.Value)
+			if err == nil {
+				break
+			}
+			log.Printf("Error handling message at offset: %d of Kafka topic: %s: %v. Retrying in %s", msg.Offset, topic, err, kafkaRetryDelay)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(kafkaRetryDelay):
+			}
+		}
+
+		if err := s.reader.CommitMessages(ctx, msg); err != nil {
+			return err
+		}
+	}
+}
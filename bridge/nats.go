@@ -0,0 +1,51 @@
+package bridge
+
+import (
+	"context"
+	"log"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSSource is a Source reading the messages of a NATS subject. Core NATS does not redeliver messages,
+// so a message whose invalidation fails is logged and lost; the entry then stays until it is evicted.
+type NATSSource struct {
+	conn    *nats.Conn
+	subject string
+	queue   string
+}
+
+// NewNATSSource creates a Source reading subject on conn. With a queue group, each message goes to one member
+// of the group, which suits caches every instance shares in Redis: one delete is enough, and the caches that keep
+// users in process are told by an InvalidationBus. Without one, every instance receives every message.
+func NewNATSSource(conn *nats.Conn, subject, queue string) *NATSSource {
+	return &NATSSource{conn: conn, subject: subject, queue: queue}
+}
+
+// Run delivers the messages of the subject to handle until ctx is done.
+func (s *NATSSource) Run(ctx context.Context, handle func(msg []byte) error) error {
+	msgs := make(chan *nats.Msg, 64)
+	var sub *nats.Subscription
+	var err error
+	if s.queue != "" {
+		sub, err = s.conn.ChanQueueSubscribe(s.subject, s.queue, msgs)
+	} else {
+		sub, err = s.conn.ChanSubscribe(s.subject, msgs)
+	}
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+	log.Printf("Reading invalidations from NATS subject: %s", s.subject)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg := <-msgs:
+			if err := handle(msg.Data); err != nil {
+				log.Printf("Error handling message from NATS subject: %s: %v", s.subject, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// streamRetryDelay is the delay between the attempts at handling a message whose invalidation failed.
+	streamRetryDelay = time.Second
+	// streamBlock is how long a read of a Redis Stream waits for new messages, so ctx is checked regularly.
+	streamBlock = 5 * time.Second
+	// streamCount is the number of messages read from a Redis Stream at once.
+	streamCount = 100
+)
+
+// RedisStreamSource is a Source reading the messages of a Redis Stream through a consumer group, such as the
+// change events the Redis sink of Debezium Server appends. A message is only acknowledged once it is handled,
+// and a message whose invalidation fails is retried until it succeeds. The messages taken before a crash are
+// resumed when the consumer restarts under the same name.
+type RedisStreamSource struct {
+	client   redis.Cmdable
+	stream   string
+	group    string
+	consumer string
+}
+
+// NewRedisStreamSource creates a Source reading stream on client as consumer of group, which is created at the
+// start of the stream if it does not exist. The messages are spread across the consumers of the group, so one
+// invalidation per message is made, which suits caches every instance shares in Redis.
+func NewRedisStreamSource(client redis.Cmdable, stream, group, consumer string) *RedisStreamSource {
+	return &RedisStreamSource{client: client, stream: stream, group: group, consumer: consumer}
+}
+
+// Run delivers the messages of the stream to handle until ctx is done or Redis fails: first the messages this
+// consumer took and did not acknowledge, then the new ones.
+func (s *RedisStreamSource) Run(ctx context.Context, handle func(msg []byte) error) error {
+	err := s.client.XGroupCreateMkStream(ctx, s.stream, s.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	log.Printf("Reading invalidations from Redis Stream: %s as consumer: %s of group: %s", s.stream, s.consumer, s.group)
+
+	start := "0"
+	for {
+		streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: s.consumer,
+			Streams:  []string{s.stream, start},
+			Count:    streamCount,
+			Block:    streamBlock,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var messages []redis.XMessage
+		for _, stream := range streams {
+			messages = append(messages, stream.Messages...)
+		}
+		// Once the pending messages are handled, new ones are read.
+		if start == "0" && len(messages) == 0 {
+			start = ">"
+			continue
+		}
+
+		for _, message := range messages {
+			if err := s.deliver(ctx, message, handle); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// deliver handles a message until it succeeds and acknowledges it. A message with no payload is logged and
+// acknowledged.
+func (s *RedisStreamSource) deliver(ctx context.Context, message redis.XMessage, handle func(msg []byte) error) error {
+	msg, err := streamPayload(message.Values)
+	if err != nil {
+		log.Printf("Skipping message: %s of Redis Stream: %s: %v", message.ID, s.stream, err)
+		return s.client.XAck(ctx, s.stream, s.group, message.ID).Err()
+	}
+
+	for {
+		err := handle(msg)
+		if err == nil {
+			break
+		}
+		log.Printf("Error handling message: %s of Redis Stream: %s: %v. Retrying in %s", message.ID, s.stream, err, streamRetryDelay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(streamRetryDelay):
+		}
+	}
+	return s.client.XAck(ctx, s.stream, s.group, message.ID).Err()
+}
+
+// streamPayload returns the payload of a stream message: its value field, as in the extended format of the Redis
+// sink of Debezium Server, or the value of its only field, as in the compact format, keyed by the record key.
+func streamPayload(values map[string]interface{}) ([]byte, error) {
+	value, ok := values["value"]
+	if !ok && len(values) == 1 {
+		for _, v := range values {
+			value, ok = v, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("no value field in message with %d fields", len(values))
+	}
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("value is not a string: %T", value)
+	}
+	return []byte(s), nil
+}
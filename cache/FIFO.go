@@ -2,141 +2,329 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 const userPrefix = "user"
 const cacheKeyPrefix = "cache_key"
+const versionKeyPrefix = "cache_version"
+const versionSeqField = "seq"
+const memberKeyPrefix = "cache_member"
 
 // FIFOCache represents a LRU cache implemented with linked list in Redis.
 type FIFOCache struct {
 	ctx       context.Context
-	client    *redis.Client
+	client    Client
 	keyPrefix string
 	capacity  int
+	options
 }
 
 // NewFIFO creates a new FIFOCache.
-func NewFIFO(ctx context.Context, client *redis.Client, capacity int, keyPrefix string) FIFOCache {
+func NewFIFO(ctx context.Context, client Client, capacity int, keyPrefix string, opts ...Option) FIFOCache {
 	log.Println("Creating new FIFO cache")
+	if err := LoadScripts(ctx, client); err != nil {
+		log.Printf("Failed to load scripts: %v", err)
+	}
 	return FIFOCache{
 		ctx:       ctx,
 		client:    client,
 		capacity:  capacity,
 		keyPrefix: keyPrefix,
+		options:   clientOptions(client, opts),
 	}
 }
 
 // MakeRequest retrieves a user. It first tries to get the user from the cache.
 // If the user is not in the cache, it gets the user from the database and adds it to the cache.
 func (c *FIFOCache) MakeRequest(id string) User {
-	log.Printf("Making request for user with id: %s", id)
+	user, _ := c.GetOrLoad(id)
+	return user
+}
+
+// GetOrLoad retrieves a user from the cache. If the user is not in the cache, it loads the user with the loader
+// and adds them to the cache. Unlike MakeRequest, it returns the error of the loader.
+// While the circuit breaker of the cache is open, the cache is bypassed and users are served from the loader,
+// or from the fallback cache if the cache was created with WithFallbackCache.
+// Pass SkipCache or ForceRefresh to change how a single call uses the cache.
+func (c *FIFOCache) GetOrLoad(id string, opts ...CallOption) (User, error) {
+	c.logf(LogRead, "Making request for user with id: %s", id)
+	call := newCallOptions(opts)
+	if call.skipCache {
+		c.logf(LogRead, "Skipping cache for user with id: %s.", id)
+		return c.load(c.ctx, id)
+	}
+	if c.degraded() {
+		c.logf(LogMiss, "Redis is unavailable. Loading user with id: %s directly.", id)
+		return c.loadDegraded(c.ctx, id)
+	}
+	if call.forceRefresh {
+		c.logf(LogRead, "Forcing refresh of user with id: %s.", id)
+		return c.loadAndSet(id)
+	}
+
 	user, err := c.Get(id)
+	if timedOut(err) {
+		c.logf(LogMiss, "Timed out getting user with id: %s from Redis. Getting from DB.", id)
+		return c.loadAndSet(id)
+	}
 	if err != nil {
-		log.Printf("Cache miss for user with id: %s. Getting from DB.", id)
-		dbUser := getUserFromDb(id)
-		if err := c.Set(dbUser); err != nil {
-			log.Printf("Cannot write to cache")
-		}
-		return dbUser
+		c.logf(LogMiss, "Cache miss for user with id: %s. Getting from DB.", id)
+		return c.coalesce(c.ctx, c.client, c.generateKey, id, c.loadAndSet, c.Get)
 	}
 
-	log.Printf("Cache hit for user with id: %s.", id)
-	return user
+	c.logf(LogHit, "Cache hit for user with id: %s.", id)
+	return user, nil
+}
+
+// loadAndSet loads a user with the loader and adds them to the cache. The user is returned even if they could not be cached.
+func (c *FIFOCache) loadAndSet(id string) (User, error) {
+	dbUser, err := c.load(c.ctx, id)
+	if err != nil {
+		log.Printf("Failed to load user with id: %s: %v", id, err)
+		return User{}, err
+	}
+	// The user was read from the store, so write-behind must not write it back.
+	loaded := *c
+	loaded.fromStore = true
+	if err := loaded.Set(dbUser); err != nil {
+		log.Printf("Cannot write to cache")
+	} else if err := c.recordWrite(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, dbUser.Id), SourceDatabase); err != nil {
+		log.Printf("Failed to record source of user with id: %s: %v", id, err)
+	}
+	return dbUser, nil
 }
 
 // Get retrieves a user from the cache.
 func (c *FIFOCache) Get(id string) (User, error) {
+	c, cancel := c.withTimeout(c.readTimeout)
+	defer cancel()
+
+	return c.get(id, func(cacheKey string) (User, error) {
+		return c.readReplicaUser(c.ctx, c.client, cacheKey, id)
+	})
+}
+
+// get retrieves a user from the cache with read, pruning the index if the value key no longer exists.
+func (c *FIFOCache) get(id string, read func(cacheKey string) (User, error)) (User, error) {
+	defer c.counters.observe(opGet, time.Now())
 	cacheKey := c.generateKey(userPrefix, id)
 
-	log.Printf("Getting user with key: %s from cache", cacheKey)
-	data, err := c.client.Get(c.ctx, cacheKey).Result()
+	c.logf(LogRead, "Getting user with key: %s from cache", cacheKey)
+	var user User
+	err := c.withRetry(c.ctx, func() (err error) {
+		user, err = read(cacheKey)
+		return err
+	})
+	if err == redis.Nil {
+		c.counters.recordMiss()
+		c.notifyMiss(c.ctx, c.client, c.generateKey, cacheKey)
+		if err := c.pruneKey(cacheKey); err != nil {
+			log.Printf("Failed to prune key: %s from list: %v", cacheKey, err)
+		}
+	}
 	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Error getting user with cache key: %s from Redis: %v", cacheKey, err)
+		}
 		return User{}, err
 	}
 
-	var user User
-	err = json.Unmarshal([]byte(data), &user)
-	if err != nil {
-		return User{}, err
+	c.counters.recordHit()
+	c.notifyHit(c.ctx, c.client, c.generateKey, cacheKey)
+	if err := c.recordHit(c.ctx, c.client, c.generateKey, cacheKey); err != nil {
+		log.Printf("Failed to record hit for key: %s: %v", cacheKey, err)
 	}
 
 	return user, nil
 }
 
 // Set adds a user to the cache. If the cache is full, it removes the oldest item before adding the new one.
+// If the user is already cached, its value is updated in place without changing its position in the queue.
+// The capacity check, the eviction and the write are performed atomically by a single Lua script.
+// If the cache was created with WithLocker, the admission runs while holding the eviction lock.
 func (c *FIFOCache) Set(user User) error {
-	log.Printf("Setting user with id: %s to cache", user.Id)
-	if c.CacheSize() == c.capacity {
-		log.Println("Cache is full. Removing oldest item.")
-		err := c.RemoveOldest()
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	defer c.counters.observe(opSet, time.Now())
+
+	err := c.withRetry(c.ctx, func() error {
+		return withLock(c.ctx, c.locker, c.generateKey(lockKeyPrefix), func() error {
+			return c.admit(user)
+		})
+	})
+	if err == nil {
+		c.counters.sets.Add(1)
+		c.notifyAdmit(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, user.Id))
+		err = c.enqueueWrite(c.ctx, c.generateKey(userPrefix, user.Id), user)
+		c.publishInvalidation(c.ctx, c.generateKey(userPrefix, user.Id))
+	}
+	return err
+}
+
+// admit adds a user to the cache. It is the critical section of Set that runs under the optional eviction lock.
+func (c *FIFOCache) admit(user User) error {
+	c.logf(LogWrite, "Setting user with id: %s to cache", user.Id)
+	a, err := c.admission(user)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	reply, err := scripts.run(c.ctx, c.client, a.script, a.keys, a.args...).StringSlice()
+	if err != nil {
+		return err
+	}
+	return c.admitted(c.ctx, c.client, c.generateKey, string(PolicyFIFO), a, reply, start, SourceSet)
+}
+
+// admission prepares the call of the script admitting a user to the queue, evicting the oldest entries to make room.
+func (c *FIFOCache) admission(user User) (admission, error) {
+	b, err := c.encodeValue(&user)
+	if err != nil {
+		return admission{}, err
+	}
+
+	cacheKey := c.generateKey(userPrefix, user.Id)
+	return admission{
+		value:    &user,
+		cacheKey: cacheKey,
+		script:   admitListScript,
+		keys:     []string{c.generateKey(cacheKeyPrefix), cacheKey, c.generateKey(versionKeyPrefix), c.generateKey(memberKeyPrefix), c.generateKey(bytesKeyPrefix)},
+		args:     []interface{}{c.itemCapacity(c.capacity), b, c.storageMode(), c.maxBytes, c.measure, c.evictionFlags()},
+	}, nil
+}
+
+// batchAdmission prepares the call of the script admitting users to the tail of the queue, evicting entries from
+// its head to make room for all of them.
+func (c *FIFOCache) batchAdmission(users []User) (batchAdmission, error) {
+	values := []interface{}{c.itemCapacity(c.capacity), c.storageMode(), c.maxBytes, c.measure, c.evictionFlags()}
+	b := batchAdmission{users: users, cacheKeys: make([]string, len(users))}
+	for i := range users {
+		v, err := c.encodeValue(&users[i])
 		if err != nil {
-			return err
+			log.Printf("Error marshalling user data for ID: %s: %v", users[i].Id, err)
+			return batchAdmission{}, err
 		}
+		b.cacheKeys[i] = c.generateKey(userPrefix, users[i].Id)
+		values = append(values, v)
 	}
 
-	return c.AddKey(user)
+	b.script = admitListBatchScript
+	b.keys = append([]string{c.generateKey(cacheKeyPrefix), c.generateKey(versionKeyPrefix), c.generateKey(memberKeyPrefix), c.generateKey(bytesKeyPrefix)}, b.cacheKeys...)
+	b.args = values
+	return b, nil
 }
 
 // Delete removes a key from the cache.
 func (c *FIFOCache) Delete(key string) error {
-	log.Printf("Deleting key: %s from cache", key)
-	return c.client.Del(c.ctx, key).Err()
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+
+	c.logf(LogWrite, "Deleting key: %s from cache", key)
+	c.flushEvicted(c.ctx, key)
+	err := c.withRetry(c.ctx, func() error {
+		return c.client.Del(c.ctx, key).Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	c.publishInvalidation(c.ctx, key)
+	return c.dropEntries(c.ctx, c.client, c.generateKey, key)
+}
+
+// Key returns the value key of a user, as passed to Delete and to the callbacks of the cache.
+func (c *FIFOCache) Key(id string) string {
+	return c.generateKey(userPrefix, id)
 }
 
 // CacheSize returns the current number of items in the cache.
+// It counts the membership set, so a duplicated list entry is never counted twice.
 func (c *FIFOCache) CacheSize() int {
-	key := c.generateKey(cacheKeyPrefix)
-	log.Printf("Getting cache size for key: %s", key)
+	key := c.generateKey(memberKeyPrefix)
+	c.logf(LogRead, "Getting cache size for key: %s", key)
 
-	size, err := c.client.LLen(c.ctx, key).Result()
+	size, err := c.client.SCard(c.ctx, key).Result()
 	if err != nil {
 		log.Printf("Error getting cache size for key: %s. Error: %v", key, err)
 		return 0
 	}
-	log.Printf("Cache size for key: %s is: %d", key, size)
+	c.logf(LogRead, "Cache size for key: %s is: %d", key, size)
 	return int(size)
 }
 
-// AddKey adds a new key to the cache.
+// AddKey adds a new key to the cache. A key already in the cache keeps its position in the queue, see pushListScript.
+// The writes and the new version of the entry, see bumpVersion, are sent in a single MULTI/EXEC pipeline, costing
+// one network roundtrip.
 func (c *FIFOCache) AddKey(user User) error {
 	listKey := c.generateKey(cacheKeyPrefix)
 	cacheKey := c.generateKey(userPrefix, user.Id)
-	log.Printf("Adding key: %s to list: %s", cacheKey, listKey)
+	c.logf(LogWrite, "Adding key: %s to list: %s", cacheKey, listKey)
 
-	if err := c.client.RPush(c.ctx, listKey, cacheKey).Err(); err != nil {
+	c.logf(LogWrite, "Setting value for key: %s", cacheKey)
+	_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pushListScript.Eval(c.ctx, pipe, []string{listKey, c.generateKey(memberKeyPrefix), cacheKey})
+		if err := c.writeValue(c.ctx, pipe, cacheKey, &user, 0); err != nil {
+			return err
+		}
+		return bumpVersionScript.Eval(c.ctx, pipe, []string{c.generateKey(versionKeyPrefix), cacheKey}).Err()
+	})
+	if err != nil {
 		return err
 	}
 
-	b, err := json.Marshal(&user)
-	if err != nil {
+	if err := c.accountBytes(c.ctx, c.client, c.generateKey(bytesKeyPrefix), cacheKey); err != nil {
 		return err
 	}
 
-	log.Printf("Setting value for key: %s", cacheKey)
-	return c.client.Set(c.ctx, cacheKey, b, 0).Err()
+	return c.trackEntry(c.ctx, c.client, c.generateKey, cacheKey, &user, SourceSet)
 }
 
 // RemoveOldest removes the oldest item from the cache.
+// The list entry and its value key are removed atomically by a Lua script.
 func (c *FIFOCache) RemoveOldest() error {
+	start := time.Now()
 	listKey := c.generateKey(cacheKeyPrefix)
-	log.Printf("Removing oldest item from list: %s", listKey)
-	removedKey, err := c.client.LPop(c.ctx, listKey).Result()
+	c.logf(LogEvict, "Removing oldest item from list: %s", listKey)
+	reply, err := scripts.run(c.ctx, c.client, evictListScript, []string{listKey, c.generateKey(versionKeyPrefix), c.generateKey(memberKeyPrefix), c.generateKey(bytesKeyPrefix)}, c.storageMode(), c.evictionFlags()).StringSlice()
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Removed key: %s", removedKey)
-	return c.Delete(removedKey)
+	removedKey := reply[0]
+	c.logf(LogEvict, "Removed key: %s", removedKey)
+	c.counters.observe(opEvict, start)
+	c.counters.evictions.Add(1)
+	e := eviction{key: removedKey, reason: EvictCapacity, value: reply[1], trace: reply[2]}
+	c.auditEvictions(c.ctx, c.client, c.generateKey, string(PolicyFIFO), []eviction{e})
+	c.flushEvicted(c.ctx, e.key)
+	if err := c.dropEntries(c.ctx, c.client, c.generateKey, removedKey); err != nil {
+		return err
+	}
+	c.notifyEvict(c.ctx, c.client, c.generateKey, e)
+	return nil
+}
+
+// pruneKey removes a key whose value no longer exists, e.g. because it expired, from the list, unless it was
+// written again in the meantime, see pruneScript.
+func (c *FIFOCache) pruneKey(cacheKey string) error {
+	c.logf(LogMiss, "Pruning key: %s from list: %s", cacheKey, c.generateKey(cacheKeyPrefix))
+	pruned, err := pruneIndex(c.ctx, c.client, c.generateKey, cacheKey, "list")
+	if err != nil || !pruned {
+		return err
+	}
+
+	return c.dropEntries(c.ctx, c.client, c.generateKey, cacheKey)
 }
 
 // generateKey creates a Redis key by joining the given parts with a colon.
 func (c *FIFOCache) generateKey(keys ...string) string {
-	allKeys := []string{c.keyPrefix}
+	allKeys := []string{c.namespace(c.keyPrefix)}
 	allKeys = append(allKeys, keys...)
 
 	return strings.Join(allKeys, ":")
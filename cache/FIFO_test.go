@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestFIFOEvictsOldestAdmitted(t *testing.T) {
+	client, _ := newTestClient(t)
+	c := NewFIFO(context.Background(), client, 3, "fifo")
+
+	setUsers(t, c.Set, nil, 1, 2, 3)
+	// Neither reads nor writes of cached users change their position in the queue.
+	if _, err := c.Get("1"); err != nil {
+		t.Fatalf("Get(1): %v", err)
+	}
+	setUsers(t, c.Set, nil, 1, 4)
+	assertCached(t, client, c.Key, 2, 3, 4)
+
+	setUsers(t, c.Set, nil, 5)
+	assertCached(t, client, c.Key, 3, 4, 5)
+}
+
+func TestFIFOEnforcesCapacity(t *testing.T) {
+	client, _ := newTestClient(t)
+	c := NewFIFO(context.Background(), client, 3, "fifo")
+
+	for i := 1; i <= 10; i++ {
+		setUsers(t, c.Set, nil, i)
+		if size, want := c.CacheSize(), min(i, 3); size != want {
+			t.Fatalf("size after %d users: %d, want %d", i, size, want)
+		}
+	}
+	assertCached(t, client, c.Key, 8, 9, 10)
+}
+
+func TestFIFOAddKeyKeepsPosition(t *testing.T) {
+	client, _ := newTestClient(t)
+	c := NewFIFO(context.Background(), client, 3, "fifo")
+
+	setUsers(t, c.AddKey, nil, 1, 2, 1)
+	entries, err := client.LRange(context.Background(), c.generateKey(cacheKeyPrefix), 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{c.Key("1"), c.Key("2")}; len(entries) != 2 || entries[0] != want[0] || entries[1] != want[1] {
+		t.Errorf("queue: %v, want %v", entries, want)
+	}
+
+	if err := c.RemoveOldest(); err != nil {
+		t.Fatalf("RemoveOldest: %v", err)
+	}
+	assertCached(t, client, c.Key, 2)
+}
+
+func TestFIFOErrors(t *testing.T) {
+	client, m := newTestClient(t)
+	c := NewFIFO(context.Background(), client, 3, "fifo", WithMaxValueSize(64))
+
+	if _, err := c.Get("1"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get of a missing user: %v, want redis.Nil", err)
+	}
+	if err := c.RemoveOldest(); err == nil {
+		t.Error("RemoveOldest of an empty cache succeeded")
+	}
+	big := User{Id: "1", Name: string(make([]byte, 100))}
+	if err := c.Set(big); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Set of a large value: %v, want ErrValueTooLarge", err)
+	}
+	if size := c.CacheSize(); size != 0 {
+		t.Errorf("size after a rejected value: %d, want 0", size)
+	}
+
+	m.Close()
+	if err := c.Set(testUser(1)); err == nil {
+		t.Error("Set with Redis down succeeded")
+	}
+}
@@ -0,0 +1,349 @@
+package cache
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// flushBatchSize is the number of keys Flush deletes per DEL, so a large cache does not block the server.
+const flushBatchSize = 500
+
+// runtimeSettings holds the settings changed at runtime with SetCapacity and the AdminHandler.
+// They are shared by every copy of a cache, but not across application instances.
+type runtimeSettings struct {
+	// capacity overrides the capacity the cache was created with, if not 0.
+	capacity atomic.Int64
+	// quiet turns off the log messages of the LogCategory categories.
+	quiet atomic.Bool
+}
+
+// currentCapacity returns the capacity set at runtime, or capacity, the one the cache was created with.
+func (o options) currentCapacity(capacity int) int {
+	if o.runtime == nil {
+		return capacity
+	}
+	if override := o.runtime.capacity.Load(); override > 0 {
+		return int(override)
+	}
+	return capacity
+}
+
+// resize sets the capacity at runtime and removes the oldest entries until the size of the cache is within it.
+// It returns the number of entries removed.
+func (o options) resize(capacity int, size func() int, removeOldest func() error) (int, error) {
+	if capacity <= 0 {
+		return 0, fmt.Errorf("invalid capacity: %d", capacity)
+	}
+	log.Printf("Setting cache capacity to: %d", capacity)
+	o.runtime.capacity.Store(int64(capacity))
+
+	before := size()
+	current := before
+	for current > capacity {
+		if err := removeOldest(); err != nil {
+			return before - current, err
+		}
+		next := size()
+		if next >= current {
+			return before - current, fmt.Errorf("cache size stuck at %d", next)
+		}
+		current = next
+	}
+	log.Printf("Removed %d entries to fit capacity: %d", before-current, capacity)
+	return before - current, nil
+}
+
+// SetCapacity changes the capacity of the cache at runtime and removes the oldest entries until the cache fits it.
+// It returns the number of entries removed. Other application instances keep their own capacity until it is changed too.
+func (c *FIFOCache) SetCapacity(capacity int) (int, error) {
+	return c.resize(capacity, c.CacheSize, c.RemoveOldest)
+}
+
+// SetCapacity changes the capacity of the cache at runtime and removes the least recently used entries until the cache
+// fits it. It returns the number of entries removed. Other application instances keep their own capacity until it is changed too.
+func (c *LRUCache) SetCapacity(capacity int) (int, error) {
+	return c.resize(capacity, c.CacheSize, c.RemoveOldest)
+}
+
+// SetCapacity changes the capacity of the cache at runtime and removes the least frequently used entries until the cache
+// fits it. It returns the number of entries removed. Other application instances keep their own capacity until it is changed too.
+func (c *LFUCache) SetCapacity(capacity int) (int, error) {
+	return c.resize(capacity, c.CacheSize, c.RemoveOldest)
+}
+
+// SetCapacity changes the capacity of the cache at runtime and removes sampled oldest entries until the cache fits it.
+// It returns the number of entries removed. Other application instances keep their own capacity until it is changed too.
+func (c *ApproxLRUCache) SetCapacity(capacity int) (int, error) {
+	return c.resize(capacity, c.CacheSize, c.RemoveOldest)
+}
+
+// namespaceKeys returns every key of the cache under keyPrefix, hash-tagged or not.
+func (o options) namespaceKeys(ctx context.Context, client Client, keyPrefix string) ([]string, error) {
+	pattern := o.namespace(keyPrefix) + ":*"
+
+	// SCAN only sees the keys of one node, the one holding the keys of the cache.
+	node, err := nodeForKey(ctx, client, pattern)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	iter := node.Scan(ctx, 0, pattern, 0).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// flush deletes every key of the namespace of keyPrefix and returns the number of keys deleted.
+func (o options) flush(ctx context.Context, client Client, keyPrefix string) (int, error) {
+	pattern := o.namespace(keyPrefix) + ":*"
+	log.Printf("Flushing cache keys matching: %s", pattern)
+
+	// Save the queued writes first, since their users are about to leave the cache.
+	if o.writeBehind != nil {
+		if err := o.writeBehind.Flush(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	keys, err := o.namespaceKeys(ctx, client, keyPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for start := 0; start < len(keys); start += flushBatchSize {
+		batch := keys[start:min(start+flushBatchSize, len(keys))]
+		n, err := client.Del(ctx, batch...).Result()
+		if err != nil {
+			log.Printf("Error flushing cache keys matching: %s: %v", pattern, err)
+			return deleted, err
+		}
+		deleted += int(n)
+		o.publishInvalidation(ctx, batch...)
+	}
+	log.Printf("Flushed %d cache keys matching: %s", deleted, pattern)
+	return deleted, nil
+}
+
+// Flush deletes every key of the cache: its values, its index and its metadata. It returns the number of keys deleted.
+// In write-behind mode, the queued users are saved first, and nothing is deleted if that fails.
+func (c *FIFOCache) Flush() (int, error) {
+	return c.flush(c.ctx, c.client, c.keyPrefix)
+}
+
+// Flush deletes every key of the cache: its values, its index and its metadata. It returns the number of keys deleted.
+// In write-behind mode, the queued users are saved first, and nothing is deleted if that fails.
+func (c *LRUCache) Flush() (int, error) {
+	return c.flush(c.ctx, c.client, c.keyPrefix)
+}
+
+// Flush deletes every key of the cache: its values, its index and its metadata. It returns the number of keys deleted.
+// In write-behind mode, the queued users are saved first, and nothing is deleted if that fails.
+func (c *LFUCache) Flush() (int, error) {
+	return c.flush(c.ctx, c.client, c.keyPrefix)
+}
+
+// Flush deletes every key of the cache: its values, its index and its metadata. It returns the number of keys deleted.
+// In write-behind mode, the queued users are saved first, and nothing is deleted if that fails.
+func (c *ApproxLRUCache) Flush() (int, error) {
+	return c.flush(c.ctx, c.client, c.keyPrefix)
+}
+
+// Flush deletes every key of the cache: its values, their expirations and its metadata. It returns the number of keys
+// deleted. In write-behind mode, the queued users are saved first, and nothing is deleted if that fails.
+func (c *TTLCache) Flush() (int, error) {
+	return c.flush(c.ctx, c.client, c.keyPrefix)
+}
+
+// NamespaceKeys returns every key of the cache created on client under keyPrefix with opts, including the keys
+// hash-tagged by WithHashTag or by a Cluster or Ring client. It scans the keyspace, so it is meant for tools and
+// tests rather than production servers.
+func NamespaceKeys(ctx context.Context, client Client, keyPrefix string, opts ...Option) ([]string, error) {
+	return clientOptions(client, opts).namespaceKeys(ctx, client, keyPrefix)
+}
+
+// FlushNamespace deletes every key of the cache created on client under keyPrefix with opts, like Flush, without
+// creating the cache, so a tool can start from an empty namespace. It returns the number of keys deleted.
+func FlushNamespace(ctx context.Context, client Client, keyPrefix string, opts ...Option) (int, error) {
+	return clientOptions(client, opts).flush(ctx, client, keyPrefix)
+}
+
+// adminTarget is the cache an AdminHandler manages.
+type adminTarget struct {
+	settings *runtimeSettings
+	capacity func() int
+	resize   func(capacity int) (int, error)
+	flush    func() (int, error)
+	entries  func() ([]IndexEntry, error)
+}
+
+// AdminHandler returns an HTTP handler to manage the cache at runtime, for services to mount on their own mux:
+//
+//	GET  /capacity       the capacity of the cache
+//	PUT  /capacity       sets the capacity from {"capacity": n}, removing the oldest entries that no longer fit
+//	POST /flush          deletes every key of the cache
+//	GET  /debug-logging  whether the log messages of every operation are written
+//	PUT  /debug-logging  turns them on or off from {"enabled": bool}
+//	GET  /entries        the index members in eviction order with their scores, the first ?limit=n if given
+//
+// Every request must carry the header "Authorization: Bearer <token>". With an empty token, every request is refused.
+func (c *FIFOCache) AdminHandler(token string) http.Handler {
+	return c.adminTarget(c.ctx, c.client, c.keyPrefix, c.capacity, c.SetCapacity, c.Flush).handler(token)
+}
+
+// AdminHandler returns an HTTP handler to manage the cache at runtime, for services to mount on their own mux:
+//
+//	GET  /capacity       the capacity of the cache
+//	PUT  /capacity       sets the capacity from {"capacity": n}, removing the oldest entries that no longer fit
+//	POST /flush          deletes every key of the cache
+//	GET  /debug-logging  whether the log messages of every operation are written
+//	PUT  /debug-logging  turns them on or off from {"enabled": bool}
+//	GET  /entries        the index members in eviction order with their scores, the first ?limit=n if given
+//
+// Every request must carry the header "Authorization: Bearer <token>". With an empty token, every request is refused.
+func (c *LRUCache) AdminHandler(token string) http.Handler {
+	return c.adminTarget(c.ctx, c.client, c.keyPrefix, c.capacity, c.SetCapacity, c.Flush).handler(token)
+}
+
+// AdminHandler returns an HTTP handler to manage the cache at runtime, for services to mount on their own mux:
+//
+//	GET  /capacity       the capacity of the cache
+//	PUT  /capacity       sets the capacity from {"capacity": n}, removing the least frequently used entries that no longer fit
+//	POST /flush          deletes every key of the cache
+//	GET  /debug-logging  whether the log messages of every operation are written
+//	PUT  /debug-logging  turns them on or off from {"enabled": bool}
+//	GET  /entries        the index members in eviction order with their scores, the first ?limit=n if given
+//
+// Every request must carry the header "Authorization: Bearer <token>". With an empty token, every request is refused.
+func (c *LFUCache) AdminHandler(token string) http.Handler {
+	return c.adminTarget(c.ctx, c.client, c.keyPrefix, c.capacity, c.SetCapacity, c.Flush).handler(token)
+}
+
+// AdminHandler returns an HTTP handler to manage the cache at runtime, for services to mount on their own mux:
+//
+//	GET  /capacity       the capacity of the cache
+//	PUT  /capacity       sets the capacity from {"capacity": n}, removing sampled oldest entries that no longer fit
+//	POST /flush          deletes every key of the cache
+//	GET  /debug-logging  whether the log messages of every operation are written
+//	PUT  /debug-logging  turns them on or off from {"enabled": bool}
+//	GET  /entries        the index members in eviction order with their scores, the first ?limit=n if given
+//
+// Every request must carry the header "Authorization: Bearer <token>". With an empty token, every request is refused.
+func (c *ApproxLRUCache) AdminHandler(token string) http.Handler {
+	return c.adminTarget(c.ctx, c.client, c.keyPrefix, c.capacity, c.SetCapacity, c.Flush).handler(token)
+}
+
+// adminTarget assembles the adminTarget of a cache created with capacity.
+func (o options) adminTarget(ctx context.Context, client Client, keyPrefix string, capacity int, resize func(int) (int, error), flush func() (int, error)) adminTarget {
+	return adminTarget{
+		settings: o.runtime,
+		capacity: func() int { return o.currentCapacity(capacity) },
+		resize:   resize,
+		flush:    flush,
+		entries: func() ([]IndexEntry, error) {
+			return indexEntries(ctx, client, o.namespace(keyPrefix)+":"+cacheKeyPrefix)
+		},
+	}
+}
+
+// handler serves the admin endpoints of t to the requests carrying token.
+func (t adminTarget) handler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /capacity", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, map[string]int{"capacity": t.capacity()})
+	})
+	mux.HandleFunc("PUT /capacity", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Capacity int `json:"capacity"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.Capacity <= 0 {
+			http.Error(w, "capacity must be positive", http.StatusBadRequest)
+			return
+		}
+		evicted, err := t.resize(body.Capacity)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeAdminJSON(w, map[string]int{"capacity": t.capacity(), "evicted": evicted})
+	})
+	mux.HandleFunc("POST /flush", func(w http.ResponseWriter, r *http.Request) {
+		deleted, err := t.flush()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeAdminJSON(w, map[string]int{"deleted": deleted})
+	})
+	mux.HandleFunc("GET /debug-logging", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, map[string]bool{"enabled": !t.settings.quiet.Load()})
+	})
+	mux.HandleFunc("PUT /debug-logging", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Setting cache debug logging to: %t", body.Enabled)
+		t.settings.quiet.Store(!body.Enabled)
+		writeAdminJSON(w, map[string]bool{"enabled": body.Enabled})
+	})
+	mux.HandleFunc("GET /entries", func(w http.ResponseWriter, r *http.Request) {
+		entries, err := t.entries()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			limit, err := strconv.Atoi(raw)
+			if err != nil || limit < 0 {
+				http.Error(w, "invalid limit: "+raw, http.StatusBadRequest)
+				return
+			}
+			if limit < len(entries) {
+				entries = entries[:limit]
+			}
+		}
+		if entries == nil {
+			entries = []IndexEntry{}
+		}
+		writeAdminJSON(w, entries)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			log.Printf("Refusing unauthorized cache admin request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		log.Printf("Cache admin request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// writeAdminJSON writes v as the JSON response of an admin endpoint.
+func writeAdminJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing cache admin response: %v", err)
+	}
+}
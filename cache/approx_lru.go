@@ -0,0 +1,471 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const epochKeyPrefix = "cache_epoch"
+
+// defaultSampleSize mirrors the default of Redis's maxmemory-samples setting.
+const defaultSampleSize = 5
+
+// ApproxLRUCache represents an approximated Least Recently Used (LRU) cache implemented with Redis.
+// Instead of keeping a global sorted set, it stores the last access time of every key in a Redis hash
+// and evicts by sampling a few random keys and removing the oldest one among them,
+// the same way Redis approximates LRU for its own maxmemory eviction.
+type ApproxLRUCache struct {
+	ctx        context.Context
+	client     Client
+	keyPrefix  string
+	capacity   int
+	sampleSize int
+	options
+}
+
+// NewApproxLRU creates a new ApproxLRUCache with the given context, Redis client, capacity, sample size and key prefix.
+// A sample size less than 1 falls back to the Redis default of 5.
+func NewApproxLRU(ctx context.Context, client Client, capacity int, sampleSize int, keyPrefix string, opts ...Option) ApproxLRUCache {
+	if sampleSize < 1 {
+		sampleSize = defaultSampleSize
+	}
+
+	log.Printf("Creating new approximated LRU cache with capacity: %d and sample size: %d", capacity, sampleSize)
+	return ApproxLRUCache{
+		ctx:        ctx,
+		client:     client,
+		capacity:   capacity,
+		sampleSize: sampleSize,
+		keyPrefix:  keyPrefix,
+		options:    clientOptions(client, opts),
+	}
+}
+
+// MakeRequest simulates a request for a user by their ID.
+// It first tries to get the user from the cache. If the user is not in the cache (a cache miss),
+// it fetches the user from the database, adds them to the cache, and then returns the user.
+// If the user is found in the cache (a cache hit), it returns the user directly.
+func (c *ApproxLRUCache) MakeRequest(id string) User {
+	user, _ := c.GetOrLoad(id)
+	return user
+}
+
+// GetOrLoad retrieves a user from the cache. If the user is not in the cache, it loads the user with the loader
+// and adds them to the cache. Unlike MakeRequest, it returns the error of the loader.
+// While the circuit breaker of the cache is open, the cache is bypassed and users are served from the loader,
+// or from the fallback cache if the cache was created with WithFallbackCache.
+// Pass SkipCache or ForceRefresh to change how a single call uses the cache.
+func (c *ApproxLRUCache) GetOrLoad(id string, opts ...CallOption) (User, error) {
+	c.logf(LogRead, "Request received for user ID: %s", id)
+	call := newCallOptions(opts)
+	if call.skipCache {
+		c.logf(LogRead, "Skipping cache for user ID: %s.", id)
+		return c.load(c.ctx, id)
+	}
+	if c.degraded() {
+		c.logf(LogMiss, "Redis is unavailable. Loading user ID: %s directly.", id)
+		return c.loadDegraded(c.ctx, id)
+	}
+	if call.forceRefresh {
+		c.logf(LogRead, "Forcing refresh of user ID: %s.", id)
+		return c.loadAndSet(id)
+	}
+
+	user, err := c.Get(id)
+	if timedOut(err) {
+		c.logf(LogMiss, "Timed out getting user ID: %s from Redis. Fetching from database.", id)
+		return c.loadAndSet(id)
+	}
+	if err != nil {
+		c.logf(LogMiss, "Cache miss for user ID: %s. Fetching from database.", id)
+		return c.coalesce(c.ctx, c.client, c.generateKey, id, c.loadAndSet, c.Get)
+	}
+
+	c.logf(LogHit, "Cache hit for user ID: %s.", id)
+	return user, nil
+}
+
+// loadAndSet loads a user with the loader and adds them to the cache. The user is returned even if they could not be cached.
+func (c *ApproxLRUCache) loadAndSet(id string) (User, error) {
+	dbUser, err := c.load(c.ctx, id)
+	if err != nil {
+		log.Printf("Failed to load user ID: %s: %v", id, err)
+		return User{}, err
+	}
+	// The user was read from the store, so write-behind must not write it back.
+	loaded := *c
+	loaded.fromStore = true
+	if err := loaded.Set(dbUser); err != nil {
+		log.Printf("Failed to write user ID: %s to cache: %v", id, err)
+	} else if err := c.recordWrite(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, dbUser.Id), SourceDatabase); err != nil {
+		log.Printf("Failed to record source of user ID: %s: %v", id, err)
+	}
+	return dbUser, nil
+}
+
+// Get retrieves a user from the cache by their ID.
+// If the user is found, it updates their last access time and returns the user.
+func (c *ApproxLRUCache) Get(id string) (User, error) {
+	c, cancel := c.withTimeout(c.readTimeout)
+	defer cancel()
+
+	return c.get(id, func(cacheKey string) (User, error) {
+		return c.readReplicaUser(c.ctx, c.client, cacheKey, id)
+	})
+}
+
+// get retrieves a user from the cache with read, pruning the index if the value key no longer exists.
+func (c *ApproxLRUCache) get(id string, read func(cacheKey string) (User, error)) (User, error) {
+	defer c.counters.observe(opGet, time.Now())
+	cacheKey := c.generateKey(userPrefix, id)
+	c.logf(LogRead, "Attempting to get user with cache key: %s", cacheKey)
+
+	var user User
+	err := c.withRetry(c.ctx, func() (err error) {
+		user, err = read(cacheKey)
+		return err
+	})
+	if err == redis.Nil {
+		c.counters.recordMiss()
+		c.notifyMiss(c.ctx, c.client, c.generateKey, cacheKey)
+		if err := c.pruneKey(cacheKey); err != nil {
+			log.Printf("Failed to prune key: %s from hash: %v", cacheKey, err)
+		}
+	}
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Error getting user with cache key: %s from Redis: %v", cacheKey, err)
+		}
+		return User{}, err
+	}
+
+	c.logf(LogHit, "Successfully retrieved user with cache key: %s. Updating recency.", cacheKey)
+	if err := c.UpdateRecency(id); err != nil {
+		log.Printf("Failed to update recency for user ID: %s: %v", id, err)
+		return User{}, err
+	}
+
+	c.counters.recordHit()
+	c.notifyHit(c.ctx, c.client, c.generateKey, cacheKey)
+	if err := c.recordHit(c.ctx, c.client, c.generateKey, cacheKey); err != nil {
+		log.Printf("Failed to record hit for key: %s: %v", cacheKey, err)
+	}
+
+	return user, nil
+}
+
+// Set adds a user to the cache.
+// If the cache is full, it evicts the oldest of a random sample of items before adding the new one.
+// If the user is already cached, its value and access time are updated without evicting anything.
+// If the cache was created with WithLocker, the admission runs while holding the eviction lock.
+// If it was created with WithCoordinatedEviction or with a byte capacity, sampling, eviction and admission
+// run as a single Lua script.
+func (c *ApproxLRUCache) Set(user User) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	defer c.counters.observe(opSet, time.Now())
+
+	err := c.withRetry(c.ctx, func() error {
+		return withLock(c.ctx, c.locker, c.generateKey(lockKeyPrefix), func() error {
+			return c.admit(user)
+		})
+	})
+	if err == nil {
+		c.counters.sets.Add(1)
+		c.notifyAdmit(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, user.Id))
+		err = c.enqueueWrite(c.ctx, c.generateKey(userPrefix, user.Id), user)
+		c.publishInvalidation(c.ctx, c.generateKey(userPrefix, user.Id))
+	}
+	return err
+}
+
+// admit adds a user to the cache. It is the critical section of Set that runs under the optional eviction lock.
+func (c *ApproxLRUCache) admit(user User) error {
+	c.logf(LogWrite, "Attempting to set user with ID: %s to cache.", user.Id)
+	if c.coordinated || c.maxBytes > 0 {
+		return c.admitCoordinated(user)
+	}
+
+	hashKey := c.generateKey(cacheKeyPrefix)
+	cacheKey := c.generateKey(userPrefix, user.Id)
+
+	exists, err := c.client.HExists(c.ctx, hashKey, cacheKey).Result()
+	if err != nil {
+		log.Printf("Error checking membership of key: %s in hash: %s: %v", cacheKey, hashKey, err)
+		return err
+	}
+	if exists {
+		c.logf(LogWrite, "User with ID: %s is already cached. Updating in place.", user.Id)
+		return c.AddKey(user)
+	}
+
+	currentSize := c.CacheSize()
+	if currentSize >= c.currentCapacity(c.capacity) {
+		c.logf(LogEvict, "Cache is full (size: %d, capacity: %d). Removing oldest sampled item.", currentSize, c.currentCapacity(c.capacity))
+		if err := c.RemoveOldest(); err != nil {
+			log.Printf("Failed to remove oldest sampled item from cache: %v", err)
+			return err
+		}
+	}
+
+	return c.AddKey(user)
+}
+
+// admitCoordinated adds a user to the cache through a single Lua script that samples, evicts and admits atomically.
+func (c *ApproxLRUCache) admitCoordinated(user User) error {
+	a, err := c.admission(user)
+	if err != nil {
+		log.Printf("Error marshalling user data for ID: %s: %v", user.Id, err)
+		return err
+	}
+
+	start := time.Now()
+	reply, err := scripts.run(c.ctx, c.client, a.script, a.keys, a.args...).StringSlice()
+	if err != nil {
+		log.Printf("Error admitting key: %s to hash: %s: %v", a.cacheKey, a.keys[0], err)
+		return err
+	}
+	return c.admitted(c.ctx, c.client, c.generateKey, string(PolicyApproxLRU), a, reply, start, SourceSet)
+}
+
+// admission prepares the call of the script sampling, evicting and admitting a user atomically, as in coordinated
+// eviction mode.
+func (c *ApproxLRUCache) admission(user User) (admission, error) {
+	b, err := c.encodeValue(&user)
+	if err != nil {
+		return admission{}, err
+	}
+
+	cacheKey := c.generateKey(userPrefix, user.Id)
+	return admission{
+		value:    &user,
+		cacheKey: cacheKey,
+		script:   admitSampledScript,
+		keys:     []string{c.generateKey(cacheKeyPrefix), cacheKey, c.generateKey(versionKeyPrefix), c.generateKey(epochKeyPrefix), c.generateKey(bytesKeyPrefix)},
+		args:     []interface{}{c.itemCapacity(c.capacity), c.now().UnixNano(), b, c.sampleSize, c.storageMode(), c.maxBytes, c.measure, c.evictionFlags()},
+	}, nil
+}
+
+// batchAdmission prepares the call of the script admitting users with the current access time, evicting the oldest
+// of sampled entries to make room for all of them.
+func (c *ApproxLRUCache) batchAdmission(users []User) (batchAdmission, error) {
+	values := []interface{}{c.itemCapacity(c.capacity), c.now().UnixNano(), c.sampleSize, c.storageMode(), c.maxBytes, c.measure, c.evictionFlags()}
+	b := batchAdmission{users: users, cacheKeys: make([]string, len(users))}
+	for i := range users {
+		v, err := c.encodeValue(&users[i])
+		if err != nil {
+			log.Printf("Error marshalling user data for ID: %s: %v", users[i].Id, err)
+			return batchAdmission{}, err
+		}
+		b.cacheKeys[i] = c.generateKey(userPrefix, users[i].Id)
+		values = append(values, v)
+	}
+
+	b.script = admitSampledBatchScript
+	b.keys = append([]string{c.generateKey(cacheKeyPrefix), c.generateKey(versionKeyPrefix), c.generateKey(epochKeyPrefix), c.generateKey(bytesKeyPrefix)}, b.cacheKeys...)
+	b.args = values
+	return b, nil
+}
+
+// EvictionEpoch returns the number of evictions performed in coordinated eviction mode by all application instances sharing the cache.
+func (c *ApproxLRUCache) EvictionEpoch() (int64, error) {
+	epoch, err := c.client.Get(c.ctx, c.generateKey(epochKeyPrefix)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return epoch, err
+}
+
+// Delete removes a key from the cache.
+func (c *ApproxLRUCache) Delete(key string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+
+	c.logf(LogWrite, "Deleting key: %s from cache", key)
+	c.flushEvicted(c.ctx, key)
+	err := c.withRetry(c.ctx, func() error {
+		return c.client.Del(c.ctx, key).Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	c.publishInvalidation(c.ctx, key)
+	return c.dropEntries(c.ctx, c.client, c.generateKey, key)
+}
+
+// Key returns the value key of a user, as passed to Delete and to the callbacks of the cache.
+func (c *ApproxLRUCache) Key(id string) string {
+	return c.generateKey(userPrefix, id)
+}
+
+// CacheSize returns the current number of items in the cache.
+func (c *ApproxLRUCache) CacheSize() int {
+	key := c.generateKey(cacheKeyPrefix)
+	c.logf(LogRead, "Getting cache size for key: %s", key)
+
+	size, err := c.client.HLen(c.ctx, key).Result()
+	if err != nil {
+		log.Printf("Error getting cache size for key: %s. Error: %v", key, err)
+		return 0
+	}
+	c.logf(LogRead, "Cache size for key: %s is: %d", key, size)
+	return int(size)
+}
+
+// AddKey adds a new user to the cache. It adds the user's data to a Redis key
+// and records its access time in the hash used for sampling.
+// The writes and the new version of the entry, see bumpVersion, are sent in a single MULTI/EXEC pipeline, costing
+// one network roundtrip.
+func (c *ApproxLRUCache) AddKey(user User) error {
+	hashKey := c.generateKey(cacheKeyPrefix)
+	cacheKey := c.generateKey(userPrefix, user.Id)
+	c.logf(LogWrite, "Adding key: %s to hash: %s", cacheKey, hashKey)
+
+	c.logf(LogWrite, "Setting value for key: %s", cacheKey)
+	_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(c.ctx, hashKey, cacheKey, c.now().UnixNano())
+		if err := c.writeValue(c.ctx, pipe, cacheKey, &user, 0); err != nil {
+			return err
+		}
+		return bumpVersionScript.Eval(c.ctx, pipe, []string{c.generateKey(versionKeyPrefix), cacheKey}).Err()
+	})
+	if err != nil {
+		log.Printf("Error adding key: %s to hash: %s: %v", cacheKey, hashKey, err)
+		return err
+	}
+
+	if err := c.accountBytes(c.ctx, c.client, c.generateKey(bytesKeyPrefix), cacheKey); err != nil {
+		return err
+	}
+
+	return c.trackEntry(c.ctx, c.client, c.generateKey, cacheKey, &user, SourceSet)
+}
+
+// UpdateRecency updates the access time of a user in the cache, marking them as recently used.
+func (c *ApproxLRUCache) UpdateRecency(id string) error {
+	hashKey := c.generateKey(cacheKeyPrefix)
+	cacheKey := c.generateKey(userPrefix, id)
+	c.logf(LogRead, "Updating recency for key: %s in hash: %s", cacheKey, hashKey)
+
+	if err := c.client.HSet(c.ctx, hashKey, cacheKey, c.now().UnixNano()).Err(); err != nil {
+		log.Printf("Error updating recency for key: %s: %v", cacheKey, err)
+		return err
+	}
+
+	return nil
+}
+
+// RemoveOldest samples up to sampleSize random items and removes the least recently used one among them.
+// Because only a sample is inspected, the evicted item is not guaranteed to be the globally oldest one.
+// The hash field, the version and the value key of the victim are removed together in a MULTI/EXEC transaction.
+func (c *ApproxLRUCache) RemoveOldest() error {
+	start := time.Now()
+	hashKey := c.generateKey(cacheKeyPrefix)
+	c.logf(LogEvict, "Sampling %d items from hash: %s", c.sampleSize, hashKey)
+
+	samples, err := c.client.HRandFieldWithValues(c.ctx, hashKey, c.sampleSize).Result()
+	if err != nil {
+		log.Printf("Error sampling items from hash: %s: %v", hashKey, err)
+		return err
+	}
+
+	if len(samples) == 0 {
+		log.Println("No items to remove from cache.")
+		return fmt.Errorf("no items to remove from cache")
+	}
+
+	victim := ""
+	var oldest int64
+	for _, sample := range samples {
+		accessedAt, err := strconv.ParseInt(sample.Value, 10, 64)
+		if err != nil {
+			log.Printf("Error parsing access time for key: %s: %v", sample.Key, err)
+			return err
+		}
+		if victim == "" || accessedAt < oldest {
+			victim = sample.Key
+			oldest = accessedAt
+		}
+	}
+	c.logf(LogEvict, "Oldest sampled member: %s", victim)
+
+	// Unlike the admission script, the victim is removed from Go, so its value is read beforehand for the eviction callbacks.
+	var evicted User
+	if len(c.onEvict) > 0 {
+		if evicted, err = c.readUser(c.ctx, c.client, victim, ""); err != nil && err != redis.Nil {
+			log.Printf("Error reading evicted value of key: %s: %v", victim, err)
+		}
+	}
+
+	_, err = c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(c.ctx, hashKey, victim)
+		pipe.HDel(c.ctx, c.generateKey(versionKeyPrefix), victim)
+		pipe.Del(c.ctx, victim)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error removing key: %s from hash: %s: %v", victim, hashKey, err)
+		return err
+	}
+
+	if err := c.releaseBytes(c.ctx, c.client, c.generateKey(bytesKeyPrefix), victim); err != nil {
+		return err
+	}
+
+	c.counters.observe(opEvict, start)
+	c.counters.evictions.Add(1)
+	c.auditEvictions(c.ctx, c.client, c.generateKey, string(PolicyApproxLRU), []eviction{{key: victim, reason: EvictCapacity}})
+	c.flushEvicted(c.ctx, victim)
+	if err := c.dropEntries(c.ctx, c.client, c.generateKey, victim); err != nil {
+		return err
+	}
+	c.logEvent(c.ctx, c.client, c.generateKey, EventEvict, victim, EvictCapacity)
+	if c.traces != nil {
+		c.traces.add(sampledTrace(victim, oldest, samples, c.now()))
+	}
+	c.callEvict(victim, evicted, EvictCapacity)
+	return nil
+}
+
+// sampledTrace builds the trace of a victim chosen among samples at now, with the other sampled members as candidates.
+func sampledTrace(victim string, accessedAt int64, samples []redis.KeyValue, now time.Time) EvictionTrace {
+	t := EvictionTrace{
+		Key:    victim,
+		Reason: EvictCapacity,
+		Time:   now,
+		Score:  float64(accessedAt),
+	}
+	for _, sample := range samples {
+		if sample.Key != victim {
+			t.Candidates = append(t.Candidates, EvictionCandidate{Key: sample.Key, Score: parseScore(sample.Value)})
+		}
+	}
+	return t
+}
+
+// pruneKey removes a key whose value no longer exists, e.g. because it expired, from the hash, unless it was
+// written again in the meantime, see pruneScript.
+func (c *ApproxLRUCache) pruneKey(cacheKey string) error {
+	c.logf(LogMiss, "Pruning key: %s from hash: %s", cacheKey, c.generateKey(cacheKeyPrefix))
+	pruned, err := pruneIndex(c.ctx, c.client, c.generateKey, cacheKey, "hash")
+	if err != nil || !pruned {
+		return err
+	}
+
+	return c.dropEntries(c.ctx, c.client, c.generateKey, cacheKey)
+}
+
+// generateKey creates a Redis key by joining the key prefix and other key parts with a colon.
+func (c *ApproxLRUCache) generateKey(keys ...string) string {
+	allKeys := []string{c.namespace(c.keyPrefix)}
+	allKeys = append(allKeys, keys...)
+
+	return strings.Join(allKeys, ":")
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// With a sample as large as the cache, the approximated LRU cache evicts the least recently used entry.
+func TestApproxLRUEvictsOldestSampled(t *testing.T) {
+	client, _ := newTestClient(t)
+	clock := testClock()
+	c := NewApproxLRU(context.Background(), client, 3, 3, "approx_lru", WithClock(clock))
+
+	setUsers(t, c.Set, clock, 1, 2, 3)
+	clock.Advance(time.Millisecond)
+	if _, err := c.Get("1"); err != nil {
+		t.Fatalf("Get(1): %v", err)
+	}
+	setUsers(t, c.Set, clock, 4)
+	assertCached(t, client, c.Key, 1, 3, 4)
+
+	if err := c.RemoveOldest(); err != nil {
+		t.Fatalf("RemoveOldest: %v", err)
+	}
+	assertCached(t, client, c.Key, 1, 4)
+}
+
+func TestApproxLRUEnforcesCapacity(t *testing.T) {
+	client, _ := newTestClient(t)
+	clock := testClock()
+	c := NewApproxLRU(context.Background(), client, 3, 2, "approx_lru", WithClock(clock))
+
+	for i := 1; i <= 10; i++ {
+		setUsers(t, c.Set, clock, i)
+		if size, want := c.CacheSize(), min(i, 3); size != want {
+			t.Fatalf("size after %d users: %d, want %d", i, size, want)
+		}
+	}
+	// The newest user is never evicted to admit itself.
+	if _, err := c.Get("10"); err != nil {
+		t.Errorf("Get(10): %v", err)
+	}
+}
+
+func TestApproxLRUErrors(t *testing.T) {
+	client, m := newTestClient(t)
+	c := NewApproxLRU(context.Background(), client, 3, 3, "approx_lru", WithLoader(testLoader))
+
+	if err := c.RemoveOldest(); err == nil {
+		t.Error("RemoveOldest of an empty cache succeeded")
+	}
+	if _, err := c.Get("1"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get of a missing user: %v, want redis.Nil", err)
+	}
+	if _, err := c.GetOrLoad("x"); err == nil {
+		t.Error("GetOrLoad of a user the loader cannot load succeeded")
+	}
+	if size := c.CacheSize(); size != 0 {
+		t.Errorf("size after a failed load: %d, want 0", size)
+	}
+
+	m.Close()
+	if err := c.Set(testUser(1)); err == nil {
+		t.Error("Set with Redis down succeeded")
+	}
+}
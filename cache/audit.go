@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const auditKeyPrefix = "cache_evictions"
+
+// EvictionRecord is an entry of the eviction audit trail.
+type EvictionRecord struct {
+	// Key is the value key of the evicted entry.
+	Key string `json:"key"`
+	// Reason is why the entry was evicted, EvictCapacity or EvictBytes.
+	Reason string `json:"reason"`
+	// Policy is the eviction policy of the cache: "fifo", "lru", "lfu" or "approx-lru".
+	Policy string `json:"policy"`
+	// EvictedAt is when the entry was evicted.
+	EvictedAt time.Time `json:"evicted_at"`
+	// Residency is how long the entry was cached, or 0 if its admission time was not recorded.
+	Residency time.Duration `json:"residency"`
+}
+
+// WithEvictionAudit persists every eviction of the cache to the Redis Stream <keyPrefix>:cache_evictions,
+// trimmed to approximately maxLen entries, with the evicted key, the reason, the policy and how long the
+// entry was cached. The audit trail is shared by every application instance using the cache and is queried
+// with EvictionHistory. Admission times are recorded to compute the residency, as with WithEntryInfo.
+// Every eviction costs one more read and one more write; a failed write is logged and does not fail the operation.
+func WithEvictionAudit(maxLen int64) Option {
+	return func(o *options) {
+		o.auditLen = maxLen
+	}
+}
+
+// auditEvictions appends evictions to the audit trail of the cache, if it has one, and records their residency
+// in the counters when admission times are recorded, with WithEntryInfo or WithEvictionAudit.
+// It must be called before the metadata of the evicted keys is dropped, because it reads their admission time.
+func (o options) auditEvictions(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, policy string, evictions []eviction) {
+	if (!o.recordInfo && o.auditLen <= 0) || len(evictions) == 0 {
+		return
+	}
+
+	created, err := client.HMGet(ctx, generateKey(metaKeyPrefix, metaCreatedField), evictedKeys(evictions)...).Result()
+	if err != nil {
+		log.Printf("Error getting admission times of evicted keys: %v", err)
+		created = make([]interface{}, len(evictions))
+	}
+
+	now := o.now()
+	residencies := make([]time.Duration, len(evictions))
+	for i := range evictions {
+		if s, ok := created[i].(string); ok {
+			if admittedAt, err := strconv.ParseInt(s, 10, 64); err == nil {
+				residencies[i] = now.Sub(time.Unix(0, admittedAt))
+				o.counters.recordResidency(residencies[i])
+			}
+		}
+	}
+	if o.auditLen <= 0 {
+		return
+	}
+
+	stream := generateKey(auditKeyPrefix)
+	_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, e := range evictions {
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: stream,
+				MaxLen: o.auditLen,
+				Approx: true,
+				Values: []interface{}{"key", e.key, "reason", e.reason, "policy", policy, "residency", int64(residencies[i])},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error appending evictions to stream: %s: %v", stream, err)
+	}
+}
+
+// evictionHistory returns the audited evictions between from and to, the oldest first, optionally only those of key.
+func (o options) evictionHistory(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, from, to time.Time, key string) ([]EvictionRecord, error) {
+	stream := generateKey(auditKeyPrefix)
+	log.Printf("Getting evictions from stream: %s between %s and %s", stream, from, to)
+
+	start, end := "-", "+"
+	if !from.IsZero() {
+		start = strconv.FormatInt(from.UnixMilli(), 10)
+	}
+	if !to.IsZero() {
+		end = strconv.FormatInt(to.UnixMilli(), 10)
+	}
+	messages, err := client.XRange(ctx, stream, start, end).Result()
+	if err != nil {
+		log.Printf("Error reading evictions from stream: %s: %v", stream, err)
+		return nil, err
+	}
+
+	var records []EvictionRecord
+	for _, message := range messages {
+		record := parseEvictionRecord(message)
+		if key == "" || record.Key == key {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// parseEvictionRecord reads an entry of the audit trail. The eviction time is the time of its stream ID.
+func parseEvictionRecord(message redis.XMessage) EvictionRecord {
+	field := func(name string) string {
+		s, _ := message.Values[name].(string)
+		return s
+	}
+
+	record := EvictionRecord{
+		Key:    field("key"),
+		Reason: field("reason"),
+		Policy: field("policy"),
+	}
+	if ms, _, found := strings.Cut(message.ID, "-"); found {
+		if n, err := strconv.ParseInt(ms, 10, 64); err == nil {
+			record.EvictedAt = time.UnixMilli(n)
+		}
+	}
+	if residency, err := strconv.ParseInt(field("residency"), 10, 64); err == nil {
+		record.Residency = time.Duration(residency)
+	}
+	return record
+}
+
+// EvictionHistory returns the evictions recorded in the audit trail between from and to, the oldest first.
+// A zero from or to leaves the range open on that side, and a non-empty key only returns the evictions of that value key.
+// It returns no records unless the cache was created with WithEvictionAudit.
+func (c *FIFOCache) EvictionHistory(from, to time.Time, key string) ([]EvictionRecord, error) {
+	return c.evictionHistory(c.ctx, c.client, c.generateKey, from, to, key)
+}
+
+// EvictionHistory returns the evictions recorded in the audit trail between from and to, the oldest first.
+// A zero from or to leaves the range open on that side, and a non-empty key only returns the evictions of that value key.
+// It returns no records unless the cache was created with WithEvictionAudit.
+func (c *LRUCache) EvictionHistory(from, to time.Time, key string) ([]EvictionRecord, error) {
+	return c.evictionHistory(c.ctx, c.client, c.generateKey, from, to, key)
+}
+
+// EvictionHistory returns the evictions recorded in the audit trail between from and to, the oldest first.
+// A zero from or to leaves the range open on that side, and a non-empty key only returns the evictions of that value key.
+// It returns no records unless the cache was created with WithEvictionAudit.
+func (c *LFUCache) EvictionHistory(from, to time.Time, key string) ([]EvictionRecord, error) {
+	return c.evictionHistory(c.ctx, c.client, c.generateKey, from, to, key)
+}
+
+// EvictionHistory returns the evictions recorded in the audit trail between from and to, the oldest first.
+// A zero from or to leaves the range open on that side, and a non-empty key only returns the evictions of that value key.
+// It returns no records unless the cache was created with WithEvictionAudit.
+func (c *ApproxLRUCache) EvictionHistory(from, to time.Time, key string) ([]EvictionRecord, error) {
+	return c.evictionHistory(c.ctx, c.client, c.generateKey, from, to, key)
+}
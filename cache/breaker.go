@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+	"weak"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCircuitOpen is returned for Redis commands rejected by an open CircuitBreaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// States of a CircuitBreaker, as reported in Stats.
+const (
+	// BreakerClosed lets every command through.
+	BreakerClosed = "closed"
+	// BreakerOpen rejects every command with ErrCircuitOpen until the cooldown has elapsed.
+	BreakerOpen = "open"
+	// BreakerHalfOpen lets a single probe command through to decide whether to close or to open again.
+	BreakerHalfOpen = "half-open"
+)
+
+// CircuitBreaker stops sending commands to Redis after repeated failures, so that while Redis is timing out
+// or unreachable, caches fail fast and MakeRequest goes straight to the loader instead of adding
+// Redis latency to every request. It is a go-redis hook installed on a client and shared by every cache using it.
+//
+// Only connection errors and timeouts count as failures; replies such as redis.Nil or WRONGTYPE do not.
+type CircuitBreaker struct {
+	client    redis.UniversalClient
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	watching  bool
+	listeners []func(from, to string)
+	// fallbacks are the fallback caches of the caches using the breaker, cleared when it closes. They are weak
+	// pointers, so a cache that is no longer used is collected with its fallback cache.
+	fallbacks []weak.Pointer[fallbackCache]
+}
+
+// NewCircuitBreaker installs a CircuitBreaker on client that opens after threshold consecutive failures
+// and lets a probe command through once cooldown has elapsed. While it is open, the breaker also pings Redis
+// every cooldown, so it closes again even if the caches send no command.
+func NewCircuitBreaker(client redis.UniversalClient, threshold int, cooldown time.Duration) *CircuitBreaker {
+	b := &CircuitBreaker{
+		client:    client,
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+	client.AddHook(b)
+	return b
+}
+
+// WithCircuitBreaker puts the cache in degraded mode while breaker is open: GetOrLoad and MakeRequest bypass the cache
+// and serve users from the loader. The state of the breaker is also reported in the Stats of the cache.
+// The breaker must have been created with NewCircuitBreaker on the client of the cache.
+func WithCircuitBreaker(breaker *CircuitBreaker) Option {
+	return func(o *options) {
+		o.breaker = breaker
+	}
+}
+
+// State returns the current state of the breaker: BreakerClosed, BreakerOpen or BreakerHalfOpen.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// OnStateChange registers fn to be called on every transition of the breaker, e.g. with BreakerOpen and BreakerClosed
+// when Redis comes back. Listeners are called synchronously, in the order they were registered.
+func (b *CircuitBreaker) OnStateChange(fn func(from, to string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.listeners = append(b.listeners, fn)
+}
+
+// addFallback registers the fallback cache of a cache using the breaker, so it is cleared when the breaker closes and
+// no longer holds users that may have changed while Redis was unavailable. A fallback cache is registered once.
+func (b *CircuitBreaker) addFallback(f *fallbackCache) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, fallback := range b.liveFallbacks() {
+		if fallback == f {
+			return
+		}
+	}
+	b.fallbacks = append(b.fallbacks, weak.Make(f))
+}
+
+// liveFallbacks returns the registered fallback caches that were not collected, and forgets the others.
+// It must be called with the lock held.
+func (b *CircuitBreaker) liveFallbacks() []*fallbackCache {
+	var live []*fallbackCache
+	kept := b.fallbacks[:0]
+	for _, p := range b.fallbacks {
+		if f := p.Value(); f != nil {
+			live = append(live, f)
+			kept = append(kept, p)
+		}
+	}
+	clear(b.fallbacks[len(kept):])
+	b.fallbacks = kept
+	return live
+}
+
+// degraded reports whether the cache should bypass Redis because its circuit breaker is open.
+func (o options) degraded() bool {
+	return o.breaker != nil && o.breaker.State() == BreakerOpen
+}
+
+// setState moves the breaker to state. It must be called with the lock held,
+// and returns a function that notifies the listeners once the lock is released.
+func (b *CircuitBreaker) setState(state string) func() {
+	from := b.state
+	b.state = state
+	if from == state {
+		return func() {}
+	}
+
+	if state == BreakerOpen && !b.watching {
+		b.watching = true
+		go b.watch()
+	}
+	var fallbacks []*fallbackCache
+	if state == BreakerClosed {
+		fallbacks = b.liveFallbacks()
+	}
+	listeners := append([]func(from, to string){}, b.listeners...)
+	return func() {
+		for _, f := range fallbacks {
+			f.clear()
+		}
+		for _, fn := range listeners {
+			fn(from, state)
+		}
+	}
+}
+
+// watch pings Redis every cooldown until the breaker closes. Each ping goes through the breaker,
+// so it serves as the half-open probe when no other command is sent.
+func (b *CircuitBreaker) watch() {
+	for {
+		time.Sleep(b.cooldown)
+
+		b.mu.Lock()
+		if b.state == BreakerClosed {
+			b.watching = false
+			b.mu.Unlock()
+			return
+		}
+		b.mu.Unlock()
+
+		if err := b.client.Ping(context.Background()).Err(); err == redis.ErrClosed {
+			b.mu.Lock()
+			b.watching = false
+			b.mu.Unlock()
+			return
+		}
+	}
+}
+
+// allow reports whether a command may be sent. Once the cooldown of an open breaker has elapsed,
+// a single command is let through as a probe.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	notify := func() {}
+	defer func() {
+		b.mu.Unlock()
+		notify()
+	}()
+
+	switch b.state {
+	case BreakerClosed:
+		return nil
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		log.Println("Circuit breaker cooldown elapsed. Probing Redis.")
+		notify = b.setState(BreakerHalfOpen)
+		b.probing = true
+		return nil
+	default:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	}
+}
+
+// record updates the breaker with the outcome of a command.
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	notify := func() {}
+	defer func() {
+		b.mu.Unlock()
+		notify()
+	}()
+
+	// A command canceled by its caller says nothing about Redis.
+	if errors.Is(err, context.Canceled) {
+		b.probing = false
+		return
+	}
+	if !isConnectionError(err) {
+		if b.state != BreakerClosed {
+			log.Println("Redis is reachable again. Closing circuit breaker.")
+		}
+		notify = b.setState(BreakerClosed)
+		b.failures = 0
+		b.probing = false
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		if b.state != BreakerOpen {
+			log.Printf("Opening circuit breaker after %d consecutive failures: %v", b.failures, err)
+		}
+		notify = b.setState(BreakerOpen)
+		b.openedAt = time.Now()
+		b.probing = false
+	}
+}
+
+// isConnectionError reports whether err means Redis could not be reached or did not answer in time,
+// as opposed to a reply of the server.
+func isConnectionError(err error) bool {
+	if err == nil || err == redis.Nil || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	var reply redis.Error
+	return !errors.As(err, &reply)
+}
+
+// DialHook implements redis.Hook. Connections are dialed through the command hooks, so it adds nothing.
+func (b *CircuitBreaker) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook implements redis.Hook.
+func (b *CircuitBreaker) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if err := b.allow(); err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+		err := next(ctx, cmd)
+		b.record(err)
+		return err
+	}
+}
+
+// ProcessPipelineHook implements redis.Hook. A pipeline or transaction counts as a single command.
+func (b *CircuitBreaker) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if err := b.allow(); err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+		err := next(ctx, cmds)
+		b.record(err)
+		return err
+	}
+}
@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// countingLoader returns a testLoader counting its calls in loads.
+func countingLoader(loads *atomic.Int64) Loader {
+	return func(ctx context.Context, id string) (User, error) {
+		loads.Add(1)
+		return testLoader(ctx, id)
+	}
+}
+
+// waitForState waits for breaker to reach state, failing the test after a second.
+func waitForState(t *testing.T, breaker *CircuitBreaker, state string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); breaker.State() != state; {
+		if time.Now().After(deadline) {
+			t.Fatalf("breaker state: %s, want %s", breaker.State(), state)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCircuitBreakerIgnoresReplies(t *testing.T) {
+	client, _ := newTestClient(t)
+	breaker := NewCircuitBreaker(client, 2, time.Minute)
+	c := NewLRU(context.Background(), client, 10, "lru", WithCircuitBreaker(breaker))
+
+	for i := 0; i < 5; i++ {
+		if _, err := c.Get("1"); !errors.Is(err, redis.Nil) {
+			t.Fatalf("Get of a missing user: %v, want redis.Nil", err)
+		}
+	}
+	if state := breaker.State(); state != BreakerClosed {
+		t.Errorf("breaker state after misses: %s, want %s", state, BreakerClosed)
+	}
+}
+
+func TestCircuitBreakerServesTheFallbackCacheDuringAnOutage(t *testing.T) {
+	client, m := newTestClient(t)
+	breaker := NewCircuitBreaker(client, 2, 50*time.Millisecond)
+	var mu sync.Mutex
+	var transitions []string
+	breaker.OnStateChange(func(from, to string) {
+		mu.Lock()
+		defer mu.Unlock()
+		transitions = append(transitions, from+"->"+to)
+	})
+	var loads atomic.Int64
+	c := NewLRU(context.Background(), client, 10, "lru",
+		WithLoader(countingLoader(&loads)), WithCircuitBreaker(breaker), WithFallbackCache(10))
+
+	if _, err := c.GetOrLoad("1"); err != nil {
+		t.Fatalf("GetOrLoad: %v", err)
+	}
+
+	m.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get("1"); err == nil {
+			t.Fatal("Get succeeded while Redis is down")
+		}
+	}
+	if state := breaker.State(); state != BreakerOpen {
+		t.Fatalf("breaker state after the failures: %s, want %s", state, BreakerOpen)
+	}
+	if _, err := c.Get("1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Get while the breaker is open: %v, want ErrCircuitOpen", err)
+	}
+
+	// The first request of the outage goes to the loader, the next ones to the fallback cache.
+	loads.Store(0)
+	for i := 0; i < 3; i++ {
+		if user, err := c.GetOrLoad("2"); err != nil || user != testUser(2) {
+			t.Fatalf("GetOrLoad while the breaker is open: %+v, %v", user, err)
+		}
+	}
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loads during the outage: %d, want 1", n)
+	}
+
+	if err := m.Restart(); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	waitForState(t, breaker, BreakerClosed)
+	mu.Lock()
+	want := []string{BreakerClosed + "->" + BreakerOpen, BreakerOpen + "->" + BreakerHalfOpen, BreakerHalfOpen + "->" + BreakerClosed}
+	if !slices.Equal(transitions, want) {
+		t.Errorf("transitions: %v, want %v", transitions, want)
+	}
+	mu.Unlock()
+
+	// The fallback cache was discarded, so the user is loaded again once Redis is back.
+	if _, err := c.GetOrLoad("2"); err != nil {
+		t.Fatalf("GetOrLoad after the outage: %v", err)
+	}
+	if n := loads.Load(); n != 2 {
+		t.Errorf("loads after the outage: %d, want 2", n)
+	}
+	if !m.Exists(c.Key("2")) {
+		t.Error("the user loaded after the outage is not cached")
+	}
+}
+
+func TestFallbackCacheEvictsTheLeastRecentlyUsed(t *testing.T) {
+	var o options
+	WithFallbackCache(2)(&o)
+	f := o.fallback
+
+	f.add(testUser(1))
+	f.add(testUser(2))
+	f.get("1")
+	f.add(testUser(3))
+	for id, want := range map[string]bool{"1": true, "2": false, "3": true} {
+		if _, ok := f.get(id); ok != want {
+			t.Errorf("user %s in the fallback cache: %v, want %v", id, ok, want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package cache
+
+// CallOption changes how a single GetOrLoad call uses the cache.
+type CallOption func(*callOptions)
+
+// callOptions holds the settings of a single call.
+type callOptions struct {
+	skipCache    bool
+	forceRefresh bool
+}
+
+// newCallOptions applies opts on top of the defaults.
+func newCallOptions(opts []CallOption) callOptions {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// SkipCache makes GetOrLoad load the user with the loader without reading or writing the cache,
+// e.g. to debug what the source of truth returns.
+func SkipCache() CallOption {
+	return func(o *callOptions) {
+		o.skipCache = true
+	}
+}
+
+// ForceRefresh makes GetOrLoad ignore the cached user, load it with the loader and write the result to the cache,
+// e.g. behind an admin refresh button.
+func ForceRefresh() CallOption {
+	return func(o *callOptions) {
+		o.forceRefresh = true
+	}
+}
@@ -0,0 +1,241 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNotModified is returned by GetIfChanged when the cached entry is still at the version the caller already has.
+var ErrNotModified = errors.New("not modified")
+
+// ErrVersionMismatch is returned by CompareAndSet when the cached entry is missing
+// or its version differs from the expected one.
+var ErrVersionMismatch = errors.New("version mismatch")
+
+// compareAndSetScript atomically replaces a cached value if its current version matches the expected one.
+// Entries written without a version are considered to be at version 0.
+//
+// KEYS[1]: the value key
+// KEYS[2]: the version hash
+// KEYS[3]: the size hash
+// ARGV[1]: the expected version
+// ARGV[2]: the serialized value
+// ARGV[3]: the storage mode of the value, see writeValue
+// ARGV[4]: "1" to record the new size of the entry in the size hash, see trackBytes
+// ARGV[5]: how to measure the entry, see measureMemory and measureLength
+//
+// It returns the new version, or -1 if the entry is missing or its version does not match.
+var compareAndSetScript = scripts.register(bumpVersion + writeValue + trackBytes + `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+local current = tonumber(redis.call('HGET', KEYS[2], KEYS[1]) or '0')
+if current ~= tonumber(ARGV[1]) then
+	return -1
+end
+write_value(KEYS[1], ARGV[2], ARGV[3], true)
+if ARGV[4] == '1' then
+	account_bytes(KEYS[3], KEYS[1], ARGV[5])
+end
+return bump_version(KEYS[2], KEYS[1])
+`)
+
+// getIfChangedScript returns a cached value only if its version differs from the one the caller already has.
+// Entries written without a version are considered to be at version 0.
+//
+// KEYS[1]: the value key
+// KEYS[2]: the version hash
+// ARGV[1]: the version known by the caller
+// ARGV[2]: the storage mode of the value, see readValue
+//
+// It returns false if the value key does not exist, {version} if the version matches,
+// or {version, value} otherwise.
+var getIfChangedScript = scripts.register(readValue + `
+local value = read_value(KEYS[1], ARGV[2])
+if not value then
+	return false
+end
+local version = tonumber(redis.call('HGET', KEYS[2], KEYS[1]) or '0')
+if version == tonumber(ARGV[1]) then
+	return {version}
+end
+return {version, value}
+`)
+
+// Version returns the current version of a cached user. Every Set or CompareAndSet assigns a new, higher version.
+func (c *FIFOCache) Version(id string) (int64, error) {
+	return entryVersion(c.ctx, c.client, c.generateKey(versionKeyPrefix), c.generateKey(userPrefix, id))
+}
+
+// CompareAndSet replaces a cached user only if its version still equals expectedVersion,
+// so concurrent writers cannot silently overwrite each other. It returns the new version,
+// or ErrVersionMismatch if the entry changed or is no longer cached. The position in the queue is unchanged.
+func (c *FIFOCache) CompareAndSet(id string, expectedVersion int64, user User) (int64, error) {
+	return compareAndSet(c.ctx, c.client, c.options, c.generateKey, c.generateKey(userPrefix, id), expectedVersion, user)
+}
+
+// GetIfChanged retrieves a user only if its version differs from lastVersion, returning the user and its current version.
+// If the version matches, it returns ErrNotModified without transferring or decoding the value.
+func (c *FIFOCache) GetIfChanged(id string, lastVersion int64) (User, int64, error) {
+	return getIfChanged(c.ctx, c.client, c.options, c.generateKey(versionKeyPrefix), id, c.generateKey(userPrefix, id), lastVersion)
+}
+
+// Version returns the current version of a cached user. Every Set or CompareAndSet assigns a new, higher version.
+func (c *LRUCache) Version(id string) (int64, error) {
+	return entryVersion(c.ctx, c.client, c.generateKey(versionKeyPrefix), c.generateKey(userPrefix, id))
+}
+
+// CompareAndSet replaces a cached user only if its version still equals expectedVersion,
+// so concurrent writers cannot silently overwrite each other. It returns the new version,
+// or ErrVersionMismatch if the entry changed or is no longer cached. The recency is unchanged.
+func (c *LRUCache) CompareAndSet(id string, expectedVersion int64, user User) (int64, error) {
+	return compareAndSet(c.ctx, c.client, c.options, c.generateKey, c.generateKey(userPrefix, id), expectedVersion, user)
+}
+
+// GetIfChanged retrieves a user only if its version differs from lastVersion, returning the user and its current version.
+// If the version matches, it returns ErrNotModified without transferring or decoding the value.
+// Both outcomes count as an access and update the recency.
+func (c *LRUCache) GetIfChanged(id string, lastVersion int64) (User, int64, error) {
+	user, version, err := getIfChanged(c.ctx, c.client, c.options, c.generateKey(versionKeyPrefix), id, c.generateKey(userPrefix, id), lastVersion)
+	if err != nil && err != ErrNotModified {
+		return User{}, 0, err
+	}
+
+	if err := c.UpdateRecency(id); err != nil {
+		log.Printf("Failed to update recency for user ID: %s: %v", id, err)
+	}
+	return user, version, err
+}
+
+// Version returns the current version of a cached user. Every Set or CompareAndSet assigns a new, higher version.
+func (c *LFUCache) Version(id string) (int64, error) {
+	return entryVersion(c.ctx, c.client, c.generateKey(versionKeyPrefix), c.generateKey(userPrefix, id))
+}
+
+// CompareAndSet replaces a cached user only if its version still equals expectedVersion,
+// so concurrent writers cannot silently overwrite each other. It returns the new version,
+// or ErrVersionMismatch if the entry changed or is no longer cached. The frequency is unchanged.
+func (c *LFUCache) CompareAndSet(id string, expectedVersion int64, user User) (int64, error) {
+	return compareAndSet(c.ctx, c.client, c.options, c.generateKey, c.generateKey(userPrefix, id), expectedVersion, user)
+}
+
+// GetIfChanged retrieves a user only if its version differs from lastVersion, returning the user and its current version.
+// If the version matches, it returns ErrNotModified without transferring or decoding the value.
+// Both outcomes count as an access and update the frequency.
+func (c *LFUCache) GetIfChanged(id string, lastVersion int64) (User, int64, error) {
+	user, version, err := getIfChanged(c.ctx, c.client, c.options, c.generateKey(versionKeyPrefix), id, c.generateKey(userPrefix, id), lastVersion)
+	if err != nil && err != ErrNotModified {
+		return User{}, 0, err
+	}
+
+	if err := c.UpdateFrequency(id); err != nil {
+		log.Printf("Failed to update frequency for user ID: %s: %v", id, err)
+	}
+	return user, version, err
+}
+
+// Version returns the current version of a cached user. Every Set or CompareAndSet assigns a new, higher version.
+func (c *ApproxLRUCache) Version(id string) (int64, error) {
+	return entryVersion(c.ctx, c.client, c.generateKey(versionKeyPrefix), c.generateKey(userPrefix, id))
+}
+
+// CompareAndSet replaces a cached user only if its version still equals expectedVersion,
+// so concurrent writers cannot silently overwrite each other. It returns the new version,
+// or ErrVersionMismatch if the entry changed or is no longer cached. The access time is unchanged.
+func (c *ApproxLRUCache) CompareAndSet(id string, expectedVersion int64, user User) (int64, error) {
+	return compareAndSet(c.ctx, c.client, c.options, c.generateKey, c.generateKey(userPrefix, id), expectedVersion, user)
+}
+
+// GetIfChanged retrieves a user only if its version differs from lastVersion, returning the user and its current version.
+// If the version matches, it returns ErrNotModified without transferring or decoding the value.
+// Both outcomes count as an access and update the access time.
+func (c *ApproxLRUCache) GetIfChanged(id string, lastVersion int64) (User, int64, error) {
+	user, version, err := getIfChanged(c.ctx, c.client, c.options, c.generateKey(versionKeyPrefix), id, c.generateKey(userPrefix, id), lastVersion)
+	if err != nil && err != ErrNotModified {
+		return User{}, 0, err
+	}
+
+	if err := c.UpdateRecency(id); err != nil {
+		log.Printf("Failed to update recency for user ID: %s: %v", id, err)
+	}
+	return user, version, err
+}
+
+// entryVersion reads the version of a value key. It returns redis.Nil if the value key does not exist.
+func entryVersion(ctx context.Context, client Client, versionKey, cacheKey string) (int64, error) {
+	var exists *redis.IntCmd
+	var version *redis.StringCmd
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		exists = pipe.Exists(ctx, cacheKey)
+		version = pipe.HGet(ctx, versionKey, cacheKey)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return 0, err
+	}
+
+	if exists.Val() == 0 {
+		return 0, redis.Nil
+	}
+	if version.Err() == redis.Nil {
+		return 0, nil
+	}
+	return version.Int64()
+}
+
+// getIfChanged runs getIfChangedScript for the value key of a user and decodes the value if it changed.
+// It returns redis.Nil if the value key does not exist.
+func getIfChanged(ctx context.Context, client Client, o options, versionKey, id, cacheKey string, lastVersion int64) (User, int64, error) {
+	o.logf(LogRead, "Getting key: %s if changed since version: %d", cacheKey, lastVersion)
+	result, err := scripts.run(ctx, client, getIfChangedScript, []string{cacheKey, versionKey}, lastVersion, o.storageMode()).Slice()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Error getting key: %s if changed: %v", cacheKey, err)
+		}
+		return User{}, 0, err
+	}
+
+	version := result[0].(int64)
+	if len(result) == 1 {
+		o.logf(LogHit, "Key: %s not modified since version: %d", cacheKey, lastVersion)
+		return User{}, version, ErrNotModified
+	}
+
+	user, err := o.decodeUser([]byte(result[1].(string)), id)
+	if err != nil {
+		log.Printf("Error unmarshalling user data for cache key: %s: %v", cacheKey, err)
+		return User{}, 0, err
+	}
+
+	return user, version, nil
+}
+
+// compareAndSet runs compareAndSetScript for a value key and updates the secondary indexes of the new value.
+func compareAndSet(ctx context.Context, client Client, o options, generateKey func(...string) string, cacheKey string, expectedVersion int64, user User) (int64, error) {
+	o.logf(LogWrite, "Compare and set for key: %s with expected version: %d", cacheKey, expectedVersion)
+	b, err := o.encodeValue(&user)
+	if err != nil {
+		log.Printf("Error marshalling user data for ID: %s: %v", user.Id, err)
+		return 0, err
+	}
+
+	version, err := scripts.run(ctx, client, compareAndSetScript, []string{cacheKey, generateKey(versionKeyPrefix), generateKey(bytesKeyPrefix)}, expectedVersion, b, o.storageMode(), o.maxBytes > 0, o.measure).Int64()
+	if err != nil {
+		log.Printf("Error running compare and set for key: %s: %v", cacheKey, err)
+		return 0, err
+	}
+	if version < 0 {
+		log.Printf("Version mismatch for key: %s", cacheKey)
+		return 0, ErrVersionMismatch
+	}
+
+	o.logf(LogWrite, "Key: %s updated to version: %d", cacheKey, version)
+	o.publishInvalidation(ctx, cacheKey)
+	if err := o.enqueueWrite(ctx, cacheKey, user); err != nil {
+		return version, err
+	}
+	return version, o.trackEntry(ctx, client, generateKey, cacheKey, &user, SourceCompareAndSet)
+}
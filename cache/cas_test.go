@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// versionedCache is implemented by the caches versioning their entries.
+type versionedCache interface {
+	Set(user User) error
+	Get(id string) (User, error)
+	Delete(key string) error
+	Key(id string) string
+	Version(id string) (int64, error)
+	CompareAndSet(id string, expectedVersion int64, user User) (int64, error)
+	GetIfChanged(id string, lastVersion int64) (User, int64, error)
+}
+
+var versionedCaches = map[string]func(client Client) versionedCache{
+	"fifo": func(client Client) versionedCache {
+		c := NewFIFO(context.Background(), client, 10, "fifo")
+		return &c
+	},
+	"lru": func(client Client) versionedCache {
+		c := NewLRU(context.Background(), client, 10, "lru")
+		return &c
+	},
+	"lfu": func(client Client) versionedCache {
+		c := NewLFU(context.Background(), client, 10, "lfu")
+		return &c
+	},
+	"approx-lru": func(client Client) versionedCache {
+		c := NewApproxLRU(context.Background(), client, 10, 5, "approx")
+		return &c
+	},
+}
+
+func TestCompareAndSet(t *testing.T) {
+	for name, newCache := range versionedCaches {
+		t.Run(name, func(t *testing.T) {
+			client, _ := newTestClient(t)
+			c := newCache(client)
+
+			if _, err := c.CompareAndSet("1", 0, testUser(1)); !errors.Is(err, ErrVersionMismatch) {
+				t.Errorf("CompareAndSet of a missing user: %v, want ErrVersionMismatch", err)
+			}
+			if err := c.Set(testUser(1)); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			version, err := c.Version("1")
+			if err != nil {
+				t.Fatalf("Version: %v", err)
+			}
+
+			renamed := User{Id: "1", Name: "Renamed", Age: 30}
+			next, err := c.CompareAndSet("1", version, renamed)
+			if err != nil {
+				t.Fatalf("CompareAndSet at the current version: %v", err)
+			}
+			if next <= version {
+				t.Errorf("version after CompareAndSet: %d, want more than %d", next, version)
+			}
+			if got, err := c.Get("1"); err != nil || got != renamed {
+				t.Errorf("Get after CompareAndSet: %+v, %v, want %+v", got, err, renamed)
+			}
+
+			// A writer still holding the old version loses.
+			if _, err := c.CompareAndSet("1", version, testUser(1)); !errors.Is(err, ErrVersionMismatch) {
+				t.Errorf("CompareAndSet at a stale version: %v, want ErrVersionMismatch", err)
+			}
+			// So does one that read before a Set.
+			if err := c.Set(testUser(1)); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			if _, err := c.CompareAndSet("1", next, renamed); !errors.Is(err, ErrVersionMismatch) {
+				t.Errorf("CompareAndSet after a Set: %v, want ErrVersionMismatch", err)
+			}
+		})
+	}
+}
+
+func TestGetIfChanged(t *testing.T) {
+	for name, newCache := range versionedCaches {
+		t.Run(name, func(t *testing.T) {
+			client, _ := newTestClient(t)
+			c := newCache(client)
+
+			if _, _, err := c.GetIfChanged("1", 0); !errors.Is(err, redis.Nil) {
+				t.Errorf("GetIfChanged of a missing user: %v, want redis.Nil", err)
+			}
+			if err := c.Set(testUser(1)); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+
+			user, version, err := c.GetIfChanged("1", 0)
+			if err != nil {
+				t.Fatalf("GetIfChanged: %v", err)
+			}
+			if user != testUser(1) {
+				t.Errorf("GetIfChanged: %+v, want %+v", user, testUser(1))
+			}
+			if _, v, err := c.GetIfChanged("1", version); !errors.Is(err, ErrNotModified) || v != version {
+				t.Errorf("GetIfChanged at the current version: %d, %v, want %d, ErrNotModified", v, err, version)
+			}
+
+			if err := c.Set(User{Id: "1", Name: "Renamed"}); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			if user, v, err := c.GetIfChanged("1", version); err != nil || user.Name != "Renamed" || v <= version {
+				t.Errorf("GetIfChanged after a Set: %+v, %d, %v", user, v, err)
+			}
+		})
+	}
+}
+
+func TestVersionOfAMissingUser(t *testing.T) {
+	for name, newCache := range versionedCaches {
+		t.Run(name, func(t *testing.T) {
+			client, _ := newTestClient(t)
+			c := newCache(client)
+
+			if _, err := c.Version("1"); !errors.Is(err, redis.Nil) {
+				t.Errorf("Version of a missing user: %v, want redis.Nil", err)
+			}
+			if err := c.Set(testUser(1)); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			if err := c.Delete(c.Key("1")); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if _, err := c.Version("1"); !errors.Is(err, redis.Nil) {
+				t.Errorf("Version of a deleted user: %v, want redis.Nil", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Client is the part of a Redis client the caches use: the commands of redis.Cmdable, including pipelines and
+// transactions, Do for the commands go-redis has no method for, and Subscribe for load leases. Every
+// redis.UniversalClient implements it, so a *redis.Client, a failover client, a *redis.ClusterClient or
+// a *redis.Ring can be passed to the constructors, as can a client of an in-memory server such as
+// miniredis in tests, or a mock embedding a redis.Cmdable and overriding the commands it fakes.
+//
+// Some features need more than Client: Verify, Repair and WatchExpirations run on the node holding the keys,
+// which only a *redis.Client, a *redis.ClusterClient or a *redis.Ring can name, and NewCircuitBreaker
+// installs a hook on a redis.UniversalClient.
+type Client interface {
+	redis.Cmdable
+	Do(ctx context.Context, args ...interface{}) *redis.Cmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestMain(m *testing.M) {
+	// Silence the per-operation logs of the caches.
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestClient returns a client of a miniredis server stopped at the end of the test.
+func newTestClient(t testing.TB) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+	m := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: m.Addr(), Protocol: 2})
+	t.Cleanup(func() { client.Close() })
+	return client, m
+}
+
+// testUser returns the user with id i.
+func testUser(i int) User {
+	return User{Id: strconv.Itoa(i), Name: "User " + strconv.Itoa(i), Age: 20 + i}
+}
+
+// testLoader loads the users of testUser, and fails for the ids that are not numbers.
+func testLoader(ctx context.Context, id string) (User, error) {
+	i, err := strconv.Atoi(id)
+	if err != nil {
+		return User{}, err
+	}
+	return testUser(i), nil
+}
+
+// testClock returns a manual clock, so the recency of the entries does not depend on the speed of the test.
+func testClock() *ManualClock {
+	return NewManualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// setUsers adds the users with the given ids to a cache, advancing clock between them if it is not nil.
+func setUsers(t *testing.T, set func(User) error, clock *ManualClock, ids ...int) {
+	t.Helper()
+	for _, id := range ids {
+		if clock != nil {
+			clock.Advance(time.Millisecond)
+		}
+		if err := set(testUser(id)); err != nil {
+			t.Fatalf("Set(%d): %v", id, err)
+		}
+	}
+}
+
+// assertCached fails the test unless the users with the given ids are the only ones cached among the ids 1 to 10.
+func assertCached(t *testing.T, client *redis.Client, key func(id string) string, ids ...int) {
+	t.Helper()
+	want := make(map[int]bool)
+	for _, id := range ids {
+		want[id] = true
+	}
+	for i := 1; i <= 10; i++ {
+		n, err := client.Exists(context.Background(), key(strconv.Itoa(i))).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cached := n == 1; cached != want[i] {
+			t.Errorf("user %d cached: %v, want %v", i, cached, want[i])
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time to a cache. It dates the recency scores of the LRU caches, the access and admission
+// times of entries and the soft expirations of the TTL cache. Latencies, hit ratio windows, timeouts and the
+// circuit breaker always use the system clock, and so does Redis for the expiration of keys.
+type Clock interface {
+	Now() time.Time
+}
+
+// WithClock makes the cache tell the time with clock instead of the system clock,
+// so tests and simulations can control recency and expiry deterministically.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.clock = clock
+	}
+}
+
+// now returns the current time of the clock of the cache.
+func (o options) now() time.Time {
+	if o.clock == nil {
+		return time.Now()
+	}
+	return o.clock.Now()
+}
+
+// recencyScore returns the score of an access at the current time in the sorted set of the LRU caches,
+// in microseconds since the epoch so that accesses within the same second are ordered.
+// Microseconds are exactly representable by the float64 scores of Redis until the year 2255.
+func (o options) recencyScore() int64 {
+	return o.now().UnixMicro()
+}
+
+// ManualClock is a Clock that only moves when told to. It is safe for concurrent use.
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManualClock returns a ManualClock set to now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set sets the clock to now.
+func (c *ManualClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// clientOptions applies opts for a cache using client. Clients sharding the keys across nodes, Cluster and Ring
+// clients, imply WithHashTag, so that every key of a cache lands on the same node and the multi-key scripts
+// and transactions of the cache keep working.
+func clientOptions(client Client, opts []Option) options {
+	o := newOptions(opts)
+	if sharded(client) {
+		o.hashTag = true
+	}
+	return o
+}
+
+// sharded reports whether client spreads the keys across several nodes.
+func sharded(client Client) bool {
+	switch client.(type) {
+	case *redis.ClusterClient, *redis.Ring:
+		return true
+	}
+	return false
+}
+
+// nodeForKey returns the client of the node holding key, for the commands that only see the keys or the events
+// of the node they run on, such as SCAN and keyspace notifications. It is client itself for a single node.
+func nodeForKey(ctx context.Context, client Client, key string) (*redis.Client, error) {
+	switch c := client.(type) {
+	case *redis.Client:
+		return c, nil
+	case *redis.ClusterClient:
+		return c.MasterForKey(ctx, key)
+	case *redis.Ring:
+		return c.GetShardClientForKey(key)
+	default:
+		return nil, fmt.Errorf("unsupported Redis client: %T", client)
+	}
+}
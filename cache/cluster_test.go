@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// keySlot returns the Redis Cluster slot of key, as CLUSTER KEYSLOT does: the CRC16 of its hash tag, the part between
+// the first { and the next }, if that part is not empty, or of the whole key otherwise, modulo 16384.
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % 16384)
+}
+
+// crc16 is the CRC16-CCITT (XModem) checksum Redis Cluster hashes keys with.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func TestKeySlot(t *testing.T) {
+	// 0x31c3 is the check value of CRC16-XModem, and the slots of foo and bar are the ones of CLUSTER KEYSLOT.
+	for key, want := range map[string]int{"123456789": 0x31c3, "foo": 12182, "bar": 5061} {
+		if slot := keySlot(key); slot != want {
+			t.Errorf("keySlot(%q) = %d, want %d", key, slot, want)
+		}
+	}
+	for key, hashed := range map[string]string{"{bar}foo": "bar", "foo{bar}{baz}": "bar", "foo{}{bar}": "foo{}{bar}", "{}foo": "{}foo"} {
+		if keySlot(key) != int(crc16(hashed)%16384) {
+			t.Errorf("keySlot(%q) does not hash %q", key, hashed)
+		}
+	}
+}
+
+// clusterTestCache is the part of the cache types TestHashTagKeysShareSlot exercises.
+type clusterTestCache interface {
+	Get(id string) (User, error)
+	GetOrLoad(id string, opts ...CallOption) (User, error)
+	SetTagged(user User, tags ...string) error
+	InvalidateTag(tag string) (int, error)
+	Delete(key string) error
+	Key(id string) string
+}
+
+// Every key a cache creates with WithHashTag must map to the same slot, so its scripts and transactions run on a
+// single node of a Redis Cluster.
+func TestHashTagKeysShareSlot(t *testing.T) {
+	ctx := context.Background()
+	opts := []Option{
+		WithHashTag(), WithLoader(testLoader), WithLoadLease(time.Second), WithTags(), WithIndex("name"),
+		WithEntryInfo(), WithEventLog(100), WithEvictionAudit(100), WithTopK(3), WithMaxBytes(1 << 20),
+	}
+	for name, newCache := range map[string]func(client Client) clusterTestCache{
+		"lru": func(client Client) clusterTestCache {
+			c := NewLRU(ctx, client, 3, "lru", opts...)
+			return &c
+		},
+		"lfu": func(client Client) clusterTestCache {
+			c := NewLFU(ctx, client, 3, "lfu", opts...)
+			return &c
+		},
+		"fifo": func(client Client) clusterTestCache {
+			c := NewFIFO(ctx, client, 3, "fifo", opts...)
+			return &c
+		},
+		"approx-lru": func(client Client) clusterTestCache {
+			c := NewApproxLRU(ctx, client, 3, 3, "approx_lru", opts...)
+			return &c
+		},
+		"ttl": func(client Client) clusterTestCache {
+			c := NewTTL(ctx, client, time.Minute, "ttl", opts...)
+			return &c
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client, m := newTestClient(t)
+			c := newCache(client)
+
+			for _, id := range []string{"1", "2", "1", "3", "4"} {
+				if _, err := c.GetOrLoad(id); err != nil {
+					t.Fatalf("GetOrLoad(%s): %v", id, err)
+				}
+			}
+			if err := c.SetTagged(testUser(5), "tenant:acme"); err != nil {
+				t.Fatalf("SetTagged: %v", err)
+			}
+			if _, err := c.InvalidateTag("tenant:acme"); err != nil {
+				t.Fatalf("InvalidateTag: %v", err)
+			}
+			if err := c.Delete(c.Key("4")); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			c.Get("6")
+
+			want := keySlot(c.Key("1"))
+			keys := m.Keys()
+			if len(keys) < 3 {
+				t.Fatalf("only %d keys created: %v", len(keys), keys)
+			}
+			for _, key := range keys {
+				if slot := keySlot(key); slot != want {
+					t.Errorf("key %s is in slot %d, want %d", key, slot, want)
+				}
+			}
+		})
+	}
+}
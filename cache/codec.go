@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec serializes the values stored in the cache.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes values with encoding/json. It is the default codec.
+type JSONCodec struct{}
+
+// Marshal encodes v as JSON.
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON data into v.
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// jsoniterAPI is configured to produce the same output as encoding/json.
+var jsoniterAPI = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// JSONIterCodec encodes values as JSON with json-iterator, which is considerably faster than encoding/json.
+// Its output is compatible with JSONCodec, so a cache can switch between the two without invalidating entries.
+type JSONIterCodec struct{}
+
+// Marshal encodes v as JSON.
+func (JSONIterCodec) Marshal(v interface{}) ([]byte, error) {
+	return jsoniterAPI.Marshal(v)
+}
+
+// Unmarshal decodes JSON data into v.
+func (JSONIterCodec) Unmarshal(data []byte, v interface{}) error {
+	return jsoniterAPI.Unmarshal(data, v)
+}
+
+// MsgpackCodec encodes values with MessagePack, which is more compact and faster to decode than JSON.
+// Struct fields are named by their json tags, so values look the same as with JSONCodec.
+type MsgpackCodec struct{}
+
+// Marshal encodes v as MessagePack.
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes MessagePack data into v.
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// GobCodec encodes values with encoding/gob. It is only readable by Go programs, and much slower than JSONCodec for
+// small values, since every payload carries the description of its type.
+type GobCodec struct{}
+
+// Marshal encodes v as gob.
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes gob data into v.
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+// testCodecs lists the codecs, JSONCodec first as the baseline of the benchmarks.
+var testCodecs = []struct {
+	name  string
+	codec Codec
+}{
+	{"json", JSONCodec{}},
+	{"jsoniter", JSONIterCodec{}},
+	{"msgpack", MsgpackCodec{}},
+	{"gob", GobCodec{}},
+}
+
+// benchmarkUsers are the users the codecs are benchmarked with, a typical one and one with a large name.
+var benchmarkUsers = []struct {
+	name string
+	user User
+}{
+	{"small", User{Id: "42", Name: strings.Repeat("n", 32), Age: 42}},
+	{"large", User{Id: "42", Name: strings.Repeat("n", 4096), Age: 42}},
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	for _, c := range testCodecs {
+		t.Run(c.name, func(t *testing.T) {
+			want := testUser(1)
+			data, err := c.codec.Marshal(&want)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var got User
+			if err := c.codec.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if got != want {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestJSONIterCodecIsCompatibleWithJSONCodec(t *testing.T) {
+	user := testUser(1)
+	want, err := JSONCodec{}.Marshal(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := JSONIterCodec{}.Marshal(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("JSONIterCodec wrote %s, JSONCodec %s", got, want)
+	}
+}
+
+// BenchmarkCodecMarshal measures the encoding of a user by every codec, the work of every Set.
+func BenchmarkCodecMarshal(b *testing.B) {
+	for _, u := range benchmarkUsers {
+		for _, c := range testCodecs {
+			b.Run(u.name+"/"+c.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for n := 0; n < b.N; n++ {
+					if _, err := c.codec.Marshal(&u.user); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkCodecUnmarshal measures the decoding of a user by every codec, the work of every hit.
+func BenchmarkCodecUnmarshal(b *testing.B) {
+	for _, u := range benchmarkUsers {
+		for _, c := range testCodecs {
+			data, err := c.codec.Marshal(&u.user)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Run(u.name+"/"+c.name, func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(len(data)))
+				for n := 0; n < b.N; n++ {
+					var decoded User
+					if err := c.codec.Unmarshal(data, &decoded); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
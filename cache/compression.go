@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is a compression algorithm for cached values.
+type Compression byte
+
+const (
+	// Gzip compresses values with compress/gzip.
+	Gzip Compression = iota + 1
+	// Zstd compresses values with Zstandard, which is faster than gzip at a similar ratio.
+	Zstd
+)
+
+// compressionMagic starts every compressed payload. It is followed by one byte naming the Compression.
+// None of the codecs produce payloads starting with a zero byte for the values the caches store,
+// so compressed and uncompressed entries can coexist and are told apart on read.
+var compressionMagic = []byte{0x00, 0x1f}
+
+// compressingCodec wraps a Codec and compresses payloads larger than a threshold.
+type compressingCodec struct {
+	codec       Codec
+	compression Compression
+	threshold   int
+}
+
+// NewCompressingCodec returns a Codec that serializes values with codec and compresses
+// the payloads of at least threshold bytes with the given algorithm. Payloads are prefixed
+// with a small header, so values written with or without compression can always be read back.
+func NewCompressingCodec(codec Codec, compression Compression, threshold int) Codec {
+	return compressingCodec{
+		codec:       codec,
+		compression: compression,
+		threshold:   threshold,
+	}
+}
+
+// Marshal serializes v and compresses the result if it reaches the threshold.
+func (c compressingCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil || len(data) < c.threshold {
+		return data, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(compressionMagic)
+	buf.WriteByte(byte(c.compression))
+
+	var w io.WriteCloser
+	switch c.compression {
+	case Gzip:
+		w = gzip.NewWriter(&buf)
+	case Zstd:
+		w, err = zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown compression: %d", c.compression)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decompresses data if it carries the compression header, whatever algorithm was used, and decodes it into v.
+func (c compressingCodec) Unmarshal(data []byte, v interface{}) error {
+	if !bytes.HasPrefix(data, compressionMagic) || len(data) <= len(compressionMagic) {
+		return c.codec.Unmarshal(data, v)
+	}
+
+	compression := Compression(data[len(compressionMagic)])
+	payload := bytes.NewReader(data[len(compressionMagic)+1:])
+
+	var r io.Reader
+	switch compression {
+	case Gzip:
+		gr, err := gzip.NewReader(payload)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	case Zstd:
+		zr, err := zstd.NewReader(payload)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return fmt.Errorf("unknown compression: %d", compression)
+	}
+
+	decompressed, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(decompressed, v)
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCompressionRoundTrip(t *testing.T) {
+	for _, compression := range []Compression{Gzip, Zstd} {
+		client, m := newTestClient(t)
+		c := NewLRU(context.Background(), client, 10, "lru", WithCompression(compression, 64))
+
+		large := User{Id: "1", Name: strings.Repeat("n", 1000), Age: 30}
+		small := testUser(2)
+		if err := c.Set(large); err != nil {
+			t.Fatalf("Set(large): %v", err)
+		}
+		if err := c.Set(small); err != nil {
+			t.Fatalf("Set(small): %v", err)
+		}
+
+		stored, _ := m.Get(c.Key("1"))
+		if header := append(append([]byte{}, compressionMagic...), byte(compression)); !bytes.HasPrefix([]byte(stored), header) {
+			t.Errorf("compression %d: the large value is not compressed: %q", compression, stored[:10])
+		}
+		if len(stored) >= len(large.Name) {
+			t.Errorf("compression %d: the large value takes %d bytes", compression, len(stored))
+		}
+		if stored, _ := m.Get(c.Key("2")); !strings.HasPrefix(stored, "{") {
+			t.Errorf("compression %d: the small value is compressed: %q", compression, stored)
+		}
+
+		for _, want := range []User{large, small} {
+			if got, err := c.Get(want.Id); err != nil || got != want {
+				t.Errorf("compression %d: Get(%s): %v", compression, want.Id, err)
+			}
+		}
+	}
+}
+
+func TestCompressionReadsEntriesWrittenWithout(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+	plain := NewLRU(ctx, client, 10, "lru")
+	compressed := NewLRU(ctx, client, 10, "lru", WithCompression(Zstd, 1))
+
+	setUsers(t, plain.Set, nil, 1)
+	if got, err := compressed.Get("1"); err != nil || got != testUser(1) {
+		t.Errorf("Get of an uncompressed entry: %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,311 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Report describes the inconsistencies found between a cache's index and its value keys.
+type Report struct {
+	// Orphaned lists value keys that exist in Redis but are not tracked by the index.
+	Orphaned []string `json:"orphaned"`
+	// Dangling lists index members whose value key no longer exists.
+	Dangling []string `json:"dangling"`
+	// Duplicates lists index members that appear more than once. Only list based indexes can contain duplicates.
+	Duplicates []string `json:"duplicates"`
+}
+
+// Consistent reports whether no inconsistencies were found.
+func (r Report) Consistent() bool {
+	return len(r.Orphaned) == 0 && len(r.Dangling) == 0 && len(r.Duplicates) == 0
+}
+
+// Verify scans the cache namespace and reports inconsistencies between the list and the value keys.
+func (c *FIFOCache) Verify() (Report, error) {
+	members, err := c.client.LRange(c.ctx, c.generateKey(cacheKeyPrefix), 0, -1).Result()
+	if err != nil {
+		return Report{}, err
+	}
+	return verifyIndex(c.ctx, c.client, c.generateKey(userPrefix, "*"), members)
+}
+
+// Repair fixes the inconsistencies reported by Verify. Orphaned value keys are deleted,
+// dangling list entries are removed and duplicate entries are collapsed into their oldest occurrence.
+// The membership set is then rebuilt from the list.
+func (c *FIFOCache) Repair() (Report, error) {
+	report, err := c.Verify()
+	if err != nil {
+		return report, err
+	}
+	if report.Consistent() {
+		return report, c.rebuildMembers()
+	}
+
+	listKey := c.generateKey(cacheKeyPrefix)
+	log.Printf("Repairing list: %s", listKey)
+	_, err = c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range report.Orphaned {
+			pipe.Del(c.ctx, key)
+		}
+		for _, key := range report.Dangling {
+			pipe.LRem(c.ctx, listKey, 0, key)
+		}
+		if len(report.Dangling) > 0 {
+			pipe.HDel(c.ctx, c.generateKey(versionKeyPrefix), report.Dangling...)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	if err := c.releaseBytes(c.ctx, c.client, c.generateKey(bytesKeyPrefix), append(report.Orphaned, report.Dangling...)...); err != nil {
+		return report, err
+	}
+
+	for _, key := range report.Duplicates {
+		occurrences, err := c.client.LPosCount(c.ctx, listKey, key, 0, redis.LPosArgs{}).Result()
+		if err != nil {
+			return report, err
+		}
+		// A negative count makes LREM start from the tail, so the oldest occurrence is kept.
+		if len(occurrences) > 1 {
+			if err := c.client.LRem(c.ctx, listKey, -int64(len(occurrences)-1), key).Err(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	return report, c.rebuildMembers()
+}
+
+// rebuildMembers recreates the membership set from the current contents of the list.
+func (c *FIFOCache) rebuildMembers() error {
+	listKey := c.generateKey(cacheKeyPrefix)
+	memberKey := c.generateKey(memberKeyPrefix)
+	log.Printf("Rebuilding membership set: %s from list: %s", memberKey, listKey)
+
+	members, err := c.client.LRange(c.ctx, listKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(c.ctx, memberKey)
+		if len(members) > 0 {
+			values := make([]interface{}, len(members))
+			for i, member := range members {
+				values[i] = member
+			}
+			pipe.SAdd(c.ctx, memberKey, values...)
+		}
+		return nil
+	})
+	return err
+}
+
+// Verify scans the cache namespace and reports inconsistencies between the sorted set and the value keys.
+func (c *LRUCache) Verify() (Report, error) {
+	members, err := c.client.ZRange(c.ctx, c.generateKey(cacheKeyPrefix), 0, -1).Result()
+	if err != nil {
+		return Report{}, err
+	}
+	return verifyIndex(c.ctx, c.client, c.generateKey(userPrefix, "*"), members)
+}
+
+// Repair fixes the inconsistencies reported by Verify.
+// Orphaned value keys are deleted and dangling sorted set members are removed, along with their recorded sizes.
+func (c *LRUCache) Repair() (Report, error) {
+	report, err := c.Verify()
+	if err != nil || report.Consistent() {
+		return report, err
+	}
+	if err := repairSortedSet(c.ctx, c.client, c.generateKey(cacheKeyPrefix), c.generateKey(versionKeyPrefix), report); err != nil {
+		return report, err
+	}
+	return report, c.releaseBytes(c.ctx, c.client, c.generateKey(bytesKeyPrefix), append(report.Orphaned, report.Dangling...)...)
+}
+
+// Verify scans the cache namespace and reports inconsistencies between the sorted set and the value keys.
+func (c *LFUCache) Verify() (Report, error) {
+	members, err := c.client.ZRange(c.ctx, c.generateKey(cacheKeyPrefix), 0, -1).Result()
+	if err != nil {
+		return Report{}, err
+	}
+	return verifyIndex(c.ctx, c.client, c.generateKey(userPrefix, "*"), members)
+}
+
+// Repair fixes the inconsistencies reported by Verify.
+// Orphaned value keys are deleted and dangling sorted set members are removed, along with their recorded sizes.
+func (c *LFUCache) Repair() (Report, error) {
+	report, err := c.Verify()
+	if err != nil || report.Consistent() {
+		return report, err
+	}
+	if err := repairSortedSet(c.ctx, c.client, c.generateKey(cacheKeyPrefix), c.generateKey(versionKeyPrefix), report); err != nil {
+		return report, err
+	}
+	return report, c.releaseBytes(c.ctx, c.client, c.generateKey(bytesKeyPrefix), append(report.Orphaned, report.Dangling...)...)
+}
+
+// Verify scans the cache namespace and reports inconsistencies between the access time hash and the value keys.
+func (c *ApproxLRUCache) Verify() (Report, error) {
+	members, err := c.client.HKeys(c.ctx, c.generateKey(cacheKeyPrefix)).Result()
+	if err != nil {
+		return Report{}, err
+	}
+	return verifyIndex(c.ctx, c.client, c.generateKey(userPrefix, "*"), members)
+}
+
+// Repair fixes the inconsistencies reported by Verify.
+// Orphaned value keys are deleted and dangling hash fields are removed, along with their recorded sizes.
+func (c *ApproxLRUCache) Repair() (Report, error) {
+	report, err := c.Verify()
+	if err != nil || report.Consistent() {
+		return report, err
+	}
+
+	hashKey := c.generateKey(cacheKeyPrefix)
+	log.Printf("Repairing hash: %s", hashKey)
+	_, err = c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range report.Orphaned {
+			pipe.Del(c.ctx, key)
+		}
+		if len(report.Dangling) > 0 {
+			pipe.HDel(c.ctx, hashKey, report.Dangling...)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	return report, c.releaseBytes(c.ctx, c.client, c.generateKey(bytesKeyPrefix), append(report.Orphaned, report.Dangling...)...)
+}
+
+// verifyIndex compares the members of an index with the value keys matching pattern.
+func verifyIndex(ctx context.Context, client Client, pattern string, members []string) (Report, error) {
+	log.Printf("Verifying %d index members against keys matching: %s", len(members), pattern)
+	var report Report
+
+	indexed := make(map[string]int, len(members))
+	for _, member := range members {
+		indexed[member]++
+		if indexed[member] == 2 {
+			report.Duplicates = append(report.Duplicates, member)
+		}
+	}
+
+	// SCAN only sees the keys of one node, the one holding the keys of the cache.
+	node, err := nodeForKey(ctx, client, pattern)
+	if err != nil {
+		return Report{}, err
+	}
+	values := make(map[string]bool)
+	iter := node.Scan(ctx, 0, pattern, 0).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		values[key] = true
+		if indexed[key] == 0 {
+			report.Orphaned = append(report.Orphaned, key)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return Report{}, err
+	}
+
+	for member, count := range indexed {
+		if count > 0 && !values[member] {
+			report.Dangling = append(report.Dangling, member)
+		}
+	}
+
+	log.Printf("Found %d orphaned, %d dangling and %d duplicate keys", len(report.Orphaned), len(report.Dangling), len(report.Duplicates))
+	return report, nil
+}
+
+// repairSortedSet deletes orphaned value keys and removes dangling members from a sorted set index and its version hash.
+func repairSortedSet(ctx context.Context, client Client, indexKey, versionKey string, report Report) error {
+	log.Printf("Repairing sorted set: %s", indexKey)
+	_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range report.Orphaned {
+			pipe.Del(ctx, key)
+		}
+		if len(report.Dangling) > 0 {
+			members := make([]interface{}, len(report.Dangling))
+			for i, key := range report.Dangling {
+				members[i] = key
+			}
+			pipe.ZRem(ctx, indexKey, members...)
+			pipe.HDel(ctx, versionKey, report.Dangling...)
+		}
+		return nil
+	})
+	return err
+}
+
+// IndexType returns the Redis type of the index of the cache with the given key prefix:
+// "list" for FIFO, "zset" for LRU and LFU, "hash" for the approximated LRU, or "none" if the cache is empty.
+func IndexType(ctx context.Context, client Client, keyPrefix string, opts ...Option) (string, error) {
+	o := clientOptions(client, opts)
+	return client.Type(ctx, o.namespace(keyPrefix)+":"+cacheKeyPrefix).Result()
+}
+
+// IndexEntry is a member of the index of a cache with its score, as returned by IndexEntries.
+type IndexEntry struct {
+	Key   string  `json:"key"`
+	Score float64 `json:"score"`
+}
+
+// IndexEntries returns the members of the index of the cache with the given key prefix in eviction order,
+// the next victim first. Scores are the positions in the queue for FIFO, the access times in microseconds
+// for LRU, the access counts for LFU, and the access times in nanoseconds for the approximated LRU,
+// whose victims are sampled, so the order is the one of exact LRU. It returns no entries for an empty
+// cache or a TTL cache, which has no index.
+func IndexEntries(ctx context.Context, client Client, keyPrefix string, opts ...Option) ([]IndexEntry, error) {
+	return indexEntries(ctx, client, clientOptions(client, opts).namespace(keyPrefix)+":"+cacheKeyPrefix)
+}
+
+// indexEntries returns the members of the index stored at indexKey in eviction order.
+func indexEntries(ctx context.Context, client Client, indexKey string) ([]IndexEntry, error) {
+	indexType, err := client.Type(ctx, indexKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []IndexEntry
+	switch indexType {
+	case "list":
+		members, err := client.LRange(ctx, indexKey, 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, member := range members {
+			entries = append(entries, IndexEntry{Key: member, Score: float64(i)})
+		}
+	case "zset":
+		members, err := client.ZRangeWithScores(ctx, indexKey, 0, -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			entries = append(entries, IndexEntry{Key: member.Member.(string), Score: member.Score})
+		}
+	case "hash":
+		fields, err := client.HGetAll(ctx, indexKey).Result()
+		if err != nil {
+			return nil, err
+		}
+		for field, value := range fields {
+			entries = append(entries, IndexEntry{Key: field, Score: parseScore(value)})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Score != entries[j].Score {
+				return entries[i].Score < entries[j].Score
+			}
+			return entries[i].Key < entries[j].Key
+		})
+	}
+	return entries, nil
+}
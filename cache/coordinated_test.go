@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// TestCoordinatedEvictionBoundsTheSize hammers one approximated LRU cache from several clients, each with several
+// goroutines, as if they were separate application instances, and checks that the cache never exceeds its capacity,
+// as cmd/stress does against a real Redis.
+func TestCoordinatedEvictionBoundsTheSize(t *testing.T) {
+	const (
+		capacity   = 20
+		bound      = 0 // coordinated eviction admits and evicts atomically
+		clients    = 4
+		goroutines = 4
+		requests   = 100
+		keys       = 200
+	)
+	ctx := context.Background()
+	m := miniredis.RunT(t)
+
+	caches := make([]ApproxLRUCache, clients)
+	for i := range caches {
+		client := redis.NewClient(&redis.Options{Addr: m.Addr(), Protocol: 2})
+		t.Cleanup(func() { client.Close() })
+		caches[i] = NewApproxLRU(ctx, client, capacity, 5, "stress", WithCoordinatedEviction())
+	}
+
+	var maxSize atomic.Int64
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if size := int64(caches[0].CacheSize()); size > maxSize.Load() {
+				maxSize.Store(size)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, clients*goroutines)
+	for i := range caches {
+		for g := 0; g < goroutines; g++ {
+			wg.Add(1)
+			go func(c *ApproxLRUCache, seed int64) {
+				defer wg.Done()
+				rng := rand.New(rand.NewSource(seed))
+				for n := 0; n < requests; n++ {
+					if err := c.Set(testUser(rng.Intn(keys))); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}(&caches[i], int64(i*goroutines+g))
+		}
+	}
+	wg.Wait()
+	close(done)
+	<-sampled
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if size := int64(caches[0].CacheSize()); size > maxSize.Load() {
+		maxSize.Store(size)
+	}
+	if max := maxSize.Load(); max > capacity+bound {
+		t.Errorf("the cache held %d users, over its capacity of %d plus %d", max, capacity, bound)
+	}
+	if size := caches[0].CacheSize(); size != capacity {
+		t.Errorf("final size: %d, want %d", size, capacity)
+	}
+	report, err := caches[0].Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !report.Consistent() {
+		t.Errorf("inconsistent index: %+v", report)
+	}
+}
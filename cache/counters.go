@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// counters accumulates the operation statistics of a cache in process. They are reported by Stats
+// and shared by every copy of the cache, but not across application instances.
+type counters struct {
+	hits       atomic.Int64
+	misses     atomic.Int64
+	sets       atomic.Int64
+	evictions  atomic.Int64
+	loads      atomic.Int64
+	loadErrors atomic.Int64
+	loadTime   atomic.Int64
+	residents  atomic.Int64
+	residency  atomic.Int64
+	latencies  [numOps]histogram
+	window     hitWindow
+	since      atomic.Int64
+}
+
+// newCounters returns counters starting now.
+func newCounters() *counters {
+	c := &counters{}
+	c.since.Store(time.Now().UnixNano())
+	return c
+}
+
+// recordLoad records a call of the loader that took d and failed with err, if not nil.
+func (c *counters) recordLoad(d time.Duration, err error) {
+	c.latencies[opLoad].observe(d)
+	c.loads.Add(1)
+	c.loadTime.Add(int64(d))
+	if err != nil {
+		c.loadErrors.Add(1)
+	}
+}
+
+// recordResidency records an eviction of an entry that was cached for d.
+func (c *counters) recordResidency(d time.Duration) {
+	c.residents.Add(1)
+	c.residency.Add(int64(d))
+}
+
+// fill copies the counters into s.
+func (c *counters) fill(s *Stats) {
+	s.Hits = c.hits.Load()
+	s.Misses = c.misses.Load()
+	s.Sets = c.sets.Load()
+	s.Evictions = c.evictions.Load()
+	s.LoadErrors = c.loadErrors.Load()
+	if loads := c.loads.Load(); loads > 0 {
+		s.AvgLoadTime = time.Duration(c.loadTime.Load() / loads)
+	}
+	if residents := c.residents.Load(); residents > 0 {
+		s.AvgResidency = time.Duration(c.residency.Load() / residents)
+	}
+	s.GetLatency = c.latencies[opGet].summary()
+	s.SetLatency = c.latencies[opSet].summary()
+	s.EvictLatency = c.latencies[opEvict].summary()
+	s.LoadLatency = c.latencies[opLoad].summary()
+
+	now := time.Now()
+	s.Since = time.Unix(0, c.since.Load())
+	s.Time = now
+	s.HitRatio1m = c.window.ratio(now, time.Minute)
+	s.HitRatio5m = c.window.ratio(now, 5*time.Minute)
+	s.HitRatio1h = c.window.ratio(now, time.Hour)
+}
+
+// reset sets every counter back to zero.
+func (c *counters) reset() {
+	c.hits.Store(0)
+	c.misses.Store(0)
+	c.sets.Store(0)
+	c.evictions.Store(0)
+	c.loads.Store(0)
+	c.loadErrors.Store(0)
+	c.loadTime.Store(0)
+	c.residents.Store(0)
+	c.residency.Store(0)
+	for i := range c.latencies {
+		c.latencies[i].reset()
+	}
+	c.window.reset()
+	c.since.Store(time.Now().UnixNano())
+}
+
+// ResetStats sets the hit, miss, set, eviction and loader counters and the latency histograms reported by Stats back to zero.
+func (c *FIFOCache) ResetStats() {
+	c.counters.reset()
+}
+
+// ResetStats sets the hit, miss, set, eviction and loader counters and the latency histograms reported by Stats back to zero.
+func (c *LRUCache) ResetStats() {
+	c.counters.reset()
+}
+
+// ResetStats sets the hit, miss, set, eviction and loader counters and the latency histograms reported by Stats back to zero.
+func (c *LFUCache) ResetStats() {
+	c.counters.reset()
+}
+
+// ResetStats sets the hit, miss, set, eviction and loader counters and the latency histograms reported by Stats back to zero.
+func (c *ApproxLRUCache) ResetStats() {
+	c.counters.reset()
+}
+
+// ResetStats sets the hit, miss, set and loader counters and the latency histograms reported by Stats back to zero.
+func (c *TTLCache) ResetStats() {
+	c.counters.reset()
+}
@@ -6,6 +6,8 @@ type User struct {
 	Age  int    `json:"age"`
 }
 
+// myDB is the demo database MakeRequest reads from when no loader is set. The mockdb package
+// serves the same users with configurable latency and failures.
 var myDB = map[string]User{
 	"1": {Id: "1", Name: "Alice", Age: 30},
 	"2": {Id: "2", Name: "Bob", Age: 25},
@@ -17,3 +19,12 @@ var myDB = map[string]User{
 func getUserFromDb(id string) User {
 	return myDB[id]
 }
+
+// DemoUsers returns the users of the demo database.
+func DemoUsers() []User {
+	users := make([]User, 0, len(myDB))
+	for _, user := range myDB {
+		users = append(users, user)
+	}
+	return users
+}
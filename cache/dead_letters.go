@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNoDeadLetters is returned by the dead letter methods of a WriteBehind created without WithDeadLetters.
+var ErrNoDeadLetters = errors.New("cache: write-behind queue has no dead letters")
+
+// DeadLetter is a write the Store failed to save too many times, moved to the dead letters of a WriteBehind.
+type DeadLetter struct {
+	// ID is the ID of the entry of the dead letter stream.
+	ID string `json:"id"`
+	// Key is the value key the user was cached under.
+	Key string `json:"key"`
+	// User is the user that could not be saved.
+	User User `json:"user"`
+	// Error is the error of the last attempt.
+	Error string `json:"error"`
+	// Attempts is the number of saves of the write that failed.
+	Attempts int `json:"attempts"`
+	// Time is when the write was moved to the dead letters.
+	Time time.Time `json:"time"`
+}
+
+// deadLetter appends a write to the dead letter stream, with the error of its last save.
+func (wb *WriteBehind) deadLetter(ctx context.Context, w queuedWrite, cause error) error {
+	b, err := json.Marshal(w.user)
+	if err != nil {
+		return err
+	}
+	log.Printf("Moving write of key: %s to dead letter stream: %s after %d attempts", w.key, wb.deadLetterStream, w.attempts)
+	err = wb.deadLetters.XAdd(ctx, &redis.XAddArgs{
+		Stream: wb.deadLetterStream,
+		Values: []interface{}{
+			"key", w.key,
+			"user", b,
+			"error", cause.Error(),
+			"attempts", w.attempts,
+			"time", time.Now().UnixMilli(),
+		},
+	}).Err()
+	if err != nil {
+		log.Printf("Error appending write of key: %s to dead letter stream: %s: %v", w.key, wb.deadLetterStream, err)
+		return err
+	}
+	wb.deadLettersTotal.Add(1)
+	return nil
+}
+
+// DeadLetters returns up to count dead letters, oldest first, or every one if count is 0.
+func (wb *WriteBehind) DeadLetters(ctx context.Context, count int64) ([]DeadLetter, error) {
+	if wb.deadLetters == nil {
+		return nil, ErrNoDeadLetters
+	}
+
+	var messages []redis.XMessage
+	var err error
+	if count > 0 {
+		messages, err = wb.deadLetters.XRangeN(ctx, wb.deadLetterStream, "-", "+", count).Result()
+	} else {
+		messages, err = wb.deadLetters.XRange(ctx, wb.deadLetterStream, "-", "+").Result()
+	}
+	if err != nil {
+		log.Printf("Error reading dead letter stream: %s: %v", wb.deadLetterStream, err)
+		return nil, err
+	}
+
+	letters := make([]DeadLetter, 0, len(messages))
+	for _, m := range messages {
+		letters = append(letters, decodeDeadLetter(m))
+	}
+	return letters, nil
+}
+
+// decodeDeadLetter decodes an entry of a dead letter stream. A user that cannot be decoded is logged and left empty.
+func decodeDeadLetter(m redis.XMessage) DeadLetter {
+	letter := DeadLetter{ID: m.ID}
+	letter.Key, _ = m.Values["key"].(string)
+	letter.Error, _ = m.Values["error"].(string)
+	if s, ok := m.Values["attempts"].(string); ok {
+		letter.Attempts, _ = strconv.Atoi(s)
+	}
+	if s, ok := m.Values["time"].(string); ok {
+		ms, _ := strconv.ParseInt(s, 10, 64)
+		letter.Time = time.UnixMilli(ms)
+	}
+	data, _ := m.Values["user"].(string)
+	if err := json.Unmarshal([]byte(data), &letter.User); err != nil {
+		log.Printf("Error decoding user of dead letter: %s: %v", m.ID, err)
+	}
+	return letter
+}
+
+// RetryDeadLetters queues the dead letters of ids again, or every dead letter if no ids are given, and removes
+// them from the dead letters. The writes start their attempts over. It returns the number of writes queued.
+func (wb *WriteBehind) RetryDeadLetters(ctx context.Context, ids ...string) (int, error) {
+	if wb.deadLetters == nil {
+		return 0, ErrNoDeadLetters
+	}
+
+	var letters []DeadLetter
+	if len(ids) == 0 {
+		all, err := wb.DeadLetters(ctx, 0)
+		if err != nil {
+			return 0, err
+		}
+		letters = all
+	}
+	for _, id := range ids {
+		messages, err := wb.deadLetters.XRange(ctx, wb.deadLetterStream, id, id).Result()
+		if err != nil {
+			log.Printf("Error reading dead letter: %s of stream: %s: %v", id, wb.deadLetterStream, err)
+			return 0, err
+		}
+		for _, m := range messages {
+			letters = append(letters, decodeDeadLetter(m))
+		}
+	}
+
+	retried := 0
+	for _, letter := range letters {
+		if letter.Key == "" {
+			log.Printf("Skipping dead letter: %s without a key", letter.ID)
+			continue
+		}
+		if err := wb.enqueue(ctx, letter.Key, letter.User); err != nil {
+			log.Printf("Error queueing dead letter: %s: %v", letter.ID, err)
+			return retried, err
+		}
+		if err := wb.deadLetters.XDel(ctx, wb.deadLetterStream, letter.ID).Err(); err != nil {
+			log.Printf("Error deleting dead letter: %s of stream: %s: %v", letter.ID, wb.deadLetterStream, err)
+			return retried, err
+		}
+		retried++
+	}
+	log.Printf("Queued %d dead letters of stream: %s again", retried, wb.deadLetterStream)
+	return retried, nil
+}
+
+// DeadLettered returns the number of writes this WriteBehind moved to the dead letters since it was created.
+func (wb *WriteBehind) DeadLettered() int64 {
+	return wb.deadLettersTotal.Load()
+}
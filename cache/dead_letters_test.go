@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// rejectingStore is a Store saving users like recordingStore, except the user rejected, whose batches it fails.
+type rejectingStore struct {
+	recordingStore
+	rejected string
+}
+
+func (s *rejectingStore) SaveUsers(ctx context.Context, users []User) error {
+	for _, u := range users {
+		if u.Id == s.rejected {
+			return errors.New("user " + u.Id + " is invalid")
+		}
+	}
+	return s.recordingStore.SaveUsers(ctx, users)
+}
+
+// deadLetterQueues creates a WriteBehind of every queue, moving writes to the dead letters after two failed saves.
+var deadLetterQueues = map[string]func(t *testing.T, client Client, store Store) *WriteBehind{
+	"memory": func(t *testing.T, client Client, store Store) *WriteBehind {
+		return newTestWriteBehind(t, store, WithDeadLetters(client, "dead", 2))
+	},
+	"stream": func(t *testing.T, client Client, store Store) *WriteBehind {
+		return newTestDurableWriteBehind(t, client, "a", store, WithDeadLetters(client, "dead", 2))
+	},
+}
+
+func TestDeadLetters(t *testing.T) {
+	for name, newWriteBehind := range deadLetterQueues {
+		t.Run(name, func(t *testing.T) {
+			client, _ := newTestClient(t)
+			store := &rejectingStore{rejected: "2"}
+			wb := newWriteBehind(t, client, store)
+			c := NewLRU(context.Background(), client, 10, "lru", WithWriteBehind(wb))
+			setUsers(t, c.Set, nil, 1, 2)
+
+			// The batch fails twice, then the writes are saved alone and the rejected one is moved to the dead letters.
+			for i := 0; i < 2; i++ {
+				if err := wb.Flush(context.Background()); err == nil {
+					t.Fatalf("Flush %d succeeded", i+1)
+				}
+			}
+			assertSaved(t, &store.recordingStore, testUser(1))
+			if pending := wb.Pending(); pending != 0 {
+				t.Errorf("pending writes: %d, want 0", pending)
+			}
+			if n := wb.DeadLettered(); n != 1 {
+				t.Errorf("dead lettered writes: %d, want 1", n)
+			}
+
+			letters, err := wb.DeadLetters(context.Background(), 0)
+			if err != nil {
+				t.Fatalf("DeadLetters: %v", err)
+			}
+			if len(letters) != 1 {
+				t.Fatalf("dead letters: %+v, want 1", letters)
+			}
+			if l := letters[0]; l.Key != c.Key("2") || l.User != testUser(2) || l.Attempts != 2 || l.Error != "user 2 is invalid" || l.Time.IsZero() {
+				t.Errorf("dead letter: %+v", l)
+			}
+
+			// Once the store accepts the user, the dead letter is queued again and saved.
+			store.rejected = ""
+			if n, err := wb.RetryDeadLetters(context.Background()); err != nil || n != 1 {
+				t.Fatalf("RetryDeadLetters: %d, %v, want 1", n, err)
+			}
+			if err := wb.Flush(context.Background()); err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+			assertSaved(t, &store.recordingStore, testUser(1), testUser(2))
+			if letters, err := wb.DeadLetters(context.Background(), 0); err != nil || len(letters) != 0 {
+				t.Errorf("dead letters after the retry: %+v, %v", letters, err)
+			}
+		})
+	}
+}
+
+func TestRetryDeadLettersByID(t *testing.T) {
+	client, _ := newTestClient(t)
+	store := &rejectingStore{rejected: "x"}
+	wb := newTestWriteBehind(t, store, WithDeadLetters(client, "dead", 1))
+	c := NewLRU(context.Background(), client, 10, "lru", WithWriteBehind(wb))
+	for _, id := range []int{1, 2} {
+		store.rejected = strconv.Itoa(id)
+		setUsers(t, c.Set, nil, id)
+		if err := wb.Flush(context.Background()); err == nil {
+			t.Fatalf("Flush of rejected user %d succeeded", id)
+		}
+	}
+
+	letters, err := wb.DeadLetters(context.Background(), 1)
+	if err != nil || len(letters) != 1 || letters[0].User != testUser(1) {
+		t.Fatalf("first dead letter: %+v, %v", letters, err)
+	}
+	store.rejected = ""
+	if n, err := wb.RetryDeadLetters(context.Background(), letters[0].ID); err != nil || n != 1 {
+		t.Fatalf("RetryDeadLetters: %d, %v, want 1", n, err)
+	}
+	if letters, _ := wb.DeadLetters(context.Background(), 0); len(letters) != 1 || letters[0].User != testUser(2) {
+		t.Errorf("dead letters after the retry: %+v", letters)
+	}
+}
+
+func TestDeadLettersNeedWithDeadLetters(t *testing.T) {
+	wb := newTestWriteBehind(t, &recordingStore{})
+	if _, err := wb.DeadLetters(context.Background(), 0); !errors.Is(err, ErrNoDeadLetters) {
+		t.Errorf("DeadLetters: %v, want ErrNoDeadLetters", err)
+	}
+	if _, err := wb.RetryDeadLetters(context.Background()); !errors.Is(err, ErrNoDeadLetters) {
+		t.Errorf("RetryDeadLetters: %v, want ErrNoDeadLetters", err)
+	}
+}
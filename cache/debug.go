@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// DebugInfo is the live state of a cache, as published by Publish and served by DebugHandler.
+type DebugInfo struct {
+	// Algorithm is the eviction policy of the cache: "fifo", "lru", "lfu", "approx-lru" or "ttl".
+	Algorithm string `json:"algorithm"`
+	// KeyPrefix is the prefix of the keys of the cache.
+	KeyPrefix string `json:"key_prefix"`
+	// Config is the configuration the cache was created with.
+	Config DebugConfig `json:"config"`
+	// Stats are the current footprint and counters of the cache.
+	Stats Stats `json:"stats"`
+	// Error is the error encountered while collecting Stats, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// DebugConfig is the configuration of a cache reported in DebugInfo.
+type DebugConfig struct {
+	Capacity      int           `json:"capacity"`
+	MaxBytes      int64         `json:"max_bytes,omitempty"`
+	SampleSize    int           `json:"sample_size,omitempty"`
+	Expiration    time.Duration `json:"expiration,omitempty"`
+	Storage       string        `json:"storage"`
+	Compressed    bool          `json:"compressed"`
+	Encrypted     bool          `json:"encrypted"`
+	SchemaVersion int           `json:"schema_version,omitempty"`
+	Coordinated   bool          `json:"coordinated"`
+	Indexes       []string      `json:"indexes,omitempty"`
+	ReadTimeout   time.Duration `json:"read_timeout,omitempty"`
+	WriteTimeout  time.Duration `json:"write_timeout,omitempty"`
+	ReadReplica   bool          `json:"read_replica,omitempty"`
+}
+
+// Publish publishes the DebugInfo of the cache as the expvar variable name, served as JSON under /debug/vars
+// by the handler expvar registers on http.DefaultServeMux. Stats are collected from Redis on every read.
+// It returns an error if a variable with that name is already published.
+func (c *FIFOCache) Publish(name string) error {
+	return publishDebugInfo(name, c.debugInfo)
+}
+
+// Publish publishes the DebugInfo of the cache as the expvar variable name, served as JSON under /debug/vars
+// by the handler expvar registers on http.DefaultServeMux. Stats are collected from Redis on every read.
+// It returns an error if a variable with that name is already published.
+func (c *LRUCache) Publish(name string) error {
+	return publishDebugInfo(name, c.debugInfo)
+}
+
+// Publish publishes the DebugInfo of the cache as the expvar variable name, served as JSON under /debug/vars
+// by the handler expvar registers on http.DefaultServeMux. Stats are collected from Redis on every read.
+// It returns an error if a variable with that name is already published.
+func (c *LFUCache) Publish(name string) error {
+	return publishDebugInfo(name, c.debugInfo)
+}
+
+// Publish publishes the DebugInfo of the cache as the expvar variable name, served as JSON under /debug/vars
+// by the handler expvar registers on http.DefaultServeMux. Stats are collected from Redis on every read.
+// It returns an error if a variable with that name is already published.
+func (c *ApproxLRUCache) Publish(name string) error {
+	return publishDebugInfo(name, c.debugInfo)
+}
+
+// Publish publishes the DebugInfo of the cache as the expvar variable name, served as JSON under /debug/vars
+// by the handler expvar registers on http.DefaultServeMux.
+// It returns an error if a variable with that name is already published.
+func (c *TTLCache) Publish(name string) error {
+	return publishDebugInfo(name, c.debugInfo)
+}
+
+// DebugHandler returns an HTTP handler serving the DebugInfo of the cache as JSON, for services
+// that would rather mount it on their own debug mux than publish it through expvar.
+func (c *FIFOCache) DebugHandler() http.Handler {
+	return debugHandler(c.debugInfo)
+}
+
+// DebugHandler returns an HTTP handler serving the DebugInfo of the cache as JSON, for services
+// that would rather mount it on their own debug mux than publish it through expvar.
+func (c *LRUCache) DebugHandler() http.Handler {
+	return debugHandler(c.debugInfo)
+}
+
+// DebugHandler returns an HTTP handler serving the DebugInfo of the cache as JSON, for services
+// that would rather mount it on their own debug mux than publish it through expvar.
+func (c *LFUCache) DebugHandler() http.Handler {
+	return debugHandler(c.debugInfo)
+}
+
+// DebugHandler returns an HTTP handler serving the DebugInfo of the cache as JSON, for services
+// that would rather mount it on their own debug mux than publish it through expvar.
+func (c *ApproxLRUCache) DebugHandler() http.Handler {
+	return debugHandler(c.debugInfo)
+}
+
+// DebugHandler returns an HTTP handler serving the DebugInfo of the cache as JSON, for services
+// that would rather mount it on their own debug mux than publish it through expvar.
+func (c *TTLCache) DebugHandler() http.Handler {
+	return debugHandler(c.debugInfo)
+}
+
+// debugInfo collects the DebugInfo of the cache.
+func (c *FIFOCache) debugInfo() DebugInfo {
+	stats, err := c.Stats()
+	return c.newDebugInfo(string(PolicyFIFO), c.keyPrefix, c.debugConfig(c.currentCapacity(c.capacity)), stats, err)
+}
+
+// debugInfo collects the DebugInfo of the cache.
+func (c *LRUCache) debugInfo() DebugInfo {
+	stats, err := c.Stats()
+	return c.newDebugInfo(string(PolicyLRU), c.keyPrefix, c.debugConfig(c.currentCapacity(c.capacity)), stats, err)
+}
+
+// debugInfo collects the DebugInfo of the cache.
+func (c *LFUCache) debugInfo() DebugInfo {
+	stats, err := c.Stats()
+	return c.newDebugInfo(string(PolicyLFU), c.keyPrefix, c.debugConfig(c.currentCapacity(c.capacity)), stats, err)
+}
+
+// debugInfo collects the DebugInfo of the cache.
+func (c *ApproxLRUCache) debugInfo() DebugInfo {
+	stats, err := c.Stats()
+	config := c.debugConfig(c.currentCapacity(c.capacity))
+	config.SampleSize = c.sampleSize
+	return c.newDebugInfo(string(PolicyApproxLRU), c.keyPrefix, config, stats, err)
+}
+
+// debugInfo collects the DebugInfo of the cache.
+func (c *TTLCache) debugInfo() DebugInfo {
+	stats, err := c.Stats()
+	config := c.debugConfig(0)
+	config.Expiration = c.expiration
+	return c.newDebugInfo("ttl", c.keyPrefix, config, stats, err)
+}
+
+// debugConfig reports the options of a cache with the given item capacity.
+func (o options) debugConfig(capacity int) DebugConfig {
+	return DebugConfig{
+		Capacity:      o.itemCapacity(capacity),
+		MaxBytes:      o.maxBytes,
+		Storage:       o.storageMode(),
+		Compressed:    o.compression != 0,
+		Encrypted:     o.keyring != nil,
+		SchemaVersion: o.schemaVersion,
+		Coordinated:   o.coordinated,
+		Indexes:       o.indexes,
+		ReadTimeout:   o.readTimeout,
+		WriteTimeout:  o.writeTimeout,
+		ReadReplica:   o.replica != nil,
+	}
+}
+
+// newDebugInfo assembles the DebugInfo of a cache.
+func (o options) newDebugInfo(algorithm, keyPrefix string, config DebugConfig, stats Stats, err error) DebugInfo {
+	info := DebugInfo{
+		Algorithm: algorithm,
+		KeyPrefix: o.namespace(keyPrefix),
+		Config:    config,
+		Stats:     stats,
+	}
+	if err != nil {
+		info.Error = err.Error()
+	}
+	return info
+}
+
+// publishDebugInfo publishes info as the expvar variable name.
+func publishDebugInfo(name string, info func() DebugInfo) error {
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("expvar %s is already published", name)
+	}
+	log.Printf("Publishing cache debug info as expvar: %s", name)
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return info()
+	}))
+	return nil
+}
+
+// debugHandler serves info as JSON.
+func debugHandler(info func() DebugInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(info()); err != nil {
+			log.Printf("Error writing cache debug info: %v", err)
+		}
+	})
+}
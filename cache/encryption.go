@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownKeyID is returned when decrypting a value encrypted with a key the keyring does not hold.
+var ErrUnknownKeyID = errors.New("value was encrypted with an unknown key")
+
+// encryptionMagic starts every encrypted payload. It is followed by the length of the key ID, the key ID,
+// the nonce and the AES-GCM ciphertext. Like compressionMagic, it starts with a zero byte,
+// so encrypted and plaintext entries can coexist and are told apart on read.
+var encryptionMagic = []byte{0x00, 0xec}
+
+// EncryptionKey is an AES key identified by an ID that is stored with every value it encrypts.
+// The key must be 16, 24 or 32 bytes long, selecting AES-128, AES-192 or AES-256.
+type EncryptionKey struct {
+	ID  string
+	Key []byte
+}
+
+// Keyring holds the key that encrypts new values and the retired keys that can still decrypt older ones.
+type Keyring struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyring creates a Keyring that encrypts with current and decrypts with current or any of previous.
+// To rotate keys, pass the new key as current and the old one in previous until every entry encrypted
+// with it has been rewritten or evicted.
+func NewKeyring(current EncryptionKey, previous ...EncryptionKey) (*Keyring, error) {
+	k := &Keyring{
+		current: current.ID,
+		aeads:   make(map[string]cipher.AEAD),
+	}
+	for _, key := range append([]EncryptionKey{current}, previous...) {
+		if len(key.ID) == 0 || len(key.ID) > 255 {
+			return nil, fmt.Errorf("key ID must be between 1 and 255 bytes long: %q", key.ID)
+		}
+		block, err := aes.NewCipher(key.Key)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key.ID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key.ID, err)
+		}
+		k.aeads[key.ID] = aead
+	}
+	return k, nil
+}
+
+// encryptingCodec wraps a Codec and encrypts its payloads with AES-GCM.
+type encryptingCodec struct {
+	codec   Codec
+	keyring *Keyring
+}
+
+// NewEncryptingCodec returns a Codec that serializes values with codec and encrypts the payloads
+// with the current key of keyring. Payloads without the encryption header are decoded as plaintext,
+// so a cache can start encrypting without invalidating the entries it already holds.
+func NewEncryptingCodec(codec Codec, keyring *Keyring) Codec {
+	return encryptingCodec{
+		codec:   codec,
+		keyring: keyring,
+	}
+}
+
+// WithEncryption encrypts cached values with the current key of keyring, after compression if WithCompression is also given.
+// Only values stored as strings are encrypted: hash and RedisJSON storage keep fields readable by Redis and are not affected.
+func WithEncryption(keyring *Keyring) Option {
+	return func(o *options) {
+		o.keyring = keyring
+	}
+}
+
+// Marshal serializes v and encrypts the result with the current key.
+func (c encryptingCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	aead := c.keyring.aeads[c.keyring.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(encryptionMagic)
+	buf.WriteByte(byte(len(c.keyring.current)))
+	buf.WriteString(c.keyring.current)
+	buf.Write(nonce)
+	return aead.Seal(buf.Bytes(), nonce, data, nil), nil
+}
+
+// Unmarshal decrypts data with the key named in its header, or passes it through if it is not encrypted, and decodes it into v.
+func (c encryptingCodec) Unmarshal(data []byte, v interface{}) error {
+	if !bytes.HasPrefix(data, encryptionMagic) || len(data) <= len(encryptionMagic) {
+		return c.codec.Unmarshal(data, v)
+	}
+
+	rest := data[len(encryptionMagic):]
+	idLen := int(rest[0])
+	if len(rest) < 1+idLen {
+		return errors.New("truncated encrypted value")
+	}
+	id := string(rest[1 : 1+idLen])
+	rest = rest[1+idLen:]
+
+	aead, ok := c.keyring.aeads[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKeyID, id)
+	}
+	if len(rest) < aead.NonceSize() {
+		return errors.New("truncated encrypted value")
+	}
+
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], nil)
+	if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(plaintext, v)
+}
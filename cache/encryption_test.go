@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testKey returns an AES-256 key identified by id.
+func testKey(id string) EncryptionKey {
+	return EncryptionKey{ID: id, Key: bytes.Repeat([]byte(id[:1]), 32)}
+}
+
+// testKeyring returns a keyring of current and previous keys, failing the test if it cannot be built.
+func testKeyring(t *testing.T, current EncryptionKey, previous ...EncryptionKey) *Keyring {
+	t.Helper()
+	keyring, err := NewKeyring(current, previous...)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return keyring
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	client, m := newTestClient(t)
+	c := NewLRU(context.Background(), client, 10, "lru", WithEncryption(testKeyring(t, testKey("a-2024"))))
+
+	setUsers(t, c.Set, nil, 1)
+	stored, _ := m.Get(c.Key("1"))
+	if !strings.HasPrefix(stored, string(encryptionMagic)) {
+		t.Errorf("the value is not encrypted: %q", stored)
+	}
+	if strings.Contains(stored, testUser(1).Name) {
+		t.Errorf("the encrypted value holds the name in clear: %q", stored)
+	}
+	if got, err := c.Get("1"); err != nil || got != testUser(1) {
+		t.Errorf("Get: %+v, %v", got, err)
+	}
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+	oldKey, newKey := testKey("a-2024"), testKey("b-2025")
+	plain := NewLRU(ctx, client, 10, "lru")
+	before := NewLRU(ctx, client, 10, "lru", WithEncryption(testKeyring(t, oldKey)))
+	rotated := NewLRU(ctx, client, 10, "lru", WithEncryption(testKeyring(t, newKey, oldKey)))
+	after := NewLRU(ctx, client, 10, "lru", WithEncryption(testKeyring(t, newKey)))
+
+	setUsers(t, plain.Set, nil, 1)
+	setUsers(t, before.Set, nil, 2)
+	for _, id := range []string{"1", "2"} {
+		if _, err := rotated.Get(id); err != nil {
+			t.Errorf("Get(%s) with the old key retired: %v", id, err)
+		}
+	}
+	if _, err := after.Get("2"); !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("Get without the old key: %v, want ErrUnknownKeyID", err)
+	}
+
+	setUsers(t, rotated.Set, nil, 2)
+	if got, err := after.Get("2"); err != nil || got != testUser(2) {
+		t.Errorf("Get of a rewritten entry without the old key: %+v, %v", got, err)
+	}
+}
+
+func TestNewKeyringRejectsInvalidKeys(t *testing.T) {
+	for name, key := range map[string]EncryptionKey{
+		"empty ID":    {ID: "", Key: make([]byte, 32)},
+		"long ID":     {ID: strings.Repeat("k", 256), Key: make([]byte, 32)},
+		"short key":   {ID: "k", Key: make([]byte, 10)},
+		"missing key": {ID: "k"},
+	} {
+		if _, err := NewKeyring(testKey("a"), key); err == nil {
+			t.Errorf("NewKeyring with a key of %s succeeded", name)
+		}
+	}
+}
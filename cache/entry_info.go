@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const metaKeyPrefix = "cache_meta"
+
+// Fields of the metadata of an entry. Each field is a hash of the cache, keyed by value key.
+const (
+	metaCreatedField  = "created"
+	metaAccessedField = "accessed"
+	metaHitsField     = "hits"
+	metaSourceField   = "source"
+)
+
+var metaFields = []string{metaCreatedField, metaAccessedField, metaHitsField, metaSourceField}
+
+// Sources of cached entries, as reported by GetEntryInfo.
+const (
+	// SourceDatabase marks an entry loaded from the database by MakeRequest after a cache miss.
+	SourceDatabase = "db"
+	// SourceSet marks an entry written with Set or AddKey.
+	SourceSet = "set"
+	// SourceCompareAndSet marks an entry last written with CompareAndSet.
+	SourceCompareAndSet = "cas"
+)
+
+// EntryInfo describes why an entry is in the cache and how hot it is.
+type EntryInfo struct {
+	// CreatedAt is when the entry was admitted. Updating a cached entry does not change it.
+	CreatedAt time.Time `json:"created_at"`
+	// AccessedAt is when the entry was last read or written.
+	AccessedAt time.Time `json:"accessed_at"`
+	// Hits is the number of cache hits since the entry was admitted.
+	Hits int64 `json:"hits"`
+	// Source is where the current value came from, e.g. SourceDatabase.
+	Source string `json:"source"`
+}
+
+// WithEntryInfo records the admission time, last access, hit count and source of every entry,
+// returned by GetEntryInfo. Every hit then costs one more write.
+func WithEntryInfo() Option {
+	return func(o *options) {
+		o.recordInfo = true
+	}
+}
+
+// trackEntry updates the secondary indexes and the metadata of a value key after v was written to it from source.
+func (o options) trackEntry(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, cacheKey string, v interface{}, source string) error {
+	if err := o.indexEntry(ctx, client, generateKey, cacheKey, v); err != nil {
+		return err
+	}
+	return o.recordWrite(ctx, client, generateKey, cacheKey, source)
+}
+
+// dropEntries removes the secondary index entries, the tags and the metadata of value keys removed from the cache.
+func (o options) dropEntries(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, cacheKeys ...string) error {
+	if err := o.unindexEntries(ctx, client, generateKey, cacheKeys...); err != nil {
+		return err
+	}
+	if err := o.untagEntries(ctx, client, generateKey, cacheKeys...); err != nil {
+		return err
+	}
+	if (!o.recordInfo && o.auditLen <= 0) || len(cacheKeys) == 0 {
+		return nil
+	}
+
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, field := range metaFields {
+			pipe.HDel(ctx, generateKey(metaKeyPrefix, field), cacheKeys...)
+		}
+		return nil
+	})
+	return err
+}
+
+// recordWrite records a write of a value key from source. The creation time is only set on admission.
+// With an eviction audit trail but no entry info, only the creation time is recorded.
+func (o options) recordWrite(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, cacheKey, source string) error {
+	if !o.recordInfo && o.auditLen <= 0 {
+		return nil
+	}
+
+	now := o.now().UnixNano()
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSetNX(ctx, generateKey(metaKeyPrefix, metaCreatedField), cacheKey, now)
+		if !o.recordInfo {
+			return nil
+		}
+		pipe.HSet(ctx, generateKey(metaKeyPrefix, metaAccessedField), cacheKey, now)
+		pipe.HSet(ctx, generateKey(metaKeyPrefix, metaSourceField), cacheKey, source)
+		return nil
+	})
+	return err
+}
+
+// recordHit records a cache hit on each of cacheKeys, in a single pipeline.
+func (o options) recordHit(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, cacheKeys ...string) error {
+	if !o.recordInfo {
+		return nil
+	}
+
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, cacheKey := range cacheKeys {
+			pipe.HIncrBy(ctx, generateKey(metaKeyPrefix, metaHitsField), cacheKey, 1)
+			pipe.HSet(ctx, generateKey(metaKeyPrefix, metaAccessedField), cacheKey, o.now().UnixNano())
+		}
+		return nil
+	})
+	return err
+}
+
+// entryInfo returns the metadata of a value key. It returns redis.Nil if the key is not cached.
+func (o options) entryInfo(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, cacheKey string) (EntryInfo, error) {
+	log.Printf("Getting entry info of key: %s", cacheKey)
+	var exists *redis.IntCmd
+	values := make([]*redis.StringCmd, len(metaFields))
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		exists = pipe.Exists(ctx, cacheKey)
+		for i, field := range metaFields {
+			values[i] = pipe.HGet(ctx, generateKey(metaKeyPrefix, field), cacheKey)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return EntryInfo{}, err
+	}
+	if exists.Val() == 0 {
+		return EntryInfo{}, redis.Nil
+	}
+
+	parse := func(cmd *redis.StringCmd) int64 {
+		n, _ := strconv.ParseInt(cmd.Val(), 10, 64)
+		return n
+	}
+	info := EntryInfo{
+		Hits:   parse(values[2]),
+		Source: values[3].Val(),
+	}
+	if created := parse(values[0]); created > 0 {
+		info.CreatedAt = time.Unix(0, created)
+	}
+	if accessed := parse(values[1]); accessed > 0 {
+		info.AccessedAt = time.Unix(0, accessed)
+	}
+	return info, nil
+}
+
+// GetEntryInfo returns the metadata of a cached user, or redis.Nil if the user is not cached.
+// The metadata is only recorded if the cache was created with WithEntryInfo; it is empty otherwise.
+// Getting the metadata does not count as an access.
+func (c *FIFOCache) GetEntryInfo(id string) (EntryInfo, error) {
+	return c.entryInfo(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, id))
+}
+
+// GetEntryInfo returns the metadata of a cached user, or redis.Nil if the user is not cached.
+// The metadata is only recorded if the cache was created with WithEntryInfo; it is empty otherwise.
+// Getting the metadata does not count as an access.
+func (c *LRUCache) GetEntryInfo(id string) (EntryInfo, error) {
+	return c.entryInfo(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, id))
+}
+
+// GetEntryInfo returns the metadata of a cached user, or redis.Nil if the user is not cached.
+// The metadata is only recorded if the cache was created with WithEntryInfo; it is empty otherwise.
+// Getting the metadata does not count as an access.
+func (c *LFUCache) GetEntryInfo(id string) (EntryInfo, error) {
+	return c.entryInfo(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, id))
+}
+
+// GetEntryInfo returns the metadata of a cached user, or redis.Nil if the user is not cached.
+// The metadata is only recorded if the cache was created with WithEntryInfo; it is empty otherwise.
+// Getting the metadata does not count as an access.
+func (c *ApproxLRUCache) GetEntryInfo(id string) (EntryInfo, error) {
+	return c.entryInfo(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, id))
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const eventsKeyPrefix = "cache_events"
+
+// Types of the events appended to the event stream of a cache.
+const (
+	EventHit        = "hit"
+	EventMiss       = "miss"
+	EventAdmit      = "admit"
+	EventEvict      = "evict"
+	EventExpire     = "expire"
+	EventInvalidate = "invalidate"
+)
+
+// WithEventLog appends every hit, miss, admission and eviction of the cache to the Redis Stream <keyPrefix>:cache_events,
+// along with the expirations seen by a running ExpiryWatcher and the invalidations of NotifyUpdated and NotifyDeleted,
+// so external consumers can audit or replay the dynamics of the cache with XRANGE or XREAD.
+// Each entry has an "event" field, one of the Event constants, and a "key" field with the value key;
+// evictions also have a "reason" field, see WithOnEvict, and invalidations one of the Invalidate constants. The stream is trimmed to approximately maxLen entries.
+// Every logged event costs one more write; a failed write is logged and does not fail the operation.
+func WithEventLog(maxLen int64) Option {
+	return func(o *options) {
+		o.eventLogLen = maxLen
+	}
+}
+
+// logEvent appends an event to the event stream of the cache, if it has one.
+func (o options) logEvent(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, event, key, reason string) {
+	if o.eventLogLen <= 0 {
+		return
+	}
+
+	values := []interface{}{"event", event, "key", key}
+	if reason != "" {
+		values = append(values, "reason", reason)
+	}
+	stream := generateKey(eventsKeyPrefix)
+	err := client.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: o.eventLogLen,
+		Approx: true,
+		Values: values,
+	}).Err()
+	if err != nil {
+		log.Printf("Error appending %s event of key: %s to stream: %s: %v", event, key, stream, err)
+	}
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ExpiryWatcher listens for Redis expiry notifications and removes expired value keys from a cache's index,
+// so the index heals itself even when no one reads the expired key again.
+//
+// Redis only publishes expiry notifications when the server is configured with
+// `notify-keyspace-events` containing at least `Ex`.
+type ExpiryWatcher struct {
+	pubsub *redis.PubSub
+	done   chan struct{}
+}
+
+// Close stops listening for expiry notifications and waits for the watcher to exit.
+func (w *ExpiryWatcher) Close() error {
+	err := w.pubsub.Close()
+	<-w.done
+	return err
+}
+
+// WatchExpirations starts an ExpiryWatcher that prunes expired keys from the list.
+func (c *FIFOCache) WatchExpirations() (*ExpiryWatcher, error) {
+	return c.watchExpirations(c.ctx, c.client, c.generateKey, c.pruneKey)
+}
+
+// WatchExpirations starts an ExpiryWatcher that prunes expired keys from the sorted set.
+func (c *LRUCache) WatchExpirations() (*ExpiryWatcher, error) {
+	return c.watchExpirations(c.ctx, c.client, c.generateKey, c.pruneKey)
+}
+
+// WatchExpirations starts an ExpiryWatcher that prunes expired keys from the sorted set.
+func (c *LFUCache) WatchExpirations() (*ExpiryWatcher, error) {
+	return c.watchExpirations(c.ctx, c.client, c.generateKey, c.pruneKey)
+}
+
+// WatchExpirations starts an ExpiryWatcher that prunes expired keys from the access time hash.
+func (c *ApproxLRUCache) WatchExpirations() (*ExpiryWatcher, error) {
+	return c.watchExpirations(c.ctx, c.client, c.generateKey, c.pruneKey)
+}
+
+// WatchExpirations starts an ExpiryWatcher that drops the metadata and secondary index entries of expired keys.
+// The TTL cache has no index, so it only needs one for them and for the expiration callbacks.
+func (c *TTLCache) WatchExpirations() (*ExpiryWatcher, error) {
+	return c.watchExpirations(c.ctx, c.client, c.generateKey, func(cacheKey string) error {
+		return c.dropEntries(c.ctx, c.client, c.generateKey, cacheKey)
+	})
+}
+
+// watchExpirations subscribes to the expired key events of the client's database and, for every expired value key
+// of the cache, calls prune, logs the expiration to the event stream and calls the expiration callbacks.
+func (o options) watchExpirations(ctx context.Context, client Client, generateKey func(...string) string, prune func(cacheKey string) error) (*ExpiryWatcher, error) {
+	keyPrefix := generateKey(userPrefix) + ":"
+	// Keyspace notifications are only published by the node holding the expired key.
+	node, err := nodeForKey(ctx, client, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if o.notify {
+		if err := enableExpiryNotifications(ctx, node); err != nil {
+			return nil, err
+		}
+	}
+	channel := fmt.Sprintf("__keyevent@%d__:expired", node.Options().DB)
+	log.Printf("Subscribing to expiry notifications on channel: %s", channel)
+
+	pubsub := node.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		log.Printf("Error subscribing to channel: %s: %v", channel, err)
+		pubsub.Close()
+		return nil, err
+	}
+
+	w := &ExpiryWatcher{
+		pubsub: pubsub,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(w.done)
+		for msg := range pubsub.Channel() {
+			if !strings.HasPrefix(msg.Payload, keyPrefix) {
+				continue
+			}
+			log.Printf("Key: %s expired", msg.Payload)
+			if err := prune(msg.Payload); err != nil {
+				log.Printf("Failed to prune expired key: %s: %v", msg.Payload, err)
+			}
+			o.logEvent(ctx, client, generateKey, EventExpire, msg.Payload, "")
+			for _, fn := range o.onExpire {
+				fn(msg.Payload)
+			}
+		}
+	}()
+
+	return w, nil
+}
+
+// WithExpiryNotifications makes WatchExpirations enable the expired key events on the node holding the keys
+// of the cache, adding the E and x flags to its notify-keyspace-events with CONFIG SET if they are missing.
+// It fails on servers that forbid CONFIG SET, as some managed offerings do; configure them instead.
+func WithExpiryNotifications() Option {
+	return func(o *options) {
+		o.notify = true
+	}
+}
+
+// enableExpiryNotifications adds the flags of the expired key events to the notify-keyspace-events of node.
+func enableExpiryNotifications(ctx context.Context, node *redis.Client) error {
+	config, err := node.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		log.Printf("Error reading notify-keyspace-events: %v", err)
+		return err
+	}
+	flags := config["notify-keyspace-events"]
+	updated := flags
+	if !strings.Contains(updated, "E") {
+		updated += "E"
+	}
+	// A is an alias for every event class, expired events included.
+	if !strings.ContainsAny(updated, "xA") {
+		updated += "x"
+	}
+	if updated == flags {
+		return nil
+	}
+
+	log.Printf("Setting notify-keyspace-events from: %q to: %q", flags, updated)
+	return node.ConfigSet(ctx, "notify-keyspace-events", updated).Err()
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"sync"
+)
+
+// fallbackCache is a bounded in-process LRU cache of users, used while Redis is unavailable.
+type fallbackCache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// WithFallbackCache keeps up to size users in process memory while the circuit breaker of the cache is open,
+// so hot users keep being served without calling the loader during a Redis outage. The fallback cache is
+// neither consulted nor populated while Redis is available, and is discarded as soon as the breaker closes.
+// It requires WithCircuitBreaker.
+func WithFallbackCache(size int) Option {
+	return func(o *options) {
+		o.fallback = &fallbackCache{
+			size:    size,
+			order:   list.New(),
+			entries: make(map[string]*list.Element),
+		}
+	}
+}
+
+// get returns a user from the fallback cache and marks them as recently used.
+func (f *fallbackCache) get(id string) (User, bool) {
+	if f == nil {
+		return User{}, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	element, ok := f.entries[id]
+	if !ok {
+		return User{}, false
+	}
+	f.order.MoveToFront(element)
+	return element.Value.(User), true
+}
+
+// add adds a user to the fallback cache, evicting the least recently used one if it is full.
+func (f *fallbackCache) add(user User) {
+	if f == nil || f.size <= 0 {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if element, ok := f.entries[user.Id]; ok {
+		element.Value = user
+		f.order.MoveToFront(element)
+		return
+	}
+	if f.order.Len() >= f.size {
+		oldest := f.order.Back()
+		f.order.Remove(oldest)
+		delete(f.entries, oldest.Value.(User).Id)
+	}
+	f.entries[user.Id] = f.order.PushFront(user)
+}
+
+// clear discards every user of the fallback cache.
+func (f *fallbackCache) clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	log.Printf("Discarding %d users of the fallback cache", f.order.Len())
+	f.order.Init()
+	f.entries = make(map[string]*list.Element)
+}
+
+// loadDegraded serves a user while Redis is unavailable: from the fallback cache if it holds them,
+// and from the loader otherwise, adding them to the fallback cache.
+func (o options) loadDegraded(ctx context.Context, id string) (User, error) {
+	if user, ok := o.fallback.get(id); ok {
+		log.Printf("Serving user ID: %s from the fallback cache.", id)
+		return user, nil
+	}
+
+	user, err := o.load(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+	o.fallback.add(user)
+	return user, nil
+}
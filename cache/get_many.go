@@ -0,0 +1,342 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// readUsers reads the users stored at cacheKeys, with a single MGET in string storage and a single pipeline
+// otherwise. It returns the users, and whether each was found. Fields that are not stored are filled in from ids.
+// Entries with a stale schema are deleted and reported as not found, as by readValue.
+func (o options) readUsers(ctx context.Context, client redis.Cmdable, cacheKeys, ids []string) ([]User, []bool, error) {
+	users := make([]User, len(cacheKeys))
+	found := make([]bool, len(cacheKeys))
+
+	switch o.storageMode() {
+	case jsonStorage:
+		cmds := make([]*redis.JSONCmd, len(cacheKeys))
+		_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, cacheKey := range cacheKeys {
+				cmds[i] = pipe.JSONGet(ctx, cacheKey)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, nil, err
+		}
+		for i, cmd := range cmds {
+			data, err := cmd.Result()
+			if errors.Is(err, redis.Nil) || (err == nil && data == "") {
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := json.Unmarshal([]byte(data), &users[i]); err != nil {
+				return nil, nil, err
+			}
+			found[i] = true
+		}
+
+	case hashStorage:
+		cmds := make([]*redis.MapStringStringCmd, len(cacheKeys))
+		_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, cacheKey := range cacheKeys {
+				cmds[i] = pipe.HGetAll(ctx, cacheKey)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, cmd := range cmds {
+			if len(cmd.Val()) == 0 {
+				continue
+			}
+			if err := setStructFields(cmd.Val(), &users[i]); err != nil {
+				return nil, nil, err
+			}
+			found[i] = true
+		}
+
+	default:
+		values, err := client.MGet(ctx, cacheKeys...).Result()
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, value := range values {
+			data, ok := value.(string)
+			if !ok {
+				continue
+			}
+			err := o.codec.Unmarshal([]byte(data), &users[i])
+			if errors.Is(err, ErrStaleSchema) {
+				log.Printf("Key: %s has a stale schema. Deleting.", cacheKeys[i])
+				if err := client.Del(ctx, cacheKeys[i]).Err(); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			found[i] = true
+		}
+	}
+
+	for i := range users {
+		if found[i] && users[i].Id == "" {
+			users[i].Id = ids[i]
+		}
+	}
+	return users, found, nil
+}
+
+// readReplicaUsers reads users like readUsers from the replica, if the cache has one, and from client the users
+// the replica misses, or every user if it fails.
+func (o options) readReplicaUsers(ctx context.Context, client redis.Cmdable, cacheKeys, ids []string) ([]User, []bool, error) {
+	if o.replica == nil {
+		return o.readUsers(ctx, client, cacheKeys, ids)
+	}
+
+	users, found, err := o.readUsers(ctx, o.replica, cacheKeys, ids)
+	if err != nil {
+		log.Printf("Error reading %d cache keys from replica, reading from primary: %v", len(cacheKeys), err)
+		return o.readUsers(ctx, client, cacheKeys, ids)
+	}
+
+	var missing []int
+	for i := range cacheKeys {
+		if !found[i] {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return users, found, nil
+	}
+	missingKeys := make([]string, len(missing))
+	missingIDs := make([]string, len(missing))
+	for j, i := range missing {
+		missingKeys[j], missingIDs[j] = cacheKeys[i], ids[i]
+	}
+	primary, primaryFound, err := o.readUsers(ctx, client, missingKeys, missingIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for j, i := range missing {
+		users[i], found[i] = primary[j], primaryFound[j]
+	}
+	return users, found, nil
+}
+
+// getMany retrieves the users of ids with readReplicaUsers, and marks the hits as used with a single call of touch,
+// if not nil. It counts and reports every hit and miss as Get does, and prunes the misses with prune, if not nil.
+// It returns the users found, by ID, and the IDs of the others, in order.
+func (o options) getMany(ctx context.Context, client Client, generateKey func(...string) string, ids []string, touch func(cacheKeys []string) error, prune func(cacheKey string) error) (map[string]User, []string, error) {
+	users := make(map[string]User, len(ids))
+	if len(ids) == 0 {
+		return users, nil, nil
+	}
+	defer o.counters.observe(opGet, time.Now())
+
+	cacheKeys := make([]string, len(ids))
+	for i, id := range ids {
+		cacheKeys[i] = generateKey(userPrefix, id)
+	}
+	o.logf(LogRead, "Attempting to get %d users from cache.", len(ids))
+
+	var read []User
+	var found []bool
+	err := o.withRetry(ctx, func() (err error) {
+		read, found, err = o.readReplicaUsers(ctx, client, cacheKeys, ids)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error getting %d users from Redis: %v", len(ids), err)
+		return nil, nil, err
+	}
+
+	var missing, hitKeys []string
+	for i, id := range ids {
+		if !found[i] {
+			missing = append(missing, id)
+			o.counters.recordMiss()
+			o.notifyMiss(ctx, client, generateKey, cacheKeys[i])
+			if prune == nil {
+				continue
+			}
+			if err := prune(cacheKeys[i]); err != nil {
+				log.Printf("Failed to prune key: %s from index: %v", cacheKeys[i], err)
+			}
+			continue
+		}
+		users[id] = read[i]
+		hitKeys = append(hitKeys, cacheKeys[i])
+	}
+	if len(hitKeys) == 0 {
+		return users, missing, nil
+	}
+
+	o.logf(LogHit, "Successfully retrieved %d of %d users. Updating recency.", len(hitKeys), len(ids))
+	if touch != nil {
+		if err := touch(hitKeys); err != nil {
+			log.Printf("Failed to update recency for %d keys: %v", len(hitKeys), err)
+			return nil, nil, err
+		}
+	}
+	for _, cacheKey := range hitKeys {
+		o.counters.recordHit()
+		o.notifyHit(ctx, client, generateKey, cacheKey)
+	}
+	if err := o.recordHit(ctx, client, generateKey, hitKeys...); err != nil {
+		log.Printf("Failed to record hits for %d keys: %v", len(hitKeys), err)
+	}
+	return users, missing, nil
+}
+
+// GetMany retrieves the users of ids with a single read. It returns the users found, by ID, and the IDs of
+// the others, in order. Each ID counts as a hit or a miss, as with Get.
+func (c *FIFOCache) GetMany(ids []string) (map[string]User, []string, error) {
+	c, cancel := c.withTimeout(c.readTimeout)
+	defer cancel()
+	return c.getMany(c.ctx, c.client, c.generateKey, ids, nil, c.pruneKey)
+}
+
+// GetMany retrieves the users of ids with a single read, and marks the users found as recently used with a single
+// ZADD. It returns the users found, by ID, and the IDs of the others, in order. Each ID counts as a hit or a miss,
+// as with Get.
+func (c *LRUCache) GetMany(ids []string) (map[string]User, []string, error) {
+	c, cancel := c.withTimeout(c.readTimeout)
+	defer cancel()
+	return c.getMany(c.ctx, c.client, c.generateKey, ids, c.updateRecencies, c.pruneKey)
+}
+
+// updateRecencies marks cached value keys as recently used. Keys evicted since they were read are not added back.
+func (c *LRUCache) updateRecencies(cacheKeys []string) error {
+	listKey := c.generateKey(cacheKeyPrefix)
+	c.logf(LogRead, "Updating recency for %d keys in list: %s", len(cacheKeys), listKey)
+
+	score := float64(c.recencyScore())
+	members := make([]redis.Z, len(cacheKeys))
+	for i, cacheKey := range cacheKeys {
+		members[i] = redis.Z{Member: cacheKey, Score: score}
+	}
+	return c.client.ZAddXX(c.ctx, listKey, members...).Err()
+}
+
+// GetMany retrieves the users of ids with a single read, and increments the access frequency of the users found
+// in a single pipeline. It returns the users found, by ID, and the IDs of the others, in order. Each ID counts as
+// a hit or a miss, as with Get.
+func (c *LFUCache) GetMany(ids []string) (map[string]User, []string, error) {
+	c, cancel := c.withTimeout(c.readTimeout)
+	defer cancel()
+	return c.getMany(c.ctx, c.client, c.generateKey, ids, c.updateFrequencies, c.pruneKey)
+}
+
+// updateFrequencies increments the access frequency of value keys.
+func (c *LFUCache) updateFrequencies(cacheKeys []string) error {
+	listKey := c.generateKey(cacheKeyPrefix)
+	c.logf(LogRead, "Updating frequency for %d keys in list: %s", len(cacheKeys), listKey)
+
+	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for _, cacheKey := range cacheKeys {
+			pipe.ZIncrBy(c.ctx, listKey, 1, cacheKey)
+		}
+		return nil
+	})
+	return err
+}
+
+// GetMany retrieves the users of ids with a single read, and updates the access time of the users found with
+// a single HSET. It returns the users found, by ID, and the IDs of the others, in order. Each ID counts as a hit
+// or a miss, as with Get.
+func (c *ApproxLRUCache) GetMany(ids []string) (map[string]User, []string, error) {
+	c, cancel := c.withTimeout(c.readTimeout)
+	defer cancel()
+	return c.getMany(c.ctx, c.client, c.generateKey, ids, c.updateRecencies, c.pruneKey)
+}
+
+// updateRecencies sets the access time of value keys to now.
+func (c *ApproxLRUCache) updateRecencies(cacheKeys []string) error {
+	hashKey := c.generateKey(cacheKeyPrefix)
+	c.logf(LogRead, "Updating recency for %d keys in hash: %s", len(cacheKeys), hashKey)
+
+	now := c.now().UnixNano()
+	values := make([]interface{}, 0, 2*len(cacheKeys))
+	for _, cacheKey := range cacheKeys {
+		values = append(values, cacheKey, now)
+	}
+	return c.client.HSet(c.ctx, hashKey, values...).Err()
+}
+
+// GetMany retrieves the users of ids with a single read, and their soft and hard expiry with a single pipeline.
+// It returns the users found, by ID, and the IDs of the others, in order. Each ID counts as a hit or a miss, and
+// stale users and users about to expire are refreshed in the background, as with Get.
+func (c *TTLCache) GetMany(ids []string) (map[string]User, []string, error) {
+	users := make(map[string]User, len(ids))
+	if len(ids) == 0 {
+		return users, nil, nil
+	}
+	defer c.counters.observe(opGet, time.Now())
+
+	cacheKeys := make([]string, len(ids))
+	for i, id := range ids {
+		cacheKeys[i] = c.generateKey(userPrefix, id)
+	}
+	c.logf(LogRead, "Attempting to get %d users from cache.", len(ids))
+
+	// Revalidations outlive the read, so only the reads themselves use the timed copy of the cache.
+	timed, cancel := c.withTimeout(c.readTimeout)
+	defer cancel()
+
+	var read []User
+	var found []bool
+	err := timed.withRetry(timed.ctx, func() (err error) {
+		read, found, err = timed.readReplicaUsers(timed.ctx, timed.client, cacheKeys, ids)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error getting %d users from Redis: %v", len(ids), err)
+		return nil, nil, err
+	}
+
+	var hits []int
+	var hitIDs []string
+	for i, id := range ids {
+		if found[i] {
+			hits = append(hits, i)
+			hitIDs = append(hitIDs, id)
+		}
+	}
+	expiries, ok, err := timed.readExpiries(hitIDs)
+	if err != nil {
+		log.Printf("Error getting expiry of %d users: %v", len(hitIDs), err)
+		expiries, ok = make([]entryExpiry, len(hitIDs)), make([]bool, len(hitIDs))
+	}
+	served := make([]bool, len(ids))
+	for j, i := range hits {
+		served[i] = c.serve(ids[i], cacheKeys[i], expiries[j], ok[j])
+	}
+
+	var missing []string
+	for i, id := range ids {
+		if !served[i] {
+			// Users past their hard expiry were counted by serve.
+			if !found[i] {
+				c.counters.recordMiss()
+				c.notifyMiss(c.ctx, c.client, c.generateKey, cacheKeys[i])
+			}
+			missing = append(missing, id)
+			continue
+		}
+		users[id] = read[i]
+		c.counters.recordHit()
+		c.notifyHit(c.ctx, c.client, c.generateKey, cacheKeys[i])
+	}
+	return users, missing, nil
+}
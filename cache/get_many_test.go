@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// manyCache is implemented by the caches reading and writing several users at once.
+type manyCache interface {
+	Set(user User) error
+	Get(id string) (User, error)
+	Key(id string) string
+	GetMany(ids []string) (map[string]User, []string, error)
+	SetMany(users []User) error
+	LoadMany(ids []string) ([]User, []string, error)
+}
+
+// manyCaches creates every cache type with opts.
+var manyCaches = map[string]func(client Client, opts ...Option) manyCache{
+	"fifo": func(client Client, opts ...Option) manyCache {
+		c := NewFIFO(context.Background(), client, 10, "fifo", opts...)
+		return &c
+	},
+	"lru": func(client Client, opts ...Option) manyCache {
+		c := NewLRU(context.Background(), client, 10, "lru", opts...)
+		return &c
+	},
+	"lfu": func(client Client, opts ...Option) manyCache {
+		c := NewLFU(context.Background(), client, 10, "lfu", opts...)
+		return &c
+	},
+	"approx-lru": func(client Client, opts ...Option) manyCache {
+		c := NewApproxLRU(context.Background(), client, 10, 5, "approx", opts...)
+		return &c
+	},
+	"ttl": func(client Client, opts ...Option) manyCache {
+		c := NewTTL(context.Background(), client, time.Minute, "ttl", opts...)
+		return &c
+	},
+}
+
+func TestGetMany(t *testing.T) {
+	for name, newCache := range manyCaches {
+		t.Run(name, func(t *testing.T) {
+			client, _ := newTestClient(t)
+			c := newCache(client)
+			setUsers(t, c.Set, nil, 1, 2)
+
+			users, missing, err := c.GetMany([]string{"1", "3", "2", "4"})
+			if err != nil {
+				t.Fatalf("GetMany: %v", err)
+			}
+			if len(users) != 2 || users["1"] != testUser(1) || users["2"] != testUser(2) {
+				t.Errorf("users: %+v, want users 1 and 2", users)
+			}
+			if !slices.Equal(missing, []string{"3", "4"}) {
+				t.Errorf("missing: %v, want [3 4]", missing)
+			}
+
+			if users, missing, err := c.GetMany(nil); err != nil || len(users) != 0 || len(missing) != 0 {
+				t.Errorf("GetMany of no IDs: %+v, %v, %v", users, missing, err)
+			}
+		})
+	}
+}
+
+func TestGetManyUpdatesRecency(t *testing.T) {
+	client, _ := newTestClient(t)
+	clock := testClock()
+	c := NewLRU(context.Background(), client, 3, "lru", WithClock(clock))
+	setUsers(t, c.Set, clock, 1, 2, 3)
+
+	clock.Advance(time.Millisecond)
+	if _, _, err := c.GetMany([]string{"1", "2"}); err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+	setUsers(t, c.Set, clock, 4)
+	assertCached(t, client, c.Key, 1, 2, 4)
+}
+
+func TestGetManyCountsHitsAndMisses(t *testing.T) {
+	client, _ := newTestClient(t)
+	c := NewLRU(context.Background(), client, 10, "lru")
+	setUsers(t, c.Set, nil, 1)
+
+	if _, _, err := c.GetMany([]string{"1", "2", "3"}); err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("hits and misses: %d, %d, want 1, 2", stats.Hits, stats.Misses)
+	}
+}
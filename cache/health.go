@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrUnhealthy is returned by Health when Redis cannot be reached or the keys of the cache are corrupted.
+var ErrUnhealthy = errors.New("cache is unhealthy")
+
+// Health describes whether a cache is ready to serve requests.
+type Health struct {
+	// Healthy reports whether Redis answered the ping and every index key has its expected type.
+	Healthy bool `json:"healthy"`
+	// Latency is the round trip time of the ping.
+	Latency time.Duration `json:"latency"`
+	// Problems lists what made the cache unhealthy, e.g. a failed ping or an index key of the wrong type.
+	Problems []string `json:"problems,omitempty"`
+	// Stats is the current size and capacity of the cache. It is empty if Redis cannot be reached.
+	Stats Stats `json:"stats"`
+}
+
+// Health pings Redis, checks that the list, membership set, version hash and byte counter of the cache
+// have their expected types, and reports the current size and capacity. It returns ErrUnhealthy if any check fails,
+// so it can be used as a readiness check as is.
+func (c *FIFOCache) Health(ctx context.Context) (Health, error) {
+	checked := *c
+	checked.ctx = ctx
+	return c.health(ctx, c.client, map[string]string{
+		c.generateKey(cacheKeyPrefix):   "list",
+		c.generateKey(memberKeyPrefix):  "set",
+		c.generateKey(versionKeyPrefix): "hash",
+		c.generateKey(bytesKeyPrefix):   "hash",
+	}, checked.Stats)
+}
+
+// Health pings Redis, checks that the sorted set, version hash and byte counter of the cache
+// have their expected types, and reports the current size and capacity. It returns ErrUnhealthy if any check fails,
+// so it can be used as a readiness check as is.
+func (c *LRUCache) Health(ctx context.Context) (Health, error) {
+	checked := *c
+	checked.ctx = ctx
+	return c.health(ctx, c.client, map[string]string{
+		c.generateKey(cacheKeyPrefix):   "zset",
+		c.generateKey(versionKeyPrefix): "hash",
+		c.generateKey(bytesKeyPrefix):   "hash",
+	}, checked.Stats)
+}
+
+// Health pings Redis, checks that the sorted set, version hash and byte counter of the cache
+// have their expected types, and reports the current size and capacity. It returns ErrUnhealthy if any check fails,
+// so it can be used as a readiness check as is.
+func (c *LFUCache) Health(ctx context.Context) (Health, error) {
+	checked := *c
+	checked.ctx = ctx
+	return c.health(ctx, c.client, map[string]string{
+		c.generateKey(cacheKeyPrefix):   "zset",
+		c.generateKey(versionKeyPrefix): "hash",
+		c.generateKey(bytesKeyPrefix):   "hash",
+	}, checked.Stats)
+}
+
+// Health pings Redis, checks that the access time hash, version hash, epoch and byte counter of the cache
+// have their expected types, and reports the current size and capacity. It returns ErrUnhealthy if any check fails,
+// so it can be used as a readiness check as is.
+func (c *ApproxLRUCache) Health(ctx context.Context) (Health, error) {
+	checked := *c
+	checked.ctx = ctx
+	return c.health(ctx, c.client, map[string]string{
+		c.generateKey(cacheKeyPrefix):   "hash",
+		c.generateKey(versionKeyPrefix): "hash",
+		c.generateKey(epochKeyPrefix):   "string",
+		c.generateKey(bytesKeyPrefix):   "hash",
+	}, checked.Stats)
+}
+
+// Health pings Redis. The TTL cache has no index keys and no capacity, so its Stats only report its counters and breaker state.
+// It returns ErrUnhealthy if Redis cannot be reached, so it can be used as a readiness check as is.
+func (c *TTLCache) Health(ctx context.Context) (Health, error) {
+	return c.health(ctx, c.client, nil, c.Stats)
+}
+
+// health pings Redis, checks the type of every key in types, which may also be missing while the cache is empty,
+// and collects the stats of the cache.
+func (o options) health(ctx context.Context, client Client, types map[string]string, stats func() (Stats, error)) (Health, error) {
+	log.Println("Checking cache health")
+	var h Health
+
+	start := time.Now()
+	if err := client.Ping(ctx).Err(); err != nil {
+		h.Problems = append(h.Problems, fmt.Sprintf("ping: %v", err))
+		h.Stats, _ = o.stats(0, 0, func() (int64, error) { return 0, nil })
+		return reportHealth(h)
+	}
+	h.Latency = time.Since(start)
+
+	cmds := make(map[string]*redis.StatusCmd, len(types))
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key := range types {
+			cmds[key] = pipe.Type(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		h.Problems = append(h.Problems, fmt.Sprintf("checking index keys: %v", err))
+	} else {
+		keys := make([]string, 0, len(types))
+		for key := range types {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			expected := types[key]
+			if actual := cmds[key].Val(); actual != expected && actual != "none" {
+				h.Problems = append(h.Problems, fmt.Sprintf("key %s is a %s, expected a %s", key, actual, expected))
+			}
+		}
+	}
+
+	h.Stats, err = stats()
+	if err != nil {
+		h.Problems = append(h.Problems, fmt.Sprintf("stats: %v", err))
+	}
+	return reportHealth(h)
+}
+
+// reportHealth marks h as healthy if no problem was found, and returns ErrUnhealthy listing the problems otherwise.
+func reportHealth(h Health) (Health, error) {
+	if len(h.Problems) > 0 {
+		log.Printf("Cache is unhealthy: %s", strings.Join(h.Problems, "; "))
+		return h, fmt.Errorf("%w: %s", ErrUnhealthy, strings.Join(h.Problems, "; "))
+	}
+	h.Healthy = true
+	return h, nil
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// hitSlotWidth is the granularity of the windowed hit ratio.
+	hitSlotWidth = 10 * time.Second
+	// maxHitWindow is the longest window of the hit ratio.
+	maxHitWindow = time.Hour
+	numHitSlots  = int(maxHitWindow / hitSlotWidth)
+)
+
+// hitSlot counts the hits and misses of one slot of the window.
+type hitSlot struct {
+	start  int64
+	hits   int64
+	misses int64
+}
+
+// hitWindow counts hits and misses in a ring of fixed width slots covering the last hour,
+// so the hit ratio can be computed over any window up to an hour.
+type hitWindow struct {
+	mu    sync.Mutex
+	slots [numHitSlots]hitSlot
+}
+
+// record counts a hit or a miss in the current slot, clearing the slot if it last held an older period.
+func (w *hitWindow) record(now time.Time, hit bool) {
+	start := now.UnixNano() / int64(hitSlotWidth)
+	slot := &w.slots[start%int64(numHitSlots)]
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if slot.start != start {
+		*slot = hitSlot{start: start}
+	}
+	if hit {
+		slot.hits++
+	} else {
+		slot.misses++
+	}
+}
+
+// ratio returns the share of hits among the reads of the last window, rounded up to whole slots and capped at an hour.
+// It returns 0 if there was no read in the window.
+func (w *hitWindow) ratio(now time.Time, window time.Duration) float64 {
+	current := now.UnixNano() / int64(hitSlotWidth)
+	slots := int64((window + hitSlotWidth - 1) / hitSlotWidth)
+	n := min(max(slots, 1), int64(numHitSlots))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var hits, misses int64
+	for start := current - n + 1; start <= current; start++ {
+		slot := w.slots[start%int64(numHitSlots)]
+		if slot.start == start {
+			hits += slot.hits
+			misses += slot.misses
+		}
+	}
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// reset clears every slot.
+func (w *hitWindow) reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.slots = [numHitSlots]hitSlot{}
+}
+
+// recordHit counts a cache hit.
+func (c *counters) recordHit() {
+	c.hits.Add(1)
+	c.window.record(time.Now(), true)
+}
+
+// recordMiss counts a cache miss.
+func (c *counters) recordMiss() {
+	c.misses.Add(1)
+	c.window.record(time.Now(), false)
+}
+
+// HitRatio returns the share of reads that were hits over the last window, up to an hour, at a granularity of 10 seconds.
+// Unlike the lifetime Hits and Misses of Stats, it reacts to shifts of the workload. It returns 0 if there was no read.
+func (c *FIFOCache) HitRatio(window time.Duration) float64 {
+	return c.counters.window.ratio(time.Now(), window)
+}
+
+// HitRatio returns the share of reads that were hits over the last window, up to an hour, at a granularity of 10 seconds.
+// Unlike the lifetime Hits and Misses of Stats, it reacts to shifts of the workload. It returns 0 if there was no read.
+func (c *LRUCache) HitRatio(window time.Duration) float64 {
+	return c.counters.window.ratio(time.Now(), window)
+}
+
+// HitRatio returns the share of reads that were hits over the last window, up to an hour, at a granularity of 10 seconds.
+// Unlike the lifetime Hits and Misses of Stats, it reacts to shifts of the workload. It returns 0 if there was no read.
+func (c *LFUCache) HitRatio(window time.Duration) float64 {
+	return c.counters.window.ratio(time.Now(), window)
+}
+
+// HitRatio returns the share of reads that were hits over the last window, up to an hour, at a granularity of 10 seconds.
+// Unlike the lifetime Hits and Misses of Stats, it reacts to shifts of the workload. It returns 0 if there was no read.
+func (c *ApproxLRUCache) HitRatio(window time.Duration) float64 {
+	return c.counters.window.ratio(time.Now(), window)
+}
+
+// HitRatio returns the share of reads that were hits over the last window, up to an hour, at a granularity of 10 seconds.
+// Unlike the lifetime Hits and Misses of Stats, it reacts to shifts of the workload. It returns 0 if there was no read.
+func (c *TTLCache) HitRatio(window time.Duration) float64 {
+	return c.counters.window.ratio(time.Now(), window)
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Reasons of evictions, passed to the callbacks registered with WithOnEvict.
+const (
+	// EvictCapacity marks an entry evicted to make room under the item capacity, or removed by RemoveOldest.
+	EvictCapacity = "capacity"
+	// EvictBytes marks an entry evicted to fit the byte capacity.
+	EvictBytes = "bytes"
+)
+
+// WithOnHit registers fn to be called with the value key of every cache hit.
+// Callbacks run synchronously on the path of the read, so they must be fast and must not block.
+func WithOnHit(fn func(key string)) Option {
+	return func(o *options) {
+		o.onHit = append(o.onHit, fn)
+	}
+}
+
+// WithOnMiss registers fn to be called with the value key of every cache miss.
+// Callbacks run synchronously on the path of the read, so they must be fast and must not block.
+func WithOnMiss(fn func(key string)) Option {
+	return func(o *options) {
+		o.onMiss = append(o.onMiss, fn)
+	}
+}
+
+// WithOnAdmit registers fn to be called with the value key of every user written to the cache by Set.
+// Callbacks run synchronously after the write, so they must be fast and must not block.
+func WithOnAdmit(fn func(key string)) Option {
+	return func(o *options) {
+		o.onAdmit = append(o.onAdmit, fn)
+	}
+}
+
+// WithOnEvict registers fn to be called for every entry evicted by the cache, with its value key,
+// the evicted user and the reason, EvictCapacity or EvictBytes. The value is read by the eviction script
+// before the entry is deleted, so registering a callback costs one more read per eviction.
+// Entries removed with Delete or expired by Redis are not evictions. The TTL cache does not evict.
+// Callbacks run synchronously after the eviction, so they must be fast and must not block.
+func WithOnEvict(fn func(key string, value User, reason string)) Option {
+	return func(o *options) {
+		o.onEvict = append(o.onEvict, fn)
+	}
+}
+
+// WithOnExpire registers fn to be called with the value key of every entry Redis expires, as reported to a running
+// ExpiryWatcher, see WatchExpirations. Callbacks run one at a time on the goroutine of the watcher, after the key
+// is pruned from the index, so they must be fast and must not block.
+func WithOnExpire(fn func(key string)) Option {
+	return func(o *options) {
+		o.onExpire = append(o.onExpire, fn)
+	}
+}
+
+// notifyHit calls the hit callbacks with a value key, logs the hit to the event stream and counts it in the frequency sketch.
+func (o options) notifyHit(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, key string) {
+	o.logEvent(ctx, client, generateKey, EventHit, key, "")
+	o.countAccess(ctx, client, generateKey, key)
+	for _, fn := range o.onHit {
+		fn(key)
+	}
+}
+
+// notifyMiss calls the miss callbacks with a value key and logs the miss to the event stream.
+func (o options) notifyMiss(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, key string) {
+	o.logEvent(ctx, client, generateKey, EventMiss, key, "")
+	for _, fn := range o.onMiss {
+		fn(key)
+	}
+}
+
+// notifyAdmit calls the admission callbacks with a value key and logs the admission to the event stream.
+func (o options) notifyAdmit(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, key string) {
+	o.logEvent(ctx, client, generateKey, EventAdmit, key, "")
+	for _, fn := range o.onAdmit {
+		fn(key)
+	}
+}
+
+// eviction is an entry evicted by an admission or eviction script.
+type eviction struct {
+	key    string
+	reason string
+	value  string
+	trace  string
+}
+
+// parseEvictions reads the key, reason, value and trace quadruples returned by the admission scripts, see captureEvicted.
+func parseEvictions(reply []string) []eviction {
+	evictions := make([]eviction, 0, len(reply)/4)
+	for i := 0; i+3 < len(reply); i += 4 {
+		evictions = append(evictions, eviction{key: reply[i], reason: reply[i+1], value: reply[i+2], trace: reply[i+3]})
+	}
+	return evictions
+}
+
+// evictedKeys returns the keys of evictions.
+func evictedKeys(evictions []eviction) []string {
+	keys := make([]string, len(evictions))
+	for i, e := range evictions {
+		keys[i] = e.key
+	}
+	return keys
+}
+
+// notifyEvict logs an eviction to the event stream, records its trace, decodes the value of the evicted entry and calls the eviction callbacks.
+// An entry whose value cannot be decoded is reported with an empty user.
+func (o options) notifyEvict(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, e eviction) {
+	o.logEvent(ctx, client, generateKey, EventEvict, e.key, e.reason)
+	o.recordTrace(e)
+	if len(o.onEvict) == 0 {
+		return
+	}
+
+	var user User
+	if e.value != "" {
+		if err := o.decodeValue([]byte(e.value), &user); err != nil {
+			log.Printf("Error decoding evicted value of key: %s: %v", e.key, err)
+			user = User{}
+		}
+	}
+	o.callEvict(e.key, user, e.reason)
+}
+
+// callEvict calls the eviction callbacks with an evicted user.
+func (o options) callEvict(key string, user User, reason string) {
+	for _, fn := range o.onEvict {
+		fn(key, user, reason)
+	}
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// InvalidationBus broadcasts the value keys written or deleted by the caches of one application instance to the
+// other instances, over a Redis Pub/Sub channel, so they can drop the copies they keep in process memory.
+// Every instance creates its own bus on the same channel and passes it to its caches with WithInvalidationBus.
+//
+// Pub/Sub delivers at most once: messages published while an instance is disconnected are lost, so local copies
+// should still expire on their own.
+type InvalidationBus struct {
+	client  Client
+	channel string
+	origin  string
+	pubsub  *redis.PubSub
+	done    chan struct{}
+
+	mu        sync.Mutex
+	listeners []func(key string)
+}
+
+// invalidation is the message an InvalidationBus publishes.
+type invalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys"`
+}
+
+// NewInvalidationBus subscribes to channel and starts delivering the keys invalidated by the other instances
+// to the listeners registered with OnInvalidate. The keys a bus publishes itself are not delivered to it.
+func NewInvalidationBus(ctx context.Context, client Client, channel string) (*InvalidationBus, error) {
+	origin, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Subscribing to invalidations on channel: %s", channel)
+	pubsub := client.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		log.Printf("Error subscribing to channel: %s: %v", channel, err)
+		pubsub.Close()
+		return nil, err
+	}
+
+	b := &InvalidationBus{
+		client:  client,
+		channel: channel,
+		origin:  origin,
+		pubsub:  pubsub,
+		done:    make(chan struct{}),
+	}
+	go b.receive()
+	return b, nil
+}
+
+// OnInvalidate registers fn to be called with every value key invalidated by another instance.
+// Callbacks run one at a time on the goroutine of the bus, so they must be fast and must not block.
+func (b *InvalidationBus) OnInvalidate(fn func(key string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, fn)
+}
+
+// Publish broadcasts keys to the other instances. The caches publish the keys they write and delete by themselves;
+// Publish is for the changes they do not see, such as a database update that makes cached users stale.
+func (b *InvalidationBus) Publish(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(invalidation{Origin: b.origin, Keys: keys})
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, payload).Err()
+}
+
+// Close unsubscribes from the channel and waits for the bus to deliver the messages it already received.
+func (b *InvalidationBus) Close() error {
+	err := b.pubsub.Close()
+	<-b.done
+	return err
+}
+
+// receive delivers the invalidations of the other instances until the bus is closed.
+func (b *InvalidationBus) receive() {
+	defer close(b.done)
+	for msg := range b.pubsub.Channel() {
+		var inv invalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+			log.Printf("Error decoding invalidation on channel: %s: %v", b.channel, err)
+			continue
+		}
+		if inv.Origin == b.origin {
+			continue
+		}
+
+		b.mu.Lock()
+		listeners := b.listeners
+		b.mu.Unlock()
+		for _, key := range inv.Keys {
+			log.Printf("Key: %s invalidated by another instance", key)
+			for _, fn := range listeners {
+				fn(key)
+			}
+		}
+	}
+}
+
+// WithInvalidationBus makes Set, CompareAndSet and Delete publish the value keys they change on bus, once the change
+// is written, so the other instances sharing the cache drop their local copies. Evictions and expirations are not
+// published, since they do not make a copy stale. A failed publish is logged and does not fail the write.
+func WithInvalidationBus(bus *InvalidationBus) Option {
+	return func(o *options) {
+		o.bus = bus
+	}
+}
+
+// publishInvalidation publishes keys on the invalidation bus of the cache, if it has one.
+func (o options) publishInvalidation(ctx context.Context, keys ...string) {
+	if o.bus == nil {
+		return
+	}
+	if err := o.bus.Publish(ctx, keys...); err != nil {
+		log.Printf("Error publishing invalidation of keys: %v: %v", keys, err)
+	}
+}
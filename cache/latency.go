@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Operations whose latency is tracked by the counters of a cache.
+const (
+	opGet = iota
+	opSet
+	opEvict
+	opLoad
+	numOps
+)
+
+// numLatencyBounds is the number of bucket bounds of a latency histogram, see latencyBounds.
+const numLatencyBounds = 104
+
+// latencyBounds are the upper bounds of the buckets of a latency histogram: from 1µs to about a minute,
+// growing by a factor of 2^(1/4), so percentiles are estimated within 20%.
+var latencyBounds = func() [numLatencyBounds]time.Duration {
+	var bounds [numLatencyBounds]time.Duration
+	for i := range bounds {
+		bounds[i] = time.Duration(float64(time.Microsecond) * math.Pow(2, float64(i)/4))
+	}
+	return bounds
+}()
+
+// Latency summarizes the latency distribution of an operation. Percentiles are upper bounds
+// of histogram buckets, so they overestimate the true value by at most 20%.
+type Latency struct {
+	// Count is the number of operations measured.
+	Count int64         `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+}
+
+// histogram counts durations in exponential buckets. The last bucket holds durations above every bound.
+type histogram struct {
+	buckets [numLatencyBounds + 1]atomic.Int64
+}
+
+// observe records a duration.
+func (h *histogram) observe(d time.Duration) {
+	i := sort.Search(numLatencyBounds, func(i int) bool { return latencyBounds[i] >= d })
+	h.buckets[i].Add(1)
+}
+
+// summary returns the count and percentiles of the recorded durations.
+func (h *histogram) summary() Latency {
+	var counts [numLatencyBounds + 1]int64
+	var total int64
+	for i := range h.buckets {
+		counts[i] = h.buckets[i].Load()
+		total += counts[i]
+	}
+	if total == 0 {
+		return Latency{}
+	}
+
+	percentile := func(q float64) time.Duration {
+		rank := int64(math.Ceil(q * float64(total)))
+		var seen int64
+		for i, n := range counts {
+			seen += n
+			if seen >= rank {
+				if i == numLatencyBounds {
+					return time.Duration(math.MaxInt64)
+				}
+				return latencyBounds[i]
+			}
+		}
+		return time.Duration(math.MaxInt64)
+	}
+	return Latency{
+		Count: total,
+		P50:   percentile(0.50),
+		P95:   percentile(0.95),
+		P99:   percentile(0.99),
+	}
+}
+
+// reset clears the recorded durations.
+func (h *histogram) reset() {
+	for i := range h.buckets {
+		h.buckets[i].Store(0)
+	}
+}
+
+// observe records the latency of an operation that started at start.
+func (c *counters) observe(op int, start time.Time) {
+	c.latencies[op].observe(time.Since(start))
+}
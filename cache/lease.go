@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const leaseKeyPrefix = "load_lease"
+
+// WithLoadLease coalesces the loads of a missing user across every application instance sharing the cache.
+// On a miss, GetOrLoad and MakeRequest acquire a per-user lease with SET NX PX: the holder loads and caches
+// the user, then publishes on the channel named after the lease key. Other callers wait for that signal,
+// for at most lease, and read the user from the cache, so the loader is called once per miss cluster-wide.
+// If the holder fails or the lease expires first, the waiters load the user themselves.
+func WithLoadLease(lease time.Duration) Option {
+	return func(o *options) {
+		o.lease = lease
+	}
+}
+
+// coalesce runs load for a missing user while holding its lease, or waits for the holder to cache the user and reads it with get.
+func (o options) coalesce(ctx context.Context, client Client, generateKey func(...string) string, id string, load, get func(id string) (User, error)) (User, error) {
+	if o.lease <= 0 {
+		return load(id)
+	}
+
+	leaseKey := generateKey(leaseKeyPrefix, id)
+	token, err := newLockToken()
+	if err != nil {
+		return User{}, err
+	}
+
+	acquired, err := client.SetNX(ctx, leaseKey, token, o.lease).Result()
+	if err != nil {
+		log.Printf("Error acquiring load lease: %s: %v. Loading without it.", leaseKey, err)
+		return load(id)
+	}
+	if acquired {
+		log.Printf("Acquired load lease: %s", leaseKey)
+		defer func() {
+			if err := scripts.run(ctx, client, unlockScript, []string{leaseKey}, token).Err(); err != nil {
+				log.Printf("Error releasing load lease: %s: %v", leaseKey, err)
+			}
+			if err := client.Publish(ctx, leaseKey, id).Err(); err != nil {
+				log.Printf("Error publishing on channel: %s: %v", leaseKey, err)
+			}
+		}()
+		return load(id)
+	}
+
+	log.Printf("User ID: %s is being loaded by another caller. Waiting on channel: %s", id, leaseKey)
+	pubsub := client.Subscribe(ctx, leaseKey)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		log.Printf("Error subscribing to channel: %s: %v", leaseKey, err)
+		return load(id)
+	}
+
+	// The holder may have finished between the lease check and the subscription.
+	if user, err := get(id); err == nil {
+		return user, nil
+	}
+
+	timer := time.NewTimer(o.lease)
+	defer timer.Stop()
+	select {
+	case <-pubsub.Channel():
+		if user, err := get(id); err == nil {
+			return user, nil
+		}
+		log.Printf("User ID: %s was not cached by the lease holder. Loading it.", id)
+	case <-timer.C:
+		log.Printf("Timed out waiting for the load lease: %s. Loading user ID: %s.", leaseKey, id)
+	case <-ctx.Done():
+		return User{}, ctx.Err()
+	}
+	return load(id)
+}
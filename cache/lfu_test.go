@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestLFUEvictsLeastFrequentlyUsed(t *testing.T) {
+	client, _ := newTestClient(t)
+	c := NewLFU(context.Background(), client, 3, "lfu")
+
+	setUsers(t, c.Set, nil, 1, 2, 3)
+	for _, id := range []string{"1", "1", "3"} {
+		if _, err := c.Get(id); err != nil {
+			t.Fatalf("Get(%s): %v", id, err)
+		}
+	}
+	setUsers(t, c.Set, nil, 4)
+	assertCached(t, client, c.Key, 1, 3, 4)
+
+	// Writing a cached user again keeps its frequency.
+	setUsers(t, c.Set, nil, 1)
+	setUsers(t, c.Set, nil, 5)
+	assertCached(t, client, c.Key, 1, 3, 5)
+}
+
+func TestLFUEnforcesCapacity(t *testing.T) {
+	client, _ := newTestClient(t)
+	c := NewLFU(context.Background(), client, 3, "lfu")
+
+	for i := 1; i <= 10; i++ {
+		setUsers(t, c.Set, nil, i)
+		if size, want := c.CacheSize(), min(i, 3); size != want {
+			t.Fatalf("size after %d users: %d, want %d", i, size, want)
+		}
+	}
+}
+
+func TestLFURemoveOldest(t *testing.T) {
+	client, _ := newTestClient(t)
+	c := NewLFU(context.Background(), client, 3, "lfu")
+
+	if err := c.RemoveOldest(); err == nil {
+		t.Fatal("RemoveOldest of an empty cache succeeded")
+	}
+	setUsers(t, c.Set, nil, 1, 2)
+	if _, err := c.Get("1"); err != nil {
+		t.Fatalf("Get(1): %v", err)
+	}
+	if err := c.RemoveOldest(); err != nil {
+		t.Fatalf("RemoveOldest: %v", err)
+	}
+	assertCached(t, client, c.Key, 1)
+}
+
+func TestLFUErrors(t *testing.T) {
+	client, m := newTestClient(t)
+	c := NewLFU(context.Background(), client, 3, "lfu", WithLoader(testLoader))
+
+	if _, err := c.Get("1"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get of a missing user: %v, want redis.Nil", err)
+	}
+	if _, err := c.GetOrLoad("x"); err == nil {
+		t.Error("GetOrLoad of a user the loader cannot load succeeded")
+	}
+	user, err := c.GetOrLoad("2")
+	if err != nil || user != testUser(2) {
+		t.Errorf("GetOrLoad(2): %v, %v, want %v", user, err, testUser(2))
+	}
+	assertCached(t, client, c.Key, 2)
+
+	m.Close()
+	if err := c.Set(testUser(1)); err == nil {
+		t.Error("Set with Redis down succeeded")
+	}
+}
@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"log"
+)
+
+// loadThrough returns the users of ids in order: the users cached, read with get, and the others, loaded with
+// loadMany and written back to the cache with set. If Redis is unavailable, the users are read from the fallback
+// cache or loaded, and added to the fallback cache. It also returns the IDs of the users neither cached nor loaded,
+// in order, and the error of the load, if any.
+func (o options) loadThrough(ctx context.Context, ids []string, get func([]string) (map[string]User, []string, error), set func([]User) error) ([]User, []string, error) {
+	found := make(map[string]User, len(ids))
+	var misses []string
+	if o.degraded() {
+		log.Printf("Redis is unavailable. Loading %d users directly.", len(ids))
+		for _, id := range ids {
+			if user, ok := o.fallback.get(id); ok {
+				found[id] = user
+				continue
+			}
+			misses = append(misses, id)
+		}
+		set = func(users []User) error {
+			for _, user := range users {
+				o.fallback.add(user)
+			}
+			return nil
+		}
+	} else {
+		hits, missing, err := get(ids)
+		if err != nil {
+			log.Printf("Error getting %d users from cache. Fetching them from database: %v", len(ids), err)
+			missing = ids
+		}
+		for id, user := range hits {
+			found[id] = user
+		}
+		misses = missing
+	}
+
+	var loadErr error
+	if misses = uniqueIDs(misses); len(misses) > 0 {
+		o.logf(LogMiss, "Cache miss for %d of %d users. Fetching from database.", len(misses), len(ids))
+		loaded, err := o.loadMany(ctx, misses)
+		if err != nil {
+			log.Printf("Failed to load %d users: %v", len(misses), err)
+			loadErr = err
+		}
+
+		users := make([]User, 0, len(loaded))
+		for _, id := range misses {
+			if user, ok := loaded[id]; ok {
+				users = append(users, user)
+				found[id] = user
+			}
+		}
+		if len(users) > 0 {
+			if err := set(users); err != nil {
+				log.Printf("Failed to write %d users to cache: %v", len(users), err)
+			}
+		}
+	}
+
+	users := make([]User, 0, len(ids))
+	var missing []string
+	for _, id := range ids {
+		if user, ok := found[id]; ok {
+			users = append(users, user)
+			continue
+		}
+		missing = append(missing, id)
+	}
+	return users, missing, loadErr
+}
+
+// uniqueIDs returns ids without duplicates, in order.
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	unique := ids[:0:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// LoadMany returns the users of ids in order, as GetOrLoad would one by one: the users cached are read with GetMany,
+// and the others are loaded with a single call of the batch loader and written back to the cache with SetMany.
+// It also returns the IDs of the users neither cached nor loaded, in order, and the error of the load, if any.
+func (c *FIFOCache) LoadMany(ids []string) ([]User, []string, error) {
+	return c.loadThrough(c.ctx, ids, c.GetMany, c.backfill)
+}
+
+// backfill writes users loaded from the store to the cache with SetMany, without queueing them for write-behind.
+func (c *FIFOCache) backfill(users []User) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	loaded := *c
+	loaded.fromStore = true
+	return loaded.setMany(loaded.ctx, loaded.client, loaded.generateKey, string(PolicyFIFO), users, loaded.batchAdmission, SourceDatabase)
+}
+
+// LoadMany returns the users of ids in order, as GetOrLoad would one by one: the users cached are read with GetMany,
+// and the others are loaded with a single call of the batch loader and written back to the cache with SetMany.
+// It also returns the IDs of the users neither cached nor loaded, in order, and the error of the load, if any.
+func (c *LRUCache) LoadMany(ids []string) ([]User, []string, error) {
+	return c.loadThrough(c.ctx, ids, c.GetMany, c.backfill)
+}
+
+// backfill writes users loaded from the store to the cache with SetMany, without queueing them for write-behind.
+func (c *LRUCache) backfill(users []User) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	loaded := *c
+	loaded.fromStore = true
+	return loaded.setMany(loaded.ctx, loaded.client, loaded.generateKey, string(PolicyLRU), users, loaded.batchAdmission, SourceDatabase)
+}
+
+// LoadMany returns the users of ids in order, as GetOrLoad would one by one: the users cached are read with GetMany,
+// and the others are loaded with a single call of the batch loader and written back to the cache with SetMany.
+// It also returns the IDs of the users neither cached nor loaded, in order, and the error of the load, if any.
+func (c *LFUCache) LoadMany(ids []string) ([]User, []string, error) {
+	return c.loadThrough(c.ctx, ids, c.GetMany, c.backfill)
+}
+
+// backfill writes users loaded from the store to the cache with SetMany, without queueing them for write-behind.
+func (c *LFUCache) backfill(users []User) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	loaded := *c
+	loaded.fromStore = true
+	return loaded.setMany(loaded.ctx, loaded.client, loaded.generateKey, string(PolicyLFU), users, loaded.batchAdmission, SourceDatabase)
+}
+
+// LoadMany returns the users of ids in order, as GetOrLoad would one by one: the users cached are read with GetMany,
+// and the others are loaded with a single call of the batch loader and written back to the cache with SetMany.
+// It also returns the IDs of the users neither cached nor loaded, in order, and the error of the load, if any.
+func (c *ApproxLRUCache) LoadMany(ids []string) ([]User, []string, error) {
+	return c.loadThrough(c.ctx, ids, c.GetMany, c.backfill)
+}
+
+// backfill writes users loaded from the store to the cache with SetMany, without queueing them for write-behind.
+func (c *ApproxLRUCache) backfill(users []User) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	loaded := *c
+	loaded.fromStore = true
+	return loaded.setMany(loaded.ctx, loaded.client, loaded.generateKey, string(PolicyApproxLRU), users, loaded.batchAdmission, SourceDatabase)
+}
+
+// LoadMany returns the users of ids in order, as GetOrLoad would one by one: the users cached are read with GetMany,
+// and the others are loaded with a single call of the batch loader and written back to the cache with SetMany.
+// It also returns the IDs of the users neither cached nor loaded, in order, and the error of the load, if any.
+func (c *TTLCache) LoadMany(ids []string) ([]User, []string, error) {
+	return c.loadThrough(c.ctx, ids, c.GetMany, c.SetMany)
+}
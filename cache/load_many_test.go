@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadMany(t *testing.T) {
+	for name, newCache := range manyCaches {
+		t.Run(name, func(t *testing.T) {
+			client, m := newTestClient(t)
+			var loads atomic.Int64
+			c := newCache(client, WithLoader(countingLoader(&loads)))
+			setUsers(t, c.Set, nil, 1)
+
+			users, missing, err := c.LoadMany([]string{"2", "1", "x", "2"})
+			if err == nil {
+				t.Error("LoadMany of an invalid ID did not return the error of the loader")
+			}
+			if want := []User{testUser(2), testUser(1), testUser(2)}; !slices.Equal(users, want) {
+				t.Errorf("users: %+v, want %+v", users, want)
+			}
+			if !slices.Equal(missing, []string{"x"}) {
+				t.Errorf("missing: %v, want [x]", missing)
+			}
+			// The duplicate miss is loaded once, and the loaded user is cached.
+			if n := loads.Load(); n != 2 {
+				t.Errorf("loads: %d, want 2", n)
+			}
+			if !m.Exists(c.Key("2")) {
+				t.Error("the loaded user is not cached")
+			}
+		})
+	}
+}
+
+func TestLoadManyWithABatchLoader(t *testing.T) {
+	client, _ := newTestClient(t)
+	var batches [][]string
+	c := NewLRU(context.Background(), client, 10, "lru", WithBatchLoader(func(ctx context.Context, ids []string) (map[string]User, error) {
+		batches = append(batches, ids)
+		users := make(map[string]User)
+		for _, id := range ids {
+			if user, err := testLoader(ctx, id); err == nil {
+				users[id] = user
+			}
+		}
+		return users, nil
+	}))
+	setUsers(t, c.Set, nil, 2)
+
+	users, missing, err := c.LoadMany([]string{"1", "2", "3", "x"})
+	if err != nil {
+		t.Fatalf("LoadMany: %v", err)
+	}
+	if want := []User{testUser(1), testUser(2), testUser(3)}; !slices.Equal(users, want) {
+		t.Errorf("users: %+v, want %+v", users, want)
+	}
+	if !slices.Equal(missing, []string{"x"}) {
+		t.Errorf("missing: %v, want [x]", missing)
+	}
+	if len(batches) != 1 || !slices.Equal(batches[0], []string{"1", "3", "x"}) {
+		t.Errorf("batches loaded: %v, want [[1 3 x]]", batches)
+	}
+}
+
+func TestLoadManyDoesNotQueueLoadedUsers(t *testing.T) {
+	client, _ := newTestClient(t)
+	wb := newTestWriteBehind(t, &recordingStore{})
+	c := NewLRU(context.Background(), client, 10, "lru", WithLoader(testLoader), WithWriteBehind(wb))
+
+	if _, _, err := c.LoadMany([]string{"1", "2"}); err != nil {
+		t.Fatalf("LoadMany: %v", err)
+	}
+	if pending := wb.Pending(); pending != 0 {
+		t.Errorf("pending writes after LoadMany: %d, want 0", pending)
+	}
+}
+
+func TestLoadManyServesTheFallbackCacheDuringAnOutage(t *testing.T) {
+	client, m := newTestClient(t)
+	breaker := NewCircuitBreaker(client, 1, time.Minute)
+	var loads atomic.Int64
+	c := NewLRU(context.Background(), client, 10, "lru",
+		WithLoader(countingLoader(&loads)), WithCircuitBreaker(breaker), WithFallbackCache(10))
+
+	m.Close()
+	if _, err := c.Get("1"); err == nil {
+		t.Fatal("Get succeeded while Redis is down")
+	}
+	waitForState(t, breaker, BreakerOpen)
+
+	for i := 0; i < 2; i++ {
+		if users, _, err := c.LoadMany([]string{"1", "2"}); err != nil || len(users) != 2 {
+			t.Fatalf("LoadMany while the breaker is open: %+v, %v", users, err)
+		}
+	}
+	if n := loads.Load(); n != 2 {
+		t.Errorf("loads during the outage: %d, want 2", n)
+	}
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Loader loads a user from the source of truth, such as a database, after a cache miss.
+type Loader func(ctx context.Context, id string) (User, error)
+
+// BatchLoader loads several users from the source of truth at once, such as with a single query, after cache misses.
+// It returns the users it found, by ID: the others are reported missing.
+type BatchLoader func(ctx context.Context, ids []string) (map[string]User, error)
+
+// WithLoader sets the loader MakeRequest calls after a cache miss. By default, users are read from the demo database.
+func WithLoader(loader Loader) Option {
+	return func(o *options) {
+		o.loader = loader
+	}
+}
+
+// WithBatchLoader sets the loader LoadMany calls once for all of its misses. Without it, LoadMany loads each miss
+// with the loader of WithLoader.
+func WithBatchLoader(loader BatchLoader) Option {
+	return func(o *options) {
+		o.batchLoader = loader
+	}
+}
+
+// load loads a user with the configured loader, or from the demo database if there is none.
+// Every call is counted in the Stats of the cache, along with its duration and whether it failed.
+func (o options) load(ctx context.Context, id string) (User, error) {
+	start := time.Now()
+	if o.loader == nil {
+		user := getUserFromDb(id)
+		o.counters.recordLoad(time.Since(start), nil)
+		return user, nil
+	}
+
+	user, err := o.loader(ctx, id)
+	o.counters.recordLoad(time.Since(start), err)
+	return user, err
+}
+
+// loadMany loads the users of ids with the batch loader in a single call, counted as one load in the Stats of the
+// cache, or with load, warmConcurrency at a time, if there is none. It returns the users loaded, by ID, even if some
+// failed to load.
+func (o options) loadMany(ctx context.Context, ids []string) (map[string]User, error) {
+	if o.batchLoader != nil {
+		start := time.Now()
+		users, err := o.batchLoader(ctx, ids)
+		o.counters.recordLoad(time.Since(start), err)
+		return users, err
+	}
+
+	loaded, failed, err := o.loadAll(ctx, ids)
+	users := make(map[string]User, len(loaded))
+	for _, user := range loaded {
+		users[user.Id] = user
+	}
+	if err != nil {
+		return users, fmt.Errorf("%d of %d users failed to load: %w", failed, len(ids), err)
+	}
+	return users, nil
+}
@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+)
+
+const lockKeyPrefix = "eviction_lock"
+
+// ErrLockNotHeld is returned when releasing a lock that expired or was taken over by another holder.
+var ErrLockNotHeld = errors.New("lock not held")
+
+// Locker acquires distributed locks. Lock blocks until the lock is acquired or ctx is done,
+// and returns a function that releases it.
+type Locker interface {
+	Lock(ctx context.Context, key string) (unlock func() error, err error)
+}
+
+// unlockScript deletes a lock key only if it still holds the caller's token.
+//
+// KEYS[1]: the lock key
+// ARGV[1]: the token of the holder
+var unlockScript = scripts.register(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// renewScript extends the lease of a lock key only if it still holds the caller's token.
+//
+// KEYS[1]: the lock key
+// ARGV[1]: the token of the holder
+// ARGV[2]: the new lease in milliseconds
+var renewScript = scripts.register(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// MinLockLease is the shortest lease of the locks of RedisLocker and Redlock. Shorter leases are raised to it, since
+// the lease is renewed every third of its duration and acquisition is retried every tenth of it.
+const MinLockLease = 30 * time.Millisecond
+
+// lockLease returns lease, raised to MinLockLease if it is shorter.
+func lockLease(lease time.Duration) time.Duration {
+	if lease < MinLockLease {
+		log.Printf("Lock lease: %s is shorter than the minimum. Using %s.", lease, MinLockLease)
+		return MinLockLease
+	}
+	return lease
+}
+
+// RedisLocker is a Locker backed by a single Redis instance. A lock is a key set with SET NX and a lease,
+// so it is released automatically if its holder crashes. While held, the lease is renewed in the background.
+type RedisLocker struct {
+	client     Client
+	lease      time.Duration
+	retryDelay time.Duration
+}
+
+// NewRedisLocker creates a new RedisLocker whose locks expire after lease unless renewed.
+// A lease shorter than MinLockLease, including a non-positive one, is raised to MinLockLease.
+func NewRedisLocker(client Client, lease time.Duration) *RedisLocker {
+	lease = lockLease(lease)
+	return &RedisLocker{
+		client:     client,
+		lease:      lease,
+		retryDelay: lease / 10,
+	}
+}
+
+// Lock acquires the lock for key, retrying until it is free or ctx is done.
+// The lease is renewed every third of its duration until the returned unlock function is called.
+func (l *RedisLocker) Lock(ctx context.Context, key string) (func() error, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Acquiring lock: %s", key)
+	for {
+		ok, err := l.client.SetNX(ctx, key, token, l.lease).Result()
+		if err != nil {
+			log.Printf("Error acquiring lock: %s: %v", key, err)
+			return nil, err
+		}
+		if ok {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(l.retryDelay):
+		}
+	}
+	log.Printf("Acquired lock: %s", key)
+
+	stopRenewing := renewEvery(l.lease/3, func() bool {
+		renewed, err := scripts.run(ctx, l.client, renewScript, []string{key}, token, l.lease.Milliseconds()).Int()
+		if err != nil || renewed == 0 {
+			log.Printf("Failed to renew lock: %s: %v", key, err)
+			return false
+		}
+		return true
+	})
+
+	unlock := func() error {
+		stopRenewing()
+
+		log.Printf("Releasing lock: %s", key)
+		released, err := scripts.run(context.Background(), l.client, unlockScript, []string{key}, token).Int()
+		if err != nil {
+			return err
+		}
+		if released == 0 {
+			return ErrLockNotHeld
+		}
+		return nil
+	}
+
+	return unlock, nil
+}
+
+// newLockToken returns a random token identifying a lock holder.
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// renewEvery calls renew at every interval until it returns false or the returned stop function is called.
+// stop waits for an in-flight renewal to finish.
+func renewEvery(interval time.Duration, renew func() bool) (stop func()) {
+	stopped := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopped:
+				return
+			case <-ticker.C:
+				if !renew() {
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stopped)
+		<-done
+	}
+}
+
+// withLock runs fn while holding the lock for key. If locker is nil, fn runs without locking.
+func withLock(ctx context.Context, locker Locker, key string, fn func() error) error {
+	if locker == nil {
+		return fn()
+	}
+
+	unlock, err := locker.Lock(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	fnErr := fn()
+	if err := unlock(); err != nil {
+		log.Printf("Failed to release lock: %s: %v", key, err)
+	}
+	return fnErr
+}
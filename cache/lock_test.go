@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// lockAttempt tries to acquire a lock for a short while, so a held lock fails the attempt instead of blocking the test.
+func lockAttempt(locker Locker, key string) (func() error, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	return locker.Lock(ctx, key)
+}
+
+func TestRedisLockerIsExclusive(t *testing.T) {
+	client, _ := newTestClient(t)
+	locker := NewRedisLocker(client, time.Second)
+
+	unlock, err := lockAttempt(locker, "lock")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if _, err := lockAttempt(locker, "lock"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock of a held lock: %v, want context.DeadlineExceeded", err)
+	}
+	if _, err := lockAttempt(locker, "other"); err != nil {
+		t.Errorf("Lock of another key: %v", err)
+	}
+
+	if err := unlock(); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	unlock, err = lockAttempt(locker, "lock")
+	if err != nil {
+		t.Fatalf("Lock after unlock: %v", err)
+	}
+	unlock()
+}
+
+func TestRedisLockerUnlockAfterTheLeaseExpired(t *testing.T) {
+	client, m := newTestClient(t)
+	locker := NewRedisLocker(client, time.Second)
+
+	unlock, err := lockAttempt(locker, "lock")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	m.FastForward(2 * time.Second)
+	if err := unlock(); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("unlock of an expired lock: %v, want ErrLockNotHeld", err)
+	}
+}
+
+func TestLockLeaseIsClamped(t *testing.T) {
+	client, _ := newTestClient(t)
+	for _, lease := range []time.Duration{-time.Second, 0, time.Millisecond} {
+		if l := NewRedisLocker(client, lease); l.lease != MinLockLease {
+			t.Errorf("lease of NewRedisLocker(%s): %s, want %s", lease, l.lease, MinLockLease)
+		}
+		if r := NewRedlock([]*redis.Client{client}, lease); r.lease != MinLockLease {
+			t.Errorf("lease of NewRedlock(%s): %s, want %s", lease, r.lease, MinLockLease)
+		}
+	}
+	if l := NewRedisLocker(client, time.Second); l.lease != time.Second {
+		t.Errorf("lease of NewRedisLocker(1s): %s", l.lease)
+	}
+}
+
+func TestWithLockerReleasesTheEvictionLock(t *testing.T) {
+	client, m := newTestClient(t)
+	c := NewLRU(context.Background(), client, 2, "lru", WithLocker(NewRedisLocker(client, time.Second)))
+
+	setUsers(t, c.Set, nil, 1, 2, 3)
+	if size := c.CacheSize(); size != 2 {
+		t.Errorf("size: %d, want 2", size)
+	}
+	if m.Exists(c.generateKey(lockKeyPrefix)) {
+		t.Error("the eviction lock is still held after Set")
+	}
+}
+
+// newRedlockNodes starts n miniredis nodes and returns their clients and servers.
+func newRedlockNodes(t *testing.T, n int) ([]*redis.Client, []*miniredis.Miniredis) {
+	t.Helper()
+	clients := make([]*redis.Client, n)
+	servers := make([]*miniredis.Miniredis, n)
+	for i := range clients {
+		clients[i], servers[i] = newTestClient(t)
+	}
+	return clients, servers
+}
+
+func TestRedlockIsExclusive(t *testing.T) {
+	clients, servers := newRedlockNodes(t, 3)
+	redlock := NewRedlock(clients, time.Second)
+
+	unlock, err := lockAttempt(redlock, "lock")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	for i, m := range servers {
+		if !m.Exists("lock") {
+			t.Errorf("node %d does not hold the lock", i)
+		}
+	}
+	if _, err := lockAttempt(redlock, "lock"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock of a held lock: %v, want context.DeadlineExceeded", err)
+	}
+
+	if err := unlock(); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	for i, m := range servers {
+		if m.Exists("lock") {
+			t.Errorf("node %d still holds the lock after unlock", i)
+		}
+	}
+}
+
+func TestRedlockNeedsAMajority(t *testing.T) {
+	clients, servers := newRedlockNodes(t, 3)
+	redlock := NewRedlock(clients, time.Second)
+
+	// The other node holding the lock leaves two of three, still a majority.
+	servers[0].Set("lock", "other holder")
+	unlock, err := lockAttempt(redlock, "lock")
+	if err != nil {
+		t.Fatalf("Lock with one node taken: %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Errorf("unlock: %v", err)
+	}
+
+	servers[1].Set("lock", "other holder")
+	if _, err := lockAttempt(redlock, "lock"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Lock with two nodes taken: %v, want context.DeadlineExceeded", err)
+	}
+	if got, _ := servers[2].Get("lock"); got != "" {
+		t.Errorf("a failed attempt left the lock on node 2: %q", got)
+	}
+}
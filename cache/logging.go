@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// LogCategory groups the log messages written on the path of every request, so high-traffic caches can
+// sample or rate limit them with WithLogSampling and WithLogRateLimit. Errors and rare events are always logged.
+type LogCategory string
+
+const (
+	// LogRead covers the lookups of reads before their outcome is known, the reads bypassing the cache with SkipCache or
+	// ForceRefresh, and the size checks.
+	LogRead LogCategory = "read"
+	// LogHit covers the messages of reads that found the user in the cache.
+	LogHit LogCategory = "hit"
+	// LogMiss covers the messages of reads that did not find the user, including expirations and pruning, and of the
+	// reads served directly while Redis is unavailable.
+	LogMiss LogCategory = "miss"
+	// LogWrite covers the messages of Set, AddKey and Delete.
+	LogWrite LogCategory = "write"
+	// LogEvict covers the messages of evictions and RemoveOldest.
+	LogEvict LogCategory = "evict"
+)
+
+// logLimit is the sampling rate and rate limit of a category, with the state to enforce them.
+type logLimit struct {
+	every      int64
+	perSecond  int
+	seen       int64
+	second     int64
+	logged     int
+	suppressed int64
+}
+
+// logLimiter decides which messages of each category are logged. It is shared by every copy of a cache.
+type logLimiter struct {
+	mu     sync.Mutex
+	limits map[LogCategory]*logLimit
+}
+
+// WithLogSampling logs one in n of the messages of category, e.g. WithLogSampling(LogHit, 100) logs every
+// hundredth cache hit. The first message of the category is always logged.
+func WithLogSampling(category LogCategory, n int) Option {
+	return func(o *options) {
+		o.logLimit(category).every = int64(n)
+	}
+}
+
+// WithLogRateLimit logs at most perSecond messages of category per second. Once a second has passed,
+// the number of messages dropped in the previous one is logged with the next message of the category.
+// Sampling applies first, so sampled out messages are not counted as dropped.
+func WithLogRateLimit(category LogCategory, perSecond int) Option {
+	return func(o *options) {
+		o.logLimit(category).perSecond = perSecond
+	}
+}
+
+// logLimit returns the limit of category, creating the limiter if needed.
+func (o *options) logLimit(category LogCategory) *logLimit {
+	if o.logs == nil {
+		o.logs = &logLimiter{limits: make(map[LogCategory]*logLimit)}
+	}
+	limit, ok := o.logs.limits[category]
+	if !ok {
+		limit = &logLimit{}
+		o.logs.limits[category] = limit
+	}
+	return limit
+}
+
+// allow reports whether a message of category may be logged at now, and how many messages of the category
+// the rate limit dropped in the last second it allowed a message, if that second is over.
+func (l *logLimiter) allow(category LogCategory, now time.Time) (bool, int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit, ok := l.limits[category]
+	if !ok {
+		return true, 0
+	}
+
+	limit.seen++
+	if limit.every > 1 && (limit.seen-1)%limit.every != 0 {
+		return false, 0
+	}
+	if limit.perSecond <= 0 {
+		return true, 0
+	}
+
+	var dropped int64
+	if second := now.Unix(); second != limit.second {
+		dropped = limit.suppressed
+		limit.second = second
+		limit.logged = 0
+		limit.suppressed = 0
+	}
+	if limit.logged >= limit.perSecond {
+		limit.suppressed++
+		return false, 0
+	}
+	limit.logged++
+	return true, dropped
+}
+
+// logFunc logs a message of a category, as options.logf does.
+type logFunc func(category LogCategory, format string, args ...interface{})
+
+// logAlways is the logFunc of the work not done for a cache, such as the periodic flush of a WriteBehind,
+// which logs every message.
+func logAlways(category LogCategory, format string, args ...interface{}) {
+	log.Printf(format, args...)
+}
+
+// logf logs a message of category, unless it is sampled out or over the rate limit of the category.
+func (o options) logf(category LogCategory, format string, args ...interface{}) {
+	if o.runtime != nil && o.runtime.quiet.Load() {
+		return
+	}
+	if o.logs == nil {
+		log.Printf(format, args...)
+		return
+	}
+
+	ok, dropped := o.logs.allow(category, time.Now())
+	if dropped > 0 {
+		log.Printf("Dropped %d %s log messages over the rate limit", dropped, category)
+	}
+	if ok {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// captureLogs returns the buffer the logs are written to until the end of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(io.Discard) })
+	return &buf
+}
+
+func TestMissesAreNotLoggedAsErrors(t *testing.T) {
+	ctx := context.Background()
+	caches := map[string]func(Client) func(string) (User, error){
+		"fifo": func(client Client) func(string) (User, error) {
+			c := NewFIFO(ctx, client, 3, "fifo")
+			return c.Get
+		},
+		"lru": func(client Client) func(string) (User, error) {
+			c := NewLRU(ctx, client, 3, "lru")
+			return c.Get
+		},
+		"lfu": func(client Client) func(string) (User, error) {
+			c := NewLFU(ctx, client, 3, "lfu")
+			return c.Get
+		},
+		"approx-lru": func(client Client) func(string) (User, error) {
+			c := NewApproxLRU(ctx, client, 3, 2, "approx")
+			return c.Get
+		},
+		"ttl": func(client Client) func(string) (User, error) {
+			c := NewTTL(ctx, client, time.Minute, "ttl")
+			return c.Get
+		},
+		"ttl-fields": func(client Client) func(string) (User, error) {
+			c := NewTTL(ctx, client, time.Minute, "ttl")
+			return func(id string) (User, error) { return c.GetFields(id, "name") }
+		},
+	}
+	for name, newCache := range caches {
+		t.Run(name, func(t *testing.T) {
+			client, _ := newTestClient(t)
+			get := newCache(client)
+			logs := captureLogs(t)
+
+			if _, err := get("1"); !errors.Is(err, redis.Nil) {
+				t.Fatalf("Get of a missing user: %v, want redis.Nil", err)
+			}
+			if strings.Contains(logs.String(), "Error") {
+				t.Errorf("a miss logged an error:\n%s", logs)
+			}
+		})
+	}
+}
+
+func TestLogSamplingAppliesToTheFieldReads(t *testing.T) {
+	for _, tt := range []struct {
+		opts []Option
+		want int
+	}{
+		{nil, 10},
+		{[]Option{WithLogSampling(LogRead, 1000)}, 0},
+	} {
+		client, _ := newTestClient(t)
+		c := NewLRU(context.Background(), client, 3, "lru", append(tt.opts, WithHashStorage())...)
+		setUsers(t, c.Set, nil, 1)
+		logs := captureLogs(t)
+
+		for range 10 {
+			if _, err := c.GetFields("1", "name"); err != nil {
+				t.Fatalf("GetFields: %v", err)
+			}
+		}
+		// The first read logged is the lookup of the first GetFields, so sampling leaves none of the field reads.
+		if n := strings.Count(logs.String(), "Getting fields"); n != tt.want {
+			t.Errorf("logged %d field reads with %d options, want %d", n, len(tt.opts), tt.want)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
@@ -15,19 +14,24 @@ import (
 // It uses a Redis sorted set to maintain the order of items by their last access time.
 type LRUCache struct {
 	ctx       context.Context
-	client    *redis.Client
+	client    Client
 	keyPrefix string
 	capacity  int
+	options
 }
 
 // NewLRU creates a new LRUCache with the given context, Redis client, capacity, and key prefix.
-func NewLRU(ctx context.Context, client *redis.Client, capacity int, keyPrefix string) LRUCache {
+func NewLRU(ctx context.Context, client Client, capacity int, keyPrefix string, opts ...Option) LRUCache {
 	log.Println("Creating new LRU cache with capacity:", capacity)
+	if err := LoadScripts(ctx, client); err != nil {
+		log.Printf("Failed to load scripts: %v", err)
+	}
 	return LRUCache{
 		ctx:       ctx,
 		client:    client,
 		capacity:  capacity,
 		keyPrefix: keyPrefix,
+		options:   clientOptions(client, opts),
 	}
 }
 
@@ -36,118 +40,274 @@ func NewLRU(ctx context.Context, client *redis.Client, capacity int, keyPrefix s
 // it fetches the user from the database, adds them to the cache, and then returns the user.
 // If the user is found in the cache (a cache hit), it returns the user directly.
 func (c *LRUCache) MakeRequest(id string) User {
-	log.Printf("Request received for user ID: %s", id)
+	user, _ := c.GetOrLoad(id)
+	return user
+}
+
+// GetOrLoad retrieves a user from the cache. If the user is not in the cache, it loads the user with the loader
+// and adds them to the cache. Unlike MakeRequest, it returns the error of the loader.
+// While the circuit breaker of the cache is open, the cache is bypassed and users are served from the loader,
+// or from the fallback cache if the cache was created with WithFallbackCache.
+// Pass SkipCache or ForceRefresh to change how a single call uses the cache.
+func (c *LRUCache) GetOrLoad(id string, opts ...CallOption) (User, error) {
+	c.logf(LogRead, "Request received for user ID: %s", id)
+	call := newCallOptions(opts)
+	if call.skipCache {
+		c.logf(LogRead, "Skipping cache for user ID: %s.", id)
+		return c.load(c.ctx, id)
+	}
+	if c.degraded() {
+		c.logf(LogMiss, "Redis is unavailable. Loading user ID: %s directly.", id)
+		return c.loadDegraded(c.ctx, id)
+	}
+	if call.forceRefresh {
+		c.logf(LogRead, "Forcing refresh of user ID: %s.", id)
+		return c.loadAndSet(id)
+	}
+
 	user, err := c.Get(id)
+	if timedOut(err) {
+		c.logf(LogMiss, "Timed out getting user ID: %s from Redis. Fetching from database.", id)
+		return c.loadAndSet(id)
+	}
 	if err != nil {
-		log.Printf("Cache miss for user ID: %s. Fetching from database.", id)
-		dbUser := getUserFromDb(id)
-		if err := c.Set(dbUser); err != nil {
-			log.Printf("Failed to write user ID: %s to cache: %v", id, err)
-		}
-		return dbUser
+		c.logf(LogMiss, "Cache miss for user ID: %s. Fetching from database.", id)
+		return c.coalesce(c.ctx, c.client, c.generateKey, id, c.loadAndSet, c.Get)
 	}
 
-	log.Printf("Cache hit for user ID: %s.", id)
-	return user
+	c.logf(LogHit, "Cache hit for user ID: %s.", id)
+	return user, nil
+}
+
+// loadAndSet loads a user with the loader and adds them to the cache. The user is returned even if they could not be cached.
+func (c *LRUCache) loadAndSet(id string) (User, error) {
+	dbUser, err := c.load(c.ctx, id)
+	if err != nil {
+		log.Printf("Failed to load user ID: %s: %v", id, err)
+		return User{}, err
+	}
+	// The user was read from the store, so write-behind must not write it back.
+	loaded := *c
+	loaded.fromStore = true
+	if err := loaded.Set(dbUser); err != nil {
+		log.Printf("Failed to write user ID: %s to cache: %v", id, err)
+	} else if err := c.recordWrite(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, dbUser.Id), SourceDatabase); err != nil {
+		log.Printf("Failed to record source of user ID: %s: %v", id, err)
+	}
+	return dbUser, nil
 }
 
 // Get retrieves a user from the cache by their ID.
 // If the user is found, it updates their recency and returns the user.
 func (c *LRUCache) Get(id string) (User, error) {
+	c, cancel := c.withTimeout(c.readTimeout)
+	defer cancel()
+
+	return c.get(id, func(cacheKey string) (User, error) {
+		return c.readReplicaUser(c.ctx, c.client, cacheKey, id)
+	})
+}
+
+// get retrieves a user from the cache with read, pruning the index if the value key no longer exists.
+func (c *LRUCache) get(id string, read func(cacheKey string) (User, error)) (User, error) {
+	defer c.counters.observe(opGet, time.Now())
 	cacheKey := c.generateKey(userPrefix, id)
-	log.Printf("Attempting to get user with cache key: %s", cacheKey)
+	c.logf(LogRead, "Attempting to get user with cache key: %s", cacheKey)
 
-	data, err := c.client.Get(c.ctx, cacheKey).Result()
+	var user User
+	err := c.withRetry(c.ctx, func() (err error) {
+		user, err = read(cacheKey)
+		return err
+	})
+	if err == redis.Nil {
+		c.counters.recordMiss()
+		c.notifyMiss(c.ctx, c.client, c.generateKey, cacheKey)
+		if err := c.pruneKey(cacheKey); err != nil {
+			log.Printf("Failed to prune key: %s from sorted set: %v", cacheKey, err)
+		}
+	}
 	if err != nil {
-		log.Printf("Error getting user with cache key: %s from Redis: %v", cacheKey, err)
+		if err != redis.Nil {
+			log.Printf("Error getting user with cache key: %s from Redis: %v", cacheKey, err)
+		}
 		return User{}, err
 	}
 
-	log.Printf("Successfully retrieved user with cache key: %s. Updating recency.", cacheKey)
+	c.logf(LogHit, "Successfully retrieved user with cache key: %s. Updating recency.", cacheKey)
 	if err := c.UpdateRecency(id); err != nil {
 		log.Printf("Failed to update recency for user ID: %s: %v", id, err)
 		return User{}, err
 	}
 
-	var user User
-	if err := json.Unmarshal([]byte(data), &user); err != nil {
-		log.Printf("Error unmarshalling user data for cache key: %s: %v", cacheKey, err)
-		return User{}, err
+	c.counters.recordHit()
+	c.notifyHit(c.ctx, c.client, c.generateKey, cacheKey)
+	if err := c.recordHit(c.ctx, c.client, c.generateKey, cacheKey); err != nil {
+		log.Printf("Failed to record hit for key: %s: %v", cacheKey, err)
 	}
 
 	return user, nil
 }
 
 // Set adds a user to the cache.
-// If the cache is full, it removes the oldest item before adding the new one.
+// If the user is already cached, its value is updated in place and it is marked as recently used.
+// The capacity check, the eviction of the oldest item and the write of the new one
+// are performed atomically by a single Lua script.
+// If the cache was created with WithLocker, the admission runs while holding the eviction lock.
 func (c *LRUCache) Set(user User) error {
-	log.Printf("Attempting to set user with ID: %s to cache.", user.Id)
-	currentSize := c.CacheSize()
-	if currentSize >= c.capacity {
-		log.Printf("Cache is full (size: %d, capacity: %d). Removing oldest item.", currentSize, c.capacity)
-		if err := c.RemoveOldest(); err != nil {
-			log.Printf("Failed to remove oldest item from cache: %v", err)
-			return err
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	defer c.counters.observe(opSet, time.Now())
+
+	err := c.withRetry(c.ctx, func() error {
+		return withLock(c.ctx, c.locker, c.generateKey(lockKeyPrefix), func() error {
+			return c.admit(user)
+		})
+	})
+	if err == nil {
+		c.counters.sets.Add(1)
+		c.notifyAdmit(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, user.Id))
+		err = c.enqueueWrite(c.ctx, c.generateKey(userPrefix, user.Id), user)
+		c.publishInvalidation(c.ctx, c.generateKey(userPrefix, user.Id))
+	}
+	return err
+}
+
+// admit adds a user to the cache. It is the critical section of Set that runs under the optional eviction lock.
+func (c *LRUCache) admit(user User) error {
+	c.logf(LogWrite, "Attempting to set user with ID: %s to cache.", user.Id)
+	a, err := c.admission(user)
+	if err != nil {
+		log.Printf("Error marshalling user data for ID: %s: %v", user.Id, err)
+		return err
+	}
+
+	start := time.Now()
+	reply, err := scripts.run(c.ctx, c.client, a.script, a.keys, a.args...).StringSlice()
+	if err != nil {
+		log.Printf("Error admitting key: %s to sorted set: %s: %v", a.cacheKey, a.keys[0], err)
+		return err
+	}
+	return c.admitted(c.ctx, c.client, c.generateKey, string(PolicyLRU), a, reply, start, SourceSet)
+}
+
+// admission prepares the call of the script admitting a user as the most recently used entry, evicting the least
+// recently used entries to make room.
+func (c *LRUCache) admission(user User) (admission, error) {
+	b, err := c.encodeValue(&user)
+	if err != nil {
+		return admission{}, err
+	}
+
+	cacheKey := c.generateKey(userPrefix, user.Id)
+	return admission{
+		value:    &user,
+		cacheKey: cacheKey,
+		script:   admitSortedSetScript,
+		keys:     []string{c.generateKey(cacheKeyPrefix), cacheKey, c.generateKey(versionKeyPrefix), c.generateKey(bytesKeyPrefix)},
+		args:     []interface{}{c.itemCapacity(c.capacity), c.recencyScore(), b, 1, c.storageMode(), c.maxBytes, c.measure, c.evictionFlags()},
+	}, nil
+}
+
+// batchAdmission prepares the call of the script admitting users as the most recently used entries, in order,
+// evicting the least recently used entries to make room for all of them.
+func (c *LRUCache) batchAdmission(users []User) (batchAdmission, error) {
+	values := []interface{}{c.itemCapacity(c.capacity), 1, c.storageMode(), c.maxBytes, c.measure, c.evictionFlags()}
+	score := c.recencyScore()
+	b := batchAdmission{users: users, cacheKeys: make([]string, len(users))}
+	for i := range users {
+		v, err := c.encodeValue(&users[i])
+		if err != nil {
+			log.Printf("Error marshalling user data for ID: %s: %v", users[i].Id, err)
+			return batchAdmission{}, err
 		}
+		b.cacheKeys[i] = c.generateKey(userPrefix, users[i].Id)
+		values = append(values, score+int64(i), v)
 	}
 
-	return c.AddKey(user)
+	b.script = admitSortedSetBatchScript
+	b.keys = append([]string{c.generateKey(cacheKeyPrefix), c.generateKey(versionKeyPrefix), c.generateKey(bytesKeyPrefix)}, b.cacheKeys...)
+	b.args = values
+	return b, nil
 }
 
 // Delete removes a key from the cache.
 func (c *LRUCache) Delete(key string) error {
-	log.Printf("Deleting key: %s from cache", key)
-	return c.client.Del(c.ctx, key).Err()
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+
+	c.logf(LogWrite, "Deleting key: %s from cache", key)
+	c.flushEvicted(c.ctx, key)
+	err := c.withRetry(c.ctx, func() error {
+		return c.client.Del(c.ctx, key).Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	c.publishInvalidation(c.ctx, key)
+	return c.dropEntries(c.ctx, c.client, c.generateKey, key)
+}
+
+// Key returns the value key of a user, as passed to Delete and to the callbacks of the cache.
+func (c *LRUCache) Key(id string) string {
+	return c.generateKey(userPrefix, id)
 }
 
 // CacheSize returns the current number of items in the cache.
 func (c *LRUCache) CacheSize() int {
 	key := c.generateKey(cacheKeyPrefix)
-	log.Printf("Getting cache size for key: %s", key)
+	c.logf(LogRead, "Getting cache size for key: %s", key)
 
 	size, err := c.client.ZCard(c.ctx, key).Result()
 	if err != nil {
 		log.Printf("Error getting cache size for key: %s. Error: %v", key, err)
 		return 0
 	}
-	log.Printf("Cache size for key: %s is: %d", key, size)
+	c.logf(LogRead, "Cache size for key: %s is: %d", key, size)
 	return int(size)
 }
 
 // AddKey adds a new user to the cache. It adds the user's data to a Redis key
 // and adds the key to the sorted set for LRU tracking.
+// The writes and the new version of the entry, see bumpVersion, are sent in a single MULTI/EXEC pipeline, costing
+// one network roundtrip.
 func (c *LRUCache) AddKey(user User) error {
 	listKey := c.generateKey(cacheKeyPrefix)
 	cacheKey := c.generateKey(userPrefix, user.Id)
-	log.Printf("Adding key: %s to list: %s", cacheKey, listKey)
+	c.logf(LogWrite, "Adding key: %s to list: %s", cacheKey, listKey)
 
-	if err := c.client.ZAdd(c.ctx, listKey, redis.Z{
-		Member: cacheKey,
-		Score:  float64(time.Now().Unix()),
-	}).Err(); err != nil {
+	c.logf(LogWrite, "Setting value for key: %s", cacheKey)
+	_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(c.ctx, listKey, redis.Z{
+			Member: cacheKey,
+			Score:  float64(c.recencyScore()),
+		})
+		if err := c.writeValue(c.ctx, pipe, cacheKey, &user, 0); err != nil {
+			return err
+		}
+		return bumpVersionScript.Eval(c.ctx, pipe, []string{c.generateKey(versionKeyPrefix), cacheKey}).Err()
+	})
+	if err != nil {
 		log.Printf("Error adding key: %s to sorted set: %s: %v", cacheKey, listKey, err)
 		return err
 	}
 
-	b, err := json.Marshal(&user)
-	if err != nil {
-		log.Printf("Error marshalling user data for ID: %s: %v", user.Id, err)
+	if err := c.accountBytes(c.ctx, c.client, c.generateKey(bytesKeyPrefix), cacheKey); err != nil {
 		return err
 	}
 
-	log.Printf("Setting value for key: %s", cacheKey)
-	return c.client.Set(c.ctx, cacheKey, b, 0).Err()
+	return c.trackEntry(c.ctx, c.client, c.generateKey, cacheKey, &user, SourceSet)
 }
 
 // UpdateRecency updates the access time of a user in the cache, marking them as recently used.
 func (c *LRUCache) UpdateRecency(id string) error {
 	listKey := c.generateKey(cacheKeyPrefix)
 	cacheKey := c.generateKey(userPrefix, id)
-	log.Printf("Updating recency for key: %s in list: %s", cacheKey, listKey)
+	c.logf(LogRead, "Updating recency for key: %s in list: %s", cacheKey, listKey)
 
 	if err := c.client.ZAdd(c.ctx, listKey, redis.Z{
 		Member: cacheKey,
-		Score:  float64(time.Now().Unix()),
+		Score:  float64(c.recencyScore()),
 	}).Err(); err != nil {
 		log.Printf("Error updating recency for key: %s: %v", cacheKey, err)
 		return err
@@ -157,30 +317,51 @@ func (c *LRUCache) UpdateRecency(id string) error {
 }
 
 // RemoveOldest removes the least recently used item from the cache.
+// The index member and its value key are removed atomically by a Lua script.
 func (c *LRUCache) RemoveOldest() error {
+	start := time.Now()
 	listKey := c.generateKey(cacheKeyPrefix)
-	log.Printf("Removing oldest item from list: %s", listKey)
+	c.logf(LogEvict, "Removing oldest item from list: %s", listKey)
 
-	removed, err := c.client.ZPopMin(c.ctx, listKey, 1).Result()
+	reply, err := scripts.run(c.ctx, c.client, evictSortedSetScript, []string{listKey, c.generateKey(versionKeyPrefix), c.generateKey(bytesKeyPrefix)}, c.storageMode(), c.evictionFlags()).StringSlice()
+	if err == redis.Nil {
+		log.Println("No items to remove from cache.")
+		return fmt.Errorf("no items to remove from cache")
+	}
 	if err != nil {
 		log.Printf("Error removing oldest item from sorted set: %s: %v", listKey, err)
 		return err
 	}
 
-	if len(removed) == 0 {
-		log.Println("No items to remove from cache.")
-		return fmt.Errorf("no items to remove from cache")
+	removedMember := reply[0]
+	c.logf(LogEvict, "Popped and deleted oldest member: %s", removedMember)
+	c.counters.observe(opEvict, start)
+	c.counters.evictions.Add(1)
+	e := eviction{key: removedMember, reason: EvictCapacity, value: reply[1], trace: reply[2]}
+	c.auditEvictions(c.ctx, c.client, c.generateKey, string(PolicyLRU), []eviction{e})
+	c.flushEvicted(c.ctx, e.key)
+	if err := c.dropEntries(c.ctx, c.client, c.generateKey, removedMember); err != nil {
+		return err
 	}
+	c.notifyEvict(c.ctx, c.client, c.generateKey, e)
+	return nil
+}
 
-	removedMember := removed[0].Member.(string)
-	log.Printf("Popped oldest member: %s", removedMember)
+// pruneKey removes a key whose value no longer exists, e.g. because it expired, from the sorted set, unless it was
+// written again in the meantime, see pruneScript.
+func (c *LRUCache) pruneKey(cacheKey string) error {
+	c.logf(LogMiss, "Pruning key: %s from sorted set: %s", cacheKey, c.generateKey(cacheKeyPrefix))
+	pruned, err := pruneIndex(c.ctx, c.client, c.generateKey, cacheKey, "zset")
+	if err != nil || !pruned {
+		return err
+	}
 
-	return c.Delete(removedMember)
+	return c.dropEntries(c.ctx, c.client, c.generateKey, cacheKey)
 }
 
 // generateKey creates a Redis key by joining the key prefix and other key parts with a colon.
 func (c *LRUCache) generateKey(keys ...string) string {
-	allKeys := []string{c.keyPrefix}
+	allKeys := []string{c.namespace(c.keyPrefix)}
 	allKeys = append(allKeys, keys...)
 
 	return strings.Join(allKeys, ":")
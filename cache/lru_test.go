@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	client, _ := newTestClient(t)
+	clock := testClock()
+	c := NewLRU(context.Background(), client, 3, "lru", WithClock(clock))
+
+	setUsers(t, c.Set, clock, 1, 2, 3)
+	clock.Advance(time.Millisecond)
+	if _, err := c.Get("1"); err != nil {
+		t.Fatalf("Get(1): %v", err)
+	}
+	setUsers(t, c.Set, clock, 4)
+	assertCached(t, client, c.Key, 1, 3, 4)
+
+	setUsers(t, c.Set, clock, 5)
+	assertCached(t, client, c.Key, 1, 4, 5)
+}
+
+func TestLRUEnforcesCapacity(t *testing.T) {
+	client, _ := newTestClient(t)
+	clock := testClock()
+	c := NewLRU(context.Background(), client, 3, "lru", WithClock(clock))
+
+	for i := 1; i <= 10; i++ {
+		setUsers(t, c.Set, clock, i)
+		if size, want := c.CacheSize(), min(i, 3); size != want {
+			t.Fatalf("size after %d users: %d, want %d", i, size, want)
+		}
+	}
+	assertCached(t, client, c.Key, 8, 9, 10)
+}
+
+func TestLRURemoveOldest(t *testing.T) {
+	client, _ := newTestClient(t)
+	clock := testClock()
+	c := NewLRU(context.Background(), client, 3, "lru", WithClock(clock))
+
+	if err := c.RemoveOldest(); err == nil {
+		t.Fatal("RemoveOldest of an empty cache succeeded")
+	}
+	setUsers(t, c.Set, clock, 1, 2)
+	if err := c.RemoveOldest(); err != nil {
+		t.Fatalf("RemoveOldest: %v", err)
+	}
+	assertCached(t, client, c.Key, 2)
+}
+
+func TestLRUErrors(t *testing.T) {
+	client, m := newTestClient(t)
+	loadErr := errors.New("database down")
+	c := NewLRU(context.Background(), client, 3, "lru", WithMaxValueSize(64), WithLoader(func(ctx context.Context, id string) (User, error) {
+		return User{}, loadErr
+	}))
+
+	if _, err := c.Get("1"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get of a missing user: %v, want redis.Nil", err)
+	}
+	if _, err := c.GetOrLoad("1"); !errors.Is(err, loadErr) {
+		t.Errorf("GetOrLoad with a failing loader: %v, want %v", err, loadErr)
+	}
+	if size := c.CacheSize(); size != 0 {
+		t.Errorf("size after a failed load: %d, want 0", size)
+	}
+	big := User{Id: "1", Name: string(make([]byte, 100))}
+	if err := c.Set(big); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Set of a large value: %v, want ErrValueTooLarge", err)
+	}
+
+	m.Close()
+	if _, err := c.Get("1"); err == nil || errors.Is(err, redis.Nil) {
+		t.Errorf("Get with Redis down: %v, want a connection error", err)
+	}
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// bytesKeyPrefix names the hash that records the size of every entry when a byte budget is configured.
+// The sum of all sizes is kept in its bytesTotalField field.
+const bytesKeyPrefix = "cache_bytes"
+const bytesTotalField = "total"
+
+// Ways of measuring the size of an entry, passed to the Lua scripts.
+const (
+	// measureMemory measures entries with MEMORY USAGE, falling back to measureLength if the command is unavailable.
+	measureMemory = "memory"
+	// measureLength measures entries by the length of their serialized value.
+	measureLength = "length"
+)
+
+// trackBytes defines Lua functions that keep the size hash of a cache up to date, see bytesKeyPrefix.
+const trackBytes = `
+local function value_size(value_key, measure)
+	if measure == '` + measureMemory + `' then
+		local usage = redis.pcall('MEMORY', 'USAGE', value_key)
+		if type(usage) == 'number' then
+			return usage
+		end
+	end
+	local value_type = redis.call('TYPE', value_key).ok
+	if value_type == 'hash' then
+		local size = 0
+		for _, part in ipairs(redis.call('HGETALL', value_key)) do
+			size = size + string.len(part)
+		end
+		return size
+	end
+	if value_type == 'ReJSON-RL' then
+		return string.len(redis.call('JSON.GET', value_key))
+	end
+	return redis.call('STRLEN', value_key)
+end
+local function account_bytes(bytes_key, value_key, measure)
+	local size = value_size(value_key, measure)
+	local previous = tonumber(redis.call('HGET', bytes_key, value_key) or '0')
+	redis.call('HSET', bytes_key, value_key, size)
+	return redis.call('HINCRBY', bytes_key, '` + bytesTotalField + `', size - previous)
+end
+local function release_bytes(bytes_key, value_key)
+	local previous = redis.call('HGET', bytes_key, value_key)
+	if previous then
+		redis.call('HDEL', bytes_key, value_key)
+		redis.call('HINCRBY', bytes_key, '` + bytesTotalField + `', -tonumber(previous))
+	end
+end
+local function total_bytes(bytes_key)
+	return tonumber(redis.call('HGET', bytes_key, '` + bytesTotalField + `') or '0')
+end
+`
+
+// accountBytesScript records the current size of a value key in the size hash.
+//
+// KEYS[1]: the size hash
+// KEYS[2]: the value key
+// ARGV[1]: how to measure the entry, see measureMemory and measureLength
+//
+// It returns the total size of the cache.
+var accountBytesScript = scripts.register(trackBytes + `
+return account_bytes(KEYS[1], KEYS[2], ARGV[1])
+`)
+
+// releaseBytesScript removes value keys from the size hash and subtracts their sizes from the total.
+//
+// KEYS[1]: the size hash
+// ARGV: the value keys to release
+var releaseBytesScript = scripts.register(trackBytes + `
+for _, value_key in ipairs(ARGV) do
+	release_bytes(KEYS[1], value_key)
+end
+return total_bytes(KEYS[1])
+`)
+
+// WithMemoryCapacity expresses the capacity of the cache in bytes rather than in items:
+// the size of every entry is measured with MEMORY USAGE and entries are evicted according to the cache's policy
+// until the total fits in maxBytes, so the cache respects an actual RAM budget. The item capacity passed to the
+// constructor is ignored. An entry larger than maxBytes on its own is still admitted, evicting everything else;
+// combine with WithMaxValueSize to reject it instead.
+func WithMemoryCapacity(maxBytes int64) Option {
+	return func(o *options) {
+		o.maxBytes = maxBytes
+		o.measure = measureMemory
+		o.bytesOnly = true
+	}
+}
+
+// WithMaxBytes caps the total serialized size of the entries in the cache at maxBytes,
+// as a complement to the item capacity passed to the constructor: entries are evicted according to
+// the cache's policy until both limits hold. Sizes are measured by serialized length, which is cheaper
+// than MEMORY USAGE and available on every Redis deployment, but excludes Redis's per-key overhead.
+func WithMaxBytes(maxBytes int64) Option {
+	return func(o *options) {
+		o.maxBytes = maxBytes
+		o.measure = measureLength
+		o.bytesOnly = false
+	}
+}
+
+// itemCapacity returns the item capacity passed to the admission scripts, where 0 means no item limit.
+func (o options) itemCapacity(capacity int) int {
+	if o.bytesOnly {
+		return 0
+	}
+	return o.currentCapacity(capacity)
+}
+
+// accountBytes records the size of a value key written outside of the admission scripts.
+func (o options) accountBytes(ctx context.Context, client Client, bytesKey, cacheKey string) error {
+	if o.maxBytes <= 0 {
+		return nil
+	}
+	return scripts.run(ctx, client, accountBytesScript, []string{bytesKey, cacheKey}, o.measure).Err()
+}
+
+// releaseBytes forgets the sizes of value keys removed outside of the admission and eviction scripts.
+func (o options) releaseBytes(ctx context.Context, client Client, bytesKey string, cacheKeys ...string) error {
+	if o.maxBytes <= 0 || len(cacheKeys) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(cacheKeys))
+	for i, key := range cacheKeys {
+		args[i] = key
+	}
+	return scripts.run(ctx, client, releaseBytesScript, []string{bytesKey}, args...).Err()
+}
+
+// usedBytes reads the total size recorded in a size hash.
+func usedBytes(ctx context.Context, client Client, bytesKey string) (int64, error) {
+	log.Printf("Getting used bytes for key: %s", bytesKey)
+	total, err := client.HGet(ctx, bytesKey, bytesTotalField).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return total, err
+}
+
+// UsedBytes returns the total size of the entries in the cache. It is only tracked when a byte capacity is configured.
+func (c *FIFOCache) UsedBytes() (int64, error) {
+	return usedBytes(c.ctx, c.client, c.generateKey(bytesKeyPrefix))
+}
+
+// UsedBytes returns the total size of the entries in the cache. It is only tracked when a byte capacity is configured.
+func (c *LRUCache) UsedBytes() (int64, error) {
+	return usedBytes(c.ctx, c.client, c.generateKey(bytesKeyPrefix))
+}
+
+// UsedBytes returns the total size of the entries in the cache. It is only tracked when a byte capacity is configured.
+func (c *LFUCache) UsedBytes() (int64, error) {
+	return usedBytes(c.ctx, c.client, c.generateKey(bytesKeyPrefix))
+}
+
+// UsedBytes returns the total size of the entries in the cache. It is only tracked when a byte capacity is configured.
+func (c *ApproxLRUCache) UsedBytes() (int64, error) {
+	return usedBytes(c.ctx, c.client, c.generateKey(bytesKeyPrefix))
+}
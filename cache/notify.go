@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"context"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Reasons of the invalidations of NotifyUpdated and NotifyDeleted, logged to the event stream.
+const (
+	// InvalidateUpdated marks an entry invalidated because its user was updated in the source of truth.
+	InvalidateUpdated = "updated"
+	// InvalidateDeleted marks an entry invalidated because its user was deleted from the source of truth.
+	InvalidateDeleted = "deleted"
+)
+
+// Notifiable is a cache told about the changes to the source of truth made behind its back, so it invalidates the
+// users concerned. Every cache type and TieredCache implement it.
+type Notifiable interface {
+	NotifyUpdated(id string) error
+	NotifyDeleted(id string) error
+}
+
+// invalidate removes the value key of a user changed in the source of truth, then its index member and metadata
+// with remove, and publishes the key on the invalidation bus, so every instance drops its copy. Unlike Delete, the
+// writes of the user still queued for write-behind are discarded rather than saved, since they would overwrite
+// the change.
+func (o options) invalidate(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, cacheKey, reason string, remove func(cacheKey string) error) error {
+	log.Printf("Key: %s was %s in the source of truth. Invalidating.", cacheKey, reason)
+	if err := o.discardQueued(ctx, cacheKey); err != nil {
+		return err
+	}
+	err := o.withRetry(ctx, func() error {
+		return client.Del(ctx, cacheKey).Err()
+	})
+	if err != nil {
+		log.Printf("Error invalidating key: %s: %v", cacheKey, err)
+		return err
+	}
+	if err := remove(cacheKey); err != nil {
+		log.Printf("Error removing key: %s from index: %v", cacheKey, err)
+		return err
+	}
+
+	o.logEvent(ctx, client, generateKey, EventInvalidate, cacheKey, reason)
+	o.publishInvalidation(ctx, cacheKey)
+	return nil
+}
+
+// NotifyUpdated tells the cache that the user with id was updated in the source of truth. Its entry and index member
+// are removed, and every instance sharing an invalidation bus drops its copy, so the next read loads the new record.
+func (c *FIFOCache) NotifyUpdated(id string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.invalidate(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, id), InvalidateUpdated, c.pruneKey)
+}
+
+// NotifyDeleted tells the cache that the user with id was deleted from the source of truth. Its entry and index
+// member are removed, and every instance sharing an invalidation bus drops its copy.
+func (c *FIFOCache) NotifyDeleted(id string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.invalidate(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, id), InvalidateDeleted, c.pruneKey)
+}
+
+// NotifyUpdated tells the cache that the user with id was updated in the source of truth. Its entry and index member
+// are removed, and every instance sharing an invalidation bus drops its copy, so the next read loads the new record.
+func (c *LRUCache) NotifyUpdated(id string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.invalidate(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, id), InvalidateUpdated, c.pruneKey)
+}
+
+// NotifyDeleted tells the cache that the user with id was deleted from the source of truth. Its entry and index
+// member are removed, and every instance sharing an invalidation bus drops its copy.
+func (c *LRUCache) NotifyDeleted(id string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.invalidate(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, id), InvalidateDeleted, c.pruneKey)
+}
+
+// NotifyUpdated tells the cache that the user with id was updated in the source of truth. Its entry and index member
+// are removed, and every instance sharing an invalidation bus drops its copy, so the next read loads the new record.
+func (c *LFUCache) NotifyUpdated(id string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.invalidate(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, id), InvalidateUpdated, c.pruneKey)
+}
+
+// NotifyDeleted tells the cache that the user with id was deleted from the source of truth. Its entry and index
+// member are removed, and every instance sharing an invalidation bus drops its copy.
+func (c *LFUCache) NotifyDeleted(id string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.invalidate(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, id), InvalidateDeleted, c.pruneKey)
+}
+
+// NotifyUpdated tells the cache that the user with id was updated in the source of truth. Its entry and index member
+// are removed, and every instance sharing an invalidation bus drops its copy, so the next read loads the new record.
+func (c *ApproxLRUCache) NotifyUpdated(id string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.invalidate(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, id), InvalidateUpdated, c.pruneKey)
+}
+
+// NotifyDeleted tells the cache that the user with id was deleted from the source of truth. Its entry and index
+// member are removed, and every instance sharing an invalidation bus drops its copy.
+func (c *ApproxLRUCache) NotifyDeleted(id string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.invalidate(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, id), InvalidateDeleted, c.pruneKey)
+}
+
+// NotifyUpdated tells the cache that the user with id was updated in the source of truth. Its entry and expiry are
+// removed, and every instance sharing an invalidation bus drops its copy, so the next read loads the new record.
+func (c *TTLCache) NotifyUpdated(id string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.invalidate(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, id), InvalidateUpdated, c.dropExpiry(id))
+}
+
+// NotifyDeleted tells the cache that the user with id was deleted from the source of truth. Its entry and expiry are
+// removed, and every instance sharing an invalidation bus drops its copy.
+func (c *TTLCache) NotifyDeleted(id string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.invalidate(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, id), InvalidateDeleted, c.dropExpiry(id))
+}
+
+// dropExpiry returns a function removing the soft and hard expiry and the metadata of the entry of a user.
+func (c *TTLCache) dropExpiry(id string) func(cacheKey string) error {
+	return func(cacheKey string) error {
+		if err := c.client.Del(c.ctx, c.generateKey(softExpiryKeyPrefix, id)).Err(); err != nil {
+			return err
+		}
+		return c.dropEntries(c.ctx, c.client, c.generateKey, cacheKey)
+	}
+}
+
+// NotifyUpdated drops the user with id from the L1 and invalidates it in the L2 with its NotifyUpdated, or with
+// Delete if the L2 is not Notifiable.
+func (c *TieredCache) NotifyUpdated(id string) error {
+	c.l1.Delete(c.l2.Key(id))
+	if n, ok := c.l2.(Notifiable); ok {
+		return n.NotifyUpdated(id)
+	}
+	return c.l2.Delete(c.l2.Key(id))
+}
+
+// NotifyDeleted drops the user with id from the L1 and invalidates it in the L2 with its NotifyDeleted, or with
+// Delete if the L2 is not Notifiable.
+func (c *TieredCache) NotifyDeleted(id string) error {
+	c.l1.Delete(c.l2.Key(id))
+	if n, ok := c.l2.(Notifiable); ok {
+		return n.NotifyDeleted(id)
+	}
+	return c.l2.Delete(c.l2.Key(id))
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newTestBus creates an InvalidationBus on channel, closed at the end of the test.
+func newTestBus(t *testing.T, client Client, channel string) *InvalidationBus {
+	t.Helper()
+	bus, err := NewInvalidationBus(context.Background(), client, channel)
+	if err != nil {
+		t.Fatalf("NewInvalidationBus: %v", err)
+	}
+	t.Cleanup(func() { bus.Close() })
+	return bus
+}
+
+// receiveKey returns the next key delivered on keys, failing the test after a second.
+func receiveKey(t *testing.T, keys <-chan string) string {
+	t.Helper()
+	select {
+	case key := <-keys:
+		return key
+	case <-time.After(time.Second):
+		t.Fatal("no invalidation was delivered")
+		return ""
+	}
+}
+
+func TestNotifyInvalidates(t *testing.T) {
+	for name, newCache := range taggedCaches {
+		t.Run(name, func(t *testing.T) {
+			client, _ := newTestClient(t)
+			c := newCache(client)
+			n, ok := c.(Notifiable)
+			if !ok {
+				t.Fatalf("%T is not Notifiable", c)
+			}
+			setUsers(t, c.Set, nil, 1, 2, 3)
+
+			if err := n.NotifyUpdated("1"); err != nil {
+				t.Fatalf("NotifyUpdated: %v", err)
+			}
+			if err := n.NotifyDeleted("2"); err != nil {
+				t.Fatalf("NotifyDeleted: %v", err)
+			}
+			for id, cached := range map[string]bool{"1": false, "2": false, "3": true} {
+				if _, err := c.Get(id); cached && err != nil || !cached && !errors.Is(err, redis.Nil) {
+					t.Errorf("Get(%s) after the notifications: %v, cached %v", id, err, cached)
+				}
+			}
+		})
+	}
+}
+
+func TestNotifyDiscardsQueuedWrites(t *testing.T) {
+	client, _ := newTestClient(t)
+	store := &recordingStore{}
+	wb := newTestWriteBehind(t, store)
+	c := NewLRU(context.Background(), client, 10, "lru", WithWriteBehind(wb))
+	setUsers(t, c.Set, nil, 1, 2)
+
+	// The queued write of user 1 is older than the update, so it must not be saved over it.
+	if err := c.NotifyUpdated("1"); err != nil {
+		t.Fatalf("NotifyUpdated: %v", err)
+	}
+	if err := wb.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	assertSaved(t, store, testUser(2))
+}
+
+func TestInvalidationBusDeliversToTheOtherInstances(t *testing.T) {
+	client, _ := newTestClient(t)
+	own, other := newTestBus(t, client, "invalidations"), newTestBus(t, client, "invalidations")
+	ownKeys, otherKeys := make(chan string, 10), make(chan string, 10)
+	own.OnInvalidate(func(key string) { ownKeys <- key })
+	other.OnInvalidate(func(key string) { otherKeys <- key })
+	c := NewLRU(context.Background(), client, 10, "lru", WithInvalidationBus(own))
+
+	setUsers(t, c.Set, nil, 1)
+	if key := receiveKey(t, otherKeys); key != c.Key("1") {
+		t.Errorf("invalidated key after Set: %s, want %s", key, c.Key("1"))
+	}
+	if err := c.NotifyDeleted("1"); err != nil {
+		t.Fatalf("NotifyDeleted: %v", err)
+	}
+	if key := receiveKey(t, otherKeys); key != c.Key("1") {
+		t.Errorf("invalidated key after NotifyDeleted: %s, want %s", key, c.Key("1"))
+	}
+
+	if err := other.Publish(context.Background(), "external"); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	// The keys the cache published were not delivered to its own bus, so the first key it receives is the other one.
+	if key := receiveKey(t, ownKeys); key != "external" {
+		t.Errorf("a bus received its own invalidation of key: %s", key)
+	}
+}
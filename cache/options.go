@@ -0,0 +1,144 @@
+package cache
+
+import "time"
+
+// Option configures optional behavior of a cache. Options are passed to the cache constructors.
+type Option func(*options)
+
+// options holds the optional settings shared by all cache types.
+type options struct {
+	codec         Codec
+	compression   Compression
+	threshold     int
+	locker        Locker
+	hashTag       bool
+	coordinated   bool
+	storage       string
+	maxSize       int
+	maxBytes      int64
+	measure       string
+	bytesOnly     bool
+	indexes       []string
+	tags          bool
+	keyring       *Keyring
+	schemaVersion int
+	migrate       Migration
+	recordInfo    bool
+	loader        Loader
+	batchLoader   BatchLoader
+	maxStale      time.Duration
+	staleIfError  time.Duration
+	beta          float64
+	refreshAhead  time.Duration
+	breaker       *CircuitBreaker
+	retry         RetryPolicy
+	fallback      *fallbackCache
+	lease         time.Duration
+	readTimeout   time.Duration
+	writeTimeout  time.Duration
+	counters      *counters
+	onHit         []func(key string)
+	onMiss        []func(key string)
+	onAdmit       []func(key string)
+	onEvict       []func(key string, value User, reason string)
+	onExpire      []func(key string)
+	eventLogLen   int64
+	traces        *traceRing
+	topK          int
+	auditLen      int64
+	logs          *logLimiter
+	clock         Clock
+	rand          *lockedRand
+	replica       Client
+	bus           *InvalidationBus
+	notify        bool
+	runtime       *runtimeSettings
+	writeBehind   *WriteBehind
+	fromStore     bool
+}
+
+// newOptions applies opts on top of the defaults.
+func newOptions(opts []Option) options {
+	o := options{
+		codec:    JSONCodec{},
+		counters: newCounters(),
+		runtime:  &runtimeSettings{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.fallback != nil && o.breaker != nil {
+		o.breaker.addFallback(o.fallback)
+	}
+	if o.schemaVersion != 0 || o.migrate != nil {
+		o.codec = schemaCodec{codec: o.codec, version: o.schemaVersion, migrate: o.migrate}
+	}
+	if o.compression != 0 {
+		o.codec = NewCompressingCodec(o.codec, o.compression, o.threshold)
+	}
+	if o.keyring != nil {
+		o.codec = NewEncryptingCodec(o.codec, o.keyring)
+	}
+	return o
+}
+
+// WithCodec sets the codec used to serialize cached values. The default is JSONCodec.
+// Every application instance sharing a cache must use the same codec.
+func WithCodec(codec Codec) Option {
+	return func(o *options) {
+		o.codec = codec
+	}
+}
+
+// WithCompression compresses serialized values of at least threshold bytes with the given algorithm.
+// Entries written before compression was enabled, or below the threshold, are still read transparently.
+func WithCompression(compression Compression, threshold int) Option {
+	return func(o *options) {
+		o.compression = compression
+		o.threshold = threshold
+	}
+}
+
+// WithMaxValueSize rejects values whose serialized size exceeds maxSize bytes with ErrValueTooLarge,
+// so one huge record cannot dominate a small cache. The size is measured after compression;
+// in hash storage it is the total length of the field names and values.
+func WithMaxValueSize(maxSize int) Option {
+	return func(o *options) {
+		o.maxSize = maxSize
+	}
+}
+
+// WithLocker makes the cache hold a lock from locker around its evict-and-admit critical section,
+// so application instances sharing the same key prefix do not evict concurrently.
+func WithLocker(locker Locker) Option {
+	return func(o *options) {
+		o.locker = locker
+	}
+}
+
+// WithHashTag wraps the key prefix in braces, e.g. {lru}:cache_key, so that Redis Cluster hashes
+// every key of the cache to the same slot. This is required for the Lua scripts and transactions
+// the caches use to work against a cluster.
+func WithHashTag() Option {
+	return func(o *options) {
+		o.hashTag = true
+	}
+}
+
+// WithCoordinatedEviction makes caches whose eviction would otherwise take several roundtrips,
+// such as the approximated LRU cache, admit and evict through a single Lua script. Application instances
+// sharing the cache then coordinate through Redis alone: the cache never exceeds its capacity, and every
+// eviction increments an epoch counter that can be read with EvictionEpoch.
+func WithCoordinatedEviction() Option {
+	return func(o *options) {
+		o.coordinated = true
+	}
+}
+
+// namespace returns the first part of every key of a cache, wrapped in a hash tag if WithHashTag was given.
+func (o options) namespace(keyPrefix string) string {
+	if o.hashTag {
+		return "{" + keyPrefix + "}"
+	}
+	return keyPrefix
+}
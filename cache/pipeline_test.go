@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// roundtripCounter is a go-redis hook counting the roundtrips to the server: one per command sent alone and one per
+// pipeline or transaction, whatever its number of commands.
+type roundtripCounter struct {
+	roundtrips atomic.Int64
+}
+
+func (h *roundtripCounter) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *roundtripCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.roundtrips.Add(1)
+		return next(ctx, cmd)
+	}
+}
+
+func (h *roundtripCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		h.roundtrips.Add(1)
+		return next(ctx, cmds)
+	}
+}
+
+// countRoundtrips returns a client of a new miniredis server counting its roundtrips.
+func countRoundtrips(t testing.TB) (*redis.Client, *roundtripCounter) {
+	t.Helper()
+	client, _ := newTestClient(t)
+	counter := &roundtripCounter{}
+	client.AddHook(counter)
+	return client, counter
+}
+
+// addKeyCaches creates every cache type with an AddKey on client.
+var addKeyCaches = map[string]func(client Client) func(User) error{
+	"fifo": func(client Client) func(User) error {
+		c := NewFIFO(context.Background(), client, 1000, "fifo")
+		return c.AddKey
+	},
+	"lru": func(client Client) func(User) error {
+		c := NewLRU(context.Background(), client, 1000, "lru")
+		return c.AddKey
+	},
+	"lfu": func(client Client) func(User) error {
+		c := NewLFU(context.Background(), client, 1000, "lfu")
+		return c.AddKey
+	},
+	"approx-lru": func(client Client) func(User) error {
+		c := NewApproxLRU(context.Background(), client, 1000, 5, "approx")
+		return c.AddKey
+	},
+}
+
+func TestAddKeyTakesOneRoundtrip(t *testing.T) {
+	for name, newCache := range addKeyCaches {
+		t.Run(name, func(t *testing.T) {
+			client, counter := countRoundtrips(t)
+			addKey := newCache(client)
+			// The first write may dial a connection, whose handshake goes through the hook.
+			if err := addKey(testUser(0)); err != nil {
+				t.Fatalf("AddKey(0): %v", err)
+			}
+
+			for i := 1; i <= 3; i++ {
+				before := counter.roundtrips.Load()
+				if err := addKey(testUser(i)); err != nil {
+					t.Fatalf("AddKey(%d): %v", i, err)
+				}
+				if n := counter.roundtrips.Load() - before; n != 1 {
+					t.Errorf("AddKey(%d) took %d roundtrips, want 1", i, n)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkAddKey measures AddKey on a miniredis server, reporting its roundtrips per operation.
+func BenchmarkAddKey(b *testing.B) {
+	for _, name := range []string{"fifo", "lru", "lfu", "approx-lru"} {
+		b.Run(name, func(b *testing.B) {
+			client, counter := countRoundtrips(b)
+			addKey := addKeyCaches[name](client)
+			user := testUser(1)
+			if err := addKey(user); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			counter.roundtrips.Store(0)
+			for n := 0; n < b.N; n++ {
+				if err := addKey(user); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(counter.roundtrips.Load())/float64(b.N), "roundtrips/op")
+		})
+	}
+}
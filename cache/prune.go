@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// pruneScript removes a value key from the index, the version hash and the size hash of its cache, only if the value
+// key no longer exists. The check and the removal are atomic, so an entry admitted again by another writer between
+// a miss and its prune keeps its index member.
+//
+// KEYS[1]: the index
+// KEYS[2]: the value key to prune
+// KEYS[3]: the version hash
+// KEYS[4]: the size hash
+// KEYS[5]: the membership set of a list index, or the list itself
+// ARGV[1]: the type of the index: "zset", "list" or "hash"
+//
+// It returns 1 if the value key was pruned, or 0 if it exists.
+var pruneScript = scripts.register(trackBytes + `
+if redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+if ARGV[1] == 'zset' then
+	redis.call('ZREM', KEYS[1], KEYS[2])
+elseif ARGV[1] == 'list' then
+	redis.call('LREM', KEYS[1], 0, KEYS[2])
+	redis.call('SREM', KEYS[5], KEYS[2])
+else
+	redis.call('HDEL', KEYS[1], KEYS[2])
+end
+redis.call('HDEL', KEYS[3], KEYS[2])
+release_bytes(KEYS[4], KEYS[2])
+return 1
+`)
+
+// pruneIndex removes a value key that no longer exists from the index of indexType, its version and its size, see
+// pruneScript. It returns whether the key was pruned.
+func pruneIndex(ctx context.Context, client redis.Scripter, generateKey func(...string) string, cacheKey, indexType string) (bool, error) {
+	keys := []string{generateKey(cacheKeyPrefix), cacheKey, generateKey(versionKeyPrefix), generateKey(bytesKeyPrefix), generateKey(memberKeyPrefix)}
+	pruned, err := scripts.run(ctx, client, pruneScript, keys, indexType).Int()
+	return pruned == 1, err
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// lockedRand is a random source safe for concurrent use.
+type lockedRand struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// WithSeed seeds the random decisions the cache makes in the client: the jitter of the retry delays
+// and the early refreshes of WithEarlyRefresh, so that simulations and benchmarks can be repeated.
+// The sampling of the approximated LRU happens in Redis with HRANDFIELD and cannot be seeded;
+// see the sim package for a seeded simulation of it.
+func WithSeed(seed int64) Option {
+	return func(o *options) {
+		o.rand = &lockedRand{rng: rand.New(rand.NewSource(seed))}
+	}
+}
+
+// randInt63n returns a random number in [0, n) from the seeded source of the cache, or the global one.
+func (o options) randInt63n(n int64) int64 {
+	if o.rand == nil {
+		return rand.Int63n(n)
+	}
+	o.rand.mu.Lock()
+	defer o.rand.mu.Unlock()
+	return o.rand.rng.Int63n(n)
+}
+
+// randFloat64 returns a random number in [0, 1) from the seeded source of the cache, or the global one.
+func (o options) randFloat64() float64 {
+	if o.rand == nil {
+		return rand.Float64()
+	}
+	o.rand.mu.Lock()
+	defer o.rand.mu.Unlock()
+	return o.rand.rng.Float64()
+}
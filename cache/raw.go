@@ -0,0 +1,230 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Policy names an eviction algorithm. A RawCache evicts with PolicyFIFO, PolicyLRU or PolicyLFU.
+type Policy string
+
+const (
+	PolicyFIFO      Policy = "fifo"
+	PolicyLRU       Policy = "lru"
+	PolicyLFU       Policy = "lfu"
+	PolicyApproxLRU Policy = "approx-lru"
+)
+
+// ErrRawBytesNotSupported is returned by GetBytes and SetBytes when the cache stores entries as hashes
+// or RedisJSON documents, because a raw payload has no fields to store.
+var ErrRawBytesNotSupported = errors.New("raw bytes are only supported in string storage")
+
+// RawCache is a cache of arbitrary values, decoupled from the User type. Values are serialized with the
+// configured Codec, or stored as given with SetBytes, and evicted with the FIFO, LRU or LFU algorithm.
+// Entries share the key layout of the typed caches, so the consistency checks and the expiry watcher apply to them,
+// and evictions reach the hooks, the audit trail and the traces as theirs do. WithOnEvict callbacks receive the
+// evicted value decoded into a User, which only holds the fields the value shares with it.
+type RawCache struct {
+	ctx       context.Context
+	client    Client
+	keyPrefix string
+	capacity  int
+	policy    Policy
+	options
+}
+
+// NewRawCache creates a new RawCache with the given context, Redis client, eviction policy, capacity and key prefix.
+func NewRawCache(ctx context.Context, client Client, policy Policy, capacity int, keyPrefix string, opts ...Option) RawCache {
+	log.Printf("Creating new raw %s cache with capacity: %d", policy, capacity)
+	if err := LoadScripts(ctx, client); err != nil {
+		log.Printf("Failed to load scripts: %v", err)
+	}
+	return RawCache{
+		ctx:       ctx,
+		client:    client,
+		keyPrefix: keyPrefix,
+		capacity:  capacity,
+		policy:    policy,
+		options:   clientOptions(client, opts),
+	}
+}
+
+// Get decodes the value cached under key into v, which must be a pointer.
+// If the value is found, it counts as an access for the LRU and LFU policies.
+func (c *RawCache) Get(key string, v interface{}) error {
+	cacheKey := c.generateKey(userPrefix, key)
+	c.logf(LogRead, "Attempting to get value with cache key: %s", cacheKey)
+
+	if err := c.readValue(c.ctx, c.client, cacheKey, v); err != nil {
+		return c.miss(cacheKey, err)
+	}
+	return c.touch(cacheKey)
+}
+
+// GetBytes returns the payload cached under key as stored, without decoding it.
+// It is only supported in string storage.
+func (c *RawCache) GetBytes(key string) ([]byte, error) {
+	cacheKey := c.generateKey(userPrefix, key)
+	c.logf(LogRead, "Attempting to get bytes with cache key: %s", cacheKey)
+
+	if c.storageMode() != stringStorage {
+		return nil, ErrRawBytesNotSupported
+	}
+	b, err := c.client.Get(c.ctx, cacheKey).Bytes()
+	if err != nil {
+		return nil, c.miss(cacheKey, err)
+	}
+	return b, c.touch(cacheKey)
+}
+
+// Set serializes v with the configured codec and caches it under key, evicting according to the policy if the cache is full.
+func (c *RawCache) Set(key string, v interface{}) error {
+	b, err := c.encodeValue(v)
+	if err != nil {
+		log.Printf("Error marshalling value for key: %s: %v", key, err)
+		return err
+	}
+	return c.admit(key, b, v)
+}
+
+// SetBytes caches an already serialized payload under key as is. It is only supported in string storage.
+func (c *RawCache) SetBytes(key string, value []byte) error {
+	if c.storageMode() != stringStorage {
+		return ErrRawBytesNotSupported
+	}
+	if err := c.checkSize(len(value)); err != nil {
+		return err
+	}
+	return c.admit(key, value, nil)
+}
+
+// admit writes a serialized value through the admission script of the policy, and handles its evictions as the
+// typed caches do. v is the value before serialization, indexed by its fields, or nil for a raw payload.
+// If the cache was created with WithLocker, the admission runs while holding the eviction lock.
+func (c *RawCache) admit(key string, b []byte, v interface{}) error {
+	a, err := c.admission(key, b, v)
+	if err != nil {
+		return err
+	}
+	c.logf(LogWrite, "Setting value with cache key: %s", a.cacheKey)
+
+	return withLock(c.ctx, c.locker, c.generateKey(lockKeyPrefix), func() error {
+		start := time.Now()
+		reply, err := scripts.run(c.ctx, c.client, a.script, a.keys, a.args...).StringSlice()
+		if err != nil {
+			log.Printf("Error admitting key: %s: %v", a.cacheKey, err)
+			return err
+		}
+		if err := c.admitted(c.ctx, c.client, c.generateKey, string(c.policy), a, reply, start, SourceSet); err != nil {
+			return err
+		}
+		c.publishInvalidation(c.ctx, a.cacheKey)
+		return nil
+	})
+}
+
+// admission prepares the call of the admission script of the policy, with the same keys and arguments as the typed
+// cache of that policy.
+func (c *RawCache) admission(key string, b []byte, v interface{}) (admission, error) {
+	indexKey := c.generateKey(cacheKeyPrefix)
+	cacheKey := c.generateKey(userPrefix, key)
+	a := admission{value: v, cacheKey: cacheKey}
+	switch c.policy {
+	case PolicyFIFO:
+		a.script = admitListScript
+		a.keys = []string{indexKey, cacheKey, c.generateKey(versionKeyPrefix), c.generateKey(memberKeyPrefix), c.generateKey(bytesKeyPrefix)}
+		a.args = []interface{}{c.itemCapacity(c.capacity), b, c.storageMode(), c.maxBytes, c.measure, c.evictionFlags()}
+	case PolicyLRU:
+		a.script = admitSortedSetScript
+		a.keys = []string{indexKey, cacheKey, c.generateKey(versionKeyPrefix), c.generateKey(bytesKeyPrefix)}
+		a.args = []interface{}{c.itemCapacity(c.capacity), c.recencyScore(), b, 1, c.storageMode(), c.maxBytes, c.measure, c.evictionFlags()}
+	case PolicyLFU:
+		a.script = admitSortedSetScript
+		a.keys = []string{indexKey, cacheKey, c.generateKey(versionKeyPrefix), c.generateKey(bytesKeyPrefix)}
+		a.args = []interface{}{c.itemCapacity(c.capacity), 1, b, 0, c.storageMode(), c.maxBytes, c.measure, c.evictionFlags()}
+	default:
+		return admission{}, fmt.Errorf("unknown policy: %s", c.policy)
+	}
+	return a, nil
+}
+
+// Delete removes the value cached under key along with its index entry, its secondary index entries, its tags and
+// its metadata. A queued write-behind write of the key is saved first.
+func (c *RawCache) Delete(key string) error {
+	cacheKey := c.generateKey(userPrefix, key)
+	c.logf(LogWrite, "Deleting key: %s from cache", cacheKey)
+	c.flushEvicted(c.ctx, cacheKey)
+	if err := c.client.Del(c.ctx, cacheKey).Err(); err != nil {
+		return err
+	}
+	c.publishInvalidation(c.ctx, cacheKey)
+	if err := c.dropEntries(c.ctx, c.client, c.generateKey, cacheKey); err != nil {
+		return err
+	}
+	return c.pruneKey(cacheKey)
+}
+
+// CacheSize returns the current number of items in the cache.
+func (c *RawCache) CacheSize() int {
+	var size int64
+	var err error
+	if c.policy == PolicyFIFO {
+		size, err = c.client.SCard(c.ctx, c.generateKey(memberKeyPrefix)).Result()
+	} else {
+		size, err = c.client.ZCard(c.ctx, c.generateKey(cacheKeyPrefix)).Result()
+	}
+	if err != nil {
+		log.Printf("Error getting cache size: %v", err)
+		return 0
+	}
+	return int(size)
+}
+
+// touch records an access to a value key: it refreshes the recency for LRU and increments the frequency for LFU.
+func (c *RawCache) touch(cacheKey string) error {
+	indexKey := c.generateKey(cacheKeyPrefix)
+	switch c.policy {
+	case PolicyLRU:
+		return c.client.ZAdd(c.ctx, indexKey, redis.Z{Member: cacheKey, Score: float64(c.recencyScore())}).Err()
+	case PolicyLFU:
+		return c.client.ZIncrBy(c.ctx, indexKey, 1, cacheKey).Err()
+	}
+	return nil
+}
+
+// miss prunes the index entry of a value key that no longer exists and returns err.
+func (c *RawCache) miss(cacheKey string, err error) error {
+	if err == redis.Nil {
+		if err := c.pruneKey(cacheKey); err != nil {
+			log.Printf("Failed to prune key: %s from index: %v", cacheKey, err)
+		}
+	} else {
+		log.Printf("Error getting value with cache key: %s from Redis: %v", cacheKey, err)
+	}
+	return err
+}
+
+// pruneKey removes a key whose value no longer exists from the index, the version hash and the size hash, unless it
+// was written again in the meantime, see pruneScript.
+func (c *RawCache) pruneKey(cacheKey string) error {
+	indexType := "zset"
+	if c.policy == PolicyFIFO {
+		indexType = "list"
+	}
+	_, err := pruneIndex(c.ctx, c.client, c.generateKey, cacheKey, indexType)
+	return err
+}
+
+// generateKey creates a Redis key by joining the key prefix and other key parts with a colon.
+func (c *RawCache) generateKey(keys ...string) string {
+	allKeys := []string{c.namespace(c.keyPrefix)}
+	allKeys = append(allKeys, keys...)
+
+	return strings.Join(allKeys, ":")
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRawCacheEvictsThroughHooks(t *testing.T) {
+	for _, policy := range []Policy{PolicyFIFO, PolicyLRU, PolicyLFU} {
+		t.Run(string(policy), func(t *testing.T) {
+			client, _ := newTestClient(t)
+			var evicted []string
+			c := NewRawCache(context.Background(), client, policy, 2, "raw", WithClock(testClock()), WithEvictionTrace(10),
+				WithOnEvict(func(key string, value User, reason string) {
+					evicted = append(evicted, key)
+				}))
+
+			for _, key := range []string{"a", "b", "c"} {
+				if err := c.Set(key, testUser(1)); err != nil {
+					t.Fatalf("Set(%s): %v", key, err)
+				}
+			}
+			if size := c.CacheSize(); size != 2 {
+				t.Errorf("size: %d, want 2", size)
+			}
+			if len(evicted) != 1 {
+				t.Fatalf("evicted %v, want one key", evicted)
+			}
+			if traces := c.lastEvictions(10); len(traces) != 1 || traces[0].Key != evicted[0] {
+				t.Errorf("traces %+v, want the eviction of %s", traces, evicted[0])
+			}
+		})
+	}
+}
+
+func TestRawCacheDeleteDropsIndexEntries(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+	c := NewRawCache(ctx, client, PolicyLRU, 10, "raw", WithIndex("name"))
+
+	if err := c.Set("a", testUser(1)); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	indexKey := c.generateKey(indexKeyPrefix, "name", testUser(1).Name)
+	if members, err := client.SMembers(ctx, indexKey).Result(); err != nil || !slices.Equal(members, []string{c.generateKey(userPrefix, "a")}) {
+		t.Fatalf("index after Set: %v, %v", members, err)
+	}
+	if err := c.SetBytes("b", []byte(`{"id":"2"}`)); err != nil {
+		t.Fatalf("SetBytes: %v", err)
+	}
+
+	if err := c.Delete("a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if n, err := client.Exists(ctx, indexKey).Result(); err != nil || n != 0 {
+		t.Errorf("index after Delete: %d keys, %v", n, err)
+	}
+	var u User
+	if err := c.Get("a", &u); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get after Delete: %v, want redis.Nil", err)
+	}
+	if size := c.CacheSize(); size != 1 {
+		t.Errorf("size after Delete: %d, want 1", size)
+	}
+}
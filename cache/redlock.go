@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// clockDriftFactor is the fraction of the lease reserved for clock drift between the Redis nodes, as in the Redlock paper.
+const clockDriftFactor = 0.01
+
+// nodeTimeoutFactor is the fraction of the lease a call to a single node may take, so an unreachable node does not
+// use up the lease, as in the Redlock paper. It is at least minNodeTimeout.
+const nodeTimeoutFactor = 0.005
+
+const minNodeTimeout = 5 * time.Millisecond
+
+// Redlock is a Locker that implements the Redlock algorithm over several independent Redis nodes.
+// A lock is held when it was acquired on a majority of the nodes within its lease, so it survives
+// the failure of a minority of them. While held, the lease is renewed in the background on every node.
+type Redlock struct {
+	clients    []*redis.Client
+	lease      time.Duration
+	retryDelay time.Duration
+}
+
+// NewRedlock creates a new Redlock over the given independent Redis nodes, whose locks expire after lease unless renewed.
+// A lease shorter than MinLockLease, including a non-positive one, is raised to MinLockLease.
+// The scripts are loaded on every node up front, since reloading them would not fit in the timeout of a node call.
+func NewRedlock(clients []*redis.Client, lease time.Duration) *Redlock {
+	lease = lockLease(lease)
+	for _, client := range clients {
+		if err := LoadScripts(context.Background(), client); err != nil {
+			log.Printf("Failed to load scripts on node: %s: %v", client.Options().Addr, err)
+		}
+	}
+	return &Redlock{
+		clients:    clients,
+		lease:      lease,
+		retryDelay: lease / 10,
+	}
+}
+
+// Lock acquires the lock for key on a majority of the nodes, retrying after a random delay until it succeeds or ctx is done.
+// The lease is renewed every third of its duration until the returned unlock function is called.
+func (r *Redlock) Lock(ctx context.Context, key string) (func() error, error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	quorum := len(r.clients)/2 + 1
+	drift := time.Duration(float64(r.lease)*clockDriftFactor) + 2*time.Millisecond
+
+	log.Printf("Acquiring redlock: %s on %d nodes", key, len(r.clients))
+	for {
+		start := time.Now()
+		acquired := 0
+		for _, client := range r.clients {
+			nodeCtx, cancel := r.nodeContext(ctx)
+			ok, err := client.SetNX(nodeCtx, key, token, r.lease).Result()
+			cancel()
+			if err != nil {
+				log.Printf("Error acquiring redlock: %s on node: %s: %v", key, client.Options().Addr, err)
+				continue
+			}
+			if ok {
+				acquired++
+			}
+		}
+
+		validity := r.lease - time.Since(start) - drift
+		if acquired >= quorum && validity > 0 {
+			break
+		}
+
+		log.Printf("Redlock: %s acquired on %d of %d nodes. Retrying.", key, acquired, len(r.clients))
+		r.release(key, token)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(r.retryDelay/2 + time.Duration(rand.Int63n(int64(r.retryDelay)+1))):
+		}
+	}
+	log.Printf("Acquired redlock: %s", key)
+
+	stopRenewing := renewEvery(r.lease/3, func() bool {
+		renewed := 0
+		for _, client := range r.clients {
+			nodeCtx, cancel := r.nodeContext(ctx)
+			ok, err := scripts.run(nodeCtx, client, renewScript, []string{key}, token, r.lease.Milliseconds()).Int()
+			cancel()
+			if err == nil && ok == 1 {
+				renewed++
+			}
+		}
+		if renewed < quorum {
+			log.Printf("Failed to renew redlock: %s. Renewed on %d of %d nodes.", key, renewed, len(r.clients))
+			return false
+		}
+		return true
+	})
+
+	unlock := func() error {
+		stopRenewing()
+
+		log.Printf("Releasing redlock: %s", key)
+		if r.release(key, token) < quorum {
+			return ErrLockNotHeld
+		}
+		return nil
+	}
+
+	return unlock, nil
+}
+
+// release deletes the lock key on every node where it still holds token, and returns the number of nodes it was released on.
+func (r *Redlock) release(key, token string) int {
+	released := 0
+	for _, client := range r.clients {
+		nodeCtx, cancel := r.nodeContext(context.Background())
+		ok, err := scripts.run(nodeCtx, client, unlockScript, []string{key}, token).Int()
+		cancel()
+		if err != nil {
+			log.Printf("Error releasing redlock: %s on node: %s: %v", key, client.Options().Addr, err)
+			continue
+		}
+		released += ok
+	}
+	return released
+}
+
+// nodeContext returns a context bounding a call to a single node to a small fraction of the lease.
+func (r *Redlock) nodeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, max(time.Duration(float64(r.lease)*nodeTimeoutFactor), minNodeTimeout))
+}
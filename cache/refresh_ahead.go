@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"log"
+	"time"
+)
+
+const refreshAheadKeyPrefix = "refresh_ahead"
+
+// WithRefreshAhead makes the TTL cache reload the entries read less than window before they expire, in a background
+// goroutine, so an entry read often enough never expires from the point of view of its callers. Unlike
+// WithEarlyRefresh, the read returns the cached user without waiting for the loader. An entry expires at its soft
+// expiry if it has one, see WithStaleWhileRevalidate. A lock held until the entry would have expired ensures a
+// single refresh per entry across application instances. The other caches do not expire entries and ignore this option.
+func WithRefreshAhead(window time.Duration) Option {
+	return func(o *options) {
+		o.refreshAhead = window
+	}
+}
+
+// maybeRefreshAhead starts a background reload of a fresh user read less than the refresh-ahead window before it
+// expires. expiry is the two-level expiry of its entry, if hasExpiry; otherwise its TTL is read from Redis.
+func (c *TTLCache) maybeRefreshAhead(id string, expiry entryExpiry, hasExpiry bool) {
+	if c.refreshAhead <= 0 {
+		return
+	}
+
+	var remaining time.Duration
+	if hasExpiry {
+		remaining = expiry.soft.Sub(c.now())
+	} else {
+		ttl, err := c.client.PTTL(c.ctx, c.generateKey(userPrefix, id)).Result()
+		if err != nil {
+			log.Printf("Error getting TTL of user ID: %s: %v", id, err)
+			return
+		}
+		remaining = ttl
+	}
+	if remaining <= 0 || remaining > c.refreshAhead {
+		return
+	}
+
+	lockKey := c.generateKey(refreshAheadKeyPrefix, id)
+	acquired, err := c.client.SetNX(c.ctx, lockKey, 1, remaining).Result()
+	if err != nil {
+		log.Printf("Error acquiring refresh-ahead lock: %s: %v", lockKey, err)
+		return
+	}
+	if !acquired {
+		c.logf(LogRead, "User ID: %s is already being refreshed ahead of its expiration.", id)
+		return
+	}
+
+	log.Printf("User ID: %s expires in %s. Refreshing it in the background.", id, remaining)
+	// The lock is kept after a successful refresh, until the expiry it was taken for, so that reads that saw
+	// the old expiry do not refresh the entry again. It is released after a failure, for the next read to retry.
+	go func() {
+		user, err := c.load(c.ctx, id)
+		if err == nil {
+			if hasExpiry {
+				err = c.set(user, expiry.softTTL, expiry.hardTTL)
+			} else {
+				err = c.Set(user)
+			}
+		}
+		if err != nil {
+			log.Printf("Failed to refresh user ID: %s ahead of its expiration: %v", id, err)
+			c.client.Del(c.ctx, lockKey)
+		}
+	}()
+}
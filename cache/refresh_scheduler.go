@@ -0,0 +1,247 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRefreshConcurrency is the number of users a RefreshScheduler created without WithRefreshConcurrency
+// reloads at the same time.
+const DefaultRefreshConcurrency = 4
+
+// Refreshable is a cache whose users a RefreshScheduler reloads. Every cache type implements it.
+type Refreshable interface {
+	GetOrLoad(id string, opts ...CallOption) (User, error)
+	CachedIDs() ([]string, error)
+}
+
+// RefreshReport describes a run of a RefreshScheduler.
+type RefreshReport struct {
+	// Start is when the run started.
+	Start time.Time `json:"start"`
+	// Duration is how long the run took.
+	Duration time.Duration `json:"duration"`
+	// Refreshed is the number of users reloaded and written back to the cache.
+	Refreshed int `json:"refreshed"`
+	// Failed is the number of users that could not be reloaded.
+	Failed int `json:"failed"`
+	// Errors holds the error of every user that could not be reloaded, by ID, and under "" the error listing
+	// the cached users, if that failed.
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// RefreshScheduler reloads users of a cache from its loader on a schedule, whether or not they are read,
+// for data that must stay fresh regardless of the access patterns.
+type RefreshScheduler struct {
+	cache       Refreshable
+	schedule    Schedule
+	ids         []string
+	concurrency int
+	onRun       func(RefreshReport)
+
+	mu   sync.Mutex
+	last RefreshReport
+
+	running sync.Mutex
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// SchedulerOption configures a RefreshScheduler.
+type SchedulerOption func(*RefreshScheduler)
+
+// WithScheduledIDs makes a RefreshScheduler reload the users of ids, whether or not they are cached, instead of
+// every user cached when a run starts.
+func WithScheduledIDs(ids ...string) SchedulerOption {
+	return func(s *RefreshScheduler) {
+		s.ids = ids
+	}
+}
+
+// WithRefreshConcurrency sets the number of users a RefreshScheduler reloads at the same time, to bound the load
+// on the source of truth. The default is DefaultRefreshConcurrency.
+func WithRefreshConcurrency(n int) SchedulerOption {
+	return func(s *RefreshScheduler) {
+		s.concurrency = max(n, 1)
+	}
+}
+
+// WithRunReport calls onRun with the report of every run of a RefreshScheduler, e.g. to export it.
+func WithRunReport(onRun func(RefreshReport)) SchedulerOption {
+	return func(s *RefreshScheduler) {
+		s.onRun = onRun
+	}
+}
+
+// NewRefreshScheduler creates a RefreshScheduler reloading the users of cache at the times of schedule, with
+// GetOrLoad and ForceRefresh, and starts it. A run due while the previous one is still going is skipped.
+// It runs until Close.
+func NewRefreshScheduler(cache Refreshable, schedule Schedule, opts ...SchedulerOption) *RefreshScheduler {
+	s := &RefreshScheduler{
+		cache:       cache,
+		schedule:    schedule,
+		concurrency: DefaultRefreshConcurrency,
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	go s.loop()
+	return s
+}
+
+// loop runs the scheduler at the times of its schedule until Close.
+func (s *RefreshScheduler) loop() {
+	defer close(s.stopped)
+	for {
+		next := s.schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("Refresh schedule has no next run. Stopping.")
+			return
+		}
+		log.Printf("Next scheduled refresh at: %s", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if !s.running.TryLock() {
+			log.Printf("Skipping scheduled refresh: the previous run is still going.")
+			continue
+		}
+		s.run()
+		s.running.Unlock()
+	}
+}
+
+// RunNow runs the scheduler immediately, waiting for a run in progress to end first, and returns its report.
+func (s *RefreshScheduler) RunNow() RefreshReport {
+	s.running.Lock()
+	defer s.running.Unlock()
+	return s.run()
+}
+
+// run reloads the users, at most concurrency at a time, and records the report of the run.
+func (s *RefreshScheduler) run() RefreshReport {
+	report := RefreshReport{Start: time.Now(), Errors: make(map[string]string)}
+
+	ids := s.ids
+	if len(ids) == 0 {
+		cached, err := s.cache.CachedIDs()
+		if err != nil {
+			log.Printf("Error listing cached users to refresh: %v", err)
+			report.Errors[""] = err.Error()
+		}
+		ids = cached
+	}
+	log.Printf("Refreshing %d users with concurrency: %d", len(ids), s.concurrency)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s.concurrency)
+	for _, id := range ids {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			_, err := s.cache.GetOrLoad(id, ForceRefresh())
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Failed++
+				report.Errors[id] = err.Error()
+				return
+			}
+			report.Refreshed++
+		}(id)
+	}
+	wg.Wait()
+
+	report.Duration = time.Since(report.Start)
+	if len(report.Errors) == 0 {
+		report.Errors = nil
+	}
+	log.Printf("Refreshed %d users in %s, %d failed", report.Refreshed, report.Duration, report.Failed)
+
+	s.mu.Lock()
+	s.last = report
+	s.mu.Unlock()
+	if s.onRun != nil {
+		s.onRun(report)
+	}
+	return report
+}
+
+// LastReport returns the report of the last run, or a zero report if none ran yet.
+func (s *RefreshScheduler) LastReport() RefreshReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// Close stops the scheduler, waiting for a run in progress to end.
+func (s *RefreshScheduler) Close() {
+	s.once.Do(func() {
+		log.Printf("Stopping refresh scheduler")
+		close(s.done)
+	})
+	<-s.stopped
+	s.running.Lock()
+	s.running.Unlock()
+}
+
+// cachedIDs returns the IDs of the users cached with the given key prefix, read with SCAN on the node holding them.
+func (o options) cachedIDs(ctx context.Context, client Client, keyPrefix string) ([]string, error) {
+	prefix := o.namespace(keyPrefix) + ":" + userPrefix + ":"
+	node, err := nodeForKey(ctx, client, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	iter := node.Scan(ctx, 0, prefix+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		ids = append(ids, strings.TrimPrefix(iter.Val(), prefix))
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("Error scanning cached users matching: %s*: %v", prefix, err)
+		return nil, err
+	}
+	return ids, nil
+}
+
+// CachedIDs returns the IDs of the users in the cache.
+func (c *FIFOCache) CachedIDs() ([]string, error) {
+	return c.cachedIDs(c.ctx, c.client, c.keyPrefix)
+}
+
+// CachedIDs returns the IDs of the users in the cache.
+func (c *LRUCache) CachedIDs() ([]string, error) {
+	return c.cachedIDs(c.ctx, c.client, c.keyPrefix)
+}
+
+// CachedIDs returns the IDs of the users in the cache.
+func (c *LFUCache) CachedIDs() ([]string, error) {
+	return c.cachedIDs(c.ctx, c.client, c.keyPrefix)
+}
+
+// CachedIDs returns the IDs of the users in the cache.
+func (c *ApproxLRUCache) CachedIDs() ([]string, error) {
+	return c.cachedIDs(c.ctx, c.client, c.keyPrefix)
+}
+
+// CachedIDs returns the IDs of the users in the cache, including the stale ones kept for WithStaleWhileRevalidate
+// or WithStaleIfError.
+func (c *TTLCache) CachedIDs() ([]string, error) {
+	return c.cachedIDs(c.ctx, c.client, c.keyPrefix)
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// WithReadReplica makes Get read the cached values from replica, a client of the replicas of the Redis the cache
+// writes to, such as the failover client ConnectReplicas creates, or a Ring of replica nodes sharded like the
+// Ring of their primaries. Set, Delete, eviction and the recency and frequency updates of Get stay on the primary.
+//
+// Replication is asynchronous, so a hit may return a value up to the replication lag old: a user just updated may
+// be served at its previous value, and a user just deleted or evicted may still be served. A miss on the replica,
+// or an error, is checked again on the primary, so a replica lagging behind never makes the cache drop entries
+// the primary still holds. Replica reads suit caches whose users tolerate that staleness, such as ones filled
+// from a database through GetOrLoad; use the primary for reads that must see the writes before them.
+func WithReadReplica(replica Client) Option {
+	return func(o *options) {
+		o.replica = replica
+	}
+}
+
+// ConnectReplicas creates a client reading from the replicas of the Sentinel master named by a URL parsed with
+// ParseConnectionURL, for WithReadReplica. Each connection goes to a random replica that the sentinels report
+// as connected to the master, and the client follows them across failovers.
+func ConnectReplicas(rawURL string, opts ...ConnectionOption) (redis.UniversalClient, error) {
+	opt, err := ParseConnectionURL(rawURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if opt.MasterName == "" {
+		return nil, fmt.Errorf("replica reads need a Redis URL with a master_name")
+	}
+	failover := opt.Failover()
+	failover.ReplicaOnly = true
+	log.Printf("Connecting to replicas of master: %s through sentinels: %v", opt.MasterName, opt.Addrs)
+	return redis.NewFailoverClient(failover), nil
+}
+
+// readReplicaUser reads the user stored at cacheKey from the replica, if the cache has one, and from client
+// if the replica misses it or fails.
+func (o options) readReplicaUser(ctx context.Context, client redis.Cmdable, cacheKey, id string) (User, error) {
+	if o.replica != nil {
+		user, err := o.readUser(ctx, o.replica, cacheKey, id)
+		if err == nil {
+			return user, nil
+		}
+		if err != redis.Nil {
+			log.Printf("Error reading cache key: %s from replica, reading from primary: %v", cacheKey, err)
+		}
+	}
+	return o.readUser(ctx, client, cacheKey, id)
+}
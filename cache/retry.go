@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RetryPolicy retries cache operations that failed with a transient error, waiting a random delay
+// between 0 and BaseDelay * 2^attempt, capped at MaxDelay, before each new attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// BaseDelay is the upper bound of the delay before the second attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the upper bound of every delay. 0 means no cap.
+	MaxDelay time.Duration
+}
+
+// WithRetry retries Get, GetOrLoad, Set and Delete according to policy when Redis fails with a transient error:
+// a timeout, a dropped or refused connection, or a LOADING, BUSY, TRYAGAIN, READONLY or MASTERDOWN reply,
+// as during the failover of a master. Other errors, including misses, are returned at once.
+// Operations are not retried by default.
+func WithRetry(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
+
+// withRetry runs op, retrying it according to the retry policy while it fails with a transient error.
+func (o options) withRetry(ctx context.Context, op func() error) error {
+	err := op()
+	for attempt := 1; attempt < o.retry.MaxAttempts && isTransient(err); attempt++ {
+		delay := o.retry.backoff(attempt, o.randInt63n)
+		log.Printf("Transient Redis error: %v. Retrying in %s (attempt %d of %d).", err, delay, attempt+1, o.retry.MaxAttempts)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		err = op()
+	}
+	return err
+}
+
+// backoff returns a random delay before the given retry, with exponential growth and full jitter drawn with int63n.
+func (p RetryPolicy) backoff(attempt int, int63n func(int64) int64) time.Duration {
+	ceiling := p.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || (p.MaxDelay > 0 && ceiling > p.MaxDelay) {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(int63n(int64(ceiling) + 1))
+}
+
+// isTransient reports whether err is a Redis error worth retrying.
+func isTransient(err error) bool {
+	if err == nil || err == redis.Nil {
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	// A connection dropped or refused, e.g. by a master going down, is retried on a new connection,
+	// which a failover client opens to the new master once the sentinels promoted it.
+	var opErr *net.OpError
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &opErr) {
+		return true
+	}
+
+	var reply redis.Error
+	if errors.As(err, &reply) {
+		msg := reply.Error()
+		return strings.HasPrefix(msg, "LOADING ") || strings.HasPrefix(msg, "BUSY ") || strings.HasPrefix(msg, "TRYAGAIN ") ||
+			strings.HasPrefix(msg, "READONLY ") || strings.HasPrefix(msg, "MASTERDOWN ")
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// failingHook fails the next commands it is armed for with io.EOF, as a dropped connection, and counts every command.
+type failingHook struct {
+	failures atomic.Int64
+	commands atomic.Int64
+}
+
+func (h *failingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *failingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.commands.Add(1)
+		if h.failures.Add(-1) >= 0 {
+			cmd.SetErr(io.EOF)
+			return io.EOF
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h *failingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		h.commands.Add(1)
+		if h.failures.Add(-1) >= 0 {
+			for _, cmd := range cmds {
+				cmd.SetErr(io.EOF)
+			}
+			return io.EOF
+		}
+		return next(ctx, cmds)
+	}
+}
+
+func TestRetryOfTransientErrors(t *testing.T) {
+	client, _ := newTestClient(t)
+	hook := &failingHook{}
+	client.AddHook(hook)
+	c := NewLRU(context.Background(), client, 10, "lru", WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+	setUsers(t, c.Set, nil, 1)
+
+	hook.failures.Store(2)
+	if err := c.Set(testUser(2)); err != nil {
+		t.Errorf("Set after two dropped connections: %v", err)
+	}
+	hook.failures.Store(2)
+	if got, err := c.Get("2"); err != nil || got != testUser(2) {
+		t.Errorf("Get after two dropped connections: %+v, %v", got, err)
+	}
+	hook.failures.Store(3)
+	if err := c.Set(testUser(3)); !errors.Is(err, io.EOF) {
+		t.Errorf("Set after three dropped connections: %v, want io.EOF", err)
+	}
+}
+
+func TestRetryStopsAtOtherErrors(t *testing.T) {
+	for name, reply := range map[string]string{"transient": "LOADING Redis is loading the dataset in memory", "permanent": "ERR boom"} {
+		t.Run(name, func(t *testing.T) {
+			client, m := newTestClient(t)
+			hook := &failingHook{}
+			client.AddHook(hook)
+			c := NewLRU(context.Background(), client, 10, "lru", WithRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+			setUsers(t, c.Set, nil, 1)
+
+			m.SetError(reply)
+			hook.commands.Store(0)
+			if _, err := c.Get("1"); err == nil {
+				t.Fatal("Get succeeded with Redis failing")
+			}
+			m.SetError("")
+			want := int64(1)
+			if name == "transient" {
+				want = 3
+			}
+			if n := hook.commands.Load(); n != want {
+				t.Errorf("attempts of Get: %d, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Millisecond, MaxDelay: 25 * time.Millisecond}
+	ceiling := func(n int64) int64 { return n - 1 }
+	for attempt, want := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 25 * time.Millisecond, 10: 25 * time.Millisecond} {
+		if got := policy.backoff(attempt, ceiling); got != want {
+			t.Errorf("upper bound of the delay before attempt %d: %s, want %s", attempt+1, got, want)
+		}
+	}
+	if got := (RetryPolicy{MaxAttempts: 2}).backoff(1, ceiling); got != 0 {
+		t.Errorf("delay without BaseDelay: %s, want 0", got)
+	}
+}
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule tells when a RefreshScheduler runs.
+type Schedule interface {
+	// Next returns the first time a run is due strictly after t.
+	Next(t time.Time) time.Time
+}
+
+// Every returns a Schedule running every interval.
+func Every(interval time.Duration) Schedule {
+	return everySchedule(interval)
+}
+
+// everySchedule runs at a fixed interval from the previous run.
+type everySchedule time.Duration
+
+// Next returns t plus the interval, of at least a second.
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(max(time.Duration(s), time.Second))
+}
+
+// cronSchedule runs at the minutes matching the five fields of a cron expression. Every field is a bit set
+// of the values it matches.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// anyDay is set when the day of month or the day of week is "*": standard cron runs on the days matching
+	// both fields then, and on the days matching either otherwise.
+	anyDay bool
+	loc    *time.Location
+}
+
+// cronFields are the bounds of the fields of a cron expression, in order.
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseSchedule parses a cron-like schedule in the local time zone. It accepts the five fields of a cron
+// expression, minute, hour, day of month, month and day of week, each "*", a value, a range "a-b", a step "*/n"
+// or "a-b/n", or a comma-separated list of them, with 0 or 7 for Sunday. It also accepts "@every <duration>",
+// "@hourly", "@daily", "@weekly" and "@monthly".
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch spec {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily", "@midnight":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	case "@monthly":
+		spec = "0 0 1 * *"
+	}
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid schedule: %q: bad interval", spec)
+		}
+		return Every(interval), nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid schedule: %q: want %d fields, got %d", spec, len(cronFields), len(fields))
+	}
+	sets := make([]uint64, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule: %q: %s: %v", spec, cronFields[i].name, err)
+		}
+		sets[i] = set
+	}
+	// Sunday is both 0 and 7.
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &cronSchedule{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		anyDay: fields[2] == "*" || fields[4] == "*",
+		loc:    time.Local,
+	}, nil
+}
+
+// parseCronField parses a field of a cron expression into the set of the values it matches.
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step: %q", part)
+			}
+			step = n
+		}
+
+		start, end := lo, hi
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if start, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("bad value: %q", part)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("bad value: %q", part)
+				}
+			} else if hasStep {
+				end = hi
+			}
+		}
+		if start < lo || end > hi || start > end {
+			return 0, fmt.Errorf("out of range: %q", part)
+		}
+		for v := start; v <= end; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// Next returns the first minute after t matching the expression, or the zero time if none does within five years,
+// e.g. for February 30.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	t = t.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// matchDay reports whether the day of t matches the day of month and day of week fields.
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<t.Weekday()) != 0
+	if s.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log"
+)
+
+// ErrStaleSchema is returned for an entry written with another schema version that could not be migrated.
+// Reads treat such an entry as a cache miss. A Migration returns it to drop an entry it cannot upgrade.
+var ErrStaleSchema = errors.New("entry was written with another schema version")
+
+// schemaMagic starts every payload written with a schema version. It is followed by the version as a uvarint
+// and the payload of the wrapped codec. Payloads without it were written before versioning was enabled and have version 0.
+var schemaMagic = []byte{0x00, 0x5c}
+
+// Migration decodes into v the payload of an entry written with an older, or newer, schema version.
+// data is the payload as produced by the codec at the time. It returns ErrStaleSchema to treat the entry as a miss.
+type Migration func(version int, data []byte, v interface{}) error
+
+// schemaCodec wraps a Codec and tags its payloads with a schema version.
+type schemaCodec struct {
+	codec   Codec
+	version int
+	migrate Migration
+}
+
+// WithSchemaVersion tags every value written by the cache with version. When the application's struct changes,
+// increment the version: entries written with another version are decoded by migrate, or treated as
+// cache misses if migrate is nil or returns ErrStaleSchema, instead of failing to decode.
+// Migrated entries are returned as is and rewritten by the next Set. Only values stored as strings are versioned.
+func WithSchemaVersion(version int, migrate Migration) Option {
+	return func(o *options) {
+		o.schemaVersion = version
+		o.migrate = migrate
+	}
+}
+
+// Marshal serializes v and prefixes the result with the schema version.
+func (c schemaCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	header := binary.AppendUvarint(append([]byte{}, schemaMagic...), uint64(c.version))
+	return append(header, data...), nil
+}
+
+// Unmarshal decodes data into v if it was written with the current schema version, and migrates it otherwise.
+func (c schemaCodec) Unmarshal(data []byte, v interface{}) error {
+	version := 0
+	if bytes.HasPrefix(data, schemaMagic) {
+		n, size := binary.Uvarint(data[len(schemaMagic):])
+		if size <= 0 {
+			return errors.New("invalid schema version header")
+		}
+		version = int(n)
+		data = data[len(schemaMagic)+size:]
+	}
+
+	if version == c.version {
+		return c.codec.Unmarshal(data, v)
+	}
+	if c.migrate == nil {
+		return ErrStaleSchema
+	}
+
+	log.Printf("Migrating entry from schema version: %d to: %d", version, c.version)
+	return c.migrate(version, data, v)
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSchemaVersionMigratesOlderEntries(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+	unversioned := NewLRU(ctx, client, 10, "lru")
+	v1 := NewLRU(ctx, client, 10, "lru", WithSchemaVersion(1, nil))
+
+	var migrated []int
+	v2 := NewLRU(ctx, client, 10, "lru", WithSchemaVersion(2, func(version int, data []byte, v interface{}) error {
+		migrated = append(migrated, version)
+		if err := json.Unmarshal(data, v); err != nil {
+			return err
+		}
+		v.(*User).Age++
+		return nil
+	}))
+
+	setUsers(t, unversioned.Set, nil, 1)
+	setUsers(t, v1.Set, nil, 2)
+	setUsers(t, v2.Set, nil, 3)
+	for _, id := range []int{1, 2, 3} {
+		want := testUser(id)
+		if id < 3 {
+			want.Age++
+		}
+		if got, err := v2.Get(want.Id); err != nil || got != want {
+			t.Errorf("Get(%d): %+v, %v, want %+v", id, got, err, want)
+		}
+	}
+	if len(migrated) != 2 || migrated[0] != 0 || migrated[1] != 1 {
+		t.Errorf("migrated the versions %v, want [0 1]", migrated)
+	}
+}
+
+func TestSchemaVersionDropsEntriesItCannotMigrate(t *testing.T) {
+	client, m := newTestClient(t)
+	ctx := context.Background()
+	v1 := NewLRU(ctx, client, 10, "lru", WithSchemaVersion(1, nil))
+	v2 := NewLRU(ctx, client, 10, "lru", WithSchemaVersion(2, nil))
+	v3 := NewLRU(ctx, client, 10, "lru", WithSchemaVersion(3, func(version int, data []byte, v interface{}) error {
+		return ErrStaleSchema
+	}))
+
+	setUsers(t, v1.Set, nil, 1, 2)
+	if _, err := v2.Get("1"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get of an entry without a migration: %v, want redis.Nil", err)
+	}
+	if _, err := v3.Get("2"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get of an entry the migration rejects: %v, want redis.Nil", err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if m.Exists(v1.Key(id)) {
+			t.Errorf("the stale entry %s was not deleted", id)
+		}
+	}
+}
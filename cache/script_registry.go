@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// scripts is the registry shared by all cache types. Every Lua script used by the caches is registered with it.
+var scripts = &scriptRegistry{
+	loaded: make(map[redis.Scripter]bool),
+}
+
+// scriptRegistry keeps track of the Lua scripts used by the caches and of the clients they were loaded into.
+// Scripts are invoked with EVALSHA. When Redis no longer knows a script (e.g. after a restart or SCRIPT FLUSH),
+// the registry reloads every script and retries, falling back to EVAL if reloading fails.
+type scriptRegistry struct {
+	mu      sync.Mutex
+	scripts []*redis.Script
+	loaded  map[redis.Scripter]bool
+}
+
+// LoadScripts loads every script used by the caches into Redis with SCRIPT LOAD.
+// The caches call it when they are created; calling it again for the same client is a no-op.
+func LoadScripts(ctx context.Context, client redis.Scripter) error {
+	return scripts.load(ctx, client, false)
+}
+
+// register adds a script to the registry and returns it.
+func (r *scriptRegistry) register(src string) *redis.Script {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	script := redis.NewScript(src)
+	r.scripts = append(r.scripts, script)
+	return script
+}
+
+// load loads every registered script into Redis, unless they were already loaded for this client and force is false.
+func (r *scriptRegistry) load(ctx context.Context, client redis.Scripter, force bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.loaded[client] && !force {
+		return nil
+	}
+
+	log.Printf("Loading %d scripts into Redis", len(r.scripts))
+	// A cluster client loads the scripts on every master, but a ring sends SCRIPT LOAD to a single shard.
+	if ring, ok := client.(*redis.Ring); ok {
+		if err := ring.ForEachShard(ctx, func(ctx context.Context, shard *redis.Client) error {
+			return r.loadAll(ctx, shard)
+		}); err != nil {
+			return err
+		}
+	} else if err := r.loadAll(ctx, client); err != nil {
+		return err
+	}
+
+	r.loaded[client] = true
+	return nil
+}
+
+// loadAll loads every registered script with SCRIPT LOAD through client.
+func (r *scriptRegistry) loadAll(ctx context.Context, client redis.Scripter) error {
+	for _, script := range r.scripts {
+		sha, err := script.Load(ctx, client).Result()
+		if err != nil {
+			log.Printf("Error loading script: %s: %v", script.Hash(), err)
+			return err
+		}
+		log.Printf("Loaded script: %s", sha)
+	}
+	return nil
+}
+
+// run invokes a registered script with EVALSHA. On a NOSCRIPT error, the scripts are reloaded and
+// the call is retried; if reloading fails, the script is sent in full with EVAL.
+func (r *scriptRegistry) run(ctx context.Context, client redis.Scripter, script *redis.Script, keys []string, args ...interface{}) *redis.Cmd {
+	cmd := script.EvalSha(ctx, client, keys, args...)
+	if !redis.HasErrorPrefix(cmd.Err(), "NOSCRIPT") {
+		return cmd
+	}
+
+	log.Printf("Script: %s is not loaded in Redis. Reloading scripts.", script.Hash())
+	if err := r.load(ctx, client, true); err != nil {
+		log.Printf("Failed to reload scripts, falling back to EVAL: %v", err)
+		return script.Eval(ctx, client, keys, args...)
+	}
+
+	return script.EvalSha(ctx, client, keys, args...)
+}
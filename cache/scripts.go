@@ -0,0 +1,596 @@
+package cache
+
+// bumpVersion defines a Lua function that assigns a new version to a value key.
+// Versions are taken from a per-cache sequence stored in the version hash itself, so they keep
+// increasing even when an entry is evicted and admitted again.
+const bumpVersion = `
+local function bump_version(version_key, value_key)
+	local version = redis.call('HINCRBY', version_key, '` + versionSeqField + `', 1)
+	redis.call('HSET', version_key, value_key, version)
+	return version
+end
+`
+
+// bumpVersionScript assigns a new version to a value key written outside of the admission scripts, such as by
+// AddKey. It is queued in the MULTI/EXEC transaction of the write with Eval, since EVALSHA cannot be retried
+// inside a transaction.
+//
+// KEYS[1]: the version hash
+// KEYS[2]: the value key
+//
+// It returns the new version.
+var bumpVersionScript = scripts.register(bumpVersion + `
+return bump_version(KEYS[1], KEYS[2])
+`)
+
+// captureEvicted defines the Lua functions the eviction scripts use to report evicted entries.
+// Evicted entries are returned as a flat list of key, reason, value and trace quadruples. The scripts take
+// a flags argument: with "v", the value is read with readValue before the entry is deleted; with "t",
+// the trace is a JSON object with the score and rank of the victim and the candidates it was chosen over.
+// Both are empty strings otherwise.
+const captureEvicted = `
+local function has_flag(flags, flag)
+	return string.find(flags or '', flag, 1, true) ~= nil
+end
+local function capture_value(key, storage, flags)
+	if not has_flag(flags, 'v') then
+		return ''
+	end
+	return read_value(key, storage) or ''
+end
+local function trace_detail(flags, score, rank, candidates)
+	if not has_flag(flags, 't') then
+		return ''
+	end
+	local detail = {score = score or '', rank = rank}
+	if #candidates > 0 then
+		detail.candidates = candidates
+	end
+	return cjson.encode(detail)
+end
+local function trace_sorted_set(flags, index, victim, admitted)
+	if not has_flag(flags, 't') then
+		return ''
+	end
+	local candidates = {}
+	local lowest = redis.call('ZRANGE', index, 0, 6, 'WITHSCORES')
+	for i = 1, #lowest, 2 do
+		if lowest[i] ~= victim and lowest[i] ~= admitted and #candidates < 5 then
+			table.insert(candidates, {lowest[i], lowest[i + 1]})
+		end
+	end
+	return trace_detail(flags, redis.call('ZSCORE', index, victim), redis.call('ZRANK', index, victim), candidates)
+end
+local function trace_list_head(flags, index, admitted)
+	if not has_flag(flags, 't') then
+		return ''
+	end
+	local candidates = {}
+	for _, key in ipairs(redis.call('LRANGE', index, 1, 6)) do
+		if key ~= admitted and #candidates < 5 then
+			table.insert(candidates, {key, ''})
+		end
+	end
+	return trace_detail(flags, '', 0, candidates)
+end
+local function record_eviction(evicted, key, reason, value, trace)
+	table.insert(evicted, key)
+	table.insert(evicted, reason)
+	table.insert(evicted, value)
+	table.insert(evicted, trace)
+end
+`
+
+// admitSortedSetScript atomically admits a key into a cache tracked by a sorted set.
+// If the key is already a member, its value is updated in place and its score is only replaced
+// when ARGV[4] is "1", so policies can either refresh or preserve it.
+// Otherwise, if the index is at capacity, the member with the lowest score is popped and its value key deleted
+// before the new member is added with the given score and its value is written.
+// A popped member whose value key already expired frees its slot without evicting a live entry.
+//
+// If a byte capacity is given, the size of the entry is recorded and members with the lowest score,
+// other than the admitted one, are evicted until the total size fits, see trackBytes.
+//
+// Every write assigns the entry a new version, see bumpVersion.
+//
+// KEYS[1]: the sorted set index
+// KEYS[2]: the value key to admit
+// KEYS[3]: the version hash
+// KEYS[4]: the size hash
+// ARGV[1]: the capacity of the cache in items, or 0 for no item limit
+// ARGV[2]: the score of the new member
+// ARGV[3]: the serialized value
+// ARGV[4]: "1" to replace the score of an existing member, "0" to preserve it
+// ARGV[5]: the storage mode of the value, see writeValue
+// ARGV[6]: the capacity of the cache in bytes, or 0 for no byte limit
+// ARGV[7]: how to measure entries, see measureMemory and measureLength
+// ARGV[8]: the flags selecting what to return about evicted entries, see captureEvicted
+//
+// It returns the evicted entries, see captureEvicted.
+var admitSortedSetScript = scripts.register(bumpVersion + writeValue + trackBytes + readValue + captureEvicted + `
+local evicted = {}
+local function evict(member, reason)
+	local trace = trace_sorted_set(ARGV[8], KEYS[1], member, KEYS[2])
+	redis.call('ZREM', KEYS[1], member)
+	redis.call('HDEL', KEYS[3], member)
+	release_bytes(KEYS[4], member)
+	local value = capture_value(member, ARGV[5], ARGV[8])
+	if redis.call('DEL', member) == 1 then
+		record_eviction(evicted, member, reason, value, trace)
+	end
+end
+local capacity = tonumber(ARGV[1])
+if not redis.call('ZSCORE', KEYS[1], KEYS[2]) then
+	if capacity > 0 and redis.call('ZCARD', KEYS[1]) >= capacity then
+		local lowest = redis.call('ZRANGE', KEYS[1], 0, 0)
+		if lowest[1] then
+			evict(lowest[1], '` + EvictCapacity + `')
+		end
+	end
+	redis.call('ZADD', KEYS[1], ARGV[2], KEYS[2])
+elseif ARGV[4] == '1' then
+	redis.call('ZADD', KEYS[1], ARGV[2], KEYS[2])
+end
+write_value(KEYS[2], ARGV[3], ARGV[5])
+bump_version(KEYS[3], KEYS[2])
+local max_bytes = tonumber(ARGV[6])
+if max_bytes > 0 then
+	local total = account_bytes(KEYS[4], KEYS[2], ARGV[7])
+	while total > max_bytes do
+		local victim = nil
+		for _, member in ipairs(redis.call('ZRANGE', KEYS[1], 0, 1)) do
+			if member ~= KEYS[2] then
+				victim = member
+				break
+			end
+		end
+		if not victim then
+			break
+		end
+		evict(victim, '` + EvictBytes + `')
+		total = total_bytes(KEYS[4])
+	end
+end
+return evicted
+`)
+
+// pushListScript appends a value key to the tail of a list index unless the membership set already holds it, so
+// writing a cached key again keeps its position, as in admitListScript. Like bumpVersionScript, it is queued in the
+// MULTI/EXEC transaction of the write with Eval.
+//
+// KEYS[1]: the list index
+// KEYS[2]: the membership set
+// KEYS[3]: the value key
+//
+// It returns 1 if the key was pushed, or 0 if it was already a member.
+var pushListScript = scripts.register(`
+if redis.call('SADD', KEYS[2], KEYS[3]) == 0 then
+	return 0
+end
+redis.call('RPUSH', KEYS[1], KEYS[3])
+return 1
+`)
+
+// admitListScript atomically admits a key into a cache tracked by a list.
+// Membership is tracked in a companion set, so duplicate admissions are detected in O(1):
+// if the key is already cached, only its value is updated and its position is kept.
+// Otherwise, if the cache is at capacity, the head of the list is popped and its value key deleted
+// before the new key is pushed to the tail and its value is written.
+// A popped key whose value already expired frees its slot without evicting a live entry.
+//
+// If a byte capacity is given, the size of the entry is recorded and the head of the list is evicted
+// until the total size fits or the admitted key reaches the head, see trackBytes.
+//
+// Every write assigns the entry a new version, see bumpVersion.
+//
+// KEYS[1]: the list index
+// KEYS[2]: the value key to admit
+// KEYS[3]: the version hash
+// KEYS[4]: the membership set
+// KEYS[5]: the size hash
+// ARGV[1]: the capacity of the cache in items, or 0 for no item limit
+// ARGV[2]: the serialized value
+// ARGV[3]: the storage mode of the value, see writeValue
+// ARGV[4]: the capacity of the cache in bytes, or 0 for no byte limit
+// ARGV[5]: how to measure entries, see measureMemory and measureLength
+// ARGV[6]: the flags selecting what to return about evicted entries, see captureEvicted
+//
+// It returns the evicted entries, see captureEvicted.
+var admitListScript = scripts.register(bumpVersion + writeValue + trackBytes + readValue + captureEvicted + `
+local evicted = {}
+local function evict_head(reason)
+	local trace = trace_list_head(ARGV[6], KEYS[1], KEYS[2])
+	local popped = redis.call('LPOP', KEYS[1])
+	if popped then
+		redis.call('SREM', KEYS[4], popped)
+		redis.call('HDEL', KEYS[3], popped)
+		release_bytes(KEYS[5], popped)
+		local value = capture_value(popped, ARGV[3], ARGV[6])
+		if redis.call('DEL', popped) == 1 then
+			record_eviction(evicted, popped, reason, value, trace)
+		end
+	end
+	return popped
+end
+local capacity = tonumber(ARGV[1])
+if redis.call('SISMEMBER', KEYS[4], KEYS[2]) == 0 then
+	if capacity > 0 and redis.call('SCARD', KEYS[4]) >= capacity then
+		evict_head('` + EvictCapacity + `')
+	end
+	redis.call('RPUSH', KEYS[1], KEYS[2])
+	redis.call('SADD', KEYS[4], KEYS[2])
+end
+write_value(KEYS[2], ARGV[2], ARGV[3])
+bump_version(KEYS[3], KEYS[2])
+local max_bytes = tonumber(ARGV[4])
+if max_bytes > 0 then
+	local total = account_bytes(KEYS[5], KEYS[2], ARGV[5])
+	while total > max_bytes and redis.call('LINDEX', KEYS[1], 0) ~= KEYS[2] do
+		if not evict_head('` + EvictBytes + `') then
+			break
+		end
+		total = total_bytes(KEYS[5])
+	end
+end
+return evicted
+`)
+
+// evictSortedSetScript atomically pops the member with the lowest score from a sorted set index
+// and deletes its value key, so a crash cannot leave the value key orphaned.
+//
+// KEYS[1]: the sorted set index
+// KEYS[2]: the version hash
+// KEYS[3]: the size hash
+// ARGV[1]: the storage mode of the value, see writeValue
+// ARGV[2]: the flags selecting what to return about the evicted entry, see captureEvicted
+//
+// It returns the evicted key, its value and its trace, or false if the index was empty.
+var evictSortedSetScript = scripts.register(trackBytes + readValue + captureEvicted + `
+local lowest = redis.call('ZRANGE', KEYS[1], 0, 0)
+if not lowest[1] then
+	return false
+end
+local trace = trace_sorted_set(ARGV[2], KEYS[1], lowest[1], '')
+local popped = redis.call('ZPOPMIN', KEYS[1])
+local value = capture_value(popped[1], ARGV[1], ARGV[2])
+redis.call('DEL', popped[1])
+redis.call('HDEL', KEYS[2], popped[1])
+release_bytes(KEYS[3], popped[1])
+return {popped[1], value, trace}
+`)
+
+// evictListScript atomically pops the head of a list index and deletes its value key,
+// so a crash cannot leave the value key orphaned.
+//
+// KEYS[1]: the list index
+// KEYS[2]: the version hash
+// KEYS[3]: the membership set
+// KEYS[4]: the size hash
+// ARGV[1]: the storage mode of the value, see writeValue
+// ARGV[2]: the flags selecting what to return about the evicted entry, see captureEvicted
+//
+// It returns the evicted key, its value and its trace, or false if the index was empty.
+var evictListScript = scripts.register(trackBytes + readValue + captureEvicted + `
+local trace = trace_list_head(ARGV[2], KEYS[1], '')
+local evicted = redis.call('LPOP', KEYS[1])
+if not evicted then
+	return false
+end
+local value = capture_value(evicted, ARGV[1], ARGV[2])
+redis.call('DEL', evicted)
+redis.call('HDEL', KEYS[2], evicted)
+redis.call('SREM', KEYS[3], evicted)
+release_bytes(KEYS[4], evicted)
+return {evicted, value, trace}
+`)
+
+// admitSampledScript atomically admits a key into an approximated LRU cache tracked by a hash of access times.
+// It is the single entry point used by every application instance in coordinated eviction mode:
+// if the key is already cached, its value and access time are updated. Otherwise, while the cache is at capacity,
+// ARGV[4] random fields are sampled and the oldest one is evicted, incrementing the eviction epoch.
+// Because sampling, eviction and admission happen in one script, concurrent writers can never push
+// the cache above its capacity.
+//
+// If a byte capacity is given, the size of the entry is recorded and the oldest sampled field,
+// other than the admitted one, is evicted until the total size fits, see trackBytes.
+//
+// KEYS[1]: the access time hash
+// KEYS[2]: the value key to admit
+// KEYS[3]: the version hash
+// KEYS[4]: the eviction epoch counter
+// KEYS[5]: the size hash
+// ARGV[1]: the capacity of the cache in items, or 0 for no item limit
+// ARGV[2]: the access time of the new entry
+// ARGV[3]: the serialized value
+// ARGV[4]: the sample size
+// ARGV[5]: the storage mode of the value, see writeValue
+// ARGV[6]: the capacity of the cache in bytes, or 0 for no byte limit
+// ARGV[7]: how to measure entries, see measureMemory and measureLength
+// ARGV[8]: the flags selecting what to return about evicted entries, see captureEvicted
+//
+// It returns the evicted entries, see captureEvicted.
+var admitSampledScript = scripts.register(bumpVersion + writeValue + trackBytes + readValue + captureEvicted + `
+local evicted = {}
+local function evict_sampled(reason)
+	local sample = redis.call('HRANDFIELD', KEYS[1], ARGV[4], 'WITHVALUES')
+	local victim = nil
+	local oldest = nil
+	for i = 1, #sample, 2 do
+		local accessed_at = tonumber(sample[i + 1])
+		if sample[i] ~= KEYS[2] and (oldest == nil or accessed_at < oldest) then
+			victim = sample[i]
+			oldest = accessed_at
+		end
+	end
+	if victim == nil then
+		return false
+	end
+	local trace = ''
+	if has_flag(ARGV[8], 't') then
+		local candidates = {}
+		for i = 1, #sample, 2 do
+			if sample[i] ~= victim and sample[i] ~= KEYS[2] then
+				table.insert(candidates, {sample[i], sample[i + 1]})
+			end
+		end
+		trace = trace_detail(ARGV[8], redis.call('HGET', KEYS[1], victim), 0, candidates)
+	end
+	redis.call('HDEL', KEYS[1], victim)
+	redis.call('HDEL', KEYS[3], victim)
+	release_bytes(KEYS[5], victim)
+	local value = capture_value(victim, ARGV[5], ARGV[8])
+	redis.call('DEL', victim)
+	redis.call('INCR', KEYS[4])
+	record_eviction(evicted, victim, reason, value, trace)
+	return true
+end
+local capacity = tonumber(ARGV[1])
+if capacity > 0 and redis.call('HEXISTS', KEYS[1], KEYS[2]) == 0 then
+	while redis.call('HLEN', KEYS[1]) >= capacity do
+		if not evict_sampled('` + EvictCapacity + `') then
+			break
+		end
+	end
+end
+redis.call('HSET', KEYS[1], KEYS[2], ARGV[2])
+write_value(KEYS[2], ARGV[3], ARGV[5])
+bump_version(KEYS[3], KEYS[2])
+local max_bytes = tonumber(ARGV[6])
+if max_bytes > 0 then
+	local total = account_bytes(KEYS[5], KEYS[2], ARGV[7])
+	while total > max_bytes and redis.call('HLEN', KEYS[1]) > 1 do
+		if not evict_sampled('` + EvictBytes + `') then
+			break
+		end
+		total = total_bytes(KEYS[5])
+	end
+end
+return evicted
+`)
+
+// admitSortedSetBatchScript atomically admits several keys into a cache tracked by a sorted set, like
+// admitSortedSetScript, with a single eviction pass: every key is added and written first, then the members
+// with the lowest scores are evicted until the index fits its capacity, and then, if a byte capacity is given,
+// until the total size fits, sparing the last admitted key.
+//
+// KEYS[1]: the sorted set index
+// KEYS[2]: the version hash
+// KEYS[3]: the size hash
+// KEYS[4...]: the value keys to admit
+// ARGV[1]: the capacity of the cache in items, or 0 for no item limit
+// ARGV[2]: "1" to replace the score of an existing member, "0" to preserve it
+// ARGV[3]: the storage mode of the values, see writeValue
+// ARGV[4]: the capacity of the cache in bytes, or 0 for no byte limit
+// ARGV[5]: how to measure entries, see measureMemory and measureLength
+// ARGV[6]: the flags selecting what to return about evicted entries, see captureEvicted
+// ARGV[7...]: the score and the serialized value of every value key, in order
+//
+// It returns the evicted entries, see captureEvicted.
+var admitSortedSetBatchScript = scripts.register(bumpVersion + writeValue + trackBytes + readValue + captureEvicted + `
+local evicted = {}
+local function evict(member, reason)
+	local trace = trace_sorted_set(ARGV[6], KEYS[1], member, '')
+	redis.call('ZREM', KEYS[1], member)
+	redis.call('HDEL', KEYS[2], member)
+	release_bytes(KEYS[3], member)
+	local value = capture_value(member, ARGV[3], ARGV[6])
+	if redis.call('DEL', member) == 1 then
+		record_eviction(evicted, member, reason, value, trace)
+	end
+end
+local max_bytes = tonumber(ARGV[4])
+for i = 4, #KEYS do
+	local score, value = ARGV[2 * i - 1], ARGV[2 * i]
+	if ARGV[2] == '1' or not redis.call('ZSCORE', KEYS[1], KEYS[i]) then
+		redis.call('ZADD', KEYS[1], score, KEYS[i])
+	end
+	write_value(KEYS[i], value, ARGV[3])
+	bump_version(KEYS[2], KEYS[i])
+	if max_bytes > 0 then
+		account_bytes(KEYS[3], KEYS[i], ARGV[5])
+	end
+end
+local last = KEYS[#KEYS]
+local capacity = tonumber(ARGV[1])
+if capacity > 0 then
+	local excess = redis.call('ZCARD', KEYS[1]) - capacity
+	if excess > 0 then
+		for _, member in ipairs(redis.call('ZRANGE', KEYS[1], 0, excess)) do
+			if member ~= last and excess > 0 then
+				evict(member, '` + EvictCapacity + `')
+				excess = excess - 1
+			end
+		end
+	end
+end
+if max_bytes > 0 then
+	while total_bytes(KEYS[3]) > max_bytes do
+		local victim = nil
+		for _, member in ipairs(redis.call('ZRANGE', KEYS[1], 0, 1)) do
+			if member ~= last then
+				victim = member
+				break
+			end
+		end
+		if not victim then
+			break
+		end
+		evict(victim, '` + EvictBytes + `')
+	end
+end
+return evicted
+`)
+
+// admitListBatchScript atomically admits several keys into a cache tracked by a list, like admitListScript,
+// with a single eviction pass: every key that is not cached yet is pushed to the tail and every value is written
+// first, then the head of the list is evicted until the cache fits its capacity, and then, if a byte capacity
+// is given, until the total size fits or the last admitted key reaches the head.
+//
+// KEYS[1]: the list index
+// KEYS[2]: the version hash
+// KEYS[3]: the membership set
+// KEYS[4]: the size hash
+// KEYS[5...]: the value keys to admit
+// ARGV[1]: the capacity of the cache in items, or 0 for no item limit
+// ARGV[2]: the storage mode of the values, see writeValue
+// ARGV[3]: the capacity of the cache in bytes, or 0 for no byte limit
+// ARGV[4]: how to measure entries, see measureMemory and measureLength
+// ARGV[5]: the flags selecting what to return about evicted entries, see captureEvicted
+// ARGV[6...]: the serialized value of every value key, in order
+//
+// It returns the evicted entries, see captureEvicted.
+var admitListBatchScript = scripts.register(bumpVersion + writeValue + trackBytes + readValue + captureEvicted + `
+local evicted = {}
+local function evict_head(reason)
+	local trace = trace_list_head(ARGV[5], KEYS[1], '')
+	local popped = redis.call('LPOP', KEYS[1])
+	if popped then
+		redis.call('SREM', KEYS[3], popped)
+		redis.call('HDEL', KEYS[2], popped)
+		release_bytes(KEYS[4], popped)
+		local value = capture_value(popped, ARGV[2], ARGV[5])
+		if redis.call('DEL', popped) == 1 then
+			record_eviction(evicted, popped, reason, value, trace)
+		end
+	end
+	return popped
+end
+local max_bytes = tonumber(ARGV[3])
+for i = 5, #KEYS do
+	if redis.call('SISMEMBER', KEYS[3], KEYS[i]) == 0 then
+		redis.call('RPUSH', KEYS[1], KEYS[i])
+		redis.call('SADD', KEYS[3], KEYS[i])
+	end
+	write_value(KEYS[i], ARGV[i + 1], ARGV[2])
+	bump_version(KEYS[2], KEYS[i])
+	if max_bytes > 0 then
+		account_bytes(KEYS[4], KEYS[i], ARGV[4])
+	end
+end
+local capacity = tonumber(ARGV[1])
+if capacity > 0 then
+	while redis.call('SCARD', KEYS[3]) > capacity do
+		if not evict_head('` + EvictCapacity + `') then
+			break
+		end
+	end
+end
+if max_bytes > 0 then
+	local last = KEYS[#KEYS]
+	while total_bytes(KEYS[4]) > max_bytes and redis.call('LINDEX', KEYS[1], 0) ~= last do
+		if not evict_head('` + EvictBytes + `') then
+			break
+		end
+	end
+end
+return evicted
+`)
+
+// admitSampledBatchScript atomically admits several keys into an approximated LRU cache tracked by a hash of
+// access times, like admitSampledScript, with a single eviction pass: every key is added and written first,
+// then the oldest of ARGV[3] random fields is evicted until the cache fits its capacity, and then, if a byte
+// capacity is given, until the total size fits. Fields admitted by the script are only evicted when a sample
+// holds no other field, and the last admitted key never is.
+//
+// KEYS[1]: the access time hash
+// KEYS[2]: the version hash
+// KEYS[3]: the eviction epoch counter
+// KEYS[4]: the size hash
+// KEYS[5...]: the value keys to admit
+// ARGV[1]: the capacity of the cache in items, or 0 for no item limit
+// ARGV[2]: the access time of the admitted entries
+// ARGV[3]: the sample size
+// ARGV[4]: the storage mode of the values, see writeValue
+// ARGV[5]: the capacity of the cache in bytes, or 0 for no byte limit
+// ARGV[6]: how to measure entries, see measureMemory and measureLength
+// ARGV[7]: the flags selecting what to return about evicted entries, see captureEvicted
+// ARGV[8...]: the serialized value of every value key, in order
+//
+// It returns the evicted entries, see captureEvicted.
+var admitSampledBatchScript = scripts.register(bumpVersion + writeValue + trackBytes + readValue + captureEvicted + `
+local evicted = {}
+local admitted = {}
+local last = KEYS[#KEYS]
+local function evict_sampled(reason)
+	local sample = redis.call('HRANDFIELD', KEYS[1], ARGV[3], 'WITHVALUES')
+	local victim, oldest, fallback, fallback_oldest = nil, nil, nil, nil
+	for i = 1, #sample, 2 do
+		local accessed_at = tonumber(sample[i + 1])
+		if not admitted[sample[i]] and (oldest == nil or accessed_at < oldest) then
+			victim = sample[i]
+			oldest = accessed_at
+		elseif sample[i] ~= last and (fallback_oldest == nil or accessed_at < fallback_oldest) then
+			fallback = sample[i]
+			fallback_oldest = accessed_at
+		end
+	end
+	victim = victim or fallback
+	if victim == nil then
+		return false
+	end
+	local trace = ''
+	if has_flag(ARGV[7], 't') then
+		local candidates = {}
+		for i = 1, #sample, 2 do
+			if sample[i] ~= victim then
+				table.insert(candidates, {sample[i], sample[i + 1]})
+			end
+		end
+		trace = trace_detail(ARGV[7], redis.call('HGET', KEYS[1], victim), 0, candidates)
+	end
+	redis.call('HDEL', KEYS[1], victim)
+	redis.call('HDEL', KEYS[2], victim)
+	release_bytes(KEYS[4], victim)
+	local value = capture_value(victim, ARGV[4], ARGV[7])
+	redis.call('DEL', victim)
+	redis.call('INCR', KEYS[3])
+	record_eviction(evicted, victim, reason, value, trace)
+	return true
+end
+local max_bytes = tonumber(ARGV[5])
+for i = 5, #KEYS do
+	redis.call('HSET', KEYS[1], KEYS[i], ARGV[2])
+	write_value(KEYS[i], ARGV[i + 3], ARGV[4])
+	bump_version(KEYS[2], KEYS[i])
+	if max_bytes > 0 then
+		account_bytes(KEYS[4], KEYS[i], ARGV[6])
+	end
+	admitted[KEYS[i]] = true
+end
+local capacity = tonumber(ARGV[1])
+if capacity > 0 then
+	while redis.call('HLEN', KEYS[1]) > capacity do
+		if not evict_sampled('` + EvictCapacity + `') then
+			break
+		end
+	end
+end
+if max_bytes > 0 then
+	while total_bytes(KEYS[4]) > max_bytes and redis.call('HLEN', KEYS[1]) > 1 do
+		if not evict_sampled('` + EvictBytes + `') then
+			break
+		end
+	end
+end
+return evicted
+`)
@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const indexKeyPrefix = "cache_index"
+const indexedKeyPrefix = "cache_indexed"
+
+// ErrNotIndexed is returned by FindBy for a field that was not declared with WithIndex.
+var ErrNotIndexed = errors.New("field is not indexed")
+
+// WithIndex maintains a secondary index of cached users by each of the given fields, named by their stored names,
+// e.g. WithIndex("name"). Every indexed value is a Redis set of the keys of the users holding it,
+// kept up to date on Set, Delete and eviction, and queried with FindBy.
+func WithIndex(fields ...string) Option {
+	return func(o *options) {
+		o.indexes = append(o.indexes, fields...)
+	}
+}
+
+// indexed reports whether field was declared with WithIndex.
+func (o options) indexed(field string) bool {
+	for _, f := range o.indexes {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// indexEntry adds cacheKey to the index set of the current value of every indexed field of v, and removes it
+// from the set of the value it was previously indexed under. The previous values are kept in one hash per field.
+// A nil v, the raw payload of RawCache.SetBytes, has no fields to index.
+func (o options) indexEntry(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, cacheKey string, v interface{}) error {
+	if len(o.indexes) == 0 || v == nil {
+		return nil
+	}
+
+	values, err := structFields(v)
+	if err != nil {
+		return err
+	}
+
+	previous := make([]*redis.StringCmd, len(o.indexes))
+	_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, field := range o.indexes {
+			previous[i] = pipe.HGet(ctx, generateKey(indexedKeyPrefix, field), cacheKey)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	log.Printf("Indexing key: %s by fields: %v", cacheKey, o.indexes)
+	_, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, field := range o.indexes {
+			value, ok := values[field]
+			if !ok {
+				return fmt.Errorf("cannot index by unknown field: %s", field)
+			}
+			if old, err := previous[i].Result(); err == nil && old != value {
+				pipe.SRem(ctx, generateKey(indexKeyPrefix, field, old), cacheKey)
+			}
+			pipe.SAdd(ctx, generateKey(indexKeyPrefix, field, value), cacheKey)
+			pipe.HSet(ctx, generateKey(indexedKeyPrefix, field), cacheKey, value)
+		}
+		return nil
+	})
+	return err
+}
+
+// unindexEntries removes the given keys from the index sets they were indexed under.
+func (o options) unindexEntries(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, cacheKeys ...string) error {
+	if len(o.indexes) == 0 || len(cacheKeys) == 0 {
+		return nil
+	}
+
+	previous := make([]*redis.SliceCmd, len(o.indexes))
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, field := range o.indexes {
+			previous[i] = pipe.HMGet(ctx, generateKey(indexedKeyPrefix, field), cacheKeys...)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Removing keys: %v from indexes: %v", cacheKeys, o.indexes)
+	_, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, field := range o.indexes {
+			for j, value := range previous[i].Val() {
+				if s, ok := value.(string); ok {
+					pipe.SRem(ctx, generateKey(indexKeyPrefix, field, s), cacheKeys[j])
+				}
+			}
+			pipe.HDel(ctx, generateKey(indexedKeyPrefix, field), cacheKeys...)
+		}
+		return nil
+	})
+	return err
+}
+
+// findBy returns the users whose field holds value. Index entries of users that are no longer cached are pruned
+// with prune, and entries of users whose field changed since they were indexed are dropped from the set.
+func (o options) findBy(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, field, value string, prune func(cacheKey string) error) ([]User, error) {
+	if !o.indexed(field) {
+		return nil, ErrNotIndexed
+	}
+
+	setKey := generateKey(indexKeyPrefix, field, value)
+	log.Printf("Finding users by %s: %s in index: %s", field, value, setKey)
+	cacheKeys, err := client.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	userKeyPrefix := generateKey(userPrefix) + ":"
+	users := make([]User, 0, len(cacheKeys))
+	for _, cacheKey := range cacheKeys {
+		user, err := o.readUser(ctx, client, cacheKey, strings.TrimPrefix(cacheKey, userKeyPrefix))
+		if err == redis.Nil {
+			log.Printf("Indexed key: %s is no longer cached. Pruning.", cacheKey)
+			if err := prune(cacheKey); err != nil {
+				log.Printf("Failed to prune key: %s: %v", cacheKey, err)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		fields, err := structFields(&user)
+		if err != nil {
+			return nil, err
+		}
+		if fields[field] != value {
+			log.Printf("Indexed key: %s no longer has %s: %s. Removing from index.", cacheKey, field, value)
+			if err := client.SRem(ctx, setKey, cacheKey).Err(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// FindBy returns the cached users whose field, named by its stored name, holds value, e.g. FindBy("name", "Alice").
+// The field must have been declared with WithIndex. Finding users does not count as an access to them.
+func (c *FIFOCache) FindBy(field, value string) ([]User, error) {
+	return c.findBy(c.ctx, c.client, c.generateKey, field, value, c.pruneKey)
+}
+
+// FindBy returns the cached users whose field, named by its stored name, holds value, e.g. FindBy("name", "Alice").
+// The field must have been declared with WithIndex. Finding users does not count as an access to them.
+func (c *LRUCache) FindBy(field, value string) ([]User, error) {
+	return c.findBy(c.ctx, c.client, c.generateKey, field, value, c.pruneKey)
+}
+
+// FindBy returns the cached users whose field, named by its stored name, holds value, e.g. FindBy("name", "Alice").
+// The field must have been declared with WithIndex. Finding users does not count as an access to them.
+func (c *LFUCache) FindBy(field, value string) ([]User, error) {
+	return c.findBy(c.ctx, c.client, c.generateKey, field, value, c.pruneKey)
+}
+
+// FindBy returns the cached users whose field, named by its stored name, holds value, e.g. FindBy("name", "Alice").
+// The field must have been declared with WithIndex. Finding users does not count as an access to them.
+func (c *ApproxLRUCache) FindBy(field, value string) ([]User, error) {
+	return c.findBy(c.ctx, c.client, c.generateKey, field, value, c.pruneKey)
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// setManyBatchSize is the number of users SetMany admits with a single script call. Larger imports take several
+// calls, so Redis is never blocked by a single one for long.
+const setManyBatchSize = 500
+
+// batchAdmission is a call of the batch admission script of a cache, admitting several users and evicting entries
+// to make room in a single pass.
+type batchAdmission struct {
+	users     []User
+	cacheKeys []string
+	script    *redis.Script
+	keys      []string
+	args      []interface{}
+}
+
+// admitMany admits users with their batch admission scripts, setManyBatchSize users per call, and tracks them as
+// written from source. It returns the number of users admitted.
+func (o options) admitMany(ctx context.Context, client Client, generateKey func(...string) string, policy string, users []User, batch func([]User) (batchAdmission, error), source string) (int, error) {
+	admitted := 0
+	for first := 0; first < len(users); first += setManyBatchSize {
+		b, err := batch(users[first:min(first+setManyBatchSize, len(users))])
+		if err != nil {
+			return admitted, err
+		}
+
+		start := time.Now()
+		var reply []string
+		err = o.withRetry(ctx, func() error {
+			reply, err = scripts.run(ctx, client, b.script, b.keys, b.args...).StringSlice()
+			return err
+		})
+		if err != nil {
+			log.Printf("Error admitting %d keys to index: %s: %v", len(b.cacheKeys), b.keys[0], err)
+			return admitted, err
+		}
+
+		// Entries are tracked before the evictions are handled, which drop the entries of the users evicted by
+		// the users admitted after them.
+		for i := range b.users {
+			if err := o.trackEntry(ctx, client, generateKey, b.cacheKeys[i], &b.users[i], source); err != nil {
+				return admitted, err
+			}
+			o.notifyAdmit(ctx, client, generateKey, b.cacheKeys[i])
+		}
+		if err := o.evicted(ctx, client, generateKey, policy, reply, start); err != nil {
+			return admitted, err
+		}
+		o.counters.sets.Add(int64(len(b.users)))
+		o.publishInvalidation(ctx, b.cacheKeys...)
+		admitted += len(b.users)
+	}
+	return admitted, nil
+}
+
+// setMany admits users under the eviction lock with admitMany, tracked as written from source, and queues their writes
+// for write-behind.
+func (o options) setMany(ctx context.Context, client Client, generateKey func(...string) string, policy string, users []User, batch func([]User) (batchAdmission, error), source string) error {
+	if len(users) == 0 {
+		return nil
+	}
+	defer o.counters.observe(opSet, time.Now())
+
+	o.logf(LogWrite, "Attempting to set %d users to cache.", len(users))
+	err := withLock(ctx, o.locker, generateKey(lockKeyPrefix), func() error {
+		_, err := o.admitMany(ctx, client, generateKey, policy, users, batch, source)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := o.enqueueWrite(ctx, generateKey(userPrefix, user.Id), user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetMany adds users to the cache like Set, in batches admitted by a single script call each, which performs
+// the evictions the whole batch needs in one pass instead of one roundtrip per user. Cached users keep their
+// position.
+func (c *FIFOCache) SetMany(users []User) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.setMany(c.ctx, c.client, c.generateKey, string(PolicyFIFO), users, c.batchAdmission, SourceSet)
+}
+
+// SetMany adds users to the cache like Set, in batches admitted by a single script call each, which performs
+// the evictions the whole batch needs in one pass instead of one roundtrip per user. The users are admitted
+// in order, the last as the most recently used.
+func (c *LRUCache) SetMany(users []User) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.setMany(c.ctx, c.client, c.generateKey, string(PolicyLRU), users, c.batchAdmission, SourceSet)
+}
+
+// SetMany adds users to the cache like Set, in batches admitted by a single script call each, which performs
+// the evictions the whole batch needs in one pass instead of one roundtrip per user. Cached users keep their
+// access count.
+func (c *LFUCache) SetMany(users []User) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.setMany(c.ctx, c.client, c.generateKey, string(PolicyLFU), users, c.batchAdmission, SourceSet)
+}
+
+// SetMany adds users to the cache like Set in coordinated eviction mode, in batches admitted by a single script
+// call each, which samples and evicts the entries the whole batch needs in one pass instead of one roundtrip
+// per user.
+func (c *ApproxLRUCache) SetMany(users []User) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.setMany(c.ctx, c.client, c.generateKey, string(PolicyApproxLRU), users, c.batchAdmission, SourceSet)
+}
+
+// SetMany adds users to the cache like Set, with the TTL of the cache, in batches of setManyBatchSize users
+// written with a single MULTI/EXEC pipeline each.
+func (c *TTLCache) SetMany(users []User) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	defer c.counters.observe(opSet, time.Now())
+
+	soft, hard := c.expiration, c.expiration+c.maxStale
+	for first := 0; first < len(users); first += setManyBatchSize {
+		batch := users[first:min(first+setManyBatchSize, len(users))]
+		cacheKeys := make([]string, len(batch))
+		for i, user := range batch {
+			cacheKeys[i] = c.generateKey(userPrefix, user.Id)
+		}
+
+		c.logf(LogWrite, "Setting values for %d keys", len(batch))
+		err := c.withRetry(c.ctx, func() error {
+			_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+				for i := range batch {
+					c.writeExpiry(pipe, batch[i].Id, soft, hard)
+					if err := c.writeValue(c.ctx, pipe, cacheKeys[i], &batch[i], c.retention(soft, hard)); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			return err
+		})
+		if err != nil {
+			log.Printf("Error setting values for %d keys: %v", len(batch), err)
+			return err
+		}
+
+		c.counters.sets.Add(int64(len(batch)))
+		for _, cacheKey := range cacheKeys {
+			c.notifyAdmit(c.ctx, c.client, c.generateKey, cacheKey)
+		}
+		c.publishInvalidation(c.ctx, cacheKeys...)
+	}
+	return nil
+}
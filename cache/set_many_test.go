@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testUsers returns the users with ids from to to, included.
+func testUsers(from, to int) []User {
+	users := make([]User, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		users = append(users, testUser(i))
+	}
+	return users
+}
+
+func TestSetMany(t *testing.T) {
+	for name, newCache := range manyCaches {
+		t.Run(name, func(t *testing.T) {
+			client, _ := newTestClient(t)
+			c := newCache(client)
+			setUsers(t, c.Set, nil, 1)
+
+			renamed := User{Id: "1", Name: "Renamed", Age: 30}
+			if err := c.SetMany(append(testUsers(2, 3), renamed)); err != nil {
+				t.Fatalf("SetMany: %v", err)
+			}
+			for _, want := range []User{renamed, testUser(2), testUser(3)} {
+				if got, err := c.Get(want.Id); err != nil || got != want {
+					t.Errorf("Get(%s): %+v, %v, want %+v", want.Id, got, err, want)
+				}
+			}
+			if err := c.SetMany(nil); err != nil {
+				t.Errorf("SetMany of no users: %v", err)
+			}
+		})
+	}
+}
+
+func TestSetManyEvicts(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+	fifo := NewFIFO(ctx, client, 3, "fifo")
+	clock := testClock()
+	lru := NewLRU(ctx, client, 3, "lru", WithClock(clock))
+
+	setUsers(t, fifo.Set, nil, 1, 2)
+	setUsers(t, lru.Set, clock, 1, 2)
+	clock.Advance(time.Millisecond)
+	if err := fifo.SetMany(testUsers(3, 5)); err != nil {
+		t.Fatalf("SetMany: %v", err)
+	}
+	if err := lru.SetMany(testUsers(3, 5)); err != nil {
+		t.Fatalf("SetMany: %v", err)
+	}
+	assertCached(t, client, fifo.Key, 3, 4, 5)
+	assertCached(t, client, lru.Key, 3, 4, 5)
+}
+
+func TestSetManyInSeveralBatches(t *testing.T) {
+	client, _ := newTestClient(t)
+	capacity := setManyBatchSize + 100
+	c := NewLRU(context.Background(), client, capacity, "lru")
+
+	users := testUsers(1, 2*setManyBatchSize+50)
+	if err := c.SetMany(users); err != nil {
+		t.Fatalf("SetMany: %v", err)
+	}
+	if size := c.CacheSize(); size != capacity {
+		t.Errorf("size: %d, want %d", size, capacity)
+	}
+	// The last users are cached, and the first ones were evicted.
+	for _, id := range []int{1, len(users) - capacity} {
+		if _, err := c.Get(strconv.Itoa(id)); err == nil {
+			t.Errorf("user %d was not evicted", id)
+		}
+	}
+	for _, id := range []int{len(users) - capacity + 1, len(users)} {
+		if _, err := c.Get(strconv.Itoa(id)); err != nil {
+			t.Errorf("Get(%d): %v", id, err)
+		}
+	}
+}
+
+func TestSetManyQueuesWrites(t *testing.T) {
+	client, _ := newTestClient(t)
+	store := &recordingStore{}
+	wb := newTestWriteBehind(t, store)
+	c := NewLRU(context.Background(), client, 10, "lru", WithWriteBehind(wb))
+
+	if err := c.SetMany(testUsers(1, 3)); err != nil {
+		t.Fatalf("SetMany: %v", err)
+	}
+	if err := wb.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	assertSaved(t, store, testUsers(1, 3)...)
+}
@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const softExpiryKeyPrefix = "soft_expiry"
+const revalidateKeyPrefix = "revalidate"
+
+// WithStaleWhileRevalidate keeps the entries of a TTL cache for maxStale past their expiration.
+// A Get of an expired but present entry returns it immediately and refreshes it from the loader in a background goroutine,
+// so callers never wait for the loader while an entry is less than maxStale out of date.
+// The expiration of the cache becomes the soft TTL of its entries and expiration + maxStale their hard TTL;
+// SetWithExpiry sets both per entry.
+// The other caches do not expire entries and ignore this option.
+func WithStaleWhileRevalidate(maxStale time.Duration) Option {
+	return func(o *options) {
+		o.maxStale = maxStale
+	}
+}
+
+// WithStaleIfError keeps the entries of a TTL cache for maxStale past their expiration, so that if the loader fails
+// after an entry expired, MakeRequest returns the last known value instead of an empty user.
+// Expired entries are otherwise treated as misses. The other caches do not expire entries and ignore this option.
+func WithStaleIfError(maxStale time.Duration) Option {
+	return func(o *options) {
+		o.staleIfError = maxStale
+	}
+}
+
+// Fields of the expiry hash of an entry of the TTL cache.
+const (
+	expirySoftField    = "soft"
+	expiryHardField    = "hard"
+	expirySoftTTLField = "soft_ttl"
+	expiryHardTTLField = "hard_ttl"
+)
+
+// entryExpiry is the two-level expiry of an entry of the TTL cache.
+type entryExpiry struct {
+	// soft is when the entry becomes stale: reads still return it, and refresh it in the background.
+	soft time.Time
+	// hard is when the entry stops being served. Past it, reads are misses and callers block on a reload.
+	hard time.Time
+	// softTTL and hardTTL are the durations the entry was written with, reused when it is refreshed.
+	softTTL time.Duration
+	hardTTL time.Duration
+}
+
+// staleWindow returns how long a TTL cache keeps an entry past its expiration.
+func (c *TTLCache) staleWindow() time.Duration {
+	return max(c.maxStale, c.staleIfError)
+}
+
+// hardExpiration returns how long a TTL cache keeps an entry in Redis: its expiration, extended by the staleness window.
+func (c *TTLCache) hardExpiration() time.Duration {
+	return c.expiration + c.staleWindow()
+}
+
+// retention returns how long an entry written with the soft and hard TTLs is kept in Redis:
+// until its hard expiry, or longer if it may still serve as a stale-if-error fallback.
+func (c *TTLCache) retention(soft, hard time.Duration) time.Duration {
+	return max(hard, soft+c.staleIfError)
+}
+
+// writeExpiry queues the write of the soft and hard expiry of the entry of a user written now.
+// The expiry hash is stored next to the value and expires with it. Entries without a staleness window
+// simply expire, so their hash is removed instead.
+func (c *TTLCache) writeExpiry(pipe redis.Pipeliner, id string, soft, hard time.Duration) {
+	expiryKey := c.generateKey(softExpiryKeyPrefix, id)
+	pipe.Del(c.ctx, expiryKey)
+	if hard <= soft && c.staleIfError <= 0 {
+		return
+	}
+
+	now := c.now()
+	pipe.HSet(c.ctx, expiryKey,
+		expirySoftField, now.Add(soft).UnixNano(),
+		expiryHardField, now.Add(hard).UnixNano(),
+		expirySoftTTLField, int64(soft),
+		expiryHardTTLField, int64(hard),
+	)
+	pipe.PExpire(c.ctx, expiryKey, c.retention(soft, hard))
+}
+
+// readExpiry returns the soft and hard expiry of the entry of a user. It returns false if the entry has none,
+// e.g. because it was written without a staleness window, in which case Redis expires it at its TTL.
+func (c *TTLCache) readExpiry(id string) (entryExpiry, bool, error) {
+	fields, err := c.client.HGetAll(c.ctx, c.generateKey(softExpiryKeyPrefix, id)).Result()
+	if err != nil {
+		return entryExpiry{}, false, err
+	}
+	expiry, ok := parseExpiry(fields)
+	return expiry, ok, nil
+}
+
+// readExpiries returns the expiry of the entries of users like readExpiry, read in a single pipeline.
+func (c *TTLCache) readExpiries(ids []string) ([]entryExpiry, []bool, error) {
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll(c.ctx, c.generateKey(softExpiryKeyPrefix, id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expiries := make([]entryExpiry, len(ids))
+	ok := make([]bool, len(ids))
+	for i, cmd := range cmds {
+		expiries[i], ok[i] = parseExpiry(cmd.Val())
+	}
+	return expiries, ok, nil
+}
+
+// parseExpiry parses the fields of an expiry hash. It returns false if there are none.
+func parseExpiry(fields map[string]string) (entryExpiry, bool) {
+	if len(fields) == 0 {
+		return entryExpiry{}, false
+	}
+
+	parse := func(field string) int64 {
+		n, _ := strconv.ParseInt(fields[field], 10, 64)
+		return n
+	}
+	return entryExpiry{
+		soft:    time.Unix(0, parse(expirySoftField)),
+		hard:    time.Unix(0, parse(expiryHardField)),
+		softTTL: time.Duration(parse(expirySoftTTLField)),
+		hardTTL: time.Duration(parse(expiryHardTTLField)),
+	}, true
+}
+
+// serve handles the expiry of a user read from the cache. It reports whether the user may be served, counting a miss
+// if it is past its hard expiry, and revalidates it if it is stale or refreshes it ahead if it is about to expire.
+func (c *TTLCache) serve(id, cacheKey string, expiry entryExpiry, ok bool) bool {
+	if now := c.now(); ok && !now.Before(expiry.soft) {
+		if !now.Before(expiry.hard) {
+			c.logf(LogMiss, "User with cache key: %s expired %s ago.", cacheKey, now.Sub(expiry.hard))
+			c.counters.recordMiss()
+			c.notifyMiss(c.ctx, c.client, c.generateKey, cacheKey)
+			return false
+		}
+		c.revalidate(id, expiry)
+	} else {
+		c.maybeRefreshAhead(id, expiry, ok)
+	}
+	return true
+}
+
+// revalidate starts a background refresh of a stale user from the loader, keeping the TTLs the entry was written with.
+// A lock held until the hard expiry of the entry ensures a single refresh per user across application instances.
+func (c *TTLCache) revalidate(id string, expiry entryExpiry) {
+	lockKey := c.generateKey(revalidateKeyPrefix, id)
+	acquired, err := c.client.SetNX(c.ctx, lockKey, 1, max(expiry.hard.Sub(c.now()), time.Millisecond)).Result()
+	if err != nil {
+		log.Printf("Error acquiring revalidation lock: %s: %v", lockKey, err)
+		return
+	}
+	if !acquired {
+		log.Printf("User ID: %s is stale and already being revalidated.", id)
+		return
+	}
+
+	log.Printf("User ID: %s is stale. Revalidating in the background.", id)
+	go func() {
+		defer c.client.Del(c.ctx, lockKey)
+
+		user, err := c.load(c.ctx, id)
+		if err != nil {
+			log.Printf("Failed to revalidate user ID: %s: %v", id, err)
+			return
+		}
+		if err := c.set(user, expiry.softTTL, expiry.hardTTL); err != nil {
+			log.Printf("Failed to write revalidated user ID: %s to cache: %v", id, err)
+		}
+	}()
+}
+
+// getStale returns the last known value of a user whose entry expired less than the stale-if-error window ago.
+// It is the fallback of MakeRequest when the loader fails.
+func (c *TTLCache) getStale(id string) (User, error) {
+	if c.staleIfError <= 0 {
+		return User{}, redis.Nil
+	}
+
+	expiry, ok, err := c.readExpiry(id)
+	if err != nil {
+		return User{}, err
+	}
+	if ok && c.now().After(expiry.soft.Add(c.staleIfError)) {
+		return User{}, redis.Nil
+	}
+	return c.readUser(c.ctx, c.client, c.generateKey(userPrefix, id), id)
+}
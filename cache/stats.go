@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"log"
+	"time"
+)
+
+// Stats describes the footprint of a cache. The metric tags name the Prometheus series of the fields, see the metrics package.
+type Stats struct {
+	// Items is the number of entries in the cache.
+	Items int `json:"items"`
+	// Capacity is the maximum number of entries, or 0 if the capacity is expressed in bytes only.
+	Capacity int `json:"capacity"`
+	// Bytes is the total size of the entries. It is only tracked when a byte capacity is configured.
+	Bytes int64 `json:"bytes"`
+	// MaxBytes is the byte capacity of the cache, or 0 if it has none.
+	MaxBytes int64 `json:"max_bytes"`
+	// Breaker is the state of the circuit breaker of the cache, or empty if it has none.
+	Breaker string `json:"breaker,omitempty" metric:"-"`
+	// WritesPending is the number of writes queued with WithWriteBehind and not saved yet.
+	WritesPending int `json:"writes_pending"`
+	// WritesQueued is the number of writes queued with WithWriteBehind since the WriteBehind was created.
+	WritesQueued int64 `json:"writes_queued" metric:"writes_queued_total,counter"`
+	// WritesCoalesced is the number of queued writes that were never saved, because a later write of the same user
+	// replaced them before the flush, since the WriteBehind was created.
+	WritesCoalesced int64 `json:"writes_coalesced" metric:"writes_coalesced_total,counter"`
+	// CoalescingRatio is the share of the queued writes that were coalesced, or 0 if none was queued.
+	CoalescingRatio float64 `json:"coalescing_ratio"`
+	// DeadLetters is the number of queued writes moved to the dead letters since the WriteBehind was created,
+	// see WithDeadLetters.
+	DeadLetters int64 `json:"dead_letters" metric:"dead_letters_total,counter"`
+
+	// The following counters are kept in process since the cache was created or ResetStats was last called.
+
+	// Hits is the number of reads that found the user in the cache.
+	Hits int64 `json:"hits" metric:"hits_total,counter"`
+	// Misses is the number of reads that did not find the user in the cache.
+	Misses int64 `json:"misses" metric:"misses_total,counter"`
+	// Sets is the number of users written with Set.
+	Sets int64 `json:"sets" metric:"sets_total,counter"`
+	// Evictions is the number of entries evicted to make room for new ones.
+	Evictions int64 `json:"evictions" metric:"evictions_total,counter"`
+	// LoadErrors is the number of calls of the loader that failed.
+	LoadErrors int64 `json:"load_errors" metric:"load_errors_total,counter"`
+	// AvgLoadTime is the average duration of a call of the loader.
+	AvgLoadTime time.Duration `json:"avg_load_time"`
+	// AvgResidency is the average time the evicted entries were cached. It is only tracked when admission times
+	// are recorded, with WithEntryInfo or WithEvictionAudit.
+	AvgResidency time.Duration `json:"avg_residency"`
+	// GetLatency is the latency distribution of Get, including retries.
+	GetLatency Latency `json:"get_latency"`
+	// SetLatency is the latency distribution of Set, including locking, eviction and retries.
+	SetLatency Latency `json:"set_latency"`
+	// EvictLatency is the latency distribution of the admissions that evicted entries, and of RemoveOldest.
+	EvictLatency Latency `json:"evict_latency"`
+	// LoadLatency is the latency distribution of the calls of the loader.
+	LoadLatency Latency `json:"load_latency"`
+	// HitRatio1m, HitRatio5m and HitRatio1h are the shares of reads that were hits over the last minute,
+	// five minutes and hour, see HitRatio.
+	HitRatio1m float64 `json:"hit_ratio_1m"`
+	HitRatio5m float64 `json:"hit_ratio_5m"`
+	HitRatio1h float64 `json:"hit_ratio_1h"`
+	// Since is when the counters started, at the creation of the cache or the last call of ResetStats.
+	Since time.Time `json:"since" metric:"since_timestamp_seconds"`
+	// Time is when the stats were collected.
+	Time time.Time `json:"time" metric:"-"`
+}
+
+// Snapshot is a copy of Stats covering the period from Since to Time, serializable to JSON.
+// Taking a snapshot and calling ResetStats at the end of each phase of a benchmark gives per-phase metrics.
+type Snapshot struct {
+	Stats
+	// Elapsed is the length of the period covered by the counters.
+	Elapsed time.Duration `json:"elapsed"`
+	// HitRatio is the share of reads that were hits over the whole period, or 0 if there was no read.
+	HitRatio float64 `json:"hit_ratio"`
+	// SetsPerSecond is the average rate of Set over the period.
+	SetsPerSecond float64 `json:"sets_per_second"`
+	// ReadsPerSecond is the average rate of reads over the period.
+	ReadsPerSecond float64 `json:"reads_per_second"`
+}
+
+// Snapshot returns the stats as a Snapshot, with the ratio and rates of the whole period covered by the counters.
+func (s Stats) Snapshot() Snapshot {
+	snapshot := Snapshot{
+		Stats:   s,
+		Elapsed: s.Time.Sub(s.Since),
+	}
+	reads := s.Hits + s.Misses
+	if reads > 0 {
+		snapshot.HitRatio = float64(s.Hits) / float64(reads)
+	}
+	if seconds := snapshot.Elapsed.Seconds(); seconds > 0 {
+		snapshot.SetsPerSecond = float64(s.Sets) / seconds
+		snapshot.ReadsPerSecond = float64(reads) / seconds
+	}
+	return snapshot
+}
+
+// Stats returns the current footprint of the cache.
+func (c *FIFOCache) Stats() (Stats, error) {
+	return c.stats(c.CacheSize(), c.currentCapacity(c.capacity), c.UsedBytes)
+}
+
+// Stats returns the current footprint of the cache.
+func (c *LRUCache) Stats() (Stats, error) {
+	return c.stats(c.CacheSize(), c.currentCapacity(c.capacity), c.UsedBytes)
+}
+
+// Stats returns the current footprint of the cache.
+func (c *LFUCache) Stats() (Stats, error) {
+	return c.stats(c.CacheSize(), c.currentCapacity(c.capacity), c.UsedBytes)
+}
+
+// Stats returns the current footprint of the cache.
+func (c *ApproxLRUCache) Stats() (Stats, error) {
+	return c.stats(c.CacheSize(), c.currentCapacity(c.capacity), c.UsedBytes)
+}
+
+// Stats returns the operation counters of the cache. The TTL cache does not track its entries,
+// so Items, Capacity and Bytes are always 0.
+func (c *TTLCache) Stats() (Stats, error) {
+	return c.stats(0, 0, func() (int64, error) { return 0, nil })
+}
+
+// stats assembles the Stats of a cache from its size, item capacity, byte counter and operation counters.
+// The breaker state and the counters are reported even if Redis cannot be reached.
+func (o options) stats(items, capacity int, usedBytes func() (int64, error)) (Stats, error) {
+	var s Stats
+	if o.breaker != nil {
+		s.Breaker = o.breaker.State()
+	}
+	if o.writeBehind != nil {
+		s.WritesPending = o.writeBehind.Pending()
+		s.WritesQueued = o.writeBehind.Queued()
+		s.WritesCoalesced = o.writeBehind.Coalesced()
+		if s.WritesQueued > 0 {
+			s.CoalescingRatio = float64(s.WritesCoalesced) / float64(s.WritesQueued)
+		}
+		s.DeadLetters = o.writeBehind.DeadLettered()
+	}
+	o.counters.fill(&s)
+
+	bytes, err := usedBytes()
+	if err != nil {
+		log.Printf("Error getting used bytes: %v", err)
+		return s, err
+	}
+
+	s.Items = items
+	s.Capacity = o.itemCapacity(capacity)
+	s.Bytes = bytes
+	s.MaxBytes = o.maxBytes
+	return s, nil
+}
@@ -0,0 +1,382 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrValueTooLarge is returned when a serialized value exceeds the size set with WithMaxValueSize.
+var ErrValueTooLarge = errors.New("value too large")
+
+// Storage modes of cached values, passed to the Lua scripts.
+const (
+	// stringStorage stores each value as a string serialized with the codec.
+	stringStorage = "string"
+	// hashStorage stores each value as a Redis hash with one field per struct field.
+	hashStorage = "hash"
+	// jsonStorage stores each value as a document of the RedisJSON module.
+	jsonStorage = "json"
+)
+
+// writeValue defines a Lua function that stores a value in the given storage mode.
+// In hash storage, value is a JSON object of field values that replaces the whole hash.
+// In JSON storage, value is the JSON document that replaces the whole key.
+// If keep_ttl is true, the remaining time to live of the key is preserved.
+const writeValue = `
+local function write_value(key, value, storage, keep_ttl)
+	if storage ~= '` + hashStorage + `' and storage ~= '` + jsonStorage + `' then
+		if keep_ttl then
+			return redis.call('SET', key, value, 'KEEPTTL')
+		end
+		return redis.call('SET', key, value)
+	end
+	local ttl = redis.call('PTTL', key)
+	redis.call('DEL', key)
+	if storage == '` + jsonStorage + `' then
+		redis.call('JSON.SET', key, '$', value)
+	else
+		for field, field_value in pairs(cjson.decode(value)) do
+			redis.call('HSET', key, field, field_value)
+		end
+	end
+	if keep_ttl and ttl > 0 then
+		redis.call('PEXPIRE', key, ttl)
+	end
+end
+`
+
+// readValue defines a Lua function that reads a value in the given storage mode.
+// In hash storage, the hash is returned as a JSON object of field values.
+// In JSON storage, the whole document is returned. It returns false if the key does not exist.
+const readValue = `
+local function read_value(key, storage)
+	if storage == '` + jsonStorage + `' then
+		return redis.call('JSON.GET', key)
+	end
+	if storage ~= '` + hashStorage + `' then
+		return redis.call('GET', key)
+	end
+	local pairs = redis.call('HGETALL', key)
+	if #pairs == 0 then
+		return false
+	end
+	local fields = {}
+	for i = 1, #pairs, 2 do
+		fields[pairs[i]] = pairs[i + 1]
+	end
+	return cjson.encode(fields)
+end
+`
+
+// WithHashStorage stores each cached value as a Redis hash with one field per struct field, instead of
+// a single serialized string. Field names are taken from the `redis` struct tags, falling back to the `json` tags;
+// fields tagged `redis:"-"` are not stored. This enables partial reads such as HGET user:1 name.
+// The codec and compression options do not apply to values stored as hashes.
+func WithHashStorage() Option {
+	return func(o *options) {
+		o.storage = hashStorage
+	}
+}
+
+// WithRedisJSON stores each cached value as a document of the RedisJSON module, written with JSON.SET
+// and read with JSON.GET, so single paths can be read and updated in place, e.g. JSON.GET user:1 $.name.
+// The server must provide the module, see HasRedisJSON. Values are always encoded with encoding/json;
+// the codec and compression options do not apply.
+func WithRedisJSON() Option {
+	return func(o *options) {
+		o.storage = jsonStorage
+	}
+}
+
+// HasRedisJSON reports whether the Redis server provides the RedisJSON module, so callers can enable WithRedisJSON only where it is available.
+func HasRedisJSON(ctx context.Context, client Client) (bool, error) {
+	modules, err := client.Do(ctx, "MODULE", "LIST").Slice()
+	if err != nil {
+		return false, err
+	}
+	for _, module := range modules {
+		if strings.Contains(strings.ToLower(fmt.Sprint(module)), "rejson") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// storageMode returns the storage mode of the cache.
+func (o options) storageMode() string {
+	if o.storage == "" {
+		return stringStorage
+	}
+	return o.storage
+}
+
+// encodeValue serializes v for the Lua scripts: with the codec in string storage,
+// or as a JSON object of field values in hash storage.
+// It returns ErrValueTooLarge if the value exceeds the maximum value size.
+func (o options) encodeValue(v interface{}) ([]byte, error) {
+	if o.storageMode() == jsonStorage {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return b, o.checkSize(len(b))
+	}
+	if o.storageMode() != hashStorage {
+		b, err := o.codec.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return b, o.checkSize(len(b))
+	}
+
+	fields, err := structFields(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.checkSize(fieldsSize(fields)); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// decodeValue reverses encodeValue.
+func (o options) decodeValue(data []byte, v interface{}) error {
+	if o.storageMode() == jsonStorage {
+		return json.Unmarshal(data, v)
+	}
+	if o.storageMode() != hashStorage {
+		return o.codec.Unmarshal(data, v)
+	}
+
+	var fields map[string]string
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	return setStructFields(fields, v)
+}
+
+// readValue reads the value stored at cacheKey into v. It returns redis.Nil if the key does not exist.
+func (o options) readValue(ctx context.Context, client redis.Cmdable, cacheKey string, v interface{}) error {
+	if o.storageMode() == jsonStorage {
+		data, err := client.JSONGet(ctx, cacheKey).Result()
+		if err != nil {
+			return err
+		}
+		if data == "" {
+			return redis.Nil
+		}
+		return json.Unmarshal([]byte(data), v)
+	}
+	if o.storageMode() != hashStorage {
+		data, err := client.Get(ctx, cacheKey).Bytes()
+		if err != nil {
+			return err
+		}
+		err = o.codec.Unmarshal(data, v)
+		if errors.Is(err, ErrStaleSchema) {
+			log.Printf("Key: %s has a stale schema. Deleting.", cacheKey)
+			if err := client.Del(ctx, cacheKey).Err(); err != nil {
+				return err
+			}
+			return redis.Nil
+		}
+		return err
+	}
+
+	fields, err := client.HGetAll(ctx, cacheKey).Result()
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return redis.Nil
+	}
+	return setStructFields(fields, v)
+}
+
+// writeValue queues the commands storing v at cacheKey on pipe. An expiration of 0 means the key does not expire.
+// It returns ErrValueTooLarge if the value exceeds the maximum value size.
+func (o options) writeValue(ctx context.Context, pipe redis.Pipeliner, cacheKey string, v interface{}, expiration time.Duration) error {
+	if o.storageMode() == jsonStorage {
+		b, err := o.encodeValue(v)
+		if err != nil {
+			return err
+		}
+		pipe.Del(ctx, cacheKey)
+		pipe.JSONSet(ctx, cacheKey, "$", b)
+		if expiration > 0 {
+			pipe.Expire(ctx, cacheKey, expiration)
+		}
+		return nil
+	}
+	if o.storageMode() != hashStorage {
+		b, err := o.encodeValue(v)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, cacheKey, b, expiration)
+		return nil
+	}
+
+	fields, err := structFields(v)
+	if err != nil {
+		return err
+	}
+	if err := o.checkSize(fieldsSize(fields)); err != nil {
+		return err
+	}
+	pipe.Del(ctx, cacheKey)
+	pipe.HSet(ctx, cacheKey, fields)
+	if expiration > 0 {
+		pipe.Expire(ctx, cacheKey, expiration)
+	}
+	return nil
+}
+
+// checkSize returns ErrValueTooLarge if size exceeds the maximum value size. A maximum of 0 means no limit.
+func (o options) checkSize(size int) error {
+	if o.maxSize > 0 && size > o.maxSize {
+		return fmt.Errorf("%w: %d bytes exceeds the limit of %d bytes", ErrValueTooLarge, size, o.maxSize)
+	}
+	return nil
+}
+
+// fieldsSize returns the total length of the names and values of fields.
+func fieldsSize(fields map[string]string) int {
+	size := 0
+	for name, value := range fields {
+		size += len(name) + len(value)
+	}
+	return size
+}
+
+// readUser reads the user stored at cacheKey. Fields that are not stored, like the ID in hash storage, are filled in from id.
+func (o options) readUser(ctx context.Context, client redis.Cmdable, cacheKey, id string) (User, error) {
+	var user User
+	if err := o.readValue(ctx, client, cacheKey, &user); err != nil {
+		return User{}, err
+	}
+	if user.Id == "" {
+		user.Id = id
+	}
+	return user, nil
+}
+
+// decodeUser decodes a user returned by a Lua script. Fields that are not stored are filled in from id.
+func (o options) decodeUser(data []byte, id string) (User, error) {
+	var user User
+	if err := o.decodeValue(data, &user); err != nil {
+		return User{}, err
+	}
+	if user.Id == "" {
+		user.Id = id
+	}
+	return user, nil
+}
+
+// fieldName returns the hash field name of a struct field, or "" if the field is not stored.
+func fieldName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	if tag, ok := field.Tag.Lookup("redis"); ok {
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	if tag, ok := field.Tag.Lookup("json"); ok {
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// structFields returns the stored fields of the struct v points to, formatted as strings.
+func structFields(v interface{}) (map[string]string, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("hash storage requires a struct, got %T", v)
+	}
+
+	fields := make(map[string]string)
+	for i := 0; i < rv.NumField(); i++ {
+		name := fieldName(rv.Type().Field(i))
+		if name == "" {
+			continue
+		}
+		fields[name] = fmt.Sprint(rv.Field(i).Interface())
+	}
+	return fields, nil
+}
+
+// setStructFields parses the given field values into the struct v points to. Unknown fields are ignored.
+func setStructFields(fields map[string]string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("hash storage requires a pointer to a struct, got %T", v)
+	}
+	rv = rv.Elem()
+
+	for i := 0; i < rv.NumField(); i++ {
+		value, ok := fields[fieldName(rv.Type().Field(i))]
+		if !ok {
+			continue
+		}
+		if err := setField(rv.Field(i), value); err != nil {
+			return fmt.Errorf("field %s: %w", rv.Type().Field(i).Name, err)
+		}
+	}
+	return nil
+}
+
+// setField parses value into a struct field of a basic kind.
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported kind %s", field.Kind())
+	}
+	return nil
+}
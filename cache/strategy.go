@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ReadPolicy is how a Strategy serves the reads its cache misses.
+type ReadPolicy string
+
+const (
+	// ReadPolicyCacheAside reads the cache, and on a miss loads the user with the loader of WithAsideLoader and writes
+	// them to the cache, as the application would around a cache that never loads on its own.
+	ReadPolicyCacheAside ReadPolicy = "cache-aside"
+	// ReadPolicyThrough lets the cache load its misses with its own loader, see GetOrLoad of the cache types, with
+	// their leases, stale entries and fallbacks.
+	ReadPolicyThrough ReadPolicy = "read-through"
+	// ReadPolicyRefreshAhead reads through, and reloads the users served from the cache in the background once they were
+	// loaded longer than WithRefreshAfter ago, so hot users are refreshed before they turn stale.
+	ReadPolicyRefreshAhead ReadPolicy = "refresh-ahead"
+)
+
+// WritePolicy is how a Strategy writes a user to the cache and to the source of truth.
+type WritePolicy string
+
+const (
+	// WritePolicyThrough saves the user to the Store of WithStore, then writes them to the cache, so the cache never
+	// holds a user the Store has not seen.
+	WritePolicyThrough WritePolicy = "through"
+	// WritePolicyBehind writes the user to the cache and queues them in the WriteBehind of WithQueue, which saves them to
+	// its Store later.
+	WritePolicyBehind WritePolicy = "behind"
+	// WritePolicyAround saves the user to the Store of WithStore and removes them from the cache, so the cache only holds
+	// the users that are read, loaded on their next read.
+	WritePolicyAround WritePolicy = "around"
+)
+
+// ReadPolicies lists the read policies accepted by NewStrategy.
+var ReadPolicies = []ReadPolicy{ReadPolicyCacheAside, ReadPolicyThrough, ReadPolicyRefreshAhead}
+
+// WritePolicies lists the write policies accepted by NewStrategy.
+var WritePolicies = []WritePolicy{WritePolicyThrough, WritePolicyBehind, WritePolicyAround}
+
+// StrategyOption configures a Strategy.
+type StrategyOption func(*Strategy)
+
+// WithAsideLoader sets the loader of ReadPolicyCacheAside.
+func WithAsideLoader(loader Loader) StrategyOption {
+	return func(s *Strategy) {
+		s.loader = loader
+	}
+}
+
+// WithStore sets the Store WritePolicyThrough and WritePolicyAround save users to.
+func WithStore(store Store) StrategyOption {
+	return func(s *Strategy) {
+		s.store = store
+	}
+}
+
+// WithQueue sets the WriteBehind WritePolicyBehind queues users in. Unlike WithWriteBehind, the users the cache evicts
+// are not saved before they leave the cache, but with the next flush of the queue.
+func WithQueue(wb *WriteBehind) StrategyOption {
+	return func(s *Strategy) {
+		s.queue = wb
+	}
+}
+
+// WithRefreshAfter sets the age after which ReadPolicyRefreshAhead reloads the users it serves from the cache.
+func WithRefreshAfter(age time.Duration) StrategyOption {
+	return func(s *Strategy) {
+		s.refreshAfter = age
+	}
+}
+
+// Strategy composes a read policy and a write policy over a cache of any type, which only decides what to evict.
+// The cache should not be created with WithWriteBehind, since the write policy decides what reaches the store.
+// A Strategy is a RedisTier itself, so a TieredCache can put an L1 in front of it.
+type Strategy struct {
+	ctx   context.Context
+	cache RedisTier
+	read  ReadPolicy
+	write WritePolicy
+
+	loader       Loader
+	store        Store
+	queue        *WriteBehind
+	refreshAfter time.Duration
+
+	// loadedAt holds when the users served by ReadPolicyRefreshAhead were loaded or written by this instance, and
+	// refreshing the users being reloaded, by value key.
+	mu         sync.Mutex
+	loadedAt   map[string]time.Time
+	refreshing map[string]bool
+}
+
+// NewStrategy creates a Strategy serving the reads of c with read and its writes with write. It returns an error
+// if a policy is unknown or lacks its option: WithAsideLoader for ReadPolicyCacheAside, WithRefreshAfter for
+// ReadPolicyRefreshAhead, WithStore for WritePolicyThrough and WritePolicyAround, and WithQueue for WritePolicyBehind.
+func NewStrategy(ctx context.Context, c RedisTier, read ReadPolicy, write WritePolicy, opts ...StrategyOption) (*Strategy, error) {
+	s := &Strategy{
+		ctx:        ctx,
+		cache:      c,
+		read:       read,
+		write:      write,
+		loadedAt:   make(map[string]time.Time),
+		refreshing: make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	switch read {
+	case ReadPolicyCacheAside:
+		if s.loader == nil {
+			return nil, errors.New("read policy cache-aside needs a loader, see WithAsideLoader")
+		}
+	case ReadPolicyThrough:
+	case ReadPolicyRefreshAhead:
+		if s.refreshAfter <= 0 {
+			return nil, errors.New("read policy refresh-ahead needs a positive age, see WithRefreshAfter")
+		}
+	default:
+		return nil, fmt.Errorf("unknown read policy: %q, want one of %v", read, ReadPolicies)
+	}
+
+	switch write {
+	case WritePolicyThrough, WritePolicyAround:
+		if s.store == nil {
+			return nil, fmt.Errorf("write policy %s needs a store, see WithStore", write)
+		}
+	case WritePolicyBehind:
+		if s.queue == nil {
+			return nil, errors.New("write policy behind needs a queue, see WithQueue")
+		}
+	default:
+		return nil, fmt.Errorf("unknown write policy: %q, want one of %v", write, WritePolicies)
+	}
+	return s, nil
+}
+
+// Policies returns the read and write policies of the strategy.
+func (s *Strategy) Policies() (ReadPolicy, WritePolicy) {
+	return s.read, s.write
+}
+
+// Get retrieves a user from the cache, without loading them on a miss, whatever the read policy.
+func (s *Strategy) Get(id string) (User, error) {
+	return s.cache.Get(id)
+}
+
+// GetOrLoad retrieves a user with the read policy of the strategy, loading them on a miss.
+// SkipCache and ForceRefresh apply to every read policy.
+func (s *Strategy) GetOrLoad(id string, opts ...CallOption) (User, error) {
+	switch s.read {
+	case ReadPolicyCacheAside:
+		return s.getAside(id, newCallOptions(opts))
+	case ReadPolicyRefreshAhead:
+		user, err := s.cache.GetOrLoad(id, opts...)
+		if err == nil && !newCallOptions(opts).skipCache {
+			s.maybeRefresh(id)
+		}
+		return user, err
+	default:
+		return s.cache.GetOrLoad(id, opts...)
+	}
+}
+
+// MakeRequest retrieves a user like GetOrLoad, returning an empty user if they cannot be loaded.
+func (s *Strategy) MakeRequest(id string) User {
+	user, _ := s.GetOrLoad(id)
+	return user
+}
+
+// getAside reads a user from the cache, and on a miss loads them with the loader and writes them to the cache.
+// The user is returned even if they could not be cached.
+func (s *Strategy) getAside(id string, call callOptions) (User, error) {
+	if call.skipCache {
+		log.Printf("Skipping cache for user with id: %s.", id)
+		return s.loader(s.ctx, id)
+	}
+	if !call.forceRefresh {
+		user, err := s.cache.Get(id)
+		if err == nil {
+			return user, nil
+		}
+		log.Printf("Cache miss for user with id: %s. Loading it aside of the cache.", id)
+	}
+
+	user, err := s.loader(s.ctx, id)
+	if err != nil {
+		log.Printf("Failed to load user with id: %s: %v", id, err)
+		return User{}, err
+	}
+	if err := s.cache.Set(user); err != nil {
+		log.Printf("Failed to write user with id: %s to cache: %v", id, err)
+	}
+	return user, nil
+}
+
+// maybeRefresh reloads a user served by ReadPolicyRefreshAhead in the background if this instance loaded or wrote them
+// longer than the refresh age ago. A user this instance has not seen yet is counted as loaded now.
+func (s *Strategy) maybeRefresh(id string) {
+	key := s.cache.Key(id)
+	now := time.Now()
+	s.mu.Lock()
+	loadedAt, ok := s.loadedAt[key]
+	if !ok {
+		s.loadedAt[key] = now
+	}
+	if !ok || now.Sub(loadedAt) < s.refreshAfter || s.refreshing[key] {
+		s.mu.Unlock()
+		return
+	}
+	s.refreshing[key] = true
+	s.mu.Unlock()
+
+	log.Printf("User with id: %s was loaded %s ago. Refreshing it in the background.", id, now.Sub(loadedAt))
+	go func() {
+		_, err := s.cache.GetOrLoad(id, ForceRefresh())
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.refreshing, key)
+		if err != nil {
+			log.Printf("Failed to refresh user with id: %s ahead: %v", id, err)
+			return
+		}
+		s.loadedAt[key] = time.Now()
+	}()
+}
+
+// Set writes a user with the write policy of the strategy.
+func (s *Strategy) Set(user User) error {
+	switch s.write {
+	case WritePolicyThrough:
+		if err := s.store.SaveUsers(s.ctx, []User{user}); err != nil {
+			log.Printf("Failed to save user with id: %s. Leaving the cache unchanged: %v", user.Id, err)
+			return err
+		}
+		if err := s.cache.Set(user); err != nil {
+			// The cached user may be older than the saved one, so it is dropped.
+			log.Printf("Failed to write user with id: %s to cache. Dropping it: %v", user.Id, err)
+			s.cache.Delete(s.cache.Key(user.Id))
+			return err
+		}
+	case WritePolicyAround:
+		if err := s.store.SaveUsers(s.ctx, []User{user}); err != nil {
+			log.Printf("Failed to save user with id: %s: %v", user.Id, err)
+			return err
+		}
+		return s.Delete(s.cache.Key(user.Id))
+	case WritePolicyBehind:
+		if err := s.cache.Set(user); err != nil {
+			return err
+		}
+		if err := s.queue.enqueue(s.ctx, s.cache.Key(user.Id), user); err != nil {
+			log.Printf("Error queueing write of user with id: %s: %v", user.Id, err)
+			return err
+		}
+	}
+	if s.read == ReadPolicyRefreshAhead {
+		s.mu.Lock()
+		s.loadedAt[s.cache.Key(user.Id)] = time.Now()
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// Key returns the value key of a user in the cache, as passed to Delete.
+func (s *Strategy) Key(id string) string {
+	return s.cache.Key(id)
+}
+
+// Delete removes a value key from the cache. The store is left unchanged.
+func (s *Strategy) Delete(key string) error {
+	s.mu.Lock()
+	delete(s.loadedAt, key)
+	s.mu.Unlock()
+	return s.cache.Delete(key)
+}
+
+// Stats returns the stats of the cache.
+func (s *Strategy) Stats() (Stats, error) {
+	return s.cache.Stats()
+}
@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const tagKeyPrefix = "cache_tag"
+const taggedKeyPrefix = "cache_tagged"
+
+// InvalidateTagged is the reason of the invalidations of InvalidateTag, logged to the event stream.
+const InvalidateTagged = "tagged"
+
+// ErrTagsDisabled is returned by SetTagged and InvalidateTag on a cache created without WithTags.
+var ErrTagsDisabled = errors.New("tags are not enabled, see WithTags")
+
+// WithTags lets SetTagged attach tags to entries, such as the tenant or the product a user belongs to, and
+// InvalidateTag remove every entry carrying a tag at once. Every tag is a Redis set of the keys of its entries,
+// and the tags of every entry are kept in a hash, so every eviction costs one more roundtrip to drop them.
+func WithTags() Option {
+	return func(o *options) {
+		o.tags = true
+	}
+}
+
+// setTagged writes a user with set, then replaces the tags of its entry with tags.
+func (o options) setTagged(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, user User, tags []string, set func(User) error) error {
+	if !o.tags {
+		return ErrTagsDisabled
+	}
+	if err := set(user); err != nil {
+		return err
+	}
+	return o.tagEntry(ctx, client, generateKey, generateKey(userPrefix, user.Id), tags)
+}
+
+// tagEntry adds cacheKey to the set of every tag in tags, and removes it from the sets of the tags it no longer
+// carries. The tags of every entry are kept in a hash, as a JSON array.
+func (o options) tagEntry(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, cacheKey string, tags []string) error {
+	taggedKey := generateKey(taggedKeyPrefix)
+	previous, err := entryTags(client.HGet(ctx, taggedKey, cacheKey))
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Tagging key: %s with tags: %v", cacheKey, tags)
+	_, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, tag := range previous {
+			if !slices.Contains(tags, tag) {
+				pipe.SRem(ctx, generateKey(tagKeyPrefix, tag), cacheKey)
+			}
+		}
+		for _, tag := range tags {
+			pipe.SAdd(ctx, generateKey(tagKeyPrefix, tag), cacheKey)
+		}
+		if len(tags) == 0 {
+			pipe.HDel(ctx, taggedKey, cacheKey)
+		} else {
+			pipe.HSet(ctx, taggedKey, cacheKey, encoded)
+		}
+		return nil
+	})
+	return err
+}
+
+// untagEntries removes the given keys from the sets of the tags they carry.
+func (o options) untagEntries(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, cacheKeys ...string) error {
+	if !o.tags || len(cacheKeys) == 0 {
+		return nil
+	}
+
+	taggedKey := generateKey(taggedKeyPrefix)
+	values, err := client.HMGet(ctx, taggedKey, cacheKeys...).Result()
+	if err != nil {
+		return err
+	}
+
+	_, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, value := range values {
+			s, ok := value.(string)
+			if !ok {
+				continue
+			}
+			var tags []string
+			if err := json.Unmarshal([]byte(s), &tags); err != nil {
+				log.Printf("Dropping unreadable tags of key: %s: %v", cacheKeys[i], err)
+				continue
+			}
+			for _, tag := range tags {
+				pipe.SRem(ctx, generateKey(tagKeyPrefix, tag), cacheKeys[i])
+			}
+		}
+		pipe.HDel(ctx, taggedKey, cacheKeys...)
+		return nil
+	})
+	return err
+}
+
+// entryTags decodes the tags of an entry read from the tags hash. An entry without tags has none.
+func entryTags(cmd *redis.StringCmd) ([]string, error) {
+	s, err := cmd.Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	if err := json.Unmarshal([]byte(s), &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// invalidateTag removes every entry carrying tag: the queued writes of their users are saved first, as for Delete,
+// then their value keys are deleted, and their index members, metadata and tags are removed with remove. The keys
+// are published on the invalidation bus. It returns the number of entries that were still cached.
+func (o options) invalidateTag(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, tag string, remove func(cacheKey string) error) (int, error) {
+	if !o.tags {
+		return 0, ErrTagsDisabled
+	}
+
+	tagKey := generateKey(tagKeyPrefix, tag)
+	cacheKeys, err := client.SMembers(ctx, tagKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	if len(cacheKeys) == 0 {
+		log.Printf("No entries carry tag: %s", tag)
+		return 0, nil
+	}
+
+	log.Printf("Invalidating %d entries with tag: %s", len(cacheKeys), tag)
+	o.flushEvicted(ctx, cacheKeys...)
+	var deleted int64
+	err = o.withRetry(ctx, func() (err error) {
+		deleted, err = client.Del(ctx, cacheKeys...).Result()
+		return err
+	})
+	if err != nil {
+		log.Printf("Error invalidating entries with tag: %s: %v", tag, err)
+		return 0, err
+	}
+
+	// Removing an entry drops it from the set of every tag it carries, so the set of tag ends up empty, unless
+	// entries were tagged in the meantime.
+	for _, cacheKey := range cacheKeys {
+		if err := remove(cacheKey); err != nil {
+			log.Printf("Error removing key: %s from index: %v", cacheKey, err)
+			return int(deleted), err
+		}
+		o.logEvent(ctx, client, generateKey, EventInvalidate, cacheKey, InvalidateTagged)
+	}
+	o.publishInvalidation(ctx, cacheKeys...)
+	return int(deleted), nil
+}
+
+// SetTagged adds a user to the cache like Set, and replaces the tags of its entry with tags. Set keeps the tags of
+// an entry, and SetTagged without tags removes them. The cache must be created with WithTags.
+func (c *FIFOCache) SetTagged(user User, tags ...string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.setTagged(c.ctx, c.client, c.generateKey, user, tags, c.Set)
+}
+
+// InvalidateTag removes every entry carrying tag from the cache and its index, and returns the number of entries
+// removed. The cache must be created with WithTags.
+func (c *FIFOCache) InvalidateTag(tag string) (int, error) {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.invalidateTag(c.ctx, c.client, c.generateKey, tag, c.pruneKey)
+}
+
+// SetTagged adds a user to the cache like Set, and replaces the tags of its entry with tags. Set keeps the tags of
+// an entry, and SetTagged without tags removes them. The cache must be created with WithTags.
+func (c *LRUCache) SetTagged(user User, tags ...string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.setTagged(c.ctx, c.client, c.generateKey, user, tags, c.Set)
+}
+
+// InvalidateTag removes every entry carrying tag from the cache and its index, and returns the number of entries
+// removed. The cache must be created with WithTags.
+func (c *LRUCache) InvalidateTag(tag string) (int, error) {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.invalidateTag(c.ctx, c.client, c.generateKey, tag, c.pruneKey)
+}
+
+// SetTagged adds a user to the cache like Set, and replaces the tags of its entry with tags. Set keeps the tags of
+// an entry, and SetTagged without tags removes them. The cache must be created with WithTags.
+func (c *LFUCache) SetTagged(user User, tags ...string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.setTagged(c.ctx, c.client, c.generateKey, user, tags, c.Set)
+}
+
+// InvalidateTag removes every entry carrying tag from the cache and its index, and returns the number of entries
+// removed. The cache must be created with WithTags.
+func (c *LFUCache) InvalidateTag(tag string) (int, error) {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.invalidateTag(c.ctx, c.client, c.generateKey, tag, c.pruneKey)
+}
+
+// SetTagged adds a user to the cache like Set, and replaces the tags of its entry with tags. Set keeps the tags of
+// an entry, and SetTagged without tags removes them. The cache must be created with WithTags.
+func (c *ApproxLRUCache) SetTagged(user User, tags ...string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.setTagged(c.ctx, c.client, c.generateKey, user, tags, c.Set)
+}
+
+// InvalidateTag removes every entry carrying tag from the cache and its sampled index, and returns the number of
+// entries removed. The cache must be created with WithTags.
+func (c *ApproxLRUCache) InvalidateTag(tag string) (int, error) {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.invalidateTag(c.ctx, c.client, c.generateKey, tag, c.pruneKey)
+}
+
+// SetTagged adds a user to the cache like Set, and replaces the tags of its entry with tags. Set keeps the tags of
+// an entry, and SetTagged without tags removes them. Entries that expire stay in the sets of their tags until the
+// tag is invalidated. The cache must be created with WithTags.
+func (c *TTLCache) SetTagged(user User, tags ...string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	return c.setTagged(c.ctx, c.client, c.generateKey, user, tags, c.Set)
+}
+
+// InvalidateTag removes every entry carrying tag from the cache, with its expiry, and returns the number of entries
+// removed. The cache must be created with WithTags.
+func (c *TTLCache) InvalidateTag(tag string) (int, error) {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	userKeyPrefix := c.generateKey(userPrefix) + ":"
+	return c.invalidateTag(c.ctx, c.client, c.generateKey, tag, func(cacheKey string) error {
+		return c.dropExpiry(strings.TrimPrefix(cacheKey, userKeyPrefix))(cacheKey)
+	})
+}
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// taggedCache is implemented by the caches tagging their entries.
+type taggedCache interface {
+	Set(user User) error
+	Get(id string) (User, error)
+	Key(id string) string
+	SetTagged(user User, tags ...string) error
+	InvalidateTag(tag string) (int, error)
+	generateKey(parts ...string) string
+}
+
+// taggedCaches creates every cache type with opts.
+var taggedCaches = map[string]func(client Client, opts ...Option) taggedCache{
+	"fifo": func(client Client, opts ...Option) taggedCache {
+		c := NewFIFO(context.Background(), client, 10, "fifo", opts...)
+		return &c
+	},
+	"lru": func(client Client, opts ...Option) taggedCache {
+		c := NewLRU(context.Background(), client, 10, "lru", opts...)
+		return &c
+	},
+	"lfu": func(client Client, opts ...Option) taggedCache {
+		c := NewLFU(context.Background(), client, 10, "lfu", opts...)
+		return &c
+	},
+	"approx-lru": func(client Client, opts ...Option) taggedCache {
+		c := NewApproxLRU(context.Background(), client, 10, 5, "approx", opts...)
+		return &c
+	},
+	"ttl": func(client Client, opts ...Option) taggedCache {
+		c := NewTTL(context.Background(), client, time.Minute, "ttl", opts...)
+		return &c
+	},
+}
+
+func TestInvalidateTag(t *testing.T) {
+	for name, newCache := range taggedCaches {
+		t.Run(name, func(t *testing.T) {
+			client, m := newTestClient(t)
+			c := newCache(client, WithTags())
+
+			if err := c.SetTagged(testUser(1), "tenant-a", "premium"); err != nil {
+				t.Fatalf("SetTagged(1): %v", err)
+			}
+			if err := c.SetTagged(testUser(2), "tenant-a"); err != nil {
+				t.Fatalf("SetTagged(2): %v", err)
+			}
+			if err := c.SetTagged(testUser(3), "tenant-b"); err != nil {
+				t.Fatalf("SetTagged(3): %v", err)
+			}
+			// Set keeps the tags of an entry.
+			if err := c.Set(testUser(2)); err != nil {
+				t.Fatalf("Set(2): %v", err)
+			}
+
+			if n, err := c.InvalidateTag("tenant-a"); err != nil || n != 2 {
+				t.Fatalf("InvalidateTag: %d, %v, want 2", n, err)
+			}
+			for id, cached := range map[string]bool{"1": false, "2": false, "3": true} {
+				if _, err := c.Get(id); (err == nil) != cached {
+					t.Errorf("Get(%s) after InvalidateTag: %v, cached %v", id, err, cached)
+				}
+			}
+			// The invalidated entries left the sets of every tag they carried.
+			for _, tag := range []string{"tenant-a", "premium"} {
+				if m.Exists(c.generateKey(tagKeyPrefix, tag)) {
+					t.Errorf("the set of tag %s still holds keys", tag)
+				}
+			}
+			if n, err := c.InvalidateTag("tenant-a"); err != nil || n != 0 {
+				t.Errorf("InvalidateTag of an invalidated tag: %d, %v, want 0", n, err)
+			}
+		})
+	}
+}
+
+func TestSetTaggedReplacesTheTags(t *testing.T) {
+	client, _ := newTestClient(t)
+	c := NewLRU(context.Background(), client, 10, "lru", WithTags())
+
+	if err := c.SetTagged(testUser(1), "a", "b"); err != nil {
+		t.Fatalf("SetTagged: %v", err)
+	}
+	if err := c.SetTagged(testUser(1), "b"); err != nil {
+		t.Fatalf("SetTagged: %v", err)
+	}
+	if n, err := c.InvalidateTag("a"); err != nil || n != 0 {
+		t.Errorf("InvalidateTag of a removed tag: %d, %v, want 0", n, err)
+	}
+	if err := c.SetTagged(testUser(1)); err != nil {
+		t.Fatalf("SetTagged without tags: %v", err)
+	}
+	if n, err := c.InvalidateTag("b"); err != nil || n != 0 {
+		t.Errorf("InvalidateTag after the tags were removed: %d, %v, want 0", n, err)
+	}
+	if _, err := c.Get("1"); err != nil {
+		t.Errorf("Get: %v", err)
+	}
+}
+
+func TestEvictionUntagsEntries(t *testing.T) {
+	client, m := newTestClient(t)
+	clock := testClock()
+	c := NewLRU(context.Background(), client, 2, "lru", WithTags(), WithClock(clock))
+
+	if err := c.SetTagged(testUser(1), "a"); err != nil {
+		t.Fatalf("SetTagged: %v", err)
+	}
+	setUsers(t, c.Set, clock, 2, 3)
+	assertCached(t, client, c.Key, 2, 3)
+	if m.Exists(c.generateKey(tagKeyPrefix, "a")) {
+		t.Error("the set of the tag still holds the evicted entry")
+	}
+	if m.Exists(c.generateKey(taggedKeyPrefix)) {
+		t.Error("the tags hash still holds the evicted entry")
+	}
+}
+
+func TestTagsNeedWithTags(t *testing.T) {
+	client, _ := newTestClient(t)
+	c := NewLRU(context.Background(), client, 10, "lru")
+
+	if err := c.SetTagged(testUser(1), "a"); !errors.Is(err, ErrTagsDisabled) {
+		t.Errorf("SetTagged: %v, want ErrTagsDisabled", err)
+	}
+	if _, err := c.InvalidateTag("a"); !errors.Is(err, ErrTagsDisabled) {
+		t.Errorf("InvalidateTag: %v, want ErrTagsDisabled", err)
+	}
+	if _, err := c.Get("1"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get after a failed SetTagged: %v, want redis.Nil", err)
+	}
+}
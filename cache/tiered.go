@@ -0,0 +1,273 @@
+package cache
+
+import (
+	"container/list"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LocalCache is the in-process first tier of a TieredCache, keyed by the value keys of the Redis cache behind it.
+// Implementations must be safe for concurrent use, and may drop entries at any time.
+type LocalCache interface {
+	// Get returns the user cached under key, if any.
+	Get(key string) (User, bool)
+	// Set caches user under key.
+	Set(key string, user User)
+	// Delete removes the user cached under key, if any.
+	Delete(key string)
+	// Len returns the number of users cached.
+	Len() int
+}
+
+// LocalCounters is implemented by the LocalCaches that count the users they evicted and the users their admission
+// policy rejected, such as NewLocalLRU and the adapters of the ristrettocache and ottercache packages.
+// TieredCache.Stats reports the counts.
+type LocalCounters interface {
+	// Evictions returns the number of users evicted to make room for others.
+	Evictions() int64
+	// Rejections returns the number of users the cache declined to store.
+	Rejections() int64
+}
+
+// RedisTier is the Redis-backed second tier of a TieredCache. Every cache type implements it.
+type RedisTier interface {
+	Get(id string) (User, error)
+	GetOrLoad(id string, opts ...CallOption) (User, error)
+	Set(user User) error
+	Delete(key string) error
+	Key(id string) string
+	Stats() (Stats, error)
+}
+
+// TieredCache puts a small in-process cache, the L1, in front of a Redis cache, the L2. Reads are served from the L1
+// when it holds the user, and from the L2 otherwise, promoting the user into the L1. Writes go through to the L2
+// first, then to the L1. An L1 entry may be stale by the time an instance writes the user to the L2, so the
+// instances sharing the L2 drop their copies on the invalidations of an InvalidationBus, given to the L2 with
+// WithInvalidationBus and to NewTieredCache, and the L1 should expire its entries to bound the staleness of missed
+// invalidations.
+type TieredCache struct {
+	l1 LocalCache
+	l2 RedisTier
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// TieredStats reports the two tiers of a TieredCache.
+type TieredStats struct {
+	// L1 reports the in-process tier.
+	L1 LocalStats `json:"l1"`
+	// L2 is the Stats of the Redis cache. Its hits and misses only count the reads the L1 missed.
+	L2 Stats `json:"l2"`
+}
+
+// LocalStats reports the in-process tier of a TieredCache.
+type LocalStats struct {
+	// Items is the number of users in the L1.
+	Items int `json:"items"`
+	// Hits is the number of reads served from the L1.
+	Hits int64 `json:"hits"`
+	// Misses is the number of reads passed to the L2.
+	Misses int64 `json:"misses"`
+	// Evictions is the number of users the L1 evicted, if it implements LocalCounters.
+	Evictions int64 `json:"evictions"`
+	// Rejections is the number of users the L1 declined to store, if it implements LocalCounters.
+	Rejections int64 `json:"rejections"`
+}
+
+// NewTieredCache creates a TieredCache serving l2 through l1. If bus is not nil, the invalidations it receives from
+// the other instances drop the users from l1.
+func NewTieredCache(l1 LocalCache, l2 RedisTier, bus *InvalidationBus) *TieredCache {
+	c := &TieredCache{l1: l1, l2: l2}
+	if bus != nil {
+		bus.OnInvalidate(l1.Delete)
+	}
+	return c
+}
+
+// Get retrieves a user from the L1, or from the L2, promoting them into the L1.
+func (c *TieredCache) Get(id string) (User, error) {
+	key := c.l2.Key(id)
+	if user, ok := c.l1.Get(key); ok {
+		log.Printf("L1 hit for key: %s", key)
+		c.hits.Add(1)
+		return user, nil
+	}
+	c.misses.Add(1)
+
+	user, err := c.l2.Get(id)
+	if err != nil {
+		return User{}, err
+	}
+	c.l1.Set(key, user)
+	return user, nil
+}
+
+// GetOrLoad retrieves a user from the L1, or from the L2 with its GetOrLoad, loading them on a miss,
+// and promotes them into the L1. SkipCache and ForceRefresh bypass the L1 too.
+func (c *TieredCache) GetOrLoad(id string, opts ...CallOption) (User, error) {
+	key := c.l2.Key(id)
+	call := newCallOptions(opts)
+	if !call.skipCache && !call.forceRefresh {
+		if user, ok := c.l1.Get(key); ok {
+			log.Printf("L1 hit for key: %s", key)
+			c.hits.Add(1)
+			return user, nil
+		}
+		c.misses.Add(1)
+	}
+
+	user, err := c.l2.GetOrLoad(id, opts...)
+	if err != nil {
+		return User{}, err
+	}
+	if !call.skipCache {
+		c.l1.Set(key, user)
+	}
+	return user, nil
+}
+
+// MakeRequest retrieves a user like GetOrLoad, returning an empty user if they cannot be loaded.
+func (c *TieredCache) MakeRequest(id string) User {
+	user, _ := c.GetOrLoad(id)
+	return user
+}
+
+// Set writes a user to the L2, then to the L1. If the L2 write fails, the user is dropped from the L1,
+// which could otherwise keep serving a value the L2 no longer agrees with.
+func (c *TieredCache) Set(user User) error {
+	key := c.l2.Key(user.Id)
+	if err := c.l2.Set(user); err != nil {
+		c.l1.Delete(key)
+		return err
+	}
+	c.l1.Set(key, user)
+	return nil
+}
+
+// Key returns the value key of a user in the Redis cache, as passed to Delete.
+func (c *TieredCache) Key(id string) string {
+	return c.l2.Key(id)
+}
+
+// Delete removes a value key from both tiers.
+func (c *TieredCache) Delete(key string) error {
+	c.l1.Delete(key)
+	return c.l2.Delete(key)
+}
+
+// Stats returns the stats of both tiers.
+func (c *TieredCache) Stats() (TieredStats, error) {
+	l2, err := c.l2.Stats()
+	l1 := LocalStats{Items: c.l1.Len(), Hits: c.hits.Load(), Misses: c.misses.Load()}
+	if counters, ok := c.l1.(LocalCounters); ok {
+		l1.Evictions, l1.Rejections = counters.Evictions(), counters.Rejections()
+	}
+	return TieredStats{L1: l1, L2: l2}, err
+}
+
+// localLRU is the default LocalCache: a bounded LRU cache whose entries expire after a TTL.
+type localLRU struct {
+	size int
+	ttl  time.Duration
+
+	mu        sync.Mutex
+	order     *list.List
+	entries   map[string]*list.Element
+	evictions int64
+}
+
+// localEntry is an entry of a localLRU.
+type localEntry struct {
+	key     string
+	user    User
+	expires time.Time
+}
+
+// NewLocalLRU creates a LocalCache holding up to size users, evicting the least recently used one when full.
+// Entries expire ttl after they are set, or never if ttl is 0.
+func NewLocalLRU(size int, ttl time.Duration) LocalCache {
+	return &localLRU{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the user cached under key if it has not expired, and marks it as recently used.
+func (l *localLRU) Get(key string) (User, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	element, ok := l.entries[key]
+	if !ok {
+		return User{}, false
+	}
+	entry := element.Value.(*localEntry)
+	if !entry.expires.IsZero() && !time.Now().Before(entry.expires) {
+		l.order.Remove(element)
+		delete(l.entries, key)
+		return User{}, false
+	}
+	l.order.MoveToFront(element)
+	return entry.user, true
+}
+
+// Set caches user under key, evicting the least recently used user if the cache is full.
+func (l *localLRU) Set(key string, user User) {
+	if l.size <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var expires time.Time
+	if l.ttl > 0 {
+		expires = time.Now().Add(l.ttl)
+	}
+	if element, ok := l.entries[key]; ok {
+		element.Value = &localEntry{key: key, user: user, expires: expires}
+		l.order.MoveToFront(element)
+		return
+	}
+	if l.order.Len() >= l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*localEntry).key)
+		l.evictions++
+	}
+	l.entries[key] = l.order.PushFront(&localEntry{key: key, user: user, expires: expires})
+}
+
+// Delete removes the user cached under key.
+func (l *localLRU) Delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if element, ok := l.entries[key]; ok {
+		l.order.Remove(element)
+		delete(l.entries, key)
+	}
+}
+
+// Len returns the number of users cached, including expired ones not yet removed.
+func (l *localLRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// Evictions returns the number of users evicted to make room for others.
+func (l *localLRU) Evictions() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.evictions
+}
+
+// Rejections returns 0: the LRU admits every user.
+func (l *localLRU) Rejections() int64 {
+	return 0
+}
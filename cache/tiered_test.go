@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestTieredCacheServesTheL1(t *testing.T) {
+	client, m := newTestClient(t)
+	l2 := NewLRU(context.Background(), client, 10, "lru", WithLoader(testLoader))
+	c := NewTieredCache(NewLocalLRU(10, 0), &l2, nil)
+
+	setUsers(t, c.Set, nil, 1)
+	// The L1 keeps serving the user once the L2 lost it.
+	m.Del(c.Key("1"))
+	if got, err := c.Get("1"); err != nil || got != testUser(1) {
+		t.Errorf("Get from the L1: %+v, %v", got, err)
+	}
+	if _, err := c.Get("2"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get of a missing user: %v, want redis.Nil", err)
+	}
+	if got, err := c.GetOrLoad("2"); err != nil || got != testUser(2) {
+		t.Errorf("GetOrLoad: %+v, %v", got, err)
+	}
+	m.Del(c.Key("2"))
+	if got, err := c.Get("2"); err != nil || got != testUser(2) {
+		t.Errorf("Get of a loaded user from the L1: %+v, %v", got, err)
+	}
+
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.L1.Items != 2 || stats.L1.Hits != 2 || stats.L1.Misses != 2 {
+		t.Errorf("L1 stats: %+v, want 2 items, 2 hits and 2 misses", stats.L1)
+	}
+}
+
+func TestTieredCacheDropsStaleCopies(t *testing.T) {
+	client, _ := newTestClient(t)
+	l2 := NewLRU(context.Background(), client, 10, "lru")
+	c := NewTieredCache(NewLocalLRU(10, 0), &l2, nil)
+	setUsers(t, c.Set, nil, 1, 2)
+
+	if err := c.NotifyUpdated("1"); err != nil {
+		t.Fatalf("NotifyUpdated: %v", err)
+	}
+	if err := c.Delete(c.Key("2")); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	for _, id := range []string{"1", "2"} {
+		if _, err := c.Get(id); !errors.Is(err, redis.Nil) {
+			t.Errorf("Get(%s): %v, want redis.Nil", id, err)
+		}
+	}
+}
+
+func TestTieredCacheInvalidatesOtherInstances(t *testing.T) {
+	client, _ := newTestClient(t)
+	newInstance := func() *TieredCache {
+		bus := newTestBus(t, client, "invalidations")
+		l2 := NewLRU(context.Background(), client, 10, "lru", WithInvalidationBus(bus))
+		return NewTieredCache(NewLocalLRU(10, 0), &l2, bus)
+	}
+	a, b := newInstance(), newInstance()
+
+	setUsers(t, a.Set, nil, 1)
+	if _, err := b.Get("1"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	renamed := User{Id: "1", Name: "Renamed", Age: 30}
+	if err := a.Set(renamed); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(5 * time.Millisecond) {
+		got, err := b.Get("1")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if got == renamed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the other instance still serves %+v", got)
+		}
+	}
+}
+
+func TestLocalLRU(t *testing.T) {
+	l := NewLocalLRU(2, 0)
+	l.Set("1", testUser(1))
+	l.Set("2", testUser(2))
+	l.Get("1")
+	l.Set("3", testUser(3))
+	for key, want := range map[string]bool{"1": true, "2": false, "3": true} {
+		if _, ok := l.Get(key); ok != want {
+			t.Errorf("%s in the local LRU: %v, want %v", key, ok, want)
+		}
+	}
+	if n := l.(LocalCounters).Evictions(); n != 1 {
+		t.Errorf("evictions: %d, want 1", n)
+	}
+
+	expiring := NewLocalLRU(2, 10*time.Millisecond)
+	expiring.Set("1", testUser(1))
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := expiring.Get("1"); ok {
+		t.Error("the local LRU served an expired user")
+	}
+}
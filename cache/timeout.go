@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WithReadTimeout bounds Get and GetFields, including their retries, to timeout. When a lookup times out,
+// GetOrLoad and MakeRequest serve the user from the loader instead of waiting for a slow Redis.
+// For the deadline to interrupt a command already sent, the client must be created with ContextTimeoutEnabled;
+// otherwise it only stops further commands and retries. Reads are not bounded by default.
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.readTimeout = timeout
+	}
+}
+
+// WithWriteTimeout bounds Set and Delete, including eviction, locking and retries, to timeout.
+// Like WithReadTimeout, it only interrupts commands in flight if the client has ContextTimeoutEnabled.
+// Writes are not bounded by default.
+func WithWriteTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.writeTimeout = timeout
+	}
+}
+
+// timedOut reports whether a cache operation failed because its deadline was exceeded.
+func timedOut(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// withTimeout returns a copy of the cache whose operations use a context that expires after timeout,
+// and the function releasing that context. The cache itself is returned if timeout is 0.
+func (c *FIFOCache) withTimeout(timeout time.Duration) (*FIFOCache, context.CancelFunc) {
+	if timeout <= 0 {
+		return c, func() {}
+	}
+	timed := *c
+	var cancel context.CancelFunc
+	timed.ctx, cancel = context.WithTimeout(c.ctx, timeout)
+	return &timed, cancel
+}
+
+// withTimeout returns a copy of the cache whose operations use a context that expires after timeout,
+// and the function releasing that context. The cache itself is returned if timeout is 0.
+func (c *LRUCache) withTimeout(timeout time.Duration) (*LRUCache, context.CancelFunc) {
+	if timeout <= 0 {
+		return c, func() {}
+	}
+	timed := *c
+	var cancel context.CancelFunc
+	timed.ctx, cancel = context.WithTimeout(c.ctx, timeout)
+	return &timed, cancel
+}
+
+// withTimeout returns a copy of the cache whose operations use a context that expires after timeout,
+// and the function releasing that context. The cache itself is returned if timeout is 0.
+func (c *LFUCache) withTimeout(timeout time.Duration) (*LFUCache, context.CancelFunc) {
+	if timeout <= 0 {
+		return c, func() {}
+	}
+	timed := *c
+	var cancel context.CancelFunc
+	timed.ctx, cancel = context.WithTimeout(c.ctx, timeout)
+	return &timed, cancel
+}
+
+// withTimeout returns a copy of the cache whose operations use a context that expires after timeout,
+// and the function releasing that context. The cache itself is returned if timeout is 0.
+func (c *ApproxLRUCache) withTimeout(timeout time.Duration) (*ApproxLRUCache, context.CancelFunc) {
+	if timeout <= 0 {
+		return c, func() {}
+	}
+	timed := *c
+	var cancel context.CancelFunc
+	timed.ctx, cancel = context.WithTimeout(c.ctx, timeout)
+	return &timed, cancel
+}
+
+// withTimeout returns a copy of the cache whose operations use a context that expires after timeout,
+// and the function releasing that context. The cache itself is returned if timeout is 0.
+// Background revalidations must use the original cache, as the context of the copy is released with the operation.
+func (c *TTLCache) withTimeout(timeout time.Duration) (*TTLCache, context.CancelFunc) {
+	if timeout <= 0 {
+		return c, func() {}
+	}
+	timed := *c
+	var cancel context.CancelFunc
+	timed.ctx, cancel = context.WithTimeout(c.ctx, timeout)
+	return &timed, cancel
+}
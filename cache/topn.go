@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	sketchKeyPrefix = "cache_sketch"
+	topKeyPrefix    = "cache_top"
+
+	// sketchWidth and sketchDepth size the count-min sketch of WithTopK. With these dimensions,
+	// an estimate exceeds the true count by at most 0.1% of all the counted hits with a probability above 98%.
+	sketchWidth = 2048
+	sketchDepth = 4
+)
+
+// ErrTopKDisabled is returned by TopN when the cache has no frequency index and was not created with WithTopK.
+var ErrTopKDisabled = errors.New("top-k tracking is not enabled")
+
+// Frequency is a key and the number of times it was accessed.
+type Frequency struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// WithTopK tracks the k most frequently hit keys of a cache whose policy does not count accesses itself.
+// Hits are counted in a count-min sketch, the hash <keyPrefix>:cache_sketch, and the keys with the highest
+// estimates are kept in the sorted set <keyPrefix>:cache_top, capped at k members. Counts are estimates
+// that can only be too high, and keys stay ranked after they are evicted. Every hit costs one more script call.
+// The LFU cache ranks its entries by its own frequency index and does not need it.
+func WithTopK(k int) Option {
+	return func(o *options) {
+		o.topK = k
+	}
+}
+
+// countSketchScript increments the counters of a key in a count-min sketch and ranks the key
+// by its estimate, the smallest of its counters, in a sorted set capped at k members.
+//
+// KEYS[1]: the sketch hash
+// KEYS[2]: the sorted set of the top keys
+// ARGV[1]: the key
+// ARGV[2]: k
+// ARGV[3...]: the sketch field of the key in each row
+var countSketchScript = scripts.register(`
+local estimate = nil
+for i = 3, #ARGV do
+	local count = redis.call('HINCRBY', KEYS[1], ARGV[i], 1)
+	if estimate == nil or count < estimate then
+		estimate = count
+	end
+end
+redis.call('ZADD', KEYS[2], estimate, ARGV[1])
+local k = tonumber(ARGV[2])
+if redis.call('ZCARD', KEYS[2]) > k then
+	redis.call('ZREMRANGEBYRANK', KEYS[2], 0, -(k + 1))
+end
+return estimate
+`)
+
+// sketchFields returns the sketch field of key in each row, derived from two halves of its FNV-1a hash.
+func sketchFields(key string) []interface{} {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := uint32(sum), uint32(sum>>32)|1
+
+	fields := make([]interface{}, sketchDepth)
+	for row := range sketchDepth {
+		col := (h1 + uint32(row)*h2) % sketchWidth
+		fields[row] = strconv.Itoa(row) + ":" + strconv.Itoa(int(col))
+	}
+	return fields
+}
+
+// countAccess counts a hit of key in the frequency sketch, if the cache was created with WithTopK.
+func (o options) countAccess(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, key string) {
+	if o.topK <= 0 {
+		return
+	}
+
+	keys := []string{generateKey(sketchKeyPrefix), generateKey(topKeyPrefix)}
+	args := append([]interface{}{key, o.topK}, sketchFields(key)...)
+	if err := scripts.run(ctx, client, countSketchScript, keys, args...).Err(); err != nil {
+		log.Printf("Error counting access of key: %s: %v", key, err)
+	}
+}
+
+// topN returns up to n members of a sorted set with the highest scores, the highest first.
+func topN(ctx context.Context, client redis.Cmdable, key string, n int) ([]Frequency, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	members, err := client.ZRevRangeWithScores(ctx, key, 0, int64(n-1)).Result()
+	if err != nil {
+		log.Printf("Error getting top %d members of sorted set: %s: %v", n, key, err)
+		return nil, err
+	}
+
+	top := make([]Frequency, len(members))
+	for i, member := range members {
+		top[i] = Frequency{Key: member.Member.(string), Count: int64(member.Score)}
+	}
+	return top, nil
+}
+
+// sketchTopN returns up to n of the most frequently hit keys tracked with WithTopK.
+func (o options) sketchTopN(ctx context.Context, client redis.Cmdable, generateKey func(...string) string, n int) ([]Frequency, error) {
+	if o.topK <= 0 {
+		return nil, ErrTopKDisabled
+	}
+	return topN(ctx, client, generateKey(topKeyPrefix), min(n, o.topK))
+}
+
+// TopN returns up to n cached keys with the highest access frequencies, the most frequent first,
+// read directly from the frequency index of the cache.
+func (c *LFUCache) TopN(n int) ([]Frequency, error) {
+	return topN(c.ctx, c.client, c.generateKey(cacheKeyPrefix), n)
+}
+
+// TopN returns up to n of the most frequently hit keys with their estimated hit counts, the most frequent first.
+// It returns ErrTopKDisabled unless the cache was created with WithTopK.
+func (c *FIFOCache) TopN(n int) ([]Frequency, error) {
+	return c.sketchTopN(c.ctx, c.client, c.generateKey, n)
+}
+
+// TopN returns up to n of the most frequently hit keys with their estimated hit counts, the most frequent first.
+// It returns ErrTopKDisabled unless the cache was created with WithTopK.
+func (c *LRUCache) TopN(n int) ([]Frequency, error) {
+	return c.sketchTopN(c.ctx, c.client, c.generateKey, n)
+}
+
+// TopN returns up to n of the most frequently hit keys with their estimated hit counts, the most frequent first.
+// It returns ErrTopKDisabled unless the cache was created with WithTopK.
+func (c *ApproxLRUCache) TopN(n int) ([]Frequency, error) {
+	return c.sketchTopN(c.ctx, c.client, c.generateKey, n)
+}
+
+// TopN returns up to n of the most frequently hit keys with their estimated hit counts, the most frequent first.
+// It returns ErrTopKDisabled unless the cache was created with WithTopK.
+func (c *TTLCache) TopN(n int) ([]Frequency, error) {
+	return c.sketchTopN(c.ctx, c.client, c.generateKey, n)
+}
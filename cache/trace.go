@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EvictionCandidate is an entry the victim of an eviction was chosen over, with its score.
+type EvictionCandidate struct {
+	Key   string
+	Score float64
+}
+
+// EvictionTrace records why a victim was chosen by an eviction.
+// Score is the score of the victim in the index: the access time for LRU and the approximated LRU,
+// the access count for LFU, and 0 for FIFO, whose victim is always the head of the queue.
+// Rank is the position of the victim in the index, and Candidates are the next entries in line,
+// or for the approximated LRU the other members of the sample.
+type EvictionTrace struct {
+	Key        string
+	Reason     string
+	Time       time.Time
+	Score      float64
+	Rank       int
+	Candidates []EvictionCandidate
+}
+
+// traceRing keeps the most recent eviction traces in a ring of fixed size.
+type traceRing struct {
+	mu      sync.Mutex
+	entries []EvictionTrace
+	next    int
+	full    bool
+}
+
+// WithEvictionTrace records why each victim was chosen into a ring buffer of the last size evictions,
+// retrievable with LastEvictions. The eviction scripts look up the competing candidates of every victim,
+// so tracing adds some work to each eviction and is meant for debugging.
+func WithEvictionTrace(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.traces = &traceRing{entries: make([]EvictionTrace, size)}
+		}
+	}
+}
+
+// add stores a trace, overwriting the oldest one if the ring is full.
+func (r *traceRing) add(t EvictionTrace) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[r.next] = t
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// last returns up to n traces, the most recent first.
+func (r *traceRing) last(n int) []EvictionTrace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	size := r.next
+	if r.full {
+		size = len(r.entries)
+	}
+	n = min(n, size)
+
+	traces := make([]EvictionTrace, 0, max(n, 0))
+	for i := 1; i <= n; i++ {
+		traces = append(traces, r.entries[(r.next-i+len(r.entries))%len(r.entries)])
+	}
+	return traces
+}
+
+// evictionFlags returns the flags telling the eviction scripts what to return about evicted entries:
+// "v" for their values if eviction callbacks are registered and "t" for their traces if tracing is enabled.
+func (o options) evictionFlags() string {
+	flags := ""
+	if len(o.onEvict) > 0 {
+		flags += "v"
+	}
+	if o.traces != nil {
+		flags += "t"
+	}
+	return flags
+}
+
+// recordTrace parses the trace returned by an eviction script and stores it in the ring buffer, if tracing is enabled.
+func (o options) recordTrace(e eviction) {
+	if o.traces == nil || e.trace == "" {
+		return
+	}
+
+	var detail struct {
+		Score      string     `json:"score"`
+		Rank       int        `json:"rank"`
+		Candidates [][]string `json:"candidates"`
+	}
+	if err := json.Unmarshal([]byte(e.trace), &detail); err != nil {
+		log.Printf("Error parsing eviction trace of key: %s: %v", e.key, err)
+		return
+	}
+
+	t := EvictionTrace{
+		Key:    e.key,
+		Reason: e.reason,
+		Time:   o.now(),
+		Score:  parseScore(detail.Score),
+		Rank:   detail.Rank,
+	}
+	for _, candidate := range detail.Candidates {
+		if len(candidate) == 2 {
+			t.Candidates = append(t.Candidates, EvictionCandidate{Key: candidate[0], Score: parseScore(candidate[1])})
+		}
+	}
+	o.traces.add(t)
+}
+
+// parseScore parses a score returned by Redis, treating a missing or malformed score as 0.
+func parseScore(s string) float64 {
+	score, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return score
+}
+
+// lastEvictions returns up to n of the most recent eviction traces, or nil if tracing is disabled.
+func (o options) lastEvictions(n int) []EvictionTrace {
+	if o.traces == nil {
+		return nil
+	}
+	return o.traces.last(n)
+}
+
+// LastEvictions returns up to n of the most recent eviction traces, the most recent first.
+// It returns nil unless the cache was created with WithEvictionTrace.
+func (c *FIFOCache) LastEvictions(n int) []EvictionTrace {
+	return c.lastEvictions(n)
+}
+
+// LastEvictions returns up to n of the most recent eviction traces, the most recent first.
+// It returns nil unless the cache was created with WithEvictionTrace.
+func (c *LRUCache) LastEvictions(n int) []EvictionTrace {
+	return c.lastEvictions(n)
+}
+
+// LastEvictions returns up to n of the most recent eviction traces, the most recent first.
+// It returns nil unless the cache was created with WithEvictionTrace.
+func (c *LFUCache) LastEvictions(n int) []EvictionTrace {
+	return c.lastEvictions(n)
+}
+
+// LastEvictions returns up to n of the most recent eviction traces, the most recent first.
+// It returns nil unless the cache was created with WithEvictionTrace.
+func (c *ApproxLRUCache) LastEvictions(n int) []EvictionTrace {
+	return c.lastEvictions(n)
+}
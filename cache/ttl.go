@@ -2,7 +2,7 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"log"
 	"strings"
 	"time"
@@ -13,11 +13,14 @@ import (
 // TTLCache represents a Time To Live (TTL) cache implemented with Redis.
 // It sets an expiration time for each key, and Redis automatically handles the eviction
 // of expired keys. This cache is effective for data that becomes stale after a certain period.
+// Unlike the other caches, it does not version its entries and has no CompareAndSet or GetIfChanged: its keys
+// expire on the server without running a script, so their versions could not be dropped with them.
 type TTLCache struct {
 	ctx        context.Context
-	client     *redis.Client
+	client     Client
 	expiration time.Duration
 	keyPrefix  string
+	options
 }
 
 // NewTTL initializes and returns a new TTLCache.
@@ -27,15 +30,17 @@ type TTLCache struct {
 //   - client: The Redis client instance.
 //   - expiration: The duration for which each cache entry should be valid.
 //   - keyPrefix: A prefix for all cache keys to avoid collisions.
+//   - opts: Optional settings applied to the cache.
 //
 // Returns:
 //   A new instance of TTLCache.
-func NewTTL(ctx context.Context, client *redis.Client, expiration time.Duration, keyPrefix string) TTLCache {
+func NewTTL(ctx context.Context, client Client, expiration time.Duration, keyPrefix string, opts ...Option) TTLCache {
 	return TTLCache{
 		ctx:        ctx,
 		client:     client,
 		keyPrefix:  keyPrefix,
 		expiration: expiration,
+		options:    clientOptions(client, opts),
 	}
 }
 
@@ -43,6 +48,8 @@ func NewTTL(ctx context.Context, client *redis.Client, expiration time.Duration,
 // It first attempts to retrieve the user from the cache. If the user is not found (a cache miss),
 // it fetches the user from the database, stores the new user in the cache with a defined TTL,
 // and then returns the user. If the user is found in the cache (a cache hit), it returns the user directly.
+// If the database call fails and the cache was created with WithStaleIfError, the last known value is returned instead.
+// If it was created with WithEarlyRefresh, a hit may also reload the user shortly before it expires.
 // This method is ideal for scenarios where data should be cached for a specific duration.
 //
 // Parameters:
@@ -51,24 +58,65 @@ func NewTTL(ctx context.Context, client *redis.Client, expiration time.Duration,
 // Returns:
 //   The requested User object.
 func (c *TTLCache) MakeRequest(id string) User {
-	log.Printf("Request received for user ID: %s", id)
+	user, _ := c.GetOrLoad(id)
+	return user
+}
+
+// GetOrLoad retrieves a user from the cache. If the user is not in the cache, it loads the user with the loader
+// and adds them to the cache. Unlike MakeRequest, it returns the error of the loader.
+// While the circuit breaker of the cache is open, the cache is bypassed and users are served from the loader,
+// or from the fallback cache if the cache was created with WithFallbackCache.
+// Pass SkipCache or ForceRefresh to change how a single call uses the cache.
+func (c *TTLCache) GetOrLoad(id string, opts ...CallOption) (User, error) {
+	c.logf(LogRead, "Request received for user ID: %s", id)
+	call := newCallOptions(opts)
+	if call.skipCache {
+		c.logf(LogRead, "Skipping cache for user ID: %s.", id)
+		return c.load(c.ctx, id)
+	}
+	if c.degraded() {
+		c.logf(LogMiss, "Redis is unavailable. Loading user ID: %s directly.", id)
+		return c.loadDegraded(c.ctx, id)
+	}
+	if call.forceRefresh {
+		c.logf(LogRead, "Forcing refresh of user ID: %s.", id)
+		return c.loadAndSet(id)
+	}
 	user, err := c.Get(id)
-	if err != nil {
-		log.Printf("Cache miss for user ID: %s. Fetching from database.", id)
-		dbUser := getUserFromDb(id)
-		if err := c.Set(dbUser); err != nil {
-			log.Printf("Failed to write user ID: %s to cache: %v", id, err)
-		}
-		return dbUser
+	if timedOut(err) {
+		c.logf(LogMiss, "Timed out getting user ID: %s from Redis. Fetching from database.", id)
+		return c.loadAndSet(id)
+	}
+	if err == nil && !c.refreshEarly(id) {
+		c.logf(LogHit, "Cache hit for user ID: %s.", id)
+		return user, nil
 	}
 
-	log.Printf("Cache hit for user ID: %s.", id)
-	return user
+	if err == nil {
+		c.logf(LogHit, "Refreshing user ID: %s ahead of its expiration.", id)
+	} else {
+		c.logf(LogMiss, "Cache miss for user ID: %s. Fetching from database.", id)
+	}
+	dbUser, loadErr := c.coalesce(c.ctx, c.client, c.generateKey, id, c.loadAndSet, c.Get)
+	if loadErr != nil {
+		if err == nil {
+			return user, nil
+		}
+		if user, err := c.getStale(id); err == nil {
+			c.logf(LogMiss, "Serving stale user ID: %s.", id)
+			return user, nil
+		}
+		return User{}, loadErr
+	}
+	return dbUser, nil
 }
 
 // Get retrieves a user from the cache by their ID. It fetches the value from Redis
 // and unmarshals it into a User object. This method is a straightforward key-value lookup
 // and does not involve any TTL management, as Redis handles expiration automatically.
+// If the cache was created with WithStaleWhileRevalidate, a stale user is returned and refreshed in the background.
+// If it was created with WithRefreshAhead, a fresh user about to expire is returned and refreshed in the background.
+// Users kept past their expiration only for WithStaleIfError are reported as misses.
 //
 // Parameters:
 //   - id: The ID of the user to retrieve.
@@ -76,21 +124,40 @@ func (c *TTLCache) MakeRequest(id string) User {
 // Returns:
 //   The User object and an error if the user is not found or if unmarshalling fails.
 func (c *TTLCache) Get(id string) (User, error) {
+	defer c.counters.observe(opGet, time.Now())
 	cacheKey := c.generateKey(userPrefix, id)
-	log.Printf("Attempting to get user with cache key: %s", cacheKey)
+	c.logf(LogRead, "Attempting to get user with cache key: %s", cacheKey)
+
+	// Revalidations outlive the read, so only the read itself uses the timed copy of the cache.
+	timed, cancel := c.withTimeout(c.readTimeout)
+	defer cancel()
 
-	data, err := c.client.Get(c.ctx, cacheKey).Result()
+	var user User
+	err := timed.withRetry(timed.ctx, func() (err error) {
+		user, err = timed.readReplicaUser(timed.ctx, timed.client, cacheKey, id)
+		return err
+	})
+	if err == redis.Nil {
+		c.counters.recordMiss()
+		c.notifyMiss(c.ctx, c.client, c.generateKey, cacheKey)
+	}
 	if err != nil {
-		log.Printf("Error getting user with cache key: %s from Redis: %v", cacheKey, err)
+		if err != redis.Nil {
+			log.Printf("Error getting user with cache key: %s from Redis: %v", cacheKey, err)
+		}
 		return User{}, err
 	}
 
-	var user User
-	if err := json.Unmarshal([]byte(data), &user); err != nil {
-		log.Printf("Error unmarshalling user data for cache key: %s: %v", cacheKey, err)
-		return User{}, err
+	expiry, ok, err := timed.readExpiry(id)
+	if err != nil {
+		log.Printf("Error getting expiry of user ID: %s: %v", id, err)
+	}
+	if !c.serve(id, cacheKey, expiry, ok) {
+		return User{}, redis.Nil
 	}
 
+	c.counters.recordHit()
+	c.notifyHit(c.ctx, c.client, c.generateKey, cacheKey)
 	return user, nil
 }
 
@@ -104,16 +171,87 @@ func (c *TTLCache) Get(id string) (User, error) {
 // Returns:
 //   An error if marshalling or the Redis SET operation fails.
 func (c *TTLCache) Set(user User) error {
+	return c.set(user, c.expiration, c.expiration+c.maxStale)
+}
+
+// SetWithExpiry adds a user to the cache with its own soft and hard TTL instead of those of the cache.
+// Until soft, Get returns the user as fresh. Between soft and hard, Get still returns it and refreshes it
+// from the loader in the background, keeping both TTLs. Past hard, Get reports a miss and GetOrLoad blocks on a reload.
+//
+// Parameters:
+//   - user: The User object to store in the cache.
+//   - soft: How long the entry is fresh.
+//   - hard: How long the entry may be served at all. It must not be shorter than soft.
+//
+// Returns:
+//   An error if hard is shorter than soft, or if marshalling or the Redis SET operation fails.
+func (c *TTLCache) SetWithExpiry(user User, soft, hard time.Duration) error {
+	if soft <= 0 || hard < soft {
+		return fmt.Errorf("invalid expiry: soft %s, hard %s", soft, hard)
+	}
+	return c.set(user, soft, hard)
+}
+
+// set writes a user with its soft and hard TTL. The value is kept in Redis past its hard expiry
+// only as long as it may serve as a stale-if-error fallback.
+func (c *TTLCache) set(user User, soft, hard time.Duration) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+	defer c.counters.observe(opSet, time.Now())
+
 	cacheKey := c.generateKey(userPrefix, user.Id)
 
-	b, err := json.Marshal(&user)
+	c.logf(LogWrite, "Setting value for key: %s", cacheKey)
+	err := c.withRetry(c.ctx, func() error {
+		_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+			c.writeExpiry(pipe, user.Id, soft, hard)
+			return c.writeValue(c.ctx, pipe, cacheKey, &user, c.retention(soft, hard))
+		})
+		return err
+	})
 	if err != nil {
-		log.Printf("Error marshalling user data for ID: %s: %v", user.Id, err)
+		log.Printf("Error setting value for key: %s: %v", cacheKey, err)
 		return err
 	}
+	c.counters.sets.Add(1)
+	c.notifyAdmit(c.ctx, c.client, c.generateKey, c.generateKey(userPrefix, user.Id))
+	c.publishInvalidation(c.ctx, c.generateKey(userPrefix, user.Id))
+	return nil
+}
 
-	log.Printf("Setting value for key: %s", cacheKey)
-	return c.client.Set(c.ctx, cacheKey, b, c.expiration).Err()
+// Delete removes a key from the cache, along with the soft and hard expiry of its user.
+//
+// Parameters:
+//   - key: The cache key of the user, as generated by the cache.
+//
+// Returns:
+//   An error if the Redis DEL operation fails.
+func (c *TTLCache) Delete(key string) error {
+	c, cancel := c.withTimeout(c.writeTimeout)
+	defer cancel()
+
+	id := strings.TrimPrefix(key, c.generateKey(userPrefix)+":")
+	c.logf(LogWrite, "Deleting key: %s from cache", key)
+	err := c.withRetry(c.ctx, func() error {
+		return c.client.Del(c.ctx, key, c.generateKey(softExpiryKeyPrefix, id)).Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	c.publishInvalidation(c.ctx, key)
+	return c.dropEntries(c.ctx, c.client, c.generateKey, key)
+}
+
+// Key returns the value key of a user, as passed to Delete and to the callbacks of the cache.
+//
+// Parameters:
+//   - id: The ID of the user.
+//
+// Returns:
+//   The value key of the user.
+func (c *TTLCache) Key(id string) string {
+	return c.generateKey(userPrefix, id)
 }
 
 // generateKey constructs a Redis key by joining the configured key prefix
@@ -126,7 +264,7 @@ func (c *TTLCache) Set(user User) error {
 // Returns:
 //   A single string representing the full Redis key.
 func (c *TTLCache) generateKey(keys ...string) string {
-	allKeys := []string{c.keyPrefix}
+	allKeys := []string{c.namespace(c.keyPrefix)}
 	allKeys = append(allKeys, keys...)
 
 	return strings.Join(allKeys, ":")
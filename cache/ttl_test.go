@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestTTLExpiresEntries(t *testing.T) {
+	client, m := newTestClient(t)
+	c := NewTTL(context.Background(), client, time.Minute, "ttl")
+
+	setUsers(t, c.Set, nil, 1)
+	m.FastForward(30 * time.Second)
+	setUsers(t, c.Set, nil, 2)
+	if _, err := c.Get("1"); err != nil {
+		t.Fatalf("Get(1) before its expiration: %v", err)
+	}
+
+	m.FastForward(31 * time.Second)
+	if _, err := c.Get("1"); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get(1) after its expiration: %v, want redis.Nil", err)
+	}
+	if _, err := c.Get("2"); err != nil {
+		t.Errorf("Get(2) before its expiration: %v", err)
+	}
+	assertCached(t, client, c.Key, 2)
+}
+
+func TestTTLSetResetsExpiration(t *testing.T) {
+	client, m := newTestClient(t)
+	c := NewTTL(context.Background(), client, time.Minute, "ttl")
+
+	setUsers(t, c.Set, nil, 1)
+	m.FastForward(45 * time.Second)
+	setUsers(t, c.Set, nil, 1)
+	m.FastForward(45 * time.Second)
+	if _, err := c.Get("1"); err != nil {
+		t.Errorf("Get(1) after it was written again: %v", err)
+	}
+	if ttl := m.TTL(c.Key("1")); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("TTL of user 1: %s, want at most a minute", ttl)
+	}
+}
+
+func TestTTLErrors(t *testing.T) {
+	client, m := newTestClient(t)
+	loadErr := errors.New("database down")
+	c := NewTTL(context.Background(), client, time.Minute, "ttl", WithLoader(func(ctx context.Context, id string) (User, error) {
+		return User{}, loadErr
+	}))
+
+	if _, err := c.GetOrLoad("1"); !errors.Is(err, loadErr) {
+		t.Errorf("GetOrLoad with a failing loader: %v, want %v", err, loadErr)
+	}
+	assertCached(t, client, c.Key)
+
+	m.Close()
+	if err := c.Set(testUser(1)); err == nil {
+		t.Error("Set with Redis down succeeded")
+	}
+}
@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// warmConcurrency is the number of users Warm loads at the same time.
+const warmConcurrency = 8
+
+// IDLister lists the IDs of the users of a store, such as the most active ones, for WarmFromLoader.
+type IDLister interface {
+	// ListIDs returns up to limit IDs, the most important first, or every ID if limit is 0.
+	ListIDs(ctx context.Context, limit int) ([]string, error)
+}
+
+// admission is a call of the admission script of a cache, admitting a user and evicting entries to make room.
+type admission struct {
+	value    interface{} // the value admitted, indexed by its fields, or nil for a raw payload
+	cacheKey string
+	script   *redis.Script
+	keys     []string
+	args     []interface{}
+}
+
+// admitted handles the reply of an admission script: it counts, audits and reports the entries evicted to make room,
+// and tracks the admitted entry as written from source.
+func (o options) admitted(ctx context.Context, client Client, generateKey func(...string) string, policy string, a admission, reply []string, start time.Time, source string) error {
+	if err := o.evicted(ctx, client, generateKey, policy, reply, start); err != nil {
+		return err
+	}
+	return o.trackEntry(ctx, client, generateKey, a.cacheKey, a.value, source)
+}
+
+// evicted handles the entries an admission script evicted to make room: it counts, audits and reports them, and
+// drops their metadata.
+func (o options) evicted(ctx context.Context, client Client, generateKey func(...string) string, policy string, reply []string, start time.Time) error {
+	evictions := parseEvictions(reply)
+	for _, e := range evictions {
+		o.logf(LogEvict, "Cache was full. Evicted %s member: %s", policy, e.key)
+	}
+	o.counters.evictions.Add(int64(len(evictions)))
+	if len(evictions) > 0 {
+		o.counters.observe(opEvict, start)
+	}
+	o.auditEvictions(ctx, client, generateKey, policy, evictions)
+	o.flushEvicted(ctx, evictedKeys(evictions)...)
+	if err := o.dropEntries(ctx, client, generateKey, evictedKeys(evictions)...); err != nil {
+		return err
+	}
+	for _, e := range evictions {
+		o.notifyEvict(ctx, client, generateKey, e)
+	}
+	return nil
+}
+
+// loadAll loads the users of ids with the loader, warmConcurrency at a time. It returns the users loaded, in the order
+// of ids, the number of users that failed to load, and the error of the first of them.
+func (o options) loadAll(ctx context.Context, ids []string) ([]User, int, error) {
+	users := make([]User, len(ids))
+	loaded := make([]bool, len(ids))
+	errs := make([]error, len(ids))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, warmConcurrency)
+	for i, id := range ids {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			users[i], errs[i] = o.load(ctx, id)
+			loaded[i] = errs[i] == nil
+		}(i, id)
+	}
+	wg.Wait()
+
+	var ok []User
+	failed := 0
+	var firstErr error
+	for i := range ids {
+		if loaded[i] {
+			ok = append(ok, users[i])
+			continue
+		}
+		log.Printf("Failed to load user ID: %s to warm the cache: %v", ids[i], errs[i])
+		failed++
+		if firstErr == nil {
+			firstErr = errs[i]
+		}
+	}
+	return ok, failed, firstErr
+}
+
+// warm loads the users of ids and admits them under the eviction lock, in batches of setManyBatchSize users
+// admitted by a single script call each. Only the first users that fit in capacity are warmed, since the others
+// would evict them. It returns the number of users admitted.
+func (o options) warm(ctx context.Context, client Client, generateKey func(...string) string, policy string, capacity int, ids []string, batch func([]User) (batchAdmission, error)) (int, error) {
+	if capacity > 0 && len(ids) > capacity {
+		log.Printf("Warming the first %d of %d users, the capacity of the cache.", capacity, len(ids))
+		ids = ids[:capacity]
+	}
+	log.Printf("Warming cache with %d users.", len(ids))
+
+	users, failed, loadErr := o.loadAll(ctx, ids)
+	admitted := 0
+	err := withLock(ctx, o.locker, generateKey(lockKeyPrefix), func() error {
+		var err error
+		admitted, err = o.admitMany(ctx, client, generateKey, policy, users, batch, SourceDatabase)
+		return err
+	})
+	if err != nil {
+		log.Printf("Error warming cache: %v", err)
+		return admitted, err
+	}
+
+	log.Printf("Warmed cache with %d users, %d failed to load.", admitted, failed)
+	if loadErr != nil {
+		return admitted, fmt.Errorf("%d of %d users failed to load: %w", failed, len(ids), loadErr)
+	}
+	return admitted, nil
+}
+
+// Warm loads the users of ids with the loader and admits them to the cache before traffic arrives, in batches,
+// following the eviction policy of the cache. Only the first ids that fit in the capacity are warmed, so ids should
+// come most important first. Users that fail to load are skipped, and reported in the error with the number of
+// users admitted.
+func (c *FIFOCache) Warm(ctx context.Context, ids []string) (int, error) {
+	return c.warm(ctx, c.client, c.generateKey, string(PolicyFIFO), c.itemCapacity(c.capacity), ids, c.batchAdmission)
+}
+
+// Warm loads the users of ids with the loader and admits them to the cache before traffic arrives, in batches,
+// following the eviction policy of the cache. Only the first ids that fit in the capacity are warmed, so ids should
+// come most important first. Users that fail to load are skipped, and reported in the error with the number of
+// users admitted.
+func (c *LRUCache) Warm(ctx context.Context, ids []string) (int, error) {
+	return c.warm(ctx, c.client, c.generateKey, string(PolicyLRU), c.itemCapacity(c.capacity), ids, c.batchAdmission)
+}
+
+// Warm loads the users of ids with the loader and admits them to the cache before traffic arrives, in batches,
+// following the eviction policy of the cache, each with an access count of 1. Only the first ids that fit in the
+// capacity are warmed, so ids should come most important first. Users that fail to load are skipped, and reported
+// in the error with the number of users admitted.
+func (c *LFUCache) Warm(ctx context.Context, ids []string) (int, error) {
+	return c.warm(ctx, c.client, c.generateKey, string(PolicyLFU), c.itemCapacity(c.capacity), ids, c.batchAdmission)
+}
+
+// Warm loads the users of ids with the loader and admits them to the cache before traffic arrives, in batches,
+// with the sampling script of the coordinated eviction mode. Only the first ids that fit in the capacity are warmed,
+// so ids should come most important first. Users that fail to load are skipped, and reported in the error with the
+// number of users admitted.
+func (c *ApproxLRUCache) Warm(ctx context.Context, ids []string) (int, error) {
+	return c.warm(ctx, c.client, c.generateKey, string(PolicyApproxLRU), c.itemCapacity(c.capacity), ids, c.batchAdmission)
+}
+
+// Warm loads the users of ids with the loader and writes them to the cache before traffic arrives, with SetMany.
+// The TTL cache has no capacity, so every user is written. Users that fail to load are skipped, and reported in the
+// error with the number of users written.
+func (c *TTLCache) Warm(ctx context.Context, ids []string) (int, error) {
+	log.Printf("Warming cache with %d users.", len(ids))
+	users, failed, loadErr := c.loadAll(ctx, ids)
+	if err := c.SetMany(users); err != nil {
+		log.Printf("Error warming cache: %v", err)
+		return 0, err
+	}
+
+	log.Printf("Warmed cache with %d users, %d failed to load.", len(users), failed)
+	if loadErr != nil {
+		return len(users), fmt.Errorf("%d of %d users failed to load: %w", failed, len(ids), loadErr)
+	}
+	return len(users), nil
+}
+
+// WarmFromLoader warms the cache with the IDs listed by lister, as many as fit in the cache, see Warm.
+func (c *FIFOCache) WarmFromLoader(ctx context.Context, lister IDLister) (int, error) {
+	return warmFrom(ctx, lister, c.itemCapacity(c.capacity), c.Warm)
+}
+
+// WarmFromLoader warms the cache with the IDs listed by lister, as many as fit in the cache, see Warm.
+func (c *LRUCache) WarmFromLoader(ctx context.Context, lister IDLister) (int, error) {
+	return warmFrom(ctx, lister, c.itemCapacity(c.capacity), c.Warm)
+}
+
+// WarmFromLoader warms the cache with the IDs listed by lister, as many as fit in the cache, see Warm.
+func (c *LFUCache) WarmFromLoader(ctx context.Context, lister IDLister) (int, error) {
+	return warmFrom(ctx, lister, c.itemCapacity(c.capacity), c.Warm)
+}
+
+// WarmFromLoader warms the cache with the IDs listed by lister, as many as fit in the cache, see Warm.
+func (c *ApproxLRUCache) WarmFromLoader(ctx context.Context, lister IDLister) (int, error) {
+	return warmFrom(ctx, lister, c.itemCapacity(c.capacity), c.Warm)
+}
+
+// WarmFromLoader warms the cache with every ID listed by lister, see Warm.
+func (c *TTLCache) WarmFromLoader(ctx context.Context, lister IDLister) (int, error) {
+	return warmFrom(ctx, lister, 0, c.Warm)
+}
+
+// warmFrom lists up to limit IDs with lister, or every ID if limit is 0, and warms a cache with them.
+func warmFrom(ctx context.Context, lister IDLister, limit int, warm func(context.Context, []string) (int, error)) (int, error) {
+	ids, err := lister.ListIDs(ctx, limit)
+	if err != nil {
+		log.Printf("Error listing IDs to warm the cache: %v", err)
+		return 0, err
+	}
+	return warm(ctx, ids)
+}
@@ -0,0 +1,491 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults of NewWriteBehind.
+const (
+	// DefaultFlushInterval is the interval between the flushes of a WriteBehind created without one.
+	DefaultFlushInterval = time.Second
+	// DefaultFlushBatchSize is the number of users per Store call of a WriteBehind created without one.
+	DefaultFlushBatchSize = 100
+)
+
+// Store persists the users written to a cache in write-behind mode, such as a database. SaveUsers must be
+// idempotent, since a batch whose save failed is saved again with the next flush.
+type Store interface {
+	SaveUsers(ctx context.Context, users []User) error
+}
+
+// StoreFunc adapts a function to a Store.
+type StoreFunc func(ctx context.Context, users []User) error
+
+// SaveUsers calls f.
+func (f StoreFunc) SaveUsers(ctx context.Context, users []User) error {
+	return f(ctx, users)
+}
+
+// WriteBehind queues the users written to a cache and persists them to a Store in the background, in batches.
+// A WriteBehind serves a single cache, passed to it with WithWriteBehind.
+//
+// The queue of NewWriteBehind lives in the process: writes of the same user coalesce, so only the last one queued
+// before a flush is saved, but the queued users are lost if the process crashes before they are flushed; call Close
+// before exiting. The queue of NewDurableWriteBehind is a Redis Stream, which survives restarts.
+type WriteBehind struct {
+	store     Store
+	queue     writeQueue
+	interval  time.Duration
+	batchSize int
+
+	// saving serializes the calls of the Store, so an older write of a user never overwrites a newer one.
+	saving sync.Mutex
+
+	deadLetters      Client
+	deadLetterStream string
+	maxAttempts      int
+	deadLettersTotal atomic.Int64
+
+	queuedTotal    atomic.Int64
+	coalescedTotal atomic.Int64
+
+	full    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// queuedWrite is a user waiting in a write queue, with the value key it is cached under.
+type queuedWrite struct {
+	key  string
+	user User
+	// id is the ID of the stream entry of the write, in a durable queue.
+	id string
+	// attempts is the number of saves of the write that failed.
+	attempts int
+}
+
+// writeQueue holds the writes of a WriteBehind until they are saved.
+type writeQueue interface {
+	// push queues a write and returns the number of writes queued, and whether the write replaced a queued write
+	// of the same key.
+	push(ctx context.Context, cacheKey string, user User) (int, bool, error)
+	// take returns up to n of the oldest queued writes, or every one if n is 0, and hands them to the caller
+	// until done or failed is called.
+	take(ctx context.Context, n int) ([]queuedWrite, error)
+	// takeKeys returns the queued writes of keys, or more, such as every queued write, if the queue cannot
+	// single them out.
+	takeKeys(ctx context.Context, keys []string) ([]queuedWrite, error)
+	// done removes writes that were saved from the queue.
+	done(ctx context.Context, writes []queuedWrite) error
+	// failed hands back writes whose save failed, with their attempts, to be taken first by the next flush.
+	failed(ctx context.Context, writes []queuedWrite)
+	// len returns the number of writes queued.
+	len(ctx context.Context) (int, error)
+}
+
+// WriteBehindOption configures a WriteBehind.
+type WriteBehindOption func(*WriteBehind)
+
+// WithDeadLetters moves the writes the Store fails to save maxAttempts times to the Redis Stream named stream,
+// with the error of the last attempt, instead of retrying them forever. A batch that fails is retried by the next
+// flush, and its writes that reached maxAttempts are then saved one at a time, so a single write the Store rejects
+// does not hold back the others. Dead letters are listed with DeadLetters and queued again with RetryDeadLetters.
+//
+// Failures count for any error, including an outage of the Store, so maxAttempts times the flush interval should
+// outlast the outages the Store is expected to recover from.
+func WithDeadLetters(client Client, stream string, maxAttempts int) WriteBehindOption {
+	return func(wb *WriteBehind) {
+		wb.deadLetters = client
+		wb.deadLetterStream = stream
+		wb.maxAttempts = max(maxAttempts, 1)
+	}
+}
+
+// NewWriteBehind creates a WriteBehind saving the queued users to store every interval, or as soon as batchSize
+// users are queued, in batches of up to batchSize users. An interval or batch size of 0 selects DefaultFlushInterval
+// or DefaultFlushBatchSize. It starts the goroutine flushing the queue, which runs until Close.
+func NewWriteBehind(store Store, interval time.Duration, batchSize int, opts ...WriteBehindOption) *WriteBehind {
+	return newWriteBehind(store, newMemoryQueue(), interval, batchSize, opts)
+}
+
+// newWriteBehind creates a WriteBehind queueing the writes in queue and starts flushing it.
+func newWriteBehind(store Store, queue writeQueue, interval time.Duration, batchSize int, opts []WriteBehindOption) *WriteBehind {
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultFlushBatchSize
+	}
+	log.Printf("Starting write-behind queue with flush interval: %s and batch size: %d", interval, batchSize)
+	wb := &WriteBehind{
+		store:     store,
+		queue:     queue,
+		interval:  interval,
+		batchSize: batchSize,
+		full:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(wb)
+	}
+	go wb.run()
+	return wb
+}
+
+// WithWriteBehind makes Set update the cache and queue the user in wb, which saves it to its Store later,
+// instead of leaving the write of the source of truth to the caller. Users the cache loads with its loader are not
+// queued. A queued user the cache evicts or deletes is saved before Set or Delete returns, so the cache never drops
+// a write the Store has not seen; if that save fails, the user stays queued. If the user cannot be queued, Set
+// returns the error, although the cache was updated.
+// The TTL cache ignores this option.
+func WithWriteBehind(wb *WriteBehind) Option {
+	return func(o *options) {
+		o.writeBehind = wb
+	}
+}
+
+// run flushes the queue every interval, and whenever a batch is full, until Close.
+func (wb *WriteBehind) run() {
+	defer close(wb.stopped)
+	ticker := time.NewTicker(wb.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wb.done:
+			return
+		case <-ticker.C:
+		case <-wb.full:
+		}
+		if err := wb.Flush(context.Background()); err != nil {
+			log.Printf("Error flushing write-behind queue, retrying in %s: %v", wb.interval, err)
+		}
+	}
+}
+
+// enqueue queues a write of the user cached under cacheKey, and wakes up the flushing goroutine once a batch is full.
+func (wb *WriteBehind) enqueue(ctx context.Context, cacheKey string, user User) error {
+	queued, replaced, err := wb.queue.push(ctx, cacheKey, user)
+	if err != nil {
+		return err
+	}
+	wb.queuedTotal.Add(1)
+	if replaced {
+		wb.coalescedTotal.Add(1)
+	}
+	if queued >= wb.batchSize {
+		select {
+		case wb.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// save saves writes to the store, and removes them from the queue, or hands them back to it if the save fails.
+// Only the last write of every key is saved; the others are removed from the queue first.
+// With WithDeadLetters, the writes that failed too many times are saved alone, and moved to the dead letters
+// if that fails too. The progress is logged with logf, the logger of the cache the save runs for, if any.
+func (wb *WriteBehind) save(ctx context.Context, logf logFunc, writes []queuedWrite) error {
+	writes, superseded := coalesce(writes)
+	if len(superseded) > 0 {
+		logf(LogWrite, "Coalescing %d queued writes into later writes of the same users", len(superseded))
+		if err := wb.queue.done(ctx, superseded); err != nil {
+			wb.queue.failed(ctx, append(superseded, writes...))
+			return err
+		}
+		wb.coalescedTotal.Add(int64(len(superseded)))
+	}
+
+	users := make([]User, len(writes))
+	for i, w := range writes {
+		users[i] = w.user
+	}
+
+	logf(LogWrite, "Saving %d queued users to store", len(users))
+	err := wb.store.SaveUsers(ctx, users)
+	if err == nil {
+		return wb.queue.done(ctx, writes)
+	}
+
+	log.Printf("Error saving %d queued users to store: %v", len(users), err)
+	var retry []queuedWrite
+	for _, w := range writes {
+		w.attempts++
+		if wb.deadLetters == nil || w.attempts < wb.maxAttempts {
+			retry = append(retry, w)
+		} else if !wb.saveAlone(ctx, w) {
+			retry = append(retry, w)
+		}
+	}
+	if len(retry) > 0 {
+		wb.queue.failed(ctx, retry)
+	}
+	return err
+}
+
+// coalesce splits writes into the last write of every key, in order, and the earlier writes they supersede.
+func coalesce(writes []queuedWrite) (latest, superseded []queuedWrite) {
+	last := make(map[string]int, len(writes))
+	for i, w := range writes {
+		last[w.key] = i
+	}
+	if len(last) == len(writes) {
+		return writes, nil
+	}
+	for i, w := range writes {
+		if last[w.key] == i {
+			latest = append(latest, w)
+		} else {
+			superseded = append(superseded, w)
+		}
+	}
+	return latest, superseded
+}
+
+// saveAlone saves a write that failed too many times on its own, and moves it to the dead letters if that fails.
+// It reports whether the write left the queue.
+func (wb *WriteBehind) saveAlone(ctx context.Context, w queuedWrite) bool {
+	err := wb.store.SaveUsers(ctx, []User{w.user})
+	if err != nil {
+		log.Printf("Error saving user: %s of key: %s after %d attempts: %v", w.user.Id, w.key, w.attempts, err)
+		if err := wb.deadLetter(ctx, w, err); err != nil {
+			return false
+		}
+	}
+	return wb.queue.done(ctx, []queuedWrite{w}) == nil
+}
+
+// Flush saves every queued user to the Store, in batches, and returns the error of the first batch that fails.
+// The users of a failed batch stay queued.
+func (wb *WriteBehind) Flush(ctx context.Context) error {
+	wb.saving.Lock()
+	defer wb.saving.Unlock()
+
+	for {
+		writes, err := wb.queue.take(ctx, wb.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(writes) == 0 {
+			return nil
+		}
+		if err := wb.save(ctx, logAlways, writes); err != nil {
+			return err
+		}
+	}
+}
+
+// flushKeys saves the queued users of keys, if any, before they leave the cache, logging with logf.
+func (wb *WriteBehind) flushKeys(ctx context.Context, logf logFunc, keys ...string) error {
+	wb.saving.Lock()
+	defer wb.saving.Unlock()
+
+	writes, err := wb.queue.takeKeys(ctx, keys)
+	if err != nil || len(writes) == 0 {
+		return err
+	}
+	logf(LogEvict, "Saving %d queued users before keys: %v leave the cache", len(writes), keys)
+	return wb.save(ctx, logf, writes)
+}
+
+// discardKeys removes the queued writes of keys without saving them, when the users changed in the Store behind the
+// back of the cache, so the stale users are not saved over the change. The other writes a durable queue hands out
+// with them are saved. It logs with logf.
+func (wb *WriteBehind) discardKeys(ctx context.Context, logf logFunc, keys ...string) error {
+	wb.saving.Lock()
+	defer wb.saving.Unlock()
+
+	writes, err := wb.queue.takeKeys(ctx, keys)
+	if err != nil || len(writes) == 0 {
+		return err
+	}
+	var discarded, others []queuedWrite
+	for _, w := range writes {
+		if slices.Contains(keys, w.key) {
+			discarded = append(discarded, w)
+		} else {
+			others = append(others, w)
+		}
+	}
+
+	logf(LogWrite, "Discarding %d queued writes of keys: %v changed in the store", len(discarded), keys)
+	if err := wb.queue.done(ctx, discarded); err != nil {
+		wb.queue.failed(ctx, writes)
+		return err
+	}
+	if len(others) > 0 {
+		return wb.save(ctx, logf, others)
+	}
+	return nil
+}
+
+// Pending returns the number of users queued and not saved yet. For a durable queue, it is the length of its
+// stream, shared by every instance, or 0 if the stream cannot be read.
+func (wb *WriteBehind) Pending() int {
+	n, err := wb.queue.len(context.Background())
+	if err != nil {
+		log.Printf("Error reading the length of the write-behind queue: %v", err)
+		return 0
+	}
+	return n
+}
+
+// Queued returns the number of writes queued since the WriteBehind was created, including the coalesced ones.
+func (wb *WriteBehind) Queued() int64 {
+	return wb.queuedTotal.Load()
+}
+
+// Coalesced returns the number of queued writes that were never saved because a later write of the same user
+// replaced them, since the WriteBehind was created.
+func (wb *WriteBehind) Coalesced() int64 {
+	return wb.coalescedTotal.Load()
+}
+
+// Close stops the background flushes and saves the users still queued. It returns the error of the final flush,
+// after which the users that could not be saved are lost unless Flush is called again.
+func (wb *WriteBehind) Close(ctx context.Context) error {
+	wb.once.Do(func() {
+		log.Printf("Stopping write-behind queue")
+		close(wb.done)
+	})
+	<-wb.stopped
+	return wb.Flush(ctx)
+}
+
+// enqueueWrite queues a user written to the cache in write-behind mode, unless it was read from the store.
+func (o options) enqueueWrite(ctx context.Context, cacheKey string, user User) error {
+	if o.writeBehind == nil || o.fromStore {
+		return nil
+	}
+	if err := o.writeBehind.enqueue(ctx, cacheKey, user); err != nil {
+		log.Printf("Error queueing write of key: %s: %v", cacheKey, err)
+		return err
+	}
+	return nil
+}
+
+// flushEvicted saves the queued users of value keys leaving the cache, so their writes are not lost.
+// A failed save is logged, and the users stay queued for the next flush.
+func (o options) flushEvicted(ctx context.Context, cacheKeys ...string) {
+	if o.writeBehind == nil || len(cacheKeys) == 0 {
+		return
+	}
+	if err := o.writeBehind.flushKeys(ctx, o.logf, cacheKeys...); err != nil {
+		log.Printf("Error saving queued users of keys: %v leaving the cache: %v", cacheKeys, err)
+	}
+}
+
+// discardQueued drops the queued writes of value keys whose users changed in the store, so a later flush does
+// not overwrite the change with them.
+func (o options) discardQueued(ctx context.Context, cacheKeys ...string) error {
+	if o.writeBehind == nil || len(cacheKeys) == 0 {
+		return nil
+	}
+	if err := o.writeBehind.discardKeys(ctx, o.logf, cacheKeys...); err != nil {
+		log.Printf("Error discarding queued users of keys: %v: %v", cacheKeys, err)
+		return err
+	}
+	return nil
+}
+
+// memoryQueue is the write queue of NewWriteBehind, in process memory. It keeps the last write of every key,
+// in the order the keys were first queued.
+type memoryQueue struct {
+	mu      sync.Mutex
+	pending map[string]queuedWrite
+	order   []string
+}
+
+// newMemoryQueue creates an empty memoryQueue.
+func newMemoryQueue() *memoryQueue {
+	return &memoryQueue{pending: make(map[string]queuedWrite)}
+}
+
+// push replaces the queued write of cacheKey, if any, or queues it last. A write replacing another starts
+// its attempts over.
+func (q *memoryQueue) push(_ context.Context, cacheKey string, user User) (int, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	_, queued := q.pending[cacheKey]
+	if !queued {
+		q.order = append(q.order, cacheKey)
+	}
+	q.pending[cacheKey] = queuedWrite{key: cacheKey, user: user}
+	return len(q.order), queued, nil
+}
+
+// take removes up to n of the oldest writes from the queue.
+func (q *memoryQueue) take(_ context.Context, n int) ([]queuedWrite, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if n <= 0 || n > len(q.order) {
+		n = len(q.order)
+	}
+	return q.remove(q.order[:n]), nil
+}
+
+// takeKeys removes the writes of keys from the queue.
+func (q *memoryQueue) takeKeys(_ context.Context, keys []string) ([]queuedWrite, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.remove(keys), nil
+}
+
+// remove removes the writes of keys from the queue and returns them. It is called with mu held.
+func (q *memoryQueue) remove(keys []string) []queuedWrite {
+	var writes []queuedWrite
+	for _, key := range keys {
+		if w, ok := q.pending[key]; ok {
+			writes = append(writes, w)
+			delete(q.pending, key)
+		}
+	}
+	if len(writes) > 0 {
+		order := q.order[:0]
+		for _, key := range q.order {
+			if _, ok := q.pending[key]; ok {
+				order = append(order, key)
+			}
+		}
+		q.order = order
+	}
+	return writes
+}
+
+// done does nothing: the writes left the queue when they were taken.
+func (q *memoryQueue) done(context.Context, []queuedWrite) error {
+	return nil
+}
+
+// failed puts writes back at the front of the queue, unless their keys were written again since.
+func (q *memoryQueue) failed(_ context.Context, writes []queuedWrite) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var front []string
+	for _, w := range writes {
+		if _, rewritten := q.pending[w.key]; rewritten {
+			continue
+		}
+		q.pending[w.key] = w
+		front = append(front, w.key)
+	}
+	q.order = append(front, q.order...)
+}
+
+// len returns the number of keys with a queued write.
+func (q *memoryQueue) len(context.Context) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.order), nil
+}
@@ -0,0 +1,266 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ClaimIdle is the time after which the writes another instance took from a durable write-behind queue, and neither
+// saved nor handed back, are claimed by the next flush of this one, on the assumption that the other instance died.
+const ClaimIdle = time.Minute
+
+// writeBehindGroup is the consumer group of the streams of durable write-behind queues.
+const writeBehindGroup = "write_behind"
+
+// pushWriteScript appends a write to the stream of a durable queue and counts it in the dirty hash, which holds
+// the number of queued writes of every value key. It returns the length of the stream.
+var pushWriteScript = scripts.register(`
+local stream, dirty = KEYS[1], KEYS[2]
+local key, user = ARGV[1], ARGV[2]
+redis.call('XADD', stream, '*', 'key', key, 'user', user)
+redis.call('HINCRBY', dirty, key, 1)
+return redis.call('XLEN', stream)
+`)
+
+// ackWritesScript acknowledges and deletes saved writes from the stream of a durable queue, given as pairs of
+// entry ID and value key, uncounts them from the dirty hash and forgets their attempts. A write acknowledged
+// by another instance that claimed it in the meantime is only uncounted once.
+var ackWritesScript = scripts.register(`
+local stream, dirty, attempts, group = KEYS[1], KEYS[2], KEYS[3], ARGV[1]
+for i = 2, #ARGV, 2 do
+	local acked = redis.call('XACK', stream, group, ARGV[i])
+	redis.call('XDEL', stream, ARGV[i])
+	redis.call('HDEL', attempts, ARGV[i])
+	if acked == 1 and ARGV[i + 1] ~= '' and redis.call('HINCRBY', dirty, ARGV[i + 1], -1) <= 0 then
+		redis.call('HDEL', dirty, ARGV[i + 1])
+	end
+end
+return 1
+`)
+
+// streamQueue is the write queue of NewDurableWriteBehind: a Redis Stream read through a consumer group,
+// so every instance takes its share of the writes. A write taken by an instance stays pending in the group
+// until it is saved, so it survives a crash of the instance: it resumes its own pending writes when it restarts
+// under the same consumer name, and the other instances claim them after ClaimIdle.
+type streamQueue struct {
+	client   Client
+	stream   string
+	dirty    string
+	attempts string
+	consumer string
+}
+
+// NewDurableWriteBehind creates a WriteBehind like NewWriteBehind, whose queue is the Redis Stream named stream,
+// shared by every instance creating its WriteBehind on the same stream. Each write is appended to the stream by Set,
+// which fails if it cannot be, and deleted from it once saved, so the writes survive a crash and are saved by
+// whichever instance flushes first. Writes are saved in the order they were queued, and only the writes of a user
+// taken in the same batch are coalesced.
+//
+// consumer names the instance in the consumer group of the stream. An instance restarting under the same name saves
+// the writes it had taken before it stopped with its first flush; otherwise, they are claimed by the other instances
+// after ClaimIdle. An empty consumer generates a random name.
+//
+// The stream, the hash counting the queued writes of every key, <stream>:dirty, and the hash counting the failed
+// saves of every write, <stream>:attempts, must be on the same node: with a Cluster or Ring client, stream is
+// wrapped in a hash tag unless it has one.
+func NewDurableWriteBehind(ctx context.Context, client Client, stream, consumer string, store Store, interval time.Duration, batchSize int, opts ...WriteBehindOption) (*WriteBehind, error) {
+	if consumer == "" {
+		token, err := newLockToken()
+		if err != nil {
+			return nil, err
+		}
+		consumer = token
+	}
+	if sharded(client) && !strings.Contains(stream, "{") {
+		stream = "{" + stream + "}"
+	}
+
+	log.Printf("Creating consumer group: %s of write-behind stream: %s for consumer: %s", writeBehindGroup, stream, consumer)
+	err := client.XGroupCreateMkStream(ctx, stream, writeBehindGroup, "0").Err()
+	if err != nil && !redis.HasErrorPrefix(err, "BUSYGROUP") {
+		log.Printf("Error creating consumer group of stream: %s: %v", stream, err)
+		return nil, err
+	}
+
+	q := &streamQueue{client: client, stream: stream, dirty: stream + ":dirty", attempts: stream + ":attempts", consumer: consumer}
+	return newWriteBehind(store, q, interval, batchSize, opts), nil
+}
+
+// push appends a write to the stream. Writes of the same key are coalesced when they are saved in the same batch.
+func (q *streamQueue) push(ctx context.Context, cacheKey string, user User) (int, bool, error) {
+	b, err := json.Marshal(user)
+	if err != nil {
+		return 0, false, err
+	}
+	n, err := scripts.run(ctx, q.client, pushWriteScript, []string{q.stream, q.dirty}, cacheKey, b).Int()
+	if err != nil {
+		log.Printf("Error appending write of key: %s to stream: %s: %v", cacheKey, q.stream, err)
+		return 0, false, err
+	}
+	return n, false, nil
+}
+
+// take returns up to n writes: the writes the consumer took before and did not save, or else the writes of other
+// consumers pending for more than ClaimIdle, or else the oldest writes no consumer took.
+func (q *streamQueue) take(ctx context.Context, n int) ([]queuedWrite, error) {
+	writes, err := q.read(ctx, "0", n)
+	if err != nil || len(writes) > 0 {
+		return writes, err
+	}
+
+	messages, _, err := q.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   q.stream,
+		Group:    writeBehindGroup,
+		Consumer: q.consumer,
+		MinIdle:  ClaimIdle,
+		Start:    "0-0",
+		Count:    int64(n),
+	}).Result()
+	if err != nil {
+		log.Printf("Error claiming idle writes of stream: %s: %v", q.stream, err)
+		return nil, err
+	}
+	if len(messages) > 0 {
+		log.Printf("Claimed %d idle writes of stream: %s", len(messages), q.stream)
+		return q.withAttempts(ctx, q.decode(ctx, messages))
+	}
+
+	return q.read(ctx, ">", n)
+}
+
+// takeKeys returns every write the consumer can take, if any of keys has a queued write: the stream cannot single
+// out the writes of keys, but they are saved in order with the writes queued before them.
+func (q *streamQueue) takeKeys(ctx context.Context, keys []string) ([]queuedWrite, error) {
+	counts, err := q.client.HMGet(ctx, q.dirty, keys...).Result()
+	if err != nil {
+		log.Printf("Error reading queued writes of keys: %v from hash: %s: %v", keys, q.dirty, err)
+		return nil, err
+	}
+	dirty := false
+	for _, count := range counts {
+		dirty = dirty || count != nil
+	}
+	if !dirty {
+		return nil, nil
+	}
+
+	pending, err := q.read(ctx, "0", 0)
+	if err != nil {
+		return nil, err
+	}
+	writes, err := q.read(ctx, ">", 0)
+	if err != nil {
+		return nil, err
+	}
+	return append(pending, writes...), nil
+}
+
+// read reads up to n writes of the consumer group from the stream, or every one if n is 0, starting at id:
+// "0" for the writes pending for the consumer, ">" for the writes no consumer took.
+func (q *streamQueue) read(ctx context.Context, id string, n int) ([]queuedWrite, error) {
+	streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    writeBehindGroup,
+		Consumer: q.consumer,
+		Streams:  []string{q.stream, id},
+		Count:    int64(n),
+		Block:    -1,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("Error reading writes from stream: %s: %v", q.stream, err)
+		return nil, err
+	}
+
+	var writes []queuedWrite
+	for _, s := range streams {
+		writes = append(writes, q.decode(ctx, s.Messages)...)
+	}
+	if id == ">" {
+		return writes, nil
+	}
+	return q.withAttempts(ctx, writes)
+}
+
+// withAttempts reads the attempts of writes taken before.
+func (q *streamQueue) withAttempts(ctx context.Context, writes []queuedWrite) ([]queuedWrite, error) {
+	if len(writes) == 0 {
+		return writes, nil
+	}
+	ids := make([]string, len(writes))
+	for i, w := range writes {
+		ids[i] = w.id
+	}
+	attempts, err := q.client.HMGet(ctx, q.attempts, ids...).Result()
+	if err != nil {
+		log.Printf("Error reading attempts of writes from hash: %s: %v", q.attempts, err)
+		return nil, err
+	}
+	for i, a := range attempts {
+		if s, ok := a.(string); ok {
+			writes[i].attempts, _ = strconv.Atoi(s)
+		}
+	}
+	return writes, nil
+}
+
+// decode decodes the writes of stream entries. Entries that cannot be decoded, such as entries deleted while
+// pending, are logged and acknowledged, so they are never taken again.
+func (q *streamQueue) decode(ctx context.Context, messages []redis.XMessage) []queuedWrite {
+	writes := make([]queuedWrite, 0, len(messages))
+	var invalid []queuedWrite
+	for _, m := range messages {
+		w := queuedWrite{id: m.ID}
+		w.key, _ = m.Values["key"].(string)
+		data, _ := m.Values["user"].(string)
+		if err := json.Unmarshal([]byte(data), &w.user); err != nil {
+			log.Printf("Dropping write: %s of stream: %s that cannot be decoded: %v", m.ID, q.stream, err)
+			invalid = append(invalid, w)
+			continue
+		}
+		writes = append(writes, w)
+	}
+	if len(invalid) > 0 {
+		q.done(ctx, invalid)
+	}
+	return writes
+}
+
+// done acknowledges and deletes saved writes.
+func (q *streamQueue) done(ctx context.Context, writes []queuedWrite) error {
+	args := []interface{}{writeBehindGroup}
+	for _, w := range writes {
+		args = append(args, w.id, w.key)
+	}
+	if err := scripts.run(ctx, q.client, ackWritesScript, []string{q.stream, q.dirty, q.attempts}, args...).Err(); err != nil {
+		log.Printf("Error acknowledging %d saved writes of stream: %s: %v", len(writes), q.stream, err)
+		return err
+	}
+	return nil
+}
+
+// failed records the attempts of writes, which stay pending for the consumer, so it takes them first with the
+// next flush.
+func (q *streamQueue) failed(ctx context.Context, writes []queuedWrite) {
+	values := make([]interface{}, 0, 2*len(writes))
+	for _, w := range writes {
+		values = append(values, w.id, w.attempts)
+	}
+	if err := q.client.HSet(ctx, q.attempts, values...).Err(); err != nil {
+		log.Printf("Error recording attempts of %d writes in hash: %s: %v", len(writes), q.attempts, err)
+	}
+}
+
+// len returns the length of the stream.
+func (q *streamQueue) len(ctx context.Context) (int, error) {
+	n, err := q.client.XLen(ctx, q.stream).Result()
+	return int(n), err
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestDurableWriteBehind creates a WriteBehind of consumer on the stream "writes", flushed only by the test.
+func newTestDurableWriteBehind(t *testing.T, client Client, consumer string, store Store, opts ...WriteBehindOption) *WriteBehind {
+	t.Helper()
+	wb, err := NewDurableWriteBehind(context.Background(), client, "writes", consumer, store, time.Hour, 0, opts...)
+	if err != nil {
+		t.Fatalf("NewDurableWriteBehind: %v", err)
+	}
+	t.Cleanup(func() { wb.Close(context.Background()) })
+	return wb
+}
+
+func TestDurableWriteBehindQueuesInAStream(t *testing.T) {
+	client, m := newTestClient(t)
+	store := &recordingStore{}
+	wb := newTestDurableWriteBehind(t, client, "a", store)
+	c := NewLRU(context.Background(), client, 10, "lru", WithWriteBehind(wb))
+
+	renamed := User{Id: "1", Name: "Renamed", Age: 30}
+	setUsers(t, c.Set, nil, 1, 2)
+	if err := c.Set(renamed); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if pending := wb.Pending(); pending != 3 {
+		t.Errorf("pending writes: %d, want 3", pending)
+	}
+
+	// The writes of a user taken in the same batch are coalesced.
+	if err := wb.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	assertSaved(t, store, testUser(2), renamed)
+	if pending := wb.Pending(); pending != 0 {
+		t.Errorf("pending writes after Flush: %d, want 0", pending)
+	}
+	if m.Exists("writes:dirty") {
+		t.Error("the dirty hash still counts saved writes")
+	}
+}
+
+func TestDurableWriteBehindSurvivesARestart(t *testing.T) {
+	client, _ := newTestClient(t)
+	failing := &recordingStore{err: errors.New("store is down")}
+	crashed := newTestDurableWriteBehind(t, client, "a", failing)
+	c := NewLRU(context.Background(), client, 10, "lru", WithWriteBehind(crashed))
+	setUsers(t, c.Set, nil, 1, 2)
+
+	// The instance takes the writes and fails to save them, then stops without handing them back.
+	if err := crashed.Flush(context.Background()); err == nil {
+		t.Fatal("Flush to a failing store succeeded")
+	}
+
+	// Another instance cannot take them before ClaimIdle.
+	other := &recordingStore{}
+	if err := newTestDurableWriteBehind(t, client, "b", other).Flush(context.Background()); err != nil {
+		t.Fatalf("Flush of another consumer: %v", err)
+	}
+	assertSaved(t, other)
+
+	// The instance restarting under the same name resumes them.
+	store := &recordingStore{}
+	restarted := newTestDurableWriteBehind(t, client, "a", store)
+	if pending := restarted.Pending(); pending != 2 {
+		t.Errorf("pending writes after the restart: %d, want 2", pending)
+	}
+	if err := restarted.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush after the restart: %v", err)
+	}
+	assertSaved(t, store, testUser(1), testUser(2))
+}
+
+func TestDurableWriteBehindSharesTheStream(t *testing.T) {
+	client, _ := newTestClient(t)
+	storeA, storeB := &recordingStore{}, &recordingStore{}
+	a := newTestDurableWriteBehind(t, client, "a", storeA)
+	b := newTestDurableWriteBehind(t, client, "b", storeB)
+	c := NewLRU(context.Background(), client, 10, "lru", WithWriteBehind(a))
+
+	setUsers(t, c.Set, nil, 1, 2)
+	if err := b.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush of b: %v", err)
+	}
+	if err := a.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush of a: %v", err)
+	}
+	assertSaved(t, storeA)
+	assertSaved(t, storeB, testUser(1), testUser(2))
+}
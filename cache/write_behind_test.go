@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingStore is a Store recording the users it saves, failing with err while it is set.
+type recordingStore struct {
+	mu    sync.Mutex
+	saved []User
+	err   error
+}
+
+func (s *recordingStore) SaveUsers(ctx context.Context, users []User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.saved = append(s.saved, users...)
+	return nil
+}
+
+// users returns the users saved so far, in order.
+func (s *recordingStore) users() []User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]User{}, s.saved...)
+}
+
+// fail makes the store fail with err, or succeed again if err is nil.
+func (s *recordingStore) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// assertSaved fails the test unless the store saved exactly the given users, in order.
+func assertSaved(t *testing.T, store *recordingStore, want ...User) {
+	t.Helper()
+	got := store.users()
+	if len(got) != len(want) {
+		t.Fatalf("saved %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("saved user %d: %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// newTestWriteBehind creates a WriteBehind flushed only by the test, closed at the end of it.
+func newTestWriteBehind(t *testing.T, store Store, opts ...WriteBehindOption) *WriteBehind {
+	t.Helper()
+	wb := NewWriteBehind(store, time.Hour, 0, opts...)
+	t.Cleanup(func() { wb.Close(context.Background()) })
+	return wb
+}
+
+func TestWriteBehindCoalescesQueuedWrites(t *testing.T) {
+	client, _ := newTestClient(t)
+	store := &recordingStore{}
+	wb := newTestWriteBehind(t, store)
+	c := NewLRU(context.Background(), client, 10, "lru", WithWriteBehind(wb))
+
+	renamed := User{Id: "1", Name: "Renamed", Age: 30}
+	setUsers(t, c.Set, nil, 1, 2)
+	if err := c.Set(renamed); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	assertSaved(t, store)
+	if pending, queued, coalesced := wb.Pending(), wb.Queued(), wb.Coalesced(); pending != 2 || queued != 3 || coalesced != 1 {
+		t.Errorf("pending, queued and coalesced writes: %d, %d, %d, want 2, 3, 1", pending, queued, coalesced)
+	}
+
+	if err := wb.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	assertSaved(t, store, renamed, testUser(2))
+	if pending := wb.Pending(); pending != 0 {
+		t.Errorf("pending writes after Flush: %d, want 0", pending)
+	}
+}
+
+func TestWriteBehindSavesEvictedUsers(t *testing.T) {
+	client, _ := newTestClient(t)
+	store := &recordingStore{}
+	clock := testClock()
+	c := NewLRU(context.Background(), client, 2, "lru", WithWriteBehind(newTestWriteBehind(t, store)), WithClock(clock))
+
+	setUsers(t, c.Set, clock, 1, 2, 3)
+	assertCached(t, client, c.Key, 2, 3)
+	assertSaved(t, store, testUser(1))
+
+	if err := c.Delete(c.Key("2")); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	assertSaved(t, store, testUser(1), testUser(2))
+}
+
+func TestWriteBehindSkipsLoadedUsers(t *testing.T) {
+	client, _ := newTestClient(t)
+	wb := newTestWriteBehind(t, &recordingStore{})
+	c := NewLRU(context.Background(), client, 10, "lru", WithWriteBehind(wb), WithLoader(testLoader))
+
+	if _, err := c.GetOrLoad("1"); err != nil {
+		t.Fatalf("GetOrLoad: %v", err)
+	}
+	if pending := wb.Pending(); pending != 0 {
+		t.Errorf("pending writes after a load: %d, want 0", pending)
+	}
+}
+
+func TestWriteBehindKeepsTheBatchesItFailsToSave(t *testing.T) {
+	client, _ := newTestClient(t)
+	store := &recordingStore{}
+	wb := newTestWriteBehind(t, store)
+	c := NewLRU(context.Background(), client, 10, "lru", WithWriteBehind(wb))
+	setUsers(t, c.Set, nil, 1, 2)
+
+	outage := errors.New("store is down")
+	store.fail(outage)
+	if err := wb.Flush(context.Background()); !errors.Is(err, outage) {
+		t.Fatalf("Flush to a failing store: %v, want %v", err, outage)
+	}
+	if pending := wb.Pending(); pending != 2 {
+		t.Errorf("pending writes after a failed Flush: %d, want 2", pending)
+	}
+
+	store.fail(nil)
+	if err := wb.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	assertSaved(t, store, testUser(1), testUser(2))
+}
+
+func TestWriteBehindFlushesFullBatches(t *testing.T) {
+	client, _ := newTestClient(t)
+	store := &recordingStore{}
+	wb := NewWriteBehind(store, time.Hour, 2)
+	defer wb.Close(context.Background())
+	c := NewLRU(context.Background(), client, 10, "lru", WithWriteBehind(wb))
+
+	setUsers(t, c.Set, nil, 1, 2)
+	for deadline := time.Now().Add(time.Second); len(store.users()) < 2; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("a full batch was not flushed")
+		}
+	}
+	assertSaved(t, store, testUser(1), testUser(2))
+}
+
+func TestWriteBehindCloseFlushes(t *testing.T) {
+	client, _ := newTestClient(t)
+	store := &recordingStore{}
+	wb := NewWriteBehind(store, time.Hour, 0)
+	c := NewLRU(context.Background(), client, 10, "lru", WithWriteBehind(wb))
+
+	setUsers(t, c.Set, nil, 1)
+	if err := wb.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	assertSaved(t, store, testUser(1))
+}
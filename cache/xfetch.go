@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const recomputeKeyPrefix = "recompute_time"
+
+// WithEarlyRefresh makes the TTL cache refresh entries probabilistically before they expire, following the XFetch algorithm.
+// On every hit, MakeRequest reloads the entry early with a probability that grows as the expiration nears and as loading
+// it takes longer, so a hot entry is usually refreshed by a single caller instead of by every caller at once when it expires.
+// A beta of 1 is the recommended default; larger values refresh earlier. The other caches do not expire entries and ignore this option.
+func WithEarlyRefresh(beta float64) Option {
+	return func(o *options) {
+		o.beta = beta
+	}
+}
+
+// loadAndSet loads a user and caches it, recording how long the load took for early refreshes.
+// The user is returned even if it could not be cached.
+func (c *TTLCache) loadAndSet(id string) (User, error) {
+	start := time.Now()
+	user, err := c.load(c.ctx, id)
+	if err != nil {
+		log.Printf("Failed to load user ID: %s: %v", id, err)
+		return User{}, err
+	}
+	recomputeTime := time.Since(start)
+
+	if err := c.Set(user); err != nil {
+		log.Printf("Failed to write user ID: %s to cache: %v", id, err)
+		return user, nil
+	}
+
+	if c.beta > 0 {
+		if err := c.client.Set(c.ctx, c.generateKey(recomputeKeyPrefix, id), int64(recomputeTime), c.hardExpiration()).Err(); err != nil {
+			log.Printf("Failed to record recompute time of user ID: %s: %v", id, err)
+		}
+	}
+	return user, nil
+}
+
+// refreshEarly decides whether a hit on a user should reload it ahead of its expiration.
+// XFetch refreshes when recompute time * beta * -ln(rand) reaches the time left before the entry expires.
+// Entries whose recompute time is unknown are never refreshed early.
+func (c *TTLCache) refreshEarly(id string) bool {
+	if c.beta <= 0 {
+		return false
+	}
+
+	var ttl *redis.DurationCmd
+	var recomputeTime, softExpiry *redis.StringCmd
+	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		ttl = pipe.PTTL(c.ctx, c.generateKey(userPrefix, id))
+		recomputeTime = pipe.Get(c.ctx, c.generateKey(recomputeKeyPrefix, id))
+		softExpiry = pipe.HGet(c.ctx, c.generateKey(softExpiryKeyPrefix, id), expirySoftField)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		log.Printf("Error getting expiration of user ID: %s: %v", id, err)
+		return false
+	}
+
+	delta, err := recomputeTime.Int64()
+	if err != nil || ttl.Val() < 0 {
+		return false
+	}
+
+	// The entry expires for the caller at its soft expiry, if it has one, rather than when Redis drops it.
+	// Past it, a stale entry is already being revalidated in the background.
+	remaining := ttl.Val()
+	if soft, err := softExpiry.Int64(); err == nil {
+		remaining = time.Unix(0, soft).Sub(c.now())
+	}
+	if remaining <= 0 {
+		return false
+	}
+	return float64(delta)*c.beta*-math.Log(c.randFloat64()) >= float64(remaining)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/workload"
+	"github.com/redis/go-redis/v9"
+)
+
+const connectionString string = "redis://@localhost:6379/0"
+
+// warmupInterval is the interval at which the hit ratio is compared during the warm-up.
+const warmupInterval = 500 * time.Millisecond
+
+// The bench command drives one cache from concurrent goroutines for a fixed duration, optionally at a
+// fixed request rate, and reports its throughput and latency percentiles, so regressions are measurable.
+// An optional warm-up runs the same workload first, until the hit ratio is steady or a duration elapses,
+// so that the results reflect a warm cache rather than cold-start misses.
+func main() {
+	url := flag.String("url", connectionString, "Redis connection URL")
+	policy := flag.String("policy", "lru", "cache policy: fifo, lru, lfu, approx-lru or ttl")
+	capacity := flag.Int("capacity", 1000, "capacity of the cache")
+	expiration := flag.Duration("ttl", time.Minute, "expiration of the entries of the ttl cache")
+	goroutines := flag.Int("goroutines", 16, "number of concurrent goroutines")
+	rate := flag.Float64("rate", 0, "total requests per second, or 0 for as fast as possible")
+	distribution := flag.String("distribution", "zipf", "key distribution: uniform, zipf, hotspot or sequential")
+	keys := flag.Int("keys", 10000, "size of the key space")
+	duration := flag.Duration("duration", 10*time.Second, "duration of the benchmark")
+	seed := flag.Int64("seed", 0, "seed of the workload and of the random decisions of the cache, or 0 for a random seed")
+	export := flag.String("export", "", "path of a .json or .csv file to export the result to")
+	warmup := flag.Duration("warmup", 0, "longest warm-up before the benchmark, or 0 for none")
+	tolerance := flag.Float64("warmup-tolerance", 0.01, "change of the hit ratio between intervals that ends the warm-up early, or 0 to warm up for the whole duration")
+	flag.Parse()
+
+	dist, err := workload.ParseDistribution(*distribution)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *goroutines <= 0 {
+		fmt.Fprintln(os.Stderr, "number of goroutines must be positive")
+		os.Exit(2)
+	}
+	if *export != "" {
+		if err := workload.CheckExportPath(*export); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if *seed == 0 {
+		*seed = rand.Int63()
+	}
+
+	opt, err := cache.ParseConnectionURL(*url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opt.PoolSize = max(opt.PoolSize, *goroutines)
+	client := redis.NewUniversalClient(opt)
+	ctx := context.Background()
+	prefix := "bench_" + *policy
+
+	// Start from an empty namespace and silence the per-operation logs of the cache.
+	if _, err := cache.FlushNamespace(ctx, client, prefix); err != nil {
+		log.Fatal(err)
+	}
+	log.SetOutput(io.Discard)
+
+	c, err := workload.NewCache(ctx, client, *policy, *capacity, *expiration, prefix, cache.WithSeed(*seed))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// Each goroutine paces its own share of the rate.
+	var interval time.Duration
+	if *rate > 0 {
+		interval = time.Duration(float64(time.Second) * float64(*goroutines) / *rate)
+	}
+
+	gens := make([]*workload.Generator, *goroutines)
+	for i := range gens {
+		// Each goroutine gets its own stream of keys, derived from the seed.
+		gens[i], err = workload.New(workload.Config{Distribution: dist, Keys: *keys, Seed: *seed + int64(i)})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	var warmupElapsed time.Duration
+	var steady bool
+	if *warmup > 0 {
+		warmupElapsed, steady = warmUp(c, gens, interval, *warmup, *tolerance)
+	}
+
+	stop := make(chan struct{})
+	time.AfterFunc(*duration, func() { close(stop) })
+	start := time.Now()
+	recorders := drive(c, gens, interval, stop)
+	elapsed := time.Since(start)
+
+	total := &workload.Recorder{}
+	for _, r := range recorders {
+		total.Merge(r)
+	}
+	result := total.Result(elapsed)
+	stats, _ := c.Stats()
+
+	fmt.Printf("policy:       %s\n", *policy)
+	fmt.Printf("distribution: %s over %d keys\n", dist, *keys)
+	fmt.Printf("goroutines:   %d\n", *goroutines)
+	fmt.Printf("seed:         %d\n", *seed)
+	if *warmup > 0 {
+		fmt.Printf("warm-up:      %v (steady: %t)\n", warmupElapsed.Round(time.Millisecond), steady)
+	}
+	fmt.Printf("requests:     %d in %v\n", result.Requests, result.Elapsed.Round(time.Millisecond))
+	fmt.Printf("throughput:   %.0f/s\n", result.Throughput())
+	fmt.Printf("hit ratio:    %.4f\n", result.HitRatio())
+	fmt.Printf("errors:       %d\n", result.Errors)
+	fmt.Printf("latency:      p50 %v, p95 %v, p99 %v, p99.9 %v\n", result.P50, result.P95, result.P99, result.P999)
+	fmt.Printf("get latency:  p50 %v, p99 %v\n", stats.GetLatency.P50, stats.GetLatency.P99)
+	fmt.Printf("set latency:  p50 %v, p99 %v\n", stats.SetLatency.P50, stats.SetLatency.P99)
+
+	if *export != "" {
+		run := workload.Run{
+			Time:       start,
+			Policy:     *policy,
+			Capacity:   *capacity,
+			Workload:   string(dist),
+			Keys:       *keys,
+			Seed:       *seed,
+			Goroutines: *goroutines,
+			Rate:       *rate,
+			Warmup:     warmupElapsed,
+			Result:     result,
+			Evictions:  stats.Evictions,
+		}
+		if err := workload.ExportFile(*export, []workload.Run{run}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// drive accesses c from a goroutine per generator until stop is closed, each pacing its accesses by interval
+// if it is positive, and returns their recorders.
+func drive(c workload.Cache, gens []*workload.Generator, interval time.Duration, stop <-chan struct{}) []*workload.Recorder {
+	recorders := make([]*workload.Recorder, len(gens))
+	var wg sync.WaitGroup
+	for i, gen := range gens {
+		recorders[i] = &workload.Recorder{}
+		wg.Add(1)
+		go func(r *workload.Recorder, gen *workload.Generator) {
+			defer wg.Done()
+			next := time.Now()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if interval > 0 {
+					time.Sleep(time.Until(next))
+					next = next.Add(interval)
+				}
+				key, _ := gen.Next()
+				r.Access(c, key)
+			}
+		}(recorders[i], gen)
+	}
+	wg.Wait()
+	return recorders
+}
+
+// warmUp drives c until its hit ratio changes by at most tolerance between intervals or maxDuration elapses,
+// then resets the stats of the cache so the warm-up is left out of them. It returns how long it took
+// and whether the cache reached a steady state.
+func warmUp(c workload.Cache, gens []*workload.Generator, interval, maxDuration time.Duration, tolerance float64) (time.Duration, bool) {
+	start := time.Now()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		drive(c, gens, interval, stop)
+		close(done)
+	}()
+
+	state := &workload.SteadyState{Tolerance: tolerance}
+	ticker := time.NewTicker(warmupInterval)
+	defer ticker.Stop()
+	deadline := time.After(maxDuration)
+	steady, timedOut := false, false
+	for !steady && !timedOut {
+		select {
+		case <-deadline:
+			timedOut = true
+		case <-ticker.C:
+			stats, err := c.Stats()
+			steady = err == nil && state.Observe(stats)
+		}
+	}
+	close(stop)
+	<-done
+	resetStats(c)
+	return time.Since(start), true
+}
+
+// resetStats resets the counters of c, if it has any.
+func resetStats(c workload.Cache) {
+	if r, ok := c.(interface{ ResetStats() }); ok {
+		r.ResetStats()
+	}
+}
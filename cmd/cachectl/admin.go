@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/workload"
+	"github.com/redis/go-redis/v9"
+)
+
+// target holds the flags selecting the cache a command operates on.
+type target struct {
+	url     *string
+	policy  *string
+	prefix  *string
+	hashTag *bool
+}
+
+// targetFlags defines the flags selecting a cache on fs.
+func targetFlags(fs *flag.FlagSet) *target {
+	return &target{
+		url:     fs.String("url", defaultConnectionString, "Redis connection URL"),
+		policy:  fs.String("policy", "lru", "cache policy: fifo, lru, lfu or approx-lru"),
+		prefix:  fs.String("prefix", "", "key prefix of the cache (defaults to the policy name)"),
+		hashTag: fs.Bool("hash-tag", false, "the cache was created with cache.WithHashTag"),
+	}
+}
+
+// connect creates a client for the URL of the flags, and the options the cache was created with.
+func (t *target) connect() (redis.UniversalClient, []cache.Option) {
+	if *t.prefix == "" {
+		*t.prefix = *t.policy
+	}
+	opt, err := cache.ParseConnectionURL(*t.url)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var opts []cache.Option
+	if *t.hashTag {
+		opts = append(opts, cache.WithHashTag())
+	}
+	return redis.NewUniversalClient(opt), opts
+}
+
+// open creates the cache selected by the flags.
+func (t *target) open(ctx context.Context) indexedCache {
+	client, opts := t.connect()
+	c, err := newIndexedCache(ctx, client, *t.policy, *t.prefix, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return c
+}
+
+// namespace returns the prefix of every key of the cache selected by the flags.
+func (t *target) namespace() string {
+	if *t.hashTag {
+		return "{" + *t.prefix + "}"
+	}
+	return *t.prefix
+}
+
+// entry is an index member as printed by the entries command.
+type entry struct {
+	Key   string  `json:"key"`
+	Score float64 `json:"score"`
+}
+
+// runEntries lists the entries of a cache in eviction order, the next victim first, with their scores.
+func runEntries(args []string) {
+	fs := flag.NewFlagSet("entries", flag.ExitOnError)
+	t := targetFlags(fs)
+	limit := fs.Int("limit", 0, "maximum number of entries to list (0 lists them all)")
+	fs.Parse(args)
+
+	client, opts := t.connect()
+	entries, err := cache.IndexEntries(context.Background(), client, *t.prefix, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *limit > 0 && len(entries) > *limit {
+		entries = entries[:*limit]
+	}
+
+	out := make([]entry, len(entries))
+	for i, e := range entries {
+		out[i] = entry{Key: e.Key, Score: e.Score}
+	}
+	printJSON(out)
+}
+
+// runEvict removes the users with the given IDs from a cache.
+func runEvict(args []string) {
+	fs := flag.NewFlagSet("evict", flag.ExitOnError)
+	t := targetFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: cachectl evict [flags] <id>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	c := t.open(context.Background())
+	evicted := make([]string, 0, fs.NArg())
+	for _, id := range fs.Args() {
+		key := c.Key(id)
+		if err := c.Delete(key); err != nil {
+			log.Fatalf("Error evicting key: %s: %v", key, err)
+		}
+		evicted = append(evicted, key)
+	}
+	printJSON(map[string][]string{"evicted": evicted})
+}
+
+// runPurge deletes every key of a cache: its values, its index and its metadata. Without -yes,
+// it only reports the number of keys it would delete.
+func runPurge(args []string) {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	t := targetFlags(fs)
+	yes := fs.Bool("yes", false, "delete the keys instead of only counting them")
+	fs.Parse(args)
+
+	client, _ := t.connect()
+	ctx := context.Background()
+	pattern := t.namespace() + ":*"
+
+	var keys []string
+	iter := client.Scan(ctx, 0, pattern, 0).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	if !*yes {
+		fmt.Fprintf(os.Stderr, "%d keys match %s. Pass -yes to delete them.\n", len(keys), pattern)
+		os.Exit(1)
+	}
+	// Delete the keys in batches, so a large cache does not block the server in a single DEL.
+	for start := 0; start < len(keys); start += 500 {
+		end := min(start+500, len(keys))
+		if err := client.Del(ctx, keys[start:end]...).Err(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	printJSON(map[string]int{"deleted": len(keys)})
+}
+
+// cacheStats is the output of the stats command.
+type cacheStats struct {
+	Prefix      string `json:"prefix"`
+	IndexType   string `json:"index_type"`
+	Items       int    `json:"items"`
+	Bytes       int64  `json:"bytes,omitempty"`
+	Keys        int    `json:"keys"`
+	MemoryBytes int64  `json:"memory_bytes"`
+}
+
+// runStats shows the number of entries of a cache, the bytes it accounts for if it has a byte capacity,
+// and the number of keys and memory it takes in Redis.
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	t := targetFlags(fs)
+	fs.Parse(args)
+
+	ctx := context.Background()
+	client, opts := t.connect()
+	c, err := newIndexedCache(ctx, client, *t.policy, *t.prefix, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	indexType, err := cache.IndexType(ctx, client, *t.prefix, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		log.Fatal(err)
+	}
+	footprint, err := workload.MeasureFootprint(ctx, client, t.namespace())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	printJSON(cacheStats{
+		Prefix:      *t.prefix,
+		IndexType:   indexType,
+		Items:       stats.Items,
+		Bytes:       stats.Bytes,
+		Keys:        footprint.Keys,
+		MemoryBytes: footprint.Bytes,
+	})
+}
+
+// runResize evicts entries in the order of the policy until a cache holds no more than the new capacity.
+// The capacity itself lives in the applications, which must be restarted with it.
+func runResize(args []string) {
+	fs := flag.NewFlagSet("resize", flag.ExitOnError)
+	t := targetFlags(fs)
+	capacity := fs.Int("capacity", 0, "new capacity of the cache")
+	fs.Parse(args)
+
+	if *capacity <= 0 {
+		fmt.Fprintln(os.Stderr, "resize needs a positive -capacity")
+		os.Exit(2)
+	}
+
+	c := t.open(context.Background())
+	before := c.CacheSize()
+	evicted, err := c.SetCapacity(*capacity)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printJSON(map[string]int{"before": before, "after": before - evicted, "evicted": evicted})
+}
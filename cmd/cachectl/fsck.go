@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// fsckResult is the machine-readable result of checking the invariants of one cache prefix.
+type fsckResult struct {
+	Prefix         string       `json:"prefix"`
+	IndexType      string       `json:"index_type"`
+	Policies       []string     `json:"policies"`
+	Size           int          `json:"size"`
+	Capacity       int          `json:"capacity,omitempty"`
+	WithinCapacity bool         `json:"within_capacity"`
+	Report         cache.Report `json:"report"`
+	OK             bool         `json:"ok"`
+	Error          string       `json:"error,omitempty"`
+}
+
+// policiesByIndexType maps the Redis type of a cache index to the policies using it.
+var policiesByIndexType = map[string][]string{
+	"list": {"fifo"},
+	"zset": {"lru", "lfu"},
+	"hash": {"approx-lru"},
+}
+
+// runFsck checks, for every given prefix, that the index has no duplicates, that every index member
+// has a value key and every value key an index member, and that the size is within capacity.
+// It prints one result per prefix and exits with status 1 if any invariant is violated.
+func runFsck(args []string) {
+	fs := flag.NewFlagSet("fsck", flag.ExitOnError)
+	url := fs.String("url", defaultConnectionString, "Redis connection URL")
+	capacity := fs.Int("capacity", 0, "expected capacity of the caches (0 skips the capacity check)")
+	hashTag := fs.Bool("hash-tag", false, "the caches were created with cache.WithHashTag")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: cachectl fsck [flags] <prefix>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	opt, err := cache.ParseConnectionURL(*url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client := redis.NewUniversalClient(opt)
+	ctx := context.Background()
+
+	var opts []cache.Option
+	if *hashTag {
+		opts = append(opts, cache.WithHashTag())
+	}
+
+	results := make([]fsckResult, 0, fs.NArg())
+	ok := true
+	for _, prefix := range fs.Args() {
+		result := fsck(ctx, client, prefix, *capacity, opts...)
+		ok = ok && result.OK
+		results = append(results, result)
+	}
+
+	printJSON(results)
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// fsck checks the invariants of the cache with the given prefix, detecting its policy from the type of its index.
+func fsck(ctx context.Context, client redis.UniversalClient, prefix string, capacity int, opts ...cache.Option) fsckResult {
+	result := fsckResult{
+		Prefix:   prefix,
+		Capacity: capacity,
+	}
+
+	indexType, err := cache.IndexType(ctx, client, prefix, opts...)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.IndexType = indexType
+
+	if indexType == "none" {
+		result.WithinCapacity = true
+		result.OK = true
+		return result
+	}
+
+	policies, found := policiesByIndexType[indexType]
+	if !found {
+		result.Error = fmt.Sprintf("unexpected index type: %s", indexType)
+		return result
+	}
+	result.Policies = policies
+
+	// The policies sharing an index type also share its invariants, so checking with the first one is enough.
+	c, err := newIndexedCache(ctx, client, policies[0], prefix, opts...)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Report, err = c.Verify()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Size = c.CacheSize()
+	result.WithinCapacity = capacity <= 0 || result.Size <= capacity
+	result.OK = result.WithinCapacity && result.Report.Consistent()
+	return result
+}
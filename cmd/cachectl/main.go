@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultConnectionString string = "redis://@localhost:6379/0"
+
+// indexedCache is implemented by every cache that keeps an index next to its value keys.
+type indexedCache interface {
+	Verify() (cache.Report, error)
+	Repair() (cache.Report, error)
+	CacheSize() int
+	Key(id string) string
+	Delete(key string) error
+	SetCapacity(capacity int) (int, error)
+	Stats() (cache.Stats, error)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: cachectl <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  verify   report inconsistencies between a cache's index and its value keys")
+	fmt.Fprintln(os.Stderr, "  repair   fix the inconsistencies reported by verify")
+	fmt.Fprintln(os.Stderr, "  fsck     check the invariants of every algorithm for the given prefixes")
+	fmt.Fprintln(os.Stderr, "  entries  list the entries of a cache in eviction order with their scores")
+	fmt.Fprintln(os.Stderr, "  evict    remove users from a cache")
+	fmt.Fprintln(os.Stderr, "  purge    delete every key of a cache")
+	fmt.Fprintln(os.Stderr, "  stats    show the size and memory footprint of a cache")
+	fmt.Fprintln(os.Stderr, "  resize   evict entries until a cache fits a new capacity")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	command := os.Args[1]
+	switch command {
+	case "verify", "repair":
+		runCheck(command, os.Args[2:])
+	case "fsck":
+		runFsck(os.Args[2:])
+	case "entries":
+		runEntries(os.Args[2:])
+	case "evict":
+		runEvict(os.Args[2:])
+	case "purge":
+		runPurge(os.Args[2:])
+	case "stats":
+		runStats(os.Args[2:])
+	case "resize":
+		runResize(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+// runCheck runs the verify or repair command for a single cache.
+func runCheck(command string, args []string) {
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	url := fs.String("url", defaultConnectionString, "Redis connection URL")
+	policy := fs.String("policy", "lru", "cache policy: fifo, lru, lfu or approx-lru")
+	prefix := fs.String("prefix", "", "key prefix of the cache (defaults to the policy name)")
+	hashTag := fs.Bool("hash-tag", false, "the cache was created with cache.WithHashTag")
+	fs.Parse(args)
+
+	if *prefix == "" {
+		*prefix = *policy
+	}
+
+	opt, err := cache.ParseConnectionURL(*url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client := redis.NewUniversalClient(opt)
+	ctx := context.Background()
+
+	var opts []cache.Option
+	if *hashTag {
+		opts = append(opts, cache.WithHashTag())
+	}
+
+	c, err := newIndexedCache(ctx, client, *policy, *prefix, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var report cache.Report
+	if command == "verify" {
+		report, err = c.Verify()
+	} else {
+		report, err = c.Repair()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	printJSON(report)
+
+	if command == "verify" && !report.Consistent() {
+		os.Exit(1)
+	}
+}
+
+// newIndexedCache creates the cache for the given policy. Capacity is irrelevant for the commands, which never admit entries.
+func newIndexedCache(ctx context.Context, client redis.UniversalClient, policy, prefix string, opts ...cache.Option) (indexedCache, error) {
+	switch policy {
+	case "fifo":
+		c := cache.NewFIFO(ctx, client, 0, prefix, opts...)
+		return &c, nil
+	case "lru":
+		c := cache.NewLRU(ctx, client, 0, prefix, opts...)
+		return &c, nil
+	case "lfu":
+		c := cache.NewLFU(ctx, client, 0, prefix, opts...)
+		return &c, nil
+	case "approx-lru":
+		c := cache.NewApproxLRU(ctx, client, 0, 0, prefix, opts...)
+		return &c, nil
+	default:
+		return nil, fmt.Errorf("unknown policy: %s", policy)
+	}
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/sim"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/workload"
+	"github.com/redis/go-redis/v9"
+)
+
+const connectionString string = "redis://@localhost:6379/0"
+
+// topCommands is the number of commands listed per policy in the breakdown of the commands.
+const topCommands = 6
+
+// run is the workload of one policy and its outcome.
+type run struct {
+	policy  string
+	cache   workload.Cache
+	client  redis.UniversalClient
+	prefix  string
+	counter *workload.CommandCounter
+	result  workload.Result
+	// serverCommands are the calls of each command the server counted during the run, with -commandstats.
+	serverCommands map[string]int64
+}
+
+// The compare command runs the same workload against several policies concurrently, each cache under
+// its own prefix and with its own Redis client, or simulated in memory, and prints their hit ratio, evictions,
+// Redis command counts and memory footprint. With -commandstats, the policies run one after the other, and the
+// commands are counted by the server with INFO commandstats, including those run by the Lua scripts.
+func main() {
+	url := flag.String("url", connectionString, "Redis connection URL")
+	policies := flag.String("policies", strings.Join(workload.Policies, ","), "comma separated policies to compare")
+	capacity := flag.Int("capacity", 100, "capacity of the caches")
+	expiration := flag.Duration("ttl", time.Minute, "expiration of the entries of the ttl cache")
+	distribution := flag.String("distribution", "zipf", "key distribution: uniform, zipf, hotspot or sequential")
+	keys := flag.Int("keys", 1000, "size of the key space")
+	requests := flag.Int("requests", 10000, "number of accesses")
+	seed := flag.Int64("seed", 1, "seed of the workload and of the random decisions of the caches")
+	tracePath := flag.String("trace", "", "path of an access trace to replay instead of generating a workload")
+	format := flag.String("format", "csv", "trace format: csv, arc or lirs")
+	simulate := flag.Bool("sim", false, "simulate the caches in memory instead of using Redis")
+	export := flag.String("export", "", "path of a .json or .csv file to export the results to")
+	commandStats := flag.Bool("commandstats", false, "run the policies one after the other and count their commands with INFO commandstats")
+	flag.Parse()
+
+	if *export != "" {
+		if err := workload.CheckExportPath(*export); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	accesses, err := loadAccesses(*tracePath, *format, *distribution, *keys, *requests, *seed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	opt, err := cache.ParseConnectionURL(*url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+
+	var runs []*run
+	for _, policy := range strings.Split(*policies, ",") {
+		if *simulate {
+			c, err := sim.New(policy, *capacity, *expiration, *seed)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			runs = append(runs, &run{policy: policy, cache: c, counter: &workload.CommandCounter{}})
+			continue
+		}
+
+		client := redis.NewUniversalClient(opt)
+		prefix := "compare_" + policy
+		if _, err := cache.FlushNamespace(ctx, client, prefix); err != nil {
+			log.Fatal(err)
+		}
+
+		c, err := workload.NewCache(ctx, client, policy, *capacity, *expiration, prefix, cache.WithSeed(*seed))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		// The hook is added once the cache loaded its scripts, so only the commands of the workload are counted.
+		counter := &workload.CommandCounter{}
+		client.AddHook(counter)
+		runs = append(runs, &run{policy: policy, cache: c, client: client, prefix: prefix, counter: counter})
+	}
+
+	// Silence the per-operation logs of the caches.
+	log.SetOutput(io.Discard)
+
+	start := time.Now()
+	if *commandStats && !*simulate {
+		// The server counts the commands of all its clients, so the policies must not run at the same time.
+		admin := redis.NewUniversalClient(opt)
+		for _, r := range runs {
+			before, err := workload.CommandStats(ctx, admin)
+			if err != nil {
+				log.SetOutput(os.Stderr)
+				log.Fatalf("Error getting command stats: %v", err)
+			}
+			r.result = workload.Replay(r.cache, accesses)
+			after, err := workload.CommandStats(ctx, admin)
+			if err != nil {
+				log.SetOutput(os.Stderr)
+				log.Fatalf("Error getting command stats: %v", err)
+			}
+			r.serverCommands = workload.SubtractCommandStats(after, before)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for _, r := range runs {
+			wg.Add(1)
+			go func(r *run) {
+				defer wg.Done()
+				r.result = workload.Replay(r.cache, accesses)
+			}(r)
+		}
+		wg.Wait()
+	}
+
+	// The runs to export, with the metadata of the workload. The size of the key space and the seed
+	// only apply to a generated workload.
+	exported := make([]workload.Run, 0, len(runs))
+	meta := workload.Run{Time: start, Capacity: *capacity, Workload: *distribution, Keys: *keys, Seed: *seed, Goroutines: 1}
+	if *tracePath != "" {
+		meta.Workload, meta.Keys, meta.Seed = *tracePath, 0, 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "policy\thit ratio\thits\tmisses\tevictions\tcommands\tcommands/access\tkeys\tmemory\terrors\tp99")
+	breakdowns := make([]map[string]int64, len(runs))
+	for i, r := range runs {
+		// Count the commands before measuring the memory and getting the stats, which send commands of their own.
+		commands, breakdown := r.counter.Commands(), r.counter.Counts()
+		if r.serverCommands != nil {
+			commands, breakdown = 0, r.serverCommands
+			for _, calls := range breakdown {
+				commands += calls
+			}
+		}
+		breakdowns[i] = breakdown
+
+		var footprint workload.Footprint
+		if r.client != nil {
+			if footprint, err = workload.MeasureFootprint(ctx, r.client, r.prefix); err != nil {
+				fmt.Fprintf(os.Stderr, "Error measuring memory of policy: %s: %v\n", r.policy, err)
+			}
+		}
+		stats, _ := r.cache.Stats()
+		fmt.Fprintf(w, "%s\t%.4f\t%d\t%d\t%d\t%d\t%.1f\t%d\t%s\t%d\t%v\n",
+			r.policy, r.result.HitRatio(), r.result.Hits, r.result.Misses, stats.Evictions,
+			commands, float64(commands)/float64(max(r.result.Requests, 1)), footprint.Keys, formatBytes(footprint.Bytes),
+			r.result.Errors, r.result.P99)
+
+		run := meta
+		run.Policy, run.Result, run.Evictions, run.Commands = r.policy, r.result, stats.Evictions, commands
+		run.MemoryBytes = footprint.Bytes
+		exported = append(exported, run)
+	}
+	w.Flush()
+
+	if !*simulate {
+		if *commandStats {
+			fmt.Println("\ncommands counted by the server, including those of scripts:")
+		} else {
+			fmt.Println("\ncommands sent by the clients, a script counting as one:")
+		}
+		for i, r := range runs {
+			fmt.Printf("  %-10s  %s\n", r.policy, formatBreakdown(breakdowns[i]))
+		}
+	}
+
+	if *export != "" {
+		if err := workload.ExportFile(*export, exported); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// formatBreakdown lists the most called commands of a breakdown, the most called first.
+func formatBreakdown(breakdown map[string]int64) string {
+	names := slices.Collect(maps.Keys(breakdown))
+	slices.SortFunc(names, func(a, b string) int {
+		if c := cmp.Compare(breakdown[b], breakdown[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+
+	parts := make([]string, 0, topCommands+1)
+	for i, name := range names {
+		if i == topCommands {
+			parts = append(parts, fmt.Sprintf("and %d more", len(names)-topCommands))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s %d", name, breakdown[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatBytes formats a number of bytes with a binary unit.
+func formatBytes(bytes int64) string {
+	switch {
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(bytes)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
+
+// loadAccesses reads the keys of the trace at tracePath, or generates a workload if it is empty.
+func loadAccesses(tracePath, format, distribution string, keys, requests int, seed int64) ([]string, error) {
+	if tracePath == "" {
+		dist, err := workload.ParseDistribution(distribution)
+		if err != nil {
+			return nil, err
+		}
+		gen, err := workload.New(workload.Config{Distribution: dist, Keys: keys, Requests: requests, Seed: seed})
+		if err != nil {
+			return nil, err
+		}
+		return gen.All(), nil
+	}
+
+	traceFormat, err := workload.ParseTraceFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(tracePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return workload.ReadTrace(f, traceFormat)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/workload"
+	"github.com/redis/go-redis/v9"
+)
+
+const connectionString string = "redis://@localhost:6379/0"
+
+// ANSI escape sequences used to color the output.
+const (
+	bold   = "\033[1m"
+	dim    = "\033[2m"
+	green  = "\033[32m"
+	red    = "\033[31m"
+	yellow = "\033[33m"
+	reset  = "\033[0m"
+)
+
+// side is one of the two caches of the demo and its outcome so far.
+type side struct {
+	policy string
+	prefix string
+	cache  workload.Cache
+
+	// hit is the outcome of the current access, and evicted the keys it evicted.
+	hit     bool
+	err     error
+	mu      sync.Mutex
+	evicted []string
+
+	hits   int
+	misses int
+}
+
+// The demo command runs the same access stream through two policies at once and prints, after every access,
+// whether each of them hit or missed, what they evicted and their contents in eviction order, with the keys
+// only one of them holds highlighted, to make the differences between the policies tangible.
+func main() {
+	url := flag.String("url", connectionString, "Redis connection URL")
+	left := flag.String("left", "lru", "policy of the left cache: fifo, lru, lfu or approx-lru")
+	right := flag.String("right", "lfu", "policy of the right cache: fifo, lru, lfu or approx-lru")
+	capacity := flag.Int("capacity", 5, "capacity of both caches")
+	distribution := flag.String("distribution", "zipf", "key distribution: uniform, zipf, hotspot or sequential")
+	keys := flag.Int("keys", 15, "size of the key space")
+	requests := flag.Int("requests", 50, "number of accesses, or 0 to run until interrupted")
+	seed := flag.Int64("seed", 1, "seed of the workload and of the random decisions of the caches")
+	speed := flag.Duration("speed", 300*time.Millisecond, "delay between accesses")
+	flag.Parse()
+
+	for _, policy := range []string{*left, *right} {
+		if policy == "ttl" || !slices.Contains(workload.Policies, policy) {
+			fmt.Fprintf(os.Stderr, "unsupported policy: %s: use fifo, lru, lfu or approx-lru\n", policy)
+			os.Exit(2)
+		}
+	}
+	dist, err := workload.ParseDistribution(*distribution)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	gen, err := workload.New(workload.Config{Distribution: dist, Keys: *keys, Requests: *requests, Seed: *seed})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	opt, err := cache.ParseConnectionURL(*url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client := redis.NewUniversalClient(opt)
+	ctx := context.Background()
+
+	sides := []*side{{policy: *left, prefix: "demo_left"}, {policy: *right, prefix: "demo_right"}}
+	for _, s := range sides {
+		// Start from an empty namespace.
+		if _, err := cache.FlushNamespace(ctx, client, s.prefix); err != nil {
+			log.Fatal(err)
+		}
+
+		s.cache, err = workload.NewCache(ctx, client, s.policy, *capacity, 0, s.prefix,
+			cache.WithSeed(*seed),
+			cache.WithOnEvict(func(key string, _ cache.User, _ string) {
+				s.mu.Lock()
+				s.evicted = append(s.evicted, s.id(key))
+				s.mu.Unlock()
+			}),
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	// Silence the per-operation logs of the caches, which would interleave with the output.
+	log.SetOutput(io.Discard)
+
+	width := max(len(*left), len(*right))
+	fmt.Printf("%s%s vs %s%s, capacity %d, %s over %d keys\n\n", bold, *left, *right, reset, *capacity, dist, *keys)
+
+	steps, differences := 0, 0
+	for key, ok := gen.Next(); ok; key, ok = gen.Next() {
+		steps++
+
+		// Both caches serve the access at the same time.
+		var wg sync.WaitGroup
+		for _, s := range sides {
+			wg.Add(1)
+			go func(s *side) {
+				defer wg.Done()
+				s.access(key)
+			}(s)
+		}
+		wg.Wait()
+
+		contents := make([][]string, len(sides))
+		for i, s := range sides {
+			contents[i], err = s.contents(ctx, client)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		if sides[0].hit != sides[1].hit {
+			differences++
+		}
+
+		fmt.Printf("%s%3d%s  key %s%-4s%s\n", dim, steps, reset, bold, key, reset)
+		for i, s := range sides {
+			fmt.Printf("     %-*s  %s  %s\n", width, s.policy, s.outcome(), renderContents(contents[i], contents[1-i]))
+		}
+		time.Sleep(*speed)
+	}
+
+	fmt.Printf("\n%ssummary%s  the policies decided differently on %d of %d accesses\n", bold, reset, differences, steps)
+	for _, s := range sides {
+		fmt.Printf("     %-*s  hit ratio %.2f (%d hits, %d misses)\n", width, s.policy,
+			float64(s.hits)/float64(max(s.hits+s.misses, 1)), s.hits, s.misses)
+	}
+}
+
+// access reads key from the cache of the side and, on a miss, writes it, recording the outcome and evictions.
+func (s *side) access(key string) {
+	s.mu.Lock()
+	s.evicted = s.evicted[:0]
+	s.mu.Unlock()
+
+	_, err := s.cache.Get(key)
+	s.hit, s.err = err == nil, nil
+	switch {
+	case err == nil:
+		s.hits++
+	case errors.Is(err, redis.Nil):
+		s.misses++
+		s.err = s.cache.Set(cache.User{Id: key})
+	default:
+		s.err = err
+	}
+}
+
+// outcome returns the colored outcome of the current access, with the keys it evicted.
+func (s *side) outcome() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.err != nil:
+		return red + "ERROR" + reset + " " + s.err.Error()
+	case s.hit:
+		return green + "hit " + reset + "         "
+	case len(s.evicted) > 0:
+		return fmt.Sprintf("%smiss%s %s%-8s%s", red, reset, yellow, "-"+strings.Join(s.evicted, ","), reset)
+	default:
+		return red + "miss" + reset + "         "
+	}
+}
+
+// contents returns the user ids in the cache of the side in eviction order, the next victim first.
+func (s *side) contents(ctx context.Context, client redis.UniversalClient) ([]string, error) {
+	entries, err := cache.IndexEntries(ctx, client, s.prefix)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = s.id(entry.Key)
+	}
+	return ids, nil
+}
+
+// id returns the user id of a value key.
+func (s *side) id(key string) string {
+	return strings.TrimPrefix(key, s.prefix+":user:")
+}
+
+// renderContents formats the ids of one cache, highlighting those the other cache does not hold.
+func renderContents(ids, other []string) string {
+	rendered := make([]string, len(ids))
+	for i, id := range ids {
+		if slices.Contains(other, id) {
+			rendered[i] = id
+		} else {
+			rendered[i] = yellow + bold + id + reset
+		}
+	}
+	return "[" + strings.Join(rendered, " ") + "]"
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/metrics"
+)
+
+// The grafana command writes the Grafana dashboard of the series exported by the metrics package, to be imported
+// in Grafana or provisioned from a file. The dashboard in metrics/dashboards is generated with it:
+//
+//	go run ./cmd/grafana -o metrics/dashboards/caches.json
+func main() {
+	title := flag.String("title", "Caches", "title of the dashboard")
+	output := flag.String("o", "", "file to write the dashboard to, instead of the standard output")
+	flag.Parse()
+
+	dashboard, err := metrics.Dashboard(*title)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dashboard = append(dashboard, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(dashboard)
+		return
+	}
+	if err := os.WriteFile(*output, dashboard, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,146 @@
+// The grpcserver command serves a cache of the given policy over the gRPC API of proto/cache/v1, so it can run as a
+// caching sidecar called from any language:
+//
+//	go run ./cmd/grpcserver -policy lfu -capacity 1000
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	cachev1 "github.com/AkifhanIlgaz/redis-caching-algorithms/proto/cache/v1"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/workload"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const connectionString string = "redis://@localhost:6379/0"
+
+// grpcCache is implemented by every cache type.
+type grpcCache interface {
+	workload.Cache
+	Delete(key string) error
+	Key(id string) string
+}
+
+// server implements cachev1.CacheServiceServer over a cache.
+type server struct {
+	cachev1.UnimplementedCacheServiceServer
+
+	cache  grpcCache
+	client cache.Client
+	policy string
+	prefix string
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:9090", "address to listen on")
+	url := flag.String("url", connectionString, "Redis connection URL")
+	policy := flag.String("policy", "lru", "cache policy: fifo, lru, lfu, approx-lru or ttl")
+	capacity := flag.Int("capacity", 100, "capacity of the cache")
+	expiration := flag.Duration("ttl", time.Minute, "expiration of the entries of the ttl cache")
+	prefix := flag.String("prefix", "grpc", "key prefix of the cache")
+	flag.Parse()
+
+	opt, err := cache.ParseConnectionURL(*url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client := redis.NewUniversalClient(opt)
+	ctx := context.Background()
+
+	c, err := workload.NewCache(ctx, client, *policy, *capacity, *expiration, *prefix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	cachev1.RegisterCacheServiceServer(srv, &server{cache: c.(grpcCache), client: client, policy: *policy, prefix: *prefix})
+	log.Printf("Serving %s cache with capacity %d over gRPC on %s", *policy, *capacity, *addr)
+	if err := srv.Serve(lis); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Get returns a cached user, or fails with NOT_FOUND on a miss.
+func (s *server) Get(ctx context.Context, req *cachev1.GetRequest) (*cachev1.User, error) {
+	user, err := s.cache.Get(req.GetId())
+	if errors.Is(err, redis.Nil) {
+		return nil, status.Errorf(codes.NotFound, "user %s is not cached", req.GetId())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &cachev1.User{Id: user.Id, Name: user.Name, Age: int32(user.Age)}, nil
+}
+
+// Put caches a user.
+func (s *server) Put(ctx context.Context, req *cachev1.PutRequest) (*cachev1.PutResponse, error) {
+	u := req.GetUser()
+	if u.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+	if err := s.cache.Set(cache.User{Id: u.GetId(), Name: u.GetName(), Age: int(u.GetAge())}); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &cachev1.PutResponse{}, nil
+}
+
+// Delete removes a user from the cache.
+func (s *server) Delete(ctx context.Context, req *cachev1.DeleteRequest) (*cachev1.DeleteResponse, error) {
+	if err := s.cache.Delete(s.cache.Key(req.GetId())); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &cachev1.DeleteResponse{}, nil
+}
+
+// Stats returns the size, capacity and counters of the cache.
+func (s *server) Stats(ctx context.Context, req *cachev1.StatsRequest) (*cachev1.StatsResponse, error) {
+	stats, err := s.cache.Stats()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &cachev1.StatsResponse{
+		Policy:        s.policy,
+		Items:         int64(stats.Items),
+		Capacity:      int64(stats.Capacity),
+		Bytes:         stats.Bytes,
+		MaxBytes:      stats.MaxBytes,
+		Hits:          stats.Hits,
+		Misses:        stats.Misses,
+		Sets:          stats.Sets,
+		Evictions:     stats.Evictions,
+		LoadErrors:    stats.LoadErrors,
+		AvgLoadTimeNs: int64(stats.AvgLoadTime),
+	}, nil
+}
+
+// ListEntries returns the cached keys in eviction order, with their scores. The TTL cache has no index.
+func (s *server) ListEntries(ctx context.Context, req *cachev1.ListEntriesRequest) (*cachev1.ListEntriesResponse, error) {
+	if s.policy == "ttl" {
+		return &cachev1.ListEntriesResponse{}, nil
+	}
+	entries, err := cache.IndexEntries(ctx, s.client, s.prefix)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	resp := &cachev1.ListEntriesResponse{Entries: make([]*cachev1.Entry, len(entries))}
+	for i, entry := range entries {
+		resp.Entries[i] = &cachev1.Entry{Key: entry.Key, Score: entry.Score}
+	}
+	return resp, nil
+}
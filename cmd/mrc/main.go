@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/sim"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/workload"
+	"github.com/redis/go-redis/v9"
+)
+
+const connectionString string = "redis://@localhost:6379/0"
+
+// The mrc command replays a workload against caches of a range of capacities and prints the miss-ratio curve
+// of each policy as CSV or JSON, to choose the size of a cache and compare policies across sizes.
+func main() {
+	url := flag.String("url", connectionString, "Redis connection URL")
+	policies := flag.String("policies", "lru", "comma separated policies to measure")
+	capacityList := flag.String("capacities", "", "comma separated capacities, instead of a range")
+	from := flag.Int("from", 100, "smallest capacity of the range")
+	to := flag.Int("to", 1000, "largest capacity of the range")
+	step := flag.Int("step", 100, "step between the capacities of the range")
+	expiration := flag.Duration("ttl", time.Minute, "expiration of the entries of the ttl cache")
+	distribution := flag.String("distribution", "zipf", "key distribution: uniform, zipf, hotspot or sequential")
+	keys := flag.Int("keys", 10000, "size of the key space")
+	requests := flag.Int("requests", 100000, "number of accesses")
+	seed := flag.Int64("seed", 1, "seed of the workload and of the random decisions of the caches")
+	tracePath := flag.String("trace", "", "path of an access trace to replay instead of generating a workload")
+	format := flag.String("format", "csv", "trace format: csv, arc or lirs")
+	output := flag.String("output", "csv", "output format: csv or json")
+	simulate := flag.Bool("sim", false, "simulate the caches in memory instead of using Redis")
+	flag.Parse()
+
+	capacities, err := parseCapacities(*capacityList, *from, *to, *step)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *output != "csv" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format: %s\n", *output)
+		os.Exit(2)
+	}
+	accesses, err := loadAccesses(*tracePath, *format, *distribution, *keys, *requests, *seed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var client redis.UniversalClient
+	ctx := context.Background()
+	if !*simulate {
+		opt, err := cache.ParseConnectionURL(*url)
+		if err != nil {
+			log.Fatal(err)
+		}
+		client = redis.NewUniversalClient(opt)
+	}
+
+	var curve []workload.CurvePoint
+	for _, policy := range strings.Split(*policies, ",") {
+		points, err := workload.MissRatioCurve(policy, capacities, accesses, func(capacity int) (workload.Cache, error) {
+			if *simulate {
+				return sim.New(policy, capacity, *expiration, *seed)
+			}
+			prefix := fmt.Sprintf("mrc_%s_%d", policy, capacity)
+			// Silence the logs of the flush of the namespace and the per-operation logs of the caches.
+			log.SetOutput(io.Discard)
+			if _, err := cache.FlushNamespace(ctx, client, prefix); err != nil {
+				return nil, err
+			}
+			return workload.NewCache(ctx, client, policy, capacity, *expiration, prefix, cache.WithSeed(*seed))
+		})
+		log.SetOutput(os.Stderr)
+		if err != nil {
+			log.Fatalf("Error measuring policy: %s: %v", policy, err)
+		}
+		curve = append(curve, points...)
+	}
+
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(curve); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"policy", "capacity", "requests", "hits", "misses", "hit_ratio", "miss_ratio"})
+	for _, p := range curve {
+		w.Write([]string{
+			p.Policy,
+			strconv.Itoa(p.Capacity),
+			strconv.Itoa(p.Requests),
+			strconv.Itoa(p.Hits),
+			strconv.Itoa(p.Misses),
+			strconv.FormatFloat(p.HitRatio, 'f', 4, 64),
+			strconv.FormatFloat(p.MissRatio, 'f', 4, 64),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseCapacities returns the capacities listed in list, or the range from first to last by step if it is empty.
+func parseCapacities(list string, first, last, step int) ([]int, error) {
+	if list == "" {
+		return workload.Capacities(first, last, step)
+	}
+
+	var capacities []int
+	for _, field := range strings.Split(list, ",") {
+		capacity, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || capacity <= 0 {
+			return nil, fmt.Errorf("invalid capacity: %q", field)
+		}
+		capacities = append(capacities, capacity)
+	}
+	return capacities, nil
+}
+
+// loadAccesses reads the keys of the trace at tracePath, or generates a workload if it is empty.
+func loadAccesses(tracePath, format, distribution string, keys, requests int, seed int64) ([]string, error) {
+	if tracePath == "" {
+		dist, err := workload.ParseDistribution(distribution)
+		if err != nil {
+			return nil, err
+		}
+		gen, err := workload.New(workload.Config{Distribution: dist, Keys: keys, Requests: requests, Seed: seed})
+		if err != nil {
+			return nil, err
+		}
+		return gen.All(), nil
+	}
+
+	traceFormat, err := workload.ParseTraceFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(tracePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return workload.ReadTrace(f, traceFormat)
+}
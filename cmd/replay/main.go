@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/sim"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/workload"
+	"github.com/redis/go-redis/v9"
+)
+
+const connectionString string = "redis://@localhost:6379/0"
+
+// The replay command replays an access trace against a cache and reports its hit ratio and latency,
+// so policies can be evaluated on production traces.
+func main() {
+	url := flag.String("url", connectionString, "Redis connection URL")
+	tracePath := flag.String("trace", "", "path of the access trace")
+	format := flag.String("format", "csv", "trace format: csv, arc or lirs")
+	policy := flag.String("policy", "lru", "cache policy: fifo, lru, lfu, approx-lru or ttl")
+	capacity := flag.Int("capacity", 1000, "capacity of the cache")
+	expiration := flag.Duration("ttl", time.Minute, "expiration of the entries of the ttl cache")
+	limit := flag.Int("limit", 0, "number of accesses to replay, or 0 for the whole trace")
+	simulate := flag.Bool("sim", false, "simulate the cache in memory instead of using Redis")
+	seed := flag.Int64("seed", 1, "seed of the random decisions of the cache")
+	export := flag.String("export", "", "path of a .json or .csv file to export the result to")
+	flag.Parse()
+
+	if *export != "" {
+		if err := workload.CheckExportPath(*export); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	traceFormat, err := workload.ParseTraceFormat(*format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	f, err := os.Open(*tracePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	keys, err := workload.ReadTrace(f, traceFormat)
+	f.Close()
+	if err != nil {
+		log.Fatalf("Error reading trace: %s: %v", *tracePath, err)
+	}
+	if *limit > 0 && *limit < len(keys) {
+		keys = keys[:*limit]
+	}
+
+	c, err := newCache(*url, *policy, *capacity, *expiration, *seed, *simulate)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	start := time.Now()
+	result := workload.Replay(c, keys)
+	stats, _ := c.Stats()
+
+	fmt.Printf("policy:      %s\n", *policy)
+	fmt.Printf("accesses:    %d in %v (%.0f/s)\n", result.Requests, result.Elapsed, result.Throughput())
+	fmt.Printf("hit ratio:   %.4f (%d hits, %d misses)\n", result.HitRatio(), result.Hits, result.Misses)
+	fmt.Printf("evictions:   %d\n", stats.Evictions)
+	fmt.Printf("errors:      %d\n", result.Errors)
+	fmt.Printf("latency:     p50 %v, p95 %v, p99 %v\n", result.P50, result.P95, result.P99)
+
+	if *export != "" {
+		run := workload.Run{
+			Time:       start,
+			Policy:     *policy,
+			Capacity:   *capacity,
+			Workload:   *tracePath,
+			Seed:       *seed,
+			Goroutines: 1,
+			Result:     result,
+			Evictions:  stats.Evictions,
+		}
+		if err := workload.ExportFile(*export, []workload.Run{run}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// newCache creates the cache to replay the trace against: a simulated one, or one in Redis under its own
+// prefix, emptied first. The per-operation logs of the caches are silenced.
+func newCache(url, policy string, capacity int, expiration time.Duration, seed int64, simulate bool) (workload.Cache, error) {
+	if simulate {
+		return sim.New(policy, capacity, expiration, seed)
+	}
+
+	opt, err := cache.ParseConnectionURL(url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client := redis.NewUniversalClient(opt)
+	ctx := context.Background()
+	prefix := "replay_" + policy
+
+	// Start from an empty namespace.
+	if _, err := cache.FlushNamespace(ctx, client, prefix); err != nil {
+		log.Fatal(err)
+	}
+	log.SetOutput(io.Discard)
+
+	return workload.NewCache(ctx, client, policy, capacity, expiration, prefix, cache.WithSeed(seed))
+}
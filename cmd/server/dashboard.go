@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+)
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// recentEvictions is the number of evictions the dashboard replays to a page when it connects.
+const recentEvictions = 50
+
+// eviction is an entry evicted by the cache, as pushed to the dashboard.
+type eviction struct {
+	Key    string    `json:"key"`
+	Name   string    `json:"name"`
+	Reason string    `json:"reason"`
+	Time   time.Time `json:"time"`
+}
+
+// dashboardState is the state of the cache pushed to the dashboard on every tick.
+type dashboardState struct {
+	Policy  string             `json:"policy"`
+	Entries []cache.IndexEntry `json:"entries"`
+	Total   int                `json:"total"`
+	Stats   cache.Snapshot     `json:"stats"`
+	Time    time.Time          `json:"time"`
+}
+
+// dashboard serves a web page showing the entries, hit ratio and evictions of the cache in real time.
+// The state of the cache is pushed to the page every interval and the evictions as they happen, over server-sent events.
+type dashboard struct {
+	cache    serverCache
+	client   cache.Client
+	policy   string
+	prefix   string
+	interval time.Duration
+	limit    int
+
+	mu          sync.Mutex
+	subscribers map[chan eviction]struct{}
+	recent      []eviction
+}
+
+// newDashboard creates a dashboard of the cache of the given policy and prefix, to be set once created.
+func newDashboard(client cache.Client, policy, prefix string, interval time.Duration, limit int) *dashboard {
+	return &dashboard{
+		client:      client,
+		policy:      policy,
+		prefix:      prefix,
+		interval:    interval,
+		limit:       limit,
+		subscribers: make(map[chan eviction]struct{}),
+	}
+}
+
+// onEvict records an eviction and pushes it to the connected pages. It is registered with cache.WithOnEvict,
+// so it never blocks: a page too slow to receive an eviction misses it.
+func (d *dashboard) onEvict(key string, value cache.User, reason string) {
+	e := eviction{Key: key, Name: value.Name, Reason: reason, Time: time.Now()}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.recent = append(d.recent, e)
+	if len(d.recent) > recentEvictions {
+		d.recent = d.recent[len(d.recent)-recentEvictions:]
+	}
+	for ch := range d.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// subscribe registers a page and returns the channel of its evictions, with the recent evictions to replay.
+func (d *dashboard) subscribe() (chan eviction, []eviction) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ch := make(chan eviction, 64)
+	d.subscribers[ch] = struct{}{}
+	return ch, append([]eviction(nil), d.recent...)
+}
+
+// unsubscribe forgets a page.
+func (d *dashboard) unsubscribe(ch chan eviction) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.subscribers, ch)
+}
+
+// state collects the current state of the cache, with the first limit entries of its index in eviction order.
+func (d *dashboard) state(ctx context.Context) (dashboardState, error) {
+	stats, err := d.cache.Stats()
+	if err != nil {
+		return dashboardState{}, err
+	}
+	entries, err := cache.IndexEntries(ctx, d.client, d.prefix)
+	if err != nil {
+		return dashboardState{}, err
+	}
+
+	state := dashboardState{
+		Policy:  d.policy,
+		Entries: entries,
+		Total:   len(entries),
+		Stats:   stats.Snapshot(),
+		Time:    time.Now(),
+	}
+	if len(state.Entries) > d.limit {
+		state.Entries = state.Entries[:d.limit]
+	}
+	if state.Entries == nil {
+		state.Entries = []cache.IndexEntry{}
+	}
+	return state, nil
+}
+
+// page serves the dashboard page.
+func (d *dashboard) page(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardPage)
+}
+
+// events streams the state of the cache and its evictions to a page as server-sent events,
+// "state" every interval and "evict" for every eviction, until the page disconnects.
+func (d *dashboard) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	evictions, recent := d.subscribe()
+	defer d.unsubscribe(evictions)
+
+	ctx := r.Context()
+	for _, e := range recent {
+		if err := writeEvent(w, "evict", e); err != nil {
+			return
+		}
+	}
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		state, err := d.state(ctx)
+		if err != nil {
+			log.Printf("Error collecting dashboard state: %v", err)
+			if err := writeEvent(w, "failure", map[string]string{"error": err.Error()}); err != nil {
+				return
+			}
+		} else if err := writeEvent(w, "state", state); err != nil {
+			return
+		}
+		flusher.Flush()
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-evictions:
+				if err := writeEvent(w, "evict", e); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-ticker.C:
+				break wait
+			}
+		}
+	}
+}
+
+// writeEvent writes v as a server-sent event of the given type.
+func writeEvent(w http.ResponseWriter, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
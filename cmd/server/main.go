@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/metrics"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/mockdb"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/workload"
+	"github.com/redis/go-redis/v9"
+)
+
+const connectionString string = "redis://@localhost:6379/0"
+
+// serverCache is implemented by every cache type.
+type serverCache interface {
+	workload.Cache
+	GetOrLoad(id string, opts ...cache.CallOption) (cache.User, error)
+	Delete(key string) error
+	Key(id string) string
+	cache.Notifiable
+	Health(ctx context.Context) (cache.Health, error)
+	DebugHandler() http.Handler
+}
+
+// adminCache is implemented by every cache type but the TTL cache.
+type adminCache interface {
+	AdminHandler(token string) http.Handler
+}
+
+// server serves the users of a mock database through a cache.
+type server struct {
+	cache  serverCache
+	db     *mockdb.DB
+	prefix string
+}
+
+// The server command serves the users of a mock database over HTTP through a cache of the given policy,
+// so the caching behavior can be exercised with curl, ab or any load generator:
+//
+//	GET    /users/{id}    the user, read through the cache, with X-Cache: HIT or MISS
+//	DELETE /cache/{id}    removes the user from the cache
+//	POST   /notify/{id}   invalidates the user after a change to the database, with a JSON body
+//	                      {"change": "updated"} or {"change": "deleted"}
+//	GET    /cache/stats   the stats of the cache
+//	GET    /cache/health  the health of the cache
+//	GET    /debug/cache   the configuration and stats of the cache
+//	GET    /metrics       the stats of the cache in the Prometheus text format
+//	GET    /dashboard     a live view of the entries, hit ratio and evictions of the cache
+//	       /admin/...     the admin endpoints of the cache, with -admin-token, except for the ttl policy
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	url := flag.String("url", connectionString, "Redis connection URL")
+	policy := flag.String("policy", "lru", "cache policy: fifo, lru, lfu, approx-lru or ttl")
+	capacity := flag.Int("capacity", 100, "capacity of the cache")
+	expiration := flag.Duration("ttl", time.Minute, "expiration of the entries of the ttl cache")
+	users := flag.Int("users", 1000, "number of users in the mock database, with ids from 1")
+	seed := flag.Int64("seed", 1, "seed of the mock database and of the random decisions of the cache")
+	dbLatency := flag.Duration("db-latency", 50*time.Millisecond, "latency of a call of the mock database")
+	dbJitter := flag.Duration("db-jitter", 0, "random delay added to the latency of the mock database")
+	dbErrorRate := flag.Float64("db-error-rate", 0, "share of the calls of the mock database that fail")
+	verbose := flag.Bool("v", false, "log every operation of the cache")
+	dashboardInterval := flag.Duration("dashboard-interval", time.Second, "interval between the updates of the dashboard")
+	dashboardEntries := flag.Int("dashboard-entries", 50, "number of entries shown on the dashboard")
+	adminToken := flag.String("admin-token", "", "serve the admin endpoints of the cache under /admin/, requiring this bearer token")
+	flag.Parse()
+
+	opt, err := cache.ParseConnectionURL(*url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client := redis.NewUniversalClient(opt)
+	ctx := context.Background()
+
+	s := &server{
+		db: mockdb.New(mockdb.WithGeneratedUsers(*users), mockdb.WithSeed(*seed),
+			mockdb.WithLatency(*dbLatency), mockdb.WithJitter(*dbJitter), mockdb.WithErrorRate(*dbErrorRate)),
+		prefix: "server_" + *policy,
+	}
+
+	// Start from an empty namespace.
+	if _, err := cache.FlushNamespace(ctx, client, s.prefix); err != nil {
+		log.Fatal(err)
+	}
+
+	dash := newDashboard(client, *policy, s.prefix, *dashboardInterval, *dashboardEntries)
+	c, err := workload.NewCache(ctx, client, *policy, *capacity, *expiration, s.prefix,
+		cache.WithLoader(s.db.Load),
+		cache.WithSeed(*seed),
+		cache.WithOnEvict(dash.onEvict),
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	s.cache = c.(serverCache)
+	dash.cache = s.cache
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", s.getUser)
+	mux.HandleFunc("DELETE /cache/{id}", s.deleteUser)
+	mux.HandleFunc("POST /notify/{id}", s.notify)
+	mux.HandleFunc("GET /cache/stats", s.stats)
+	mux.HandleFunc("GET /cache/health", s.health)
+	mux.Handle("GET /debug/cache", s.cache.DebugHandler())
+	registry := metrics.NewRegistry()
+	registry.Register(*policy, s.prefix, s.cache)
+	mux.Handle("GET /metrics", registry)
+	mux.HandleFunc("GET /dashboard", dash.page)
+	mux.HandleFunc("GET /dashboard/events", dash.events)
+	if admin, ok := s.cache.(adminCache); ok && *adminToken != "" {
+		mux.Handle("/admin/", http.StripPrefix("/admin", admin.AdminHandler(*adminToken)))
+	}
+
+	log.Printf("Serving %s cache with capacity %d on http://%s", *policy, *capacity, *addr)
+	if !*verbose {
+		// Silence the per-operation logs of the cache, which would flood the output under load.
+		log.SetOutput(io.Discard)
+	}
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		log.SetOutput(os.Stderr)
+		log.Fatal(err)
+	}
+}
+
+// getUser serves a user from the cache, or from the database on a miss, reporting which in the X-Cache header.
+func (s *server) getUser(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	start := time.Now()
+
+	user, err := s.cache.Get(id)
+	outcome := "HIT"
+	switch {
+	case errors.Is(err, redis.Nil):
+		// The user was just found missing, so it is loaded without reading the cache again.
+		outcome = "MISS"
+		user, err = s.cache.GetOrLoad(id, cache.ForceRefresh())
+	case err != nil:
+		// Let GetOrLoad fall back to the database while Redis fails.
+		outcome = "MISS"
+		user, err = s.cache.GetOrLoad(id)
+	}
+	w.Header().Set("X-Cache", outcome)
+	w.Header().Set("Server-Timing", fmt.Sprintf("total;dur=%.3f", float64(time.Since(start))/float64(time.Millisecond)))
+
+	switch {
+	case errors.Is(err, mockdb.ErrNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, mockdb.ErrUnavailable):
+		writeError(w, http.StatusServiceUnavailable, err)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusOK, user)
+	}
+}
+
+// deleteUser removes a user from the cache, so the next read loads it from the database.
+func (s *server) deleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := s.cache.Delete(s.cache.Key(r.PathValue("id"))); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notification is the body of a request to /notify/{id}.
+type notification struct {
+	// Change is cache.InvalidateUpdated or cache.InvalidateDeleted.
+	Change string `json:"change"`
+}
+
+// notify invalidates a user changed in the database behind the back of the cache, in every instance sharing it.
+func (s *server) notify(w http.ResponseWriter, r *http.Request) {
+	var n notification
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	id := r.PathValue("id")
+	var err error
+	switch n.Change {
+	case cache.InvalidateUpdated:
+		err = s.cache.NotifyUpdated(id)
+	case cache.InvalidateDeleted:
+		err = s.cache.NotifyDeleted(id)
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown change: %q, want %q or %q", n.Change, cache.InvalidateUpdated, cache.InvalidateDeleted))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// stats serves a snapshot of the stats of the cache, with the calls of the database.
+func (s *server) stats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.cache.Stats()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		cache.Snapshot
+		DBCalls    int64 `json:"db_calls"`
+		DBFailures int64 `json:"db_failures"`
+	}{stats.Snapshot(), s.db.Calls(), s.db.Failures()})
+}
+
+// health serves the health of the cache, with status 503 if it is unhealthy.
+func (s *server) health(w http.ResponseWriter, r *http.Request) {
+	h, err := s.cache.Health(r.Context())
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, h)
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// writeError writes err as a JSON error response with the given status.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
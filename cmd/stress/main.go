@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/workload"
+	"github.com/redis/go-redis/v9"
+)
+
+const connectionString string = "redis://@localhost:6379/0"
+
+// setter is implemented by every cache type.
+type setter interface {
+	Set(user cache.User) error
+	CacheSize() int
+	Verify() (cache.Report, error)
+}
+
+// The stress command hammers one cache from several Redis clients, each with several goroutines,
+// as if they were separate application instances, and checks that the cache never exceeds
+// its capacity by more than the allowed bound and that its index stays consistent.
+func main() {
+	url := flag.String("url", connectionString, "Redis connection URL")
+	policy := flag.String("policy", "approx-lru", "cache policy: fifo, lru, lfu or approx-lru")
+	capacity := flag.Int("capacity", 50, "capacity of the cache")
+	clients := flag.Int("clients", 4, "number of Redis clients, each simulating an application instance")
+	goroutines := flag.Int("goroutines", 8, "number of goroutines per client")
+	requests := flag.Int("requests", 1000, "number of Sets per goroutine")
+	keys := flag.Int("keys", 500, "size of the key space")
+	distribution := flag.String("distribution", "uniform", "key distribution: uniform, zipf, hotspot or sequential")
+	bound := flag.Int("bound", 0, "number of items the cache may exceed its capacity by")
+	coordinated := flag.Bool("coordinated", true, "use cache.WithCoordinatedEviction")
+	seed := flag.Int64("seed", 0, "seed of the workload, or 0 for a random seed")
+	flag.Parse()
+
+	if *seed == 0 {
+		*seed = rand.Int63()
+	}
+
+	opt, err := cache.ParseConnectionURL(*url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dist, err := workload.ParseDistribution(*distribution)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	ctx := context.Background()
+	prefix := "stress_" + *policy
+
+	var opts []cache.Option
+	if *coordinated {
+		opts = append(opts, cache.WithCoordinatedEviction())
+	}
+
+	// Start from an empty namespace and silence the per-operation logs of the caches.
+	admin := redis.NewUniversalClient(opt)
+	if _, err := cache.FlushNamespace(ctx, admin, prefix); err != nil {
+		log.Fatal(err)
+	}
+	log.SetOutput(io.Discard)
+
+	caches := make([]setter, *clients)
+	for i := range caches {
+		caches[i], err = newCache(ctx, redis.NewUniversalClient(opt), *policy, *capacity, prefix, opts...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	var maxSize, errors int64
+	done := make(chan struct{})
+	monitorDone := make(chan struct{})
+	go func() {
+		defer close(monitorDone)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if size := int64(caches[0].CacheSize()); size > atomic.LoadInt64(&maxSize) {
+				atomic.StoreInt64(&maxSize, size)
+			}
+		}
+	}()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i, c := range caches {
+		for g := 0; g < *goroutines; g++ {
+			wg.Add(1)
+			// Each goroutine gets its own stream of keys, derived from the seed.
+			gen, err := workload.New(workload.Config{Distribution: dist, Keys: *keys, Requests: *requests, Seed: *seed + int64(i**goroutines+g)})
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			go func(c setter, gen *workload.Generator) {
+				defer wg.Done()
+				for id, ok := gen.Next(); ok; id, ok = gen.Next() {
+					if err := c.Set(cache.User{Id: id, Name: "user " + id}); err != nil {
+						atomic.AddInt64(&errors, 1)
+					}
+				}
+			}(c, gen)
+		}
+	}
+	wg.Wait()
+	close(done)
+	<-monitorDone
+	elapsed := time.Since(start)
+
+	total := *clients * *goroutines * *requests
+	finalSize := caches[0].CacheSize()
+	report, err := caches[0].Verify()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	fmt.Printf("policy:         %s\n", *policy)
+	fmt.Printf("distribution:   %s\n", dist)
+	fmt.Printf("seed:           %d\n", *seed)
+	fmt.Printf("sets:           %d in %v (%.0f/s)\n", total, elapsed, float64(total)/elapsed.Seconds())
+	fmt.Printf("errors:         %d\n", errors)
+	fmt.Printf("capacity:       %d (+%d allowed)\n", *capacity, *bound)
+	fmt.Printf("max size seen:  %d\n", maxSize)
+	fmt.Printf("final size:     %d\n", finalSize)
+	fmt.Printf("consistent:     %t\n", report.Consistent())
+
+	if maxSize > int64(*capacity+*bound) || finalSize > *capacity+*bound || !report.Consistent() {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("PASS")
+}
+
+// newCache creates the cache for the given policy.
+func newCache(ctx context.Context, client redis.UniversalClient, policy string, capacity int, prefix string, opts ...cache.Option) (setter, error) {
+	switch policy {
+	case "fifo":
+		c := cache.NewFIFO(ctx, client, capacity, prefix, opts...)
+		return &c, nil
+	case "lru":
+		c := cache.NewLRU(ctx, client, capacity, prefix, opts...)
+		return &c, nil
+	case "lfu":
+		c := cache.NewLFU(ctx, client, capacity, prefix, opts...)
+		return &c, nil
+	case "approx-lru":
+		c := cache.NewApproxLRU(ctx, client, capacity, 0, prefix, opts...)
+		return &c, nil
+	default:
+		return nil, fmt.Errorf("unknown policy: %s", policy)
+	}
+}
@@ -1,52 +1,312 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/mockdb"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/workload"
 	"github.com/redis/go-redis/v9"
 )
 
 const connectionString string = "redis://@localhost:6379/0"
 
+// maxEvents is the number of recent events shown on the screen.
+const maxEvents = 12
+
+// ANSI escape sequences used to draw the screen.
+const (
+	clearScreen = "\033[H\033[2J"
+	bold        = "\033[1m"
+	green       = "\033[32m"
+	red         = "\033[31m"
+	yellow      = "\033[33m"
+	reset       = "\033[0m"
+)
+
+// readThroughCache is implemented by every cache type.
+type readThroughCache interface {
+	workload.Cache
+	GetOrLoad(id string, opts ...cache.CallOption) (cache.User, error)
+}
+
+// demo is the state shown on the screen: the cache, the database, the workload and the recent events.
+type demo struct {
+	ctx      context.Context
+	client   redis.UniversalClient
+	cache    readThroughCache
+	db       *mockdb.DB
+	policy   string
+	capacity int
+	prefix   string
+	keys     []string
+	step     int
+	// lastAccess is the duration of the last access, including the load after a miss.
+	lastAccess time.Duration
+
+	mu     sync.Mutex
+	events []string
+}
+
+// The test command runs a workload against a read-through cache in front of a mock database step by step
+// in the terminal, showing the contents of its index with their scores and the hits, misses, admissions,
+// evictions and failed loads as they happen. It steps on Enter, or on its own at the given speed.
 func main() {
-	opt, err := redis.ParseURL(connectionString)
+	url := flag.String("url", connectionString, "Redis connection URL")
+	policy := flag.String("policy", "lru", "cache policy: fifo, lru, lfu, approx-lru or ttl")
+	capacity := flag.Int("capacity", 5, "capacity of the cache")
+	expiration := flag.Duration("ttl", 10*time.Second, "expiration of the entries of the ttl cache")
+	distribution := flag.String("distribution", "zipf", "key distribution: uniform, zipf, hotspot or sequential")
+	keys := flag.Int("keys", 15, "size of the key space")
+	requests := flag.Int("requests", 100, "number of accesses")
+	seed := flag.Int64("seed", 1, "seed of the workload")
+	speed := flag.Duration("speed", 0, "delay between steps, or 0 to step manually")
+	dbLatency := flag.Duration("db-latency", 50*time.Millisecond, "latency of a call of the mock database")
+	dbJitter := flag.Duration("db-jitter", 0, "random delay added to the latency of the mock database")
+	dbErrorRate := flag.Float64("db-error-rate", 0, "share of the calls of the mock database that fail")
+	flag.Parse()
+
+	dist, err := workload.ParseDistribution(*distribution)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	gen, err := workload.New(workload.Config{Distribution: dist, Keys: *keys, Requests: *requests, Seed: *seed})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	opt, err := cache.ParseConnectionURL(*url)
 	if err != nil {
 		log.Fatal(err)
 	}
+	d := &demo{
+		ctx:      context.Background(),
+		client:   redis.NewUniversalClient(opt),
+		policy:   *policy,
+		capacity: *capacity,
+		prefix:   "demo_" + *policy,
+		keys:     gen.All(),
+		db: mockdb.New(mockdb.WithGeneratedUsers(*keys), mockdb.WithSeed(*seed),
+			mockdb.WithLatency(*dbLatency), mockdb.WithJitter(*dbJitter), mockdb.WithErrorRate(*dbErrorRate)),
+	}
+
+	// Start from an empty namespace and silence the per-operation logs of the cache, which would scroll the screen.
+	if _, err := cache.FlushNamespace(d.ctx, d.client, d.prefix); err != nil {
+		log.Fatal(err)
+	}
+	log.SetOutput(io.Discard)
+
+	c, err := workload.NewCache(d.ctx, d.client, *policy, *capacity, *expiration, d.prefix,
+		cache.WithLoader(d.db.Load),
+		cache.WithSeed(*seed),
+		cache.WithOnHit(func(key string) { d.addEvent(green + "hit    " + reset + d.id(key)) }),
+		cache.WithOnMiss(func(key string) { d.addEvent(red + "miss   " + reset + d.id(key)) }),
+		cache.WithOnAdmit(func(key string) { d.addEvent("admit  " + d.id(key)) }),
+		cache.WithOnEvict(func(key string, _ cache.User, reason string) {
+			d.addEvent(yellow + "evict  " + reset + d.id(key) + " (" + reason + ")")
+		}),
+	)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	d.cache = c.(readThroughCache)
+
+	d.run(*speed)
+	dumpStats(d.cache)
+}
+
+// run steps through the workload. Stepping is manual if speed is 0: Enter steps, "r" runs at half a second
+// per step and "q" quits. While running, Enter pauses.
+func (d *demo) run(speed time.Duration) {
+	commands := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			commands <- strings.TrimSpace(scanner.Text())
+		}
+		close(commands)
+	}()
+
+	paused := speed == 0
+	d.render(paused)
+	for d.step < len(d.keys) {
+		if paused {
+			command, ok := <-commands
+			switch {
+			case !ok || command == "q":
+				return
+			case command == "r":
+				if speed == 0 {
+					speed = 500 * time.Millisecond
+				}
+				paused = false
+			}
+		} else {
+			select {
+			case command, ok := <-commands:
+				if !ok {
+					// Keep running to the end once the input is closed.
+					commands = nil
+					continue
+				}
+				if command == "q" {
+					return
+				}
+				paused = true
+				d.render(paused)
+				continue
+			case <-time.After(speed):
+			}
+		}
+
+		d.access(d.keys[d.step])
+		d.step++
+		d.render(paused)
+	}
+}
+
+// access reads a user through the cache, loading it from the database on a miss.
+func (d *demo) access(id string) {
+	start := time.Now()
+	_, err := d.cache.GetOrLoad(id)
+	d.lastAccess = time.Since(start)
+	if err != nil {
+		d.addEvent(red + "error  " + reset + id + ": " + err.Error())
+	}
+}
+
+// addEvent appends an event to the recent events, dropping the oldest beyond maxEvents.
+func (d *demo) addEvent(event string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.events = append(d.events, event)
+	if len(d.events) > maxEvents {
+		d.events = d.events[len(d.events)-maxEvents:]
+	}
+}
+
+// id returns the user id of a value key.
+func (d *demo) id(key string) string {
+	return strings.TrimPrefix(key, d.prefix+":user:")
+}
+
+// render redraws the screen.
+func (d *demo) render(paused bool) {
+	var b strings.Builder
+	b.WriteString(clearScreen)
+	fmt.Fprintf(&b, "%spolicy %s", bold, d.policy)
+	if d.policy != "ttl" {
+		fmt.Fprintf(&b, ", capacity %d", d.capacity)
+	}
+	fmt.Fprintf(&b, "%s    step %d/%d", reset, d.step, len(d.keys))
+	if d.step > 0 {
+		fmt.Fprintf(&b, "    last key %s", d.keys[d.step-1])
+	}
+	if d.step < len(d.keys) {
+		fmt.Fprintf(&b, "    next key %s", d.keys[d.step])
+	}
+	b.WriteString("\n\n")
+
+	d.renderIndex(&b)
+
+	fmt.Fprintf(&b, "\n%sevents%s\n", bold, reset)
+	d.mu.Lock()
+	for _, event := range d.events {
+		fmt.Fprintf(&b, "  %s\n", event)
+	}
+	d.mu.Unlock()
+
+	if stats, err := d.cache.Stats(); err == nil {
+		hitRatio := 0.0
+		if stats.Hits+stats.Misses > 0 {
+			hitRatio = float64(stats.Hits) / float64(stats.Hits+stats.Misses)
+		}
+		fmt.Fprintf(&b, "\n%shits%s %d  %smisses%s %d  %shit ratio%s %.2f  %sevictions%s %d\n",
+			bold, reset, stats.Hits, bold, reset, stats.Misses, bold, reset, hitRatio, bold, reset, stats.Evictions)
+		fmt.Fprintf(&b, "%sdb calls%s %d  %sdb failures%s %d  %savg load%s %v  %slast access%s %v\n",
+			bold, reset, d.db.Calls(), bold, reset, d.db.Failures(), bold, reset, stats.AvgLoadTime.Round(time.Microsecond),
+			bold, reset, d.lastAccess.Round(time.Microsecond))
+	}
+	b.WriteString("\n")
+
+	switch {
+	case d.step == len(d.keys):
+		b.WriteString("done\n")
+	case paused:
+		b.WriteString("Enter: step   r Enter: run   q Enter: quit\n")
+	default:
+		b.WriteString("Enter: pause   q Enter: quit\n")
+	}
+	fmt.Print(b.String())
+}
+
+// renderIndex writes the contents of the index of the cache in eviction order, or for the TTL cache,
+// which has no index, its entries with their remaining time to live.
+func (d *demo) renderIndex(b *strings.Builder) {
+	if d.policy == "ttl" {
+		fmt.Fprintf(b, "%s%-10s %s%s\n", bold, "key", "ttl", reset)
+		iter := d.client.Scan(d.ctx, 0, d.prefix+":user:*", 0).Iterator()
+		for iter.Next(d.ctx) {
+			ttl := d.client.PTTL(d.ctx, iter.Val()).Val()
+			fmt.Fprintf(b, "%-10s %v\n", d.id(iter.Val()), ttl.Round(time.Millisecond))
+		}
+		return
+	}
+
+	entries, err := cache.IndexEntries(d.ctx, d.client, d.prefix)
+	if err != nil {
+		fmt.Fprintf(b, "error reading index: %v\n", err)
+		return
+	}
+	fmt.Fprintf(b, "%s%-10s %s%s    (next victim first)\n", bold, "key", scoreName(d.policy), reset)
+	for _, entry := range entries {
+		fmt.Fprintf(b, "%-10s %.0f\n", d.id(entry.Key), entry.Score)
+	}
+	for i := len(entries); i < d.capacity; i++ {
+		fmt.Fprintf(b, "%-10s\n", "-")
+	}
+}
 
-	client := redis.NewClient(opt)
-	ctx := context.Background()
-
-	// Clear the database before starting
-	client.FlushDB(ctx)
-	// Create a new FIFO cache with a capacity of 3
-	fifoCache := cache.NewLFU(ctx, client, 3, "lfu_cache")
-
-	fmt.Println("-------------------------------------------------------- Press any key to continue")
-	fmt.Scanln()
-	// Request a user that is not in the cache
-	user1 := fifoCache.MakeRequest("1")
-	fmt.Printf("Got user: %v\n", user1)
-	fmt.Println("--------------------------------------------------------")
-	fmt.Scanln()
-	// Request the same user again, this time it should be a cache hit
-	user1_cached := fifoCache.MakeRequest("1")
-	fmt.Printf("Got user from cache: %v\n", user1_cached)
-	fmt.Println("--------------------------------------------------------")
-	fmt.Scanln()
-	// Add two more users to fill the cache
-	fifoCache.MakeRequest("2")
-	fmt.Println("--------------------------------------------------------")
-	fmt.Scanln()
-	fifoCache.MakeRequest("3")
-	fmt.Println("--------------------------------------------------------")
-	fmt.Scanln()
-	// Add one more user, this should evict the first user (user1)
-	fifoCache.MakeRequest("4")
-	fmt.Println("--------------------------------------------------------")
-	fmt.Scanln()
+// scoreName returns what the scores of the index of a policy are.
+func scoreName(policy string) string {
+	switch policy {
+	case "fifo":
+		return "position"
+	case "lru":
+		return "accessed at (µs)"
+	case "lfu":
+		return "accesses"
+	default:
+		return "accessed at (ns)"
+	}
+}
 
+// dumpStats prints a JSON snapshot of the metrics of the run. Errors are printed to stderr,
+// as the logs of the cache are silenced.
+func dumpStats(c workload.Cache) {
+	stats, err := c.Stats()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting stats: %v\n", err)
+		return
+	}
+	b, err := json.MarshalIndent(stats.Snapshot(), "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	fmt.Printf("Stats of the run: %s\n", b)
 }
@@ -0,0 +1,3 @@
+// Package gormcache is a GORM plugin caching the records loaded by primary key in a cache.RawCache,
+// with any of its policies, and invalidating them when they are updated or deleted through GORM.
+package gormcache
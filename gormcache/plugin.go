@@ -0,0 +1,179 @@
+package gormcache
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Plugin caches the records loaded by primary key. It only serves queries whose sole condition is the primary key,
+// as db.First(&user, 42), db.Take(&user, "42") or db.Where(&User{ID: 42}).First(&user) build them, into a single
+// struct, without Select, Omit, Joins, Preload or Unscoped. Records updated or deleted through a model with its
+// primary key set, or with the primary key as their condition, are invalidated. Updates and deletes with other
+// conditions, and writes that bypass GORM, are not seen: the cached records then stay stale until evicted.
+type Plugin struct {
+	cache *cache.RawCache
+}
+
+// New creates a Plugin caching records in c, to be registered with db.Use.
+func New(c *cache.RawCache) *Plugin {
+	return &Plugin{cache: c}
+}
+
+// Name returns the name of the plugin.
+func (p *Plugin) Name() string {
+	return "gormcache"
+}
+
+// Initialize wraps the query callback of db and registers the invalidation after updates and deletes.
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	query := db.Callback().Query().Get("gorm:query")
+	if query == nil {
+		return errors.New("gorm:query callback not found")
+	}
+	if err := db.Callback().Query().Replace("gorm:query", p.query(query)); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("gormcache:invalidate", p.invalidate); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register("gormcache:invalidate", p.invalidate)
+}
+
+// query serves the primary-key lookups from the cache, runs the others with next, and caches the records found.
+func (p *Plugin) query(next func(*gorm.DB)) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		key, ok := p.lookupKey(db)
+		if !ok {
+			next(db)
+			return
+		}
+
+		err := p.cache.Get(key, db.Statement.Dest)
+		if err == nil {
+			log.Printf("Serving record: %s from cache", key)
+			db.RowsAffected = 1
+			return
+		}
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Error getting record: %s from cache: %v", key, err)
+		}
+
+		next(db)
+		if db.Error != nil || db.RowsAffected != 1 {
+			return
+		}
+		if err := p.cache.Set(key, db.Statement.Dest); err != nil {
+			log.Printf("Error caching record: %s: %v", key, err)
+		}
+	}
+}
+
+// lookupKey returns the cache key of the record a query loads, if it is a primary-key lookup of a single struct.
+func (p *Plugin) lookupKey(db *gorm.DB) (string, bool) {
+	stmt := db.Statement
+	if stmt.Schema == nil || stmt.Schema.PrioritizedPrimaryField == nil || stmt.Unscoped ||
+		len(stmt.Selects) > 0 || len(stmt.Omits) > 0 || len(stmt.Joins) > 0 || len(stmt.Preloads) > 0 ||
+		reflect.Indirect(reflect.ValueOf(stmt.Dest)).Kind() != reflect.Struct {
+		return "", false
+	}
+	for name := range stmt.Clauses {
+		// First and Last add an ORDER BY and a LIMIT, which do not change the record a lookup finds.
+		if name != "WHERE" && name != "ORDER BY" && name != "LIMIT" {
+			return "", false
+		}
+	}
+
+	values, ok := primaryKeyValues(stmt)
+	if !ok || len(values) != 1 {
+		return "", false
+	}
+	return recordKey(stmt, values[0]), true
+}
+
+// invalidate deletes the cached records an update or delete changed.
+func (p *Plugin) invalidate(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		return
+	}
+
+	values, _ := primaryKeyValues(stmt)
+	field := stmt.Schema.PrioritizedPrimaryField
+	switch rv := reflect.Indirect(stmt.ReflectValue); rv.Kind() {
+	case reflect.Struct:
+		if value, zero := field.ValueOf(stmt.Context, rv); !zero {
+			values = append(values, value)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if value, zero := field.ValueOf(stmt.Context, reflect.Indirect(rv.Index(i))); !zero {
+				values = append(values, value)
+			}
+		}
+	}
+
+	for _, value := range values {
+		key := recordKey(stmt, value)
+		log.Printf("Invalidating cached record: %s", key)
+		if err := p.cache.Delete(key); err != nil {
+			log.Printf("Error invalidating cached record: %s: %v", key, err)
+		}
+	}
+}
+
+// primaryKeyValues returns the primary keys a statement is restricted to, if its only condition is on the primary key.
+func primaryKeyValues(stmt *gorm.Statement) ([]interface{}, bool) {
+	c, ok := stmt.Clauses["WHERE"]
+	if !ok {
+		return nil, false
+	}
+	where, ok := c.Expression.(clause.Where)
+	if !ok || len(where.Exprs) != 1 {
+		return nil, false
+	}
+
+	switch expr := where.Exprs[0].(type) {
+	case clause.IN:
+		if isPrimaryKey(stmt, expr.Column) {
+			return expr.Values, true
+		}
+	case clause.Eq:
+		if isPrimaryKey(stmt, expr.Column) {
+			return []interface{}{expr.Value}, true
+		}
+	}
+	return nil, false
+}
+
+// isPrimaryKey reports whether column is the primary key of the model of a statement.
+func isPrimaryKey(stmt *gorm.Statement, column interface{}) bool {
+	var c clause.Column
+	switch column := column.(type) {
+	case clause.Column:
+		c = column
+	case string:
+		// Map conditions name their columns with strings.
+		c = clause.Column{Name: column}
+	default:
+		return false
+	}
+	if c.Table != "" && c.Table != clause.CurrentTable && c.Table != stmt.Table {
+		return false
+	}
+	return c.Name == clause.PrimaryKey || c.Name == stmt.Schema.PrioritizedPrimaryField.DBName
+}
+
+// recordKey returns the cache key of the record of the model of a statement with the given primary key.
+func recordKey(stmt *gorm.Statement, value interface{}) string {
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		value = rv.Elem().Interface()
+	}
+	return fmt.Sprintf("%s:%v", stmt.Schema.Table, value)
+}
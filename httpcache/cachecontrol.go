@@ -0,0 +1,81 @@
+package httpcache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheControl holds the directives of a Cache-Control header that the middleware honors.
+type cacheControl struct {
+	noStore   bool
+	noCache   bool
+	private   bool
+	public    bool
+	maxAge    time.Duration
+	hasMaxAge bool
+	sMaxAge   time.Duration
+	hasSMax   bool
+}
+
+// parseCacheControl parses the Cache-Control headers of h. Directives are case-insensitive,
+// unknown ones are ignored, and an invalid max-age is treated as 0, as RFC 9111 recommends.
+func parseCacheControl(h http.Header) cacheControl {
+	var cc cacheControl
+	for _, line := range h.Values("Cache-Control") {
+		for _, directive := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			value = strings.Trim(value, `"`)
+			switch strings.ToLower(name) {
+			case "no-store":
+				cc.noStore = true
+			case "no-cache":
+				cc.noCache = true
+			case "private":
+				cc.private = true
+			case "public":
+				cc.public = true
+			case "max-age":
+				cc.maxAge, cc.hasMaxAge = parseSeconds(value), true
+			case "s-maxage":
+				cc.sMaxAge, cc.hasSMax = parseSeconds(value), true
+			}
+		}
+	}
+	return cc
+}
+
+// parseSeconds parses a delta-seconds value, returning 0 if it is invalid.
+func parseSeconds(value string) time.Duration {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// freshness returns how long a response with header h may be served from a shared cache, from s-maxage,
+// max-age or Expires in that order, or defaultTTL if it sets none of them. It returns 0 for a response
+// that must not be stored.
+func freshness(h http.Header, now time.Time, defaultTTL time.Duration) time.Duration {
+	cc := parseCacheControl(h)
+	switch {
+	case cc.noStore || cc.noCache || cc.private:
+		return 0
+	case cc.hasSMax:
+		return cc.sMaxAge
+	case cc.hasMaxAge:
+		return cc.maxAge
+	}
+
+	if expires := h.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			// An invalid Expires means the response is already stale.
+			return 0
+		}
+		return max(t.Sub(now), 0)
+	}
+	return defaultTTL
+}
@@ -0,0 +1,277 @@
+// Package httpcache is a net/http middleware caching the responses of a handler in one of the caches
+// of the cache package, keyed by method and request URI, with Vary support, and honoring the
+// Cache-Control headers of requests and responses where a shared cache can.
+package httpcache
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultMaxBodySize is the size of the largest response body the middleware stores by default.
+const DefaultMaxBodySize = 1 << 20
+
+// cacheableStatus lists the status codes whose responses may be stored, those RFC 9110 defines as
+// heuristically cacheable, excluding 206 since the middleware does not combine partial responses.
+var cacheableStatus = []int{
+	http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+	http.StatusMovedPermanently, http.StatusPermanentRedirect, http.StatusNotFound, http.StatusMethodNotAllowed,
+	http.StatusGone, http.StatusRequestURITooLong, http.StatusNotImplemented,
+}
+
+// Store is where the middleware keeps the responses. Get returns redis.Nil for a missing key, like the caches
+// of the cache package: *cache.RawCache implements Store with any of its policies.
+type Store interface {
+	Get(key string, v interface{}) error
+	Set(key string, v interface{}) error
+	Delete(key string) error
+}
+
+// Option configures a Middleware.
+type Option func(*Middleware)
+
+// WithDefaultTTL makes the middleware store the responses that set neither Cache-Control max-age
+// nor Expires for ttl. By default, they are not stored.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(m *Middleware) {
+		m.defaultTTL = ttl
+	}
+}
+
+// WithMaxBodySize sets the size of the largest response body the middleware stores. Larger responses
+// are still served, but not stored. It defaults to DefaultMaxBodySize.
+func WithMaxBodySize(size int) Option {
+	return func(m *Middleware) {
+		m.maxBodySize = size
+	}
+}
+
+// Middleware caches the responses of GET and HEAD requests. A response is stored when its status is cacheable
+// and it is fresh for some time: for its s-maxage, its max-age, until its Expires, or for the default TTL, in this
+// order. Responses with Cache-Control no-store, no-cache or private, or setting a cookie, are not stored. Requests
+// with Cache-Control no-store bypass the cache, and with no-cache or max-age=0 they are served by the handler,
+// whose response replaces the stored one. A successful request with any other method invalidates the
+// responses stored for its URI. Served responses carry an Age header and X-Cache: HIT or MISS.
+type Middleware struct {
+	store       Store
+	defaultTTL  time.Duration
+	maxBodySize int
+	now         func() time.Time
+}
+
+// New creates a Middleware storing responses in store.
+func New(store Store, opts ...Option) *Middleware {
+	m := &Middleware{
+		store:       store,
+		maxBodySize: DefaultMaxBodySize,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// entry is a stored response. A response that varies on request headers is stored under the key of its
+// variant, and the key of the request only holds an entry with the names of those headers.
+type entry struct {
+	Status  int         `json:"status"`
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+	Stored  time.Time   `json:"stored"`
+	Expires time.Time   `json:"expires"`
+	Vary    []string    `json:"vary,omitempty"`
+}
+
+// Handler returns a handler serving the requests from the cache when it can, and from next otherwise.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.RequestURI()
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			m.serveUnsafe(w, r, next)
+			return
+		}
+
+		cc := parseCacheControl(r.Header)
+		if cc.noStore {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !cc.noCache && !(cc.hasMaxAge && cc.maxAge == 0) {
+			if e, ok := m.lookup(key, r); ok {
+				m.serveEntry(w, e)
+				return
+			}
+		}
+
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK, limit: m.maxBodySize}
+		w.Header().Set("X-Cache", "MISS")
+		next.ServeHTTP(rec, r)
+		m.storeResponse(key, r, rec)
+	})
+}
+
+// lookup returns the fresh entry stored for the request, if any.
+func (m *Middleware) lookup(key string, r *http.Request) (entry, bool) {
+	e, ok := m.get(key)
+	if ok && len(e.Vary) > 0 {
+		e, ok = m.get(variantKey(key, e.Vary, r.Header))
+	}
+	return e, ok
+}
+
+// get returns the entry stored under key if it is still fresh.
+func (m *Middleware) get(key string) (entry, bool) {
+	var e entry
+	err := m.store.Get(key, &e)
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Error getting cached response: %s: %v", key, err)
+		}
+		return entry{}, false
+	}
+	if !m.now().Before(e.Expires) {
+		log.Printf("Cached response: %s is stale", key)
+		return entry{}, false
+	}
+	return e, true
+}
+
+// serveEntry writes a stored response.
+func (m *Middleware) serveEntry(w http.ResponseWriter, e entry) {
+	header := w.Header()
+	for name, values := range e.Header {
+		header[name] = slices.Clone(values)
+	}
+	header.Set("Age", strconv.Itoa(int(m.now().Sub(e.Stored)/time.Second)))
+	header.Set("X-Cache", "HIT")
+	w.WriteHeader(e.Status)
+	if _, err := w.Write(e.Body); err != nil {
+		log.Printf("Error writing cached response: %v", err)
+	}
+}
+
+// storeResponse stores the response recorded for the request, if it may be stored.
+func (m *Middleware) storeResponse(key string, r *http.Request, rec *recorder) {
+	header := rec.Header().Clone()
+	header.Del("X-Cache")
+	if !slices.Contains(cacheableStatus, rec.status) || rec.truncated || header.Get("Set-Cookie") != "" {
+		return
+	}
+	// Responses to authenticated requests are private unless they say otherwise, see RFC 9111 section 3.5.
+	if cc := parseCacheControl(header); r.Header.Get("Authorization") != "" && !cc.public && !cc.hasSMax {
+		return
+	}
+
+	now := m.now()
+	ttl := freshness(header, now, m.defaultTTL)
+	if ttl <= 0 {
+		return
+	}
+	vary := varyHeaders(header)
+	if slices.Contains(vary, "*") {
+		return
+	}
+
+	e := entry{Status: rec.status, Header: header, Body: rec.body.Bytes(), Stored: now, Expires: now.Add(ttl)}
+	if len(vary) > 0 {
+		// The entry of the request only names the headers to vary on, and lives as long as the variant.
+		if err := m.store.Set(key, entry{Vary: vary, Stored: now, Expires: e.Expires}); err != nil {
+			log.Printf("Error caching response: %s: %v", key, err)
+			return
+		}
+		key = variantKey(key, vary, r.Header)
+	}
+	log.Printf("Caching response: %s for %s", key, ttl)
+	if err := m.store.Set(key, e); err != nil {
+		log.Printf("Error caching response: %s: %v", key, err)
+	}
+}
+
+// serveUnsafe serves a request that may change the resource and invalidates the responses stored for its URI
+// if it succeeded, as RFC 9111 section 4.4 requires.
+func (m *Middleware) serveUnsafe(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(rec, r)
+	if rec.status >= 400 {
+		return
+	}
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		key := method + " " + r.URL.RequestURI()
+		if err := m.store.Delete(key); err != nil {
+			log.Printf("Error invalidating cached response: %s: %v", key, err)
+		}
+	}
+}
+
+// varyHeaders returns the canonical names of the request headers listed in the Vary header of a response, sorted.
+func varyHeaders(h http.Header) []string {
+	var names []string
+	for _, line := range h.Values("Vary") {
+		for _, name := range strings.Split(line, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// variantKey returns the key of the variant of a response selected by the values of the vary headers of a request.
+func variantKey(key string, vary []string, h http.Header) string {
+	var b strings.Builder
+	b.WriteString(key)
+	for _, name := range vary {
+		b.WriteString("\n")
+		b.WriteString(name)
+		b.WriteString(": ")
+		b.WriteString(strings.Join(h.Values(name), ", "))
+	}
+	return b.String()
+}
+
+// recorder passes a response through to the client while recording its status and, up to limit bytes, its body.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	limit       int
+	body        bytes.Buffer
+	truncated   bool
+}
+
+// WriteHeader records the status of the response.
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body of the response, unless it exceeds the limit.
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	if !r.truncated {
+		if r.body.Len()+len(b) > r.limit {
+			r.truncated = true
+			r.body.Reset()
+		} else {
+			r.body.Write(b)
+		}
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying response writer, for http.ResponseController.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,8 @@
+// Package integration tests every policy against a real Redis, started in a container with testcontainers-go
+// unless -url names one, covering what miniredis does not emulate faithfully: the Lua scripts, RedisJSON, and
+// expirations driven by the server clock.
+//
+// The tests need Docker, so they are only built with the integration build tag:
+//
+//	go test -tags integration ./integration -args -image redis:8
+package integration
@@ -0,0 +1,87 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/workload"
+	"github.com/redis/go-redis/v9"
+	"github.com/testcontainers/testcontainers-go"
+	tcredis "github.com/testcontainers/testcontainers-go/modules/redis"
+)
+
+var (
+	url      = flag.String("url", "", "Redis connection URL, instead of starting a container")
+	image    = flag.String("image", "redis:8", "image of the Redis container")
+	policies = flag.String("policies", strings.Join(workload.Policies, ","), "comma-separated policies to test")
+)
+
+var (
+	// client is the client of the Redis the tests run against.
+	client redis.UniversalClient
+	// variants are the storage and eviction modes every policy is tested with.
+	variants []variant
+)
+
+// variant is a storage or eviction mode every policy is tested with.
+type variant struct {
+	name string
+	opts []cache.Option
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	os.Exit(run(m))
+}
+
+// run starts Redis unless -url names one, runs the tests and stops Redis. It returns the exit code of the tests.
+func run(m *testing.M) int {
+	ctx := context.Background()
+	if *url == "" {
+		container, err := tcredis.Run(ctx, *image)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := testcontainers.TerminateContainer(container); err != nil {
+				log.Printf("Error terminating container: %v", err)
+			}
+		}()
+		if *url, err = container.ConnectionString(ctx); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Started %s at %s", *image, *url)
+	}
+
+	opt, err := cache.ParseConnectionURL(*url)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client = redis.NewUniversalClient(opt)
+	defer client.Close()
+
+	variants = []variant{
+		{name: "string"},
+		{name: "hash", opts: []cache.Option{cache.WithHashStorage()}},
+		{name: "coordinated", opts: []cache.Option{cache.WithCoordinatedEviction()}},
+	}
+	if ok, err := cache.HasRedisJSON(ctx, client); err != nil {
+		log.Fatal(err)
+	} else if ok {
+		variants = append(variants, variant{name: "json", opts: []cache.Option{cache.WithRedisJSON()}})
+	} else {
+		log.Printf("RedisJSON is not available, skipping its storage")
+	}
+
+	// Silence the per-operation logs of the caches, leaving the results of the tests alone.
+	log.SetOutput(io.Discard)
+	return m.Run()
+}
@@ -0,0 +1,218 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/workload"
+	"github.com/redis/go-redis/v9"
+)
+
+// sizedCache is implemented by every cache type with a capacity.
+type sizedCache interface {
+	workload.Cache
+	CacheSize() int
+	Verify() (cache.Report, error)
+}
+
+// check is one test of a policy, run on a fresh namespace.
+type check struct {
+	name string
+	run  func(t *testing.T, ctx context.Context, policy, prefix string, opts []cache.Option)
+}
+
+var checks = []check{
+	{"round-trip", testRoundTrip},
+	{"capacity", testCapacity},
+	{"eviction-order", testEvictionOrder},
+	{"script-reload", testScriptReload},
+	{"expiration", testExpiration},
+}
+
+// TestPolicies runs every check of every policy with every variant, as subtests named policy/variant/check.
+func TestPolicies(t *testing.T) {
+	ctx := context.Background()
+	for _, policy := range strings.Split(*policies, ",") {
+		t.Run(policy, func(t *testing.T) {
+			for _, v := range variants {
+				t.Run(v.name, func(t *testing.T) {
+					for _, c := range checks {
+						t.Run(c.name, func(t *testing.T) {
+							prefix := fmt.Sprintf("integration_%s_%s", policy, v.name)
+							if _, err := cache.FlushNamespace(ctx, client, prefix, v.opts...); err != nil {
+								t.Fatalf("flushing namespace: %v", err)
+							}
+							c.run(t, ctx, policy, prefix, v.opts)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+// testRoundTrip checks that a user is read back as it was set, and that a missing user is a redis.Nil miss.
+func testRoundTrip(t *testing.T, ctx context.Context, policy, prefix string, opts []cache.Option) {
+	c, err := workload.NewCache(ctx, client, policy, 10, time.Minute, prefix, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := cache.User{Id: "1", Name: "Ada", Age: 36}
+	if err := c.Set(want); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	got, err := c.Get("1")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if _, err := c.Get("2"); !errors.Is(err, redis.Nil) {
+		t.Errorf("got %v for a missing user, want redis.Nil", err)
+	}
+}
+
+// testCapacity checks that the cache holds no more users than its capacity and that its index stays consistent.
+func testCapacity(t *testing.T, ctx context.Context, policy, prefix string, opts []cache.Option) {
+	c := newSizedCache(t, ctx, policy, 5, prefix, opts)
+	for i := 1; i <= 20; i++ {
+		if err := c.Set(user(i)); err != nil {
+			t.Fatalf("Set(%d): %v", i, err)
+		}
+	}
+	if size := c.CacheSize(); size != 5 {
+		t.Errorf("holds %d users, want 5", size)
+	}
+	verify(t, c)
+}
+
+// testEvictionOrder checks that the exact policies evict the user their policy names.
+func testEvictionOrder(t *testing.T, ctx context.Context, policy, prefix string, opts []cache.Option) {
+	// Each policy reads the users 1, 1 and 2 after caching users 1 to 3, then caches user 4.
+	var evicted int
+	switch policy {
+	case "fifo":
+		evicted = 1 // the first cached, whatever its reads
+	case "lru":
+		evicted = 3 // the least recently read
+	case "lfu":
+		evicted = 3 // the least frequently read
+	default:
+		t.Skipf("policy %s does not evict in an exact order", policy)
+	}
+
+	c := newSizedCache(t, ctx, policy, 3, prefix, opts)
+	for i := 1; i <= 3; i++ {
+		if err := c.Set(user(i)); err != nil {
+			t.Fatalf("Set(%d): %v", i, err)
+		}
+	}
+	for _, i := range []int{1, 1, 2} {
+		if _, err := c.Get(strconv.Itoa(i)); err != nil {
+			t.Fatalf("Get(%d): %v", i, err)
+		}
+	}
+	if err := c.Set(user(4)); err != nil {
+		t.Fatalf("Set(4): %v", err)
+	}
+
+	var missing []int
+	for i := 1; i <= 4; i++ {
+		if _, err := c.Get(strconv.Itoa(i)); errors.Is(err, redis.Nil) {
+			missing = append(missing, i)
+		} else if err != nil {
+			t.Fatalf("Get(%d): %v", i, err)
+		}
+	}
+	if !slices.Equal(missing, []int{evicted}) {
+		t.Errorf("evicted %v, want [%d]", missing, evicted)
+	}
+	verify(t, c)
+}
+
+// testScriptReload checks that the cache reloads its scripts after SCRIPT FLUSH, as after a restart or a failover
+// to a node that never loaded them.
+func testScriptReload(t *testing.T, ctx context.Context, policy, prefix string, opts []cache.Option) {
+	c, err := workload.NewCache(ctx, client, policy, 5, time.Minute, prefix, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(user(1)); err != nil {
+		t.Fatalf("Set(1): %v", err)
+	}
+	if err := client.ScriptFlush(ctx).Err(); err != nil {
+		t.Fatalf("SCRIPT FLUSH: %v", err)
+	}
+	for i := 2; i <= 10; i++ {
+		if err := c.Set(user(i)); err != nil {
+			t.Fatalf("Set(%d) after SCRIPT FLUSH: %v", i, err)
+		}
+	}
+	if _, err := c.Get("10"); err != nil {
+		t.Fatalf("Get(10): %v", err)
+	}
+	if c, ok := c.(sizedCache); ok {
+		verify(t, c)
+	}
+}
+
+// testExpiration checks that the users of the TTL cache expire on the server.
+func testExpiration(t *testing.T, ctx context.Context, policy, prefix string, opts []cache.Option) {
+	if policy != "ttl" {
+		t.Skipf("policy %s does not expire users", policy)
+	}
+	c, err := workload.NewCache(ctx, client, policy, 0, time.Second, prefix, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(user(1)); err != nil {
+		t.Fatalf("Set(1): %v", err)
+	}
+	if _, err := c.Get("1"); err != nil {
+		t.Fatalf("Get(1) before the expiration: %v", err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := c.Get("1"); !errors.Is(err, redis.Nil) {
+		t.Errorf("got %v after the expiration, want redis.Nil", err)
+	}
+}
+
+// newSizedCache creates a cache of a policy with a capacity, skipping the test for the TTL cache.
+func newSizedCache(t *testing.T, ctx context.Context, policy string, capacity int, prefix string, opts []cache.Option) sizedCache {
+	t.Helper()
+	if policy == "ttl" {
+		t.Skip("the ttl policy has no capacity")
+	}
+	c, err := workload.NewCache(ctx, client, policy, capacity, 0, prefix, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(sizedCache)
+}
+
+// verify fails the test if the index of a cache is inconsistent with its values.
+func verify(t *testing.T, c sizedCache) {
+	t.Helper()
+	report, err := c.Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !report.Consistent() {
+		t.Errorf("inconsistent index: %+v", report)
+	}
+}
+
+// user returns the user with id i.
+func user(i int) cache.User {
+	return cache.User{Id: strconv.Itoa(i), Name: "User " + strconv.Itoa(i), Age: 20 + i}
+}
@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// rateInterval is the range of the rates of the dashboards, chosen by Grafana from the scrape interval.
+const rateInterval = "$__rate_interval"
+
+// panel is a panel of a Grafana dashboard.
+type panel struct {
+	Type        string         `json:"type"`
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	GridPos     gridPos        `json:"gridPos"`
+	Datasource  *datasource    `json:"datasource,omitempty"`
+	Targets     []target       `json:"targets,omitempty"`
+	FieldConfig map[string]any `json:"fieldConfig,omitempty"`
+}
+
+// gridPos is the position and size of a panel on the grid of a dashboard, 24 columns wide.
+type gridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// datasource is the data source of a panel.
+type datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+// target is a query of a panel.
+type target struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+	RefID        string `json:"refId"`
+}
+
+// promDatasource is the Prometheus data source chosen with the datasource variable of the dashboards.
+var promDatasource = &datasource{Type: "prometheus", UID: "${datasource}"}
+
+// dashboardLayout places the panels of a dashboard on the grid, two side by side, under the rows they are added to.
+type dashboardLayout struct {
+	panels []panel
+	y      int
+	x      int
+}
+
+// row starts a new row.
+func (l *dashboardLayout) row(title string) {
+	if l.x > 0 {
+		l.x = 0
+		l.y += 8
+	}
+	l.panels = append(l.panels, panel{Type: "row", Title: title, GridPos: gridPos{X: 0, Y: l.y, W: 24, H: 1}})
+	l.y++
+}
+
+// add places a time series panel plotting expr, one line per legend.
+func (l *dashboardLayout) add(title, description, expr, legend, unit string) {
+	l.panels = append(l.panels, panel{
+		Type:        "timeseries",
+		Title:       title,
+		Description: description,
+		GridPos:     gridPos{X: l.x, Y: l.y, W: 12, H: 8},
+		Datasource:  promDatasource,
+		Targets:     []target{{Expr: expr, LegendFormat: legend, RefID: "A"}},
+		FieldConfig: map[string]any{"defaults": map[string]any{"unit": unit}, "overrides": []any{}},
+	})
+	l.x += 12
+	if l.x == 24 {
+		l.x = 0
+		l.y += 8
+	}
+}
+
+// selector selects the series of the caches chosen with the prefix variable of the dashboards.
+func selector(name string) string {
+	return fmt.Sprintf(`%s{%s=~"$prefix"}`, name, LabelPrefix)
+}
+
+// grafanaUnit returns the Grafana unit of a series.
+func grafanaUnit(s Series) string {
+	switch {
+	case s.Type == Counter:
+		return "ops"
+	case s.Unit == "seconds" && strings.HasSuffix(s.Name, "_timestamp_seconds"):
+		return "dateTimeAsIso"
+	case s.Unit == "seconds":
+		return "s"
+	case s.Unit == "bytes":
+		return "bytes"
+	case s.Unit == "ratio":
+		return "percentunit"
+	default:
+		return "short"
+	}
+}
+
+// seriesByField returns the name of the series read from field of cache.Stats.
+func seriesByField(field string) string {
+	for _, s := range series {
+		if s.Field == field {
+			return s.Name
+		}
+	}
+	panic("metrics: no series for field " + field + " of cache.Stats")
+}
+
+// Dashboard returns a Grafana dashboard of the series of the package as JSON, to be imported in Grafana.
+//
+// Its first row compares the eviction policies, with every cache of a policy aggregated: the hit ratio,
+// the eviction rate, the average residency of the evicted entries and how full the caches are. The second row
+// plots every series per cache, generated from the series of cache.Stats: counters as rates, summaries by their
+// 0.99 quantile, and gauges as they are. The data source and the prefixes of the caches shown are variables of
+// the dashboard.
+func Dashboard(title string) ([]byte, error) {
+	hits := seriesByField("Hits")
+	misses := seriesByField("Misses")
+	evictions := seriesByField("Evictions")
+	residency := seriesByField("AvgResidency")
+	items := seriesByField("Items")
+	capacity := seriesByField("Capacity")
+
+	rate := func(name string) string {
+		return fmt.Sprintf("sum by (%s) (rate(%s[%s]))", LabelPolicy, selector(name), rateInterval)
+	}
+	policy := "{{" + LabelPolicy + "}}"
+	perCache := "{{" + LabelPolicy + "}} {{" + LabelPrefix + "}}"
+
+	var l dashboardLayout
+	l.row("Policies")
+	l.add("Hit ratio", "Share of the reads that were hits, per eviction policy.",
+		fmt.Sprintf("%s / (%s + %s)", rate(hits), rate(hits), rate(misses)), policy, "percentunit")
+	l.add("Eviction rate", "Entries evicted per second to make room for others, per eviction policy.",
+		rate(evictions), policy, "ops")
+	l.add("Residency", "Average time the evicted entries were cached, per eviction policy. Only tracked with cache.WithEntryInfo or cache.WithEvictionAudit.",
+		fmt.Sprintf("avg by (%s) (%s > 0)", LabelPolicy, selector(residency)), policy, "s")
+	l.add("Fill", "Entries in the caches over their capacity, per eviction policy.",
+		fmt.Sprintf("sum by (%s) (%s) / sum by (%s) (%s > 0)", LabelPolicy, selector(items), LabelPolicy, selector(capacity)), policy, "percentunit")
+
+	l.row("Caches")
+	for _, s := range series {
+		switch s.Type {
+		case Counter:
+			l.add(s.Field+" rate", "Per second rate of "+s.Name+".",
+				fmt.Sprintf("rate(%s[%s])", selector(s.Name), rateInterval), perCache, grafanaUnit(s))
+		case Summary:
+			l.add(s.Field+" p99", "0.99 quantile of "+s.Name+".",
+				fmt.Sprintf(`%s{%s=~"$prefix",quantile="0.99"}`, s.Name, LabelPrefix), perCache, grafanaUnit(s))
+		default:
+			l.add(s.Field, s.Name+".", selector(s.Name), perCache, grafanaUnit(s))
+		}
+	}
+
+	dashboard := map[string]any{
+		"title":         title,
+		"uid":           Namespace + "-" + strings.ToLower(strings.Join(strings.Fields(title), "-")),
+		"schemaVersion": 39,
+		"editable":      true,
+		"refresh":       "10s",
+		"time":          map[string]string{"from": "now-1h", "to": "now"},
+		"tags":          []string{Namespace},
+		"templating": map[string]any{
+			"list": []any{
+				map[string]any{
+					"name":  "datasource",
+					"label": "Data source",
+					"type":  "datasource",
+					"query": "prometheus",
+				},
+				map[string]any{
+					"name":       "prefix",
+					"label":      "Cache",
+					"type":       "query",
+					"datasource": promDatasource,
+					"query":      fmt.Sprintf("label_values(%s, %s)", UpSeries, LabelPrefix),
+					"refresh":    2,
+					"multi":      true,
+					"includeAll": true,
+					"allValue":   ".*",
+					"current":    map[string]any{"text": "All", "value": "$__all"},
+				},
+			},
+		},
+		"panels": l.panels,
+	}
+	return json.MarshalIndent(dashboard, "", "  ")
+}
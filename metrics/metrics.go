@@ -0,0 +1,267 @@
+// Package metrics exports the Stats of caches in the Prometheus text format, with a naming scheme designed
+// for dashboards comparing caches side by side.
+//
+// Every field of cache.Stats is exported as a series named cache_<name>, where the name is given by the metric
+// tag of the field, or else its JSON name, with a _seconds suffix for durations. Counters end in _total,
+// durations and times are in seconds, and latencies are summaries with the quantiles 0.5, 0.95 and 0.99.
+// Every series carries the labels policy and prefix of the cache it describes, so the series of one algorithm
+// are aggregated with "sum by (policy)", and cache_up tells whether the Stats of a cache could be collected.
+//
+// The series are generated from the fields of cache.Stats, so a field added there is exported without any change
+// here, and so are the panels of the Grafana dashboards built by Dashboard.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+)
+
+// Namespace is the prefix of the names of every series.
+const Namespace = "cache"
+
+// Labels of every series.
+const (
+	// LabelPolicy is the eviction policy of the cache: "fifo", "lru", "lfu", "approx-lru" or "ttl".
+	LabelPolicy = "policy"
+	// LabelPrefix is the key prefix of the cache.
+	LabelPrefix = "prefix"
+)
+
+// Types of series.
+const (
+	Counter = "counter"
+	Gauge   = "gauge"
+	Summary = "summary"
+)
+
+// UpSeries is the name of the series set to 1 for the caches whose Stats were collected, and to 0 for the others.
+const UpSeries = Namespace + "_up"
+
+// quantiles are the quantiles of the summaries of latencies, with the fields of cache.Latency they are read from.
+var quantiles = []struct {
+	quantile string
+	value    func(cache.Latency) time.Duration
+}{
+	{"0.5", func(l cache.Latency) time.Duration { return l.P50 }},
+	{"0.95", func(l cache.Latency) time.Duration { return l.P95 }},
+	{"0.99", func(l cache.Latency) time.Duration { return l.P99 }},
+}
+
+// Series is a series exported for a field of cache.Stats.
+type Series struct {
+	// Name is the full name of the series, such as cache_hits_total.
+	Name string
+	// Field is the name of the field of cache.Stats the series is read from.
+	Field string
+	// Type is Counter, Gauge or Summary.
+	Type string
+	// Unit is the unit of the series: "seconds", "bytes", "ratio", or empty for a count.
+	Unit string
+
+	index []int
+}
+
+// value reads the series from stats. Summaries are read with latency instead.
+func (s Series) value(stats cache.Stats) float64 {
+	field := reflect.ValueOf(stats).FieldByIndex(s.index)
+	switch v := field.Interface().(type) {
+	case time.Duration:
+		return seconds(v)
+	case time.Time:
+		if v.IsZero() {
+			return 0
+		}
+		return float64(v.UnixNano()) / float64(time.Second)
+	}
+	if field.CanInt() {
+		return float64(field.Int())
+	}
+	return field.Float()
+}
+
+// latency reads a summary from stats.
+func (s Series) latency(stats cache.Stats) cache.Latency {
+	return reflect.ValueOf(stats).FieldByIndex(s.index).Interface().(cache.Latency)
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+	latencyType  = reflect.TypeOf(cache.Latency{})
+)
+
+// series are the series of every cache, generated from the fields of cache.Stats.
+var series = generateSeries(reflect.TypeOf(cache.Stats{}))
+
+// AllSeries returns the series exported for every cache, in the order of the fields of cache.Stats.
+// It does not include UpSeries.
+func AllSeries() []Series {
+	return append([]Series(nil), series...)
+}
+
+// generateSeries returns a series for every exported field of stats without a metric tag of "-".
+// A metric tag of "name" or "name,type" sets the name of the series and its type, Gauge by default.
+func generateSeries(stats reflect.Type) []Series {
+	var all []Series
+	for _, field := range reflect.VisibleFields(stats) {
+		tag := field.Tag.Get("metric")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+
+		name, kind, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name, _, _ = strings.Cut(field.Tag.Get("json"), ",")
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		if kind == "" {
+			kind = Gauge
+		}
+
+		s := Series{Field: field.Name, Type: kind, index: field.Index}
+		switch {
+		case field.Type == latencyType:
+			s.Type = Summary
+			s.Unit = "seconds"
+		case field.Type == durationType || field.Type == timeType:
+			s.Unit = "seconds"
+		case strings.Contains(name, "bytes"):
+			s.Unit = "bytes"
+		case strings.Contains(name, "ratio"):
+			s.Unit = "ratio"
+		case field.Type.Kind() == reflect.Int, field.Type.Kind() == reflect.Int64, field.Type.Kind() == reflect.Float64:
+		default:
+			log.Printf("Not exporting field: %s of type: %s of cache.Stats", field.Name, field.Type)
+			continue
+		}
+		if s.Unit == "seconds" && !strings.HasSuffix(name, "_seconds") {
+			name += "_seconds"
+		}
+		s.Name = Namespace + "_" + name
+		all = append(all, s)
+	}
+	return all
+}
+
+// Source is a cache whose Stats are exported. Every cache type implements it, as does workload.Cache.
+type Source interface {
+	Stats() (cache.Stats, error)
+}
+
+// registered is a cache registered with a Registry.
+type registered struct {
+	policy string
+	prefix string
+	source Source
+}
+
+// Registry collects the Stats of the caches registered with it and serves them in the Prometheus text format.
+type Registry struct {
+	mu     sync.Mutex
+	caches []registered
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a cache of the given policy and key prefix, whose Stats are collected on every scrape.
+func (r *Registry) Register(policy, prefix string, source Source) {
+	log.Printf("Registering metrics of %s cache: %s", policy, prefix)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.caches = append(r.caches, registered{policy: policy, prefix: prefix, source: source})
+}
+
+// ServeHTTP serves the series of the registered caches, as Prometheus scrapes them.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := r.Write(w); err != nil {
+		log.Printf("Error writing metrics: %v", err)
+	}
+}
+
+// Write writes the series of the registered caches to w in the Prometheus text format. A cache whose Stats
+// cannot be collected is logged and only reported by UpSeries.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	caches := append([]registered(nil), r.caches...)
+	r.mu.Unlock()
+
+	type collected struct {
+		labels string
+		stats  cache.Stats
+	}
+	var ok []collected
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "# HELP %s Whether the stats of the cache were collected.\n# TYPE %s gauge\n", UpSeries, UpSeries)
+	for _, c := range caches {
+		labels := fmt.Sprintf(`%s="%s",%s="%s"`, LabelPolicy, escapeLabel(c.policy), LabelPrefix, escapeLabel(c.prefix))
+		stats, err := c.source.Stats()
+		if err != nil {
+			log.Printf("Error collecting stats of %s cache: %s: %v", c.policy, c.prefix, err)
+			fmt.Fprintf(out, "%s{%s} 0\n", UpSeries, labels)
+			continue
+		}
+		fmt.Fprintf(out, "%s{%s} 1\n", UpSeries, labels)
+		ok = append(ok, collected{labels: labels, stats: stats})
+	}
+
+	for _, s := range series {
+		fmt.Fprintf(out, "# HELP %s The %s field of cache.Stats.\n# TYPE %s %s\n", s.Name, s.Field, s.Name, s.Type)
+		for _, c := range ok {
+			if s.Type != Summary {
+				fmt.Fprintf(out, "%s{%s} %s\n", s.Name, c.labels, formatValue(s.value(c.stats)))
+				continue
+			}
+			latency := s.latency(c.stats)
+			for _, q := range quantiles {
+				fmt.Fprintf(out, "%s{%s,quantile=\"%s\"} %s\n", s.Name, c.labels, q.quantile, formatValue(seconds(q.value(latency))))
+			}
+			fmt.Fprintf(out, "%s_count{%s} %d\n", s.Name, c.labels, latency.Count)
+		}
+	}
+	return out.Flush()
+}
+
+// labelEscaper escapes label values as the Prometheus text format requires.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value.
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// seconds converts d to seconds. Latencies above every bucket of the histograms are reported as infinite.
+func seconds(d time.Duration) float64 {
+	if d == math.MaxInt64 {
+		return math.Inf(1)
+	}
+	return d.Seconds()
+}
+
+// formatValue formats a sample value as Prometheus reads it.
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,269 @@
+// Package mockdb is an in-memory stand-in for the database behind a cache, with configurable per-call
+// latency, jitter and error rate, so demos can show the benefit of caching a slow source of truth and
+// how a cache behaves when it fails. Its Load method is a cache.Loader, and its LoadUsers method is
+// a cache.BatchLoader.
+package mockdb
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+)
+
+// ErrNotFound is returned by Load for a user that is not in the database.
+var ErrNotFound = errors.New("mockdb: user not found")
+
+// ErrUnavailable is returned by Load for the calls failed by the error rate.
+var ErrUnavailable = errors.New("mockdb: database unavailable")
+
+// DB is an in-memory database of users. It is safe for concurrent use.
+type DB struct {
+	mu        sync.Mutex
+	users     map[string]cache.User
+	latency   time.Duration
+	jitter    time.Duration
+	errorRate float64
+	rng       *rand.Rand
+
+	calls    atomic.Int64
+	failures atomic.Int64
+}
+
+// Option configures a DB.
+type Option func(*DB)
+
+// WithLatency makes every call take at least latency.
+func WithLatency(latency time.Duration) Option {
+	return func(db *DB) {
+		db.latency = latency
+	}
+}
+
+// WithJitter adds a uniformly random delay between 0 and jitter to every call.
+func WithJitter(jitter time.Duration) Option {
+	return func(db *DB) {
+		db.jitter = jitter
+	}
+}
+
+// WithErrorRate makes calls fail with ErrUnavailable with probability rate, between 0 and 1.
+// Failed calls take the same time as successful ones.
+func WithErrorRate(rate float64) Option {
+	return func(db *DB) {
+		db.errorRate = rate
+	}
+}
+
+// WithSeed seeds the jitter and the failures, so runs can be replayed.
+func WithSeed(seed int64) Option {
+	return func(db *DB) {
+		db.rng = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithUsers replaces the users of the database.
+func WithUsers(users ...cache.User) Option {
+	return func(db *DB) {
+		db.users = make(map[string]cache.User, len(users))
+		for _, user := range users {
+			db.users[user.Id] = user
+		}
+	}
+}
+
+// WithGeneratedUsers adds n users with the ids "0" to n-1, matching the keys of the workload package.
+func WithGeneratedUsers(n int) Option {
+	return func(db *DB) {
+		for i := range n {
+			id := strconv.Itoa(i)
+			db.users[id] = cache.User{Id: id, Name: "user " + id, Age: 18 + i%60}
+		}
+	}
+}
+
+// New creates a database holding the demo users of the cache package, unless configured otherwise.
+// By default, calls take no time and never fail.
+func New(opts ...Option) *DB {
+	db := &DB{rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	WithUsers(cache.DemoUsers()...)(db)
+	for _, opt := range opts {
+		opt(db)
+	}
+	return db
+}
+
+// Load returns the user with id, after the configured latency and jitter. It fails with ErrUnavailable
+// at the configured error rate, with ErrNotFound if the user does not exist, and with the error of ctx
+// if it is done before the call completes. Load is a cache.Loader.
+func (db *DB) Load(ctx context.Context, id string) (cache.User, error) {
+	db.calls.Add(1)
+
+	db.mu.Lock()
+	delay := db.latency
+	if db.jitter > 0 {
+		delay += time.Duration(db.rng.Int63n(int64(db.jitter)))
+	}
+	fail := db.errorRate > 0 && db.rng.Float64() < db.errorRate
+	user, found := db.users[id]
+	db.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			db.failures.Add(1)
+			return cache.User{}, ctx.Err()
+		}
+	}
+
+	switch {
+	case fail:
+		db.failures.Add(1)
+		return cache.User{}, ErrUnavailable
+	case !found:
+		return cache.User{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	default:
+		return user, nil
+	}
+}
+
+// LoadUsers returns the users of ids that exist, by ID, in a single call taking the configured latency and jitter
+// once. It fails like Load, and counts as one call. LoadUsers is a cache.BatchLoader.
+func (db *DB) LoadUsers(ctx context.Context, ids []string) (map[string]cache.User, error) {
+	db.calls.Add(1)
+
+	db.mu.Lock()
+	delay := db.latency
+	if db.jitter > 0 {
+		delay += time.Duration(db.rng.Int63n(int64(db.jitter)))
+	}
+	fail := db.errorRate > 0 && db.rng.Float64() < db.errorRate
+	users := make(map[string]cache.User, len(ids))
+	for _, id := range ids {
+		if user, found := db.users[id]; found {
+			users[id] = user
+		}
+	}
+	db.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			db.failures.Add(1)
+			return nil, ctx.Err()
+		}
+	}
+	if fail {
+		db.failures.Add(1)
+		return nil, ErrUnavailable
+	}
+	return users, nil
+}
+
+// Put adds or replaces a user.
+func (db *DB) Put(user cache.User) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.users[user.Id] = user
+}
+
+// SaveUsers adds or replaces users, after the configured latency and jitter. It fails with ErrUnavailable
+// at the configured error rate, saving none of them, and with the error of ctx if it is done before the call
+// completes. With SaveUsers, a DB is a cache.Store for write-behind caches.
+func (db *DB) SaveUsers(ctx context.Context, users []cache.User) error {
+	db.mu.Lock()
+	delay := db.latency
+	if db.jitter > 0 {
+		delay += time.Duration(db.rng.Int63n(int64(db.jitter)))
+	}
+	fail := db.errorRate > 0 && db.rng.Float64() < db.errorRate
+	db.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if fail {
+		return ErrUnavailable
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, user := range users {
+		db.users[user.Id] = user
+	}
+	return nil
+}
+
+// Delete removes a user.
+func (db *DB) Delete(id string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	delete(db.users, id)
+}
+
+// ListIDs returns the ids of up to limit users, or of every user if limit is 0, shortest first and then
+// alphabetically, so generated ids come in numeric order.
+// It takes no time and never fails. With ListIDs, a DB is a cache.IDLister to warm caches with.
+func (db *DB) ListIDs(ctx context.Context, limit int) ([]string, error) {
+	db.mu.Lock()
+	ids := make([]string, 0, len(db.users))
+	for id := range db.users {
+		ids = append(ids, id)
+	}
+	db.mu.Unlock()
+
+	slices.SortFunc(ids, func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(a), len(b)), strings.Compare(a, b))
+	})
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+// SetLatency changes the latency and jitter of the calls made from now on, e.g. to simulate a slowdown.
+func (db *DB) SetLatency(latency, jitter time.Duration) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.latency = latency
+	db.jitter = jitter
+}
+
+// SetErrorRate changes the error rate of the calls made from now on, e.g. to simulate an outage with 1.
+func (db *DB) SetErrorRate(rate float64) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.errorRate = rate
+}
+
+// Calls returns the number of calls of Load and LoadUsers.
+func (db *DB) Calls() int64 {
+	return db.calls.Load()
+}
+
+// Failures returns the number of calls of Load and LoadUsers that failed with ErrUnavailable or because their context was done.
+// Missing users are not failures.
+func (db *DB) Failures() int64 {
+	return db.failures.Load()
+}
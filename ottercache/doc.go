@@ -0,0 +1,3 @@
+// Package ottercache adapts an otter cache to the cache.LocalCache interface, so it can be the L1 tier
+// of a cache.TieredCache with its S3-FIFO eviction policy.
+package ottercache
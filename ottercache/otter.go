@@ -0,0 +1,69 @@
+package ottercache
+
+import (
+	"log"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/maypok86/otter"
+)
+
+// Cache is a cache.LocalCache backed by otter, holding up to its capacity of users with the S3-FIFO policy,
+// which keeps the users read more than once in its main queue and evicts the ones read once first.
+type Cache struct {
+	cache otter.Cache[string, cache.User]
+}
+
+// New creates a Cache holding up to capacity users, whose entries expire ttl after they are set, or never if ttl is 0.
+func New(capacity int, ttl time.Duration) (*Cache, error) {
+	builder := otter.MustBuilder[string, cache.User](capacity).CollectStats()
+
+	var c otter.Cache[string, cache.User]
+	var err error
+	if ttl > 0 {
+		c, err = builder.WithTTL(ttl).Build()
+	} else {
+		c, err = builder.Build()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{cache: c}, nil
+}
+
+// Get returns the user cached under key, if any.
+func (c *Cache) Get(key string) (cache.User, bool) {
+	return c.cache.Get(key)
+}
+
+// Set caches user under key, unless otter rejects it.
+func (c *Cache) Set(key string, user cache.User) {
+	if !c.cache.Set(key, user) {
+		log.Printf("Write of key: %s rejected by otter", key)
+	}
+}
+
+// Delete removes the user cached under key, if any.
+func (c *Cache) Delete(key string) {
+	c.cache.Delete(key)
+}
+
+// Len returns the number of users cached.
+func (c *Cache) Len() int {
+	return c.cache.Size()
+}
+
+// Evictions returns the number of users evicted to make room for others.
+func (c *Cache) Evictions() int64 {
+	return c.cache.Stats().EvictedCount()
+}
+
+// Rejections returns the number of users otter rejected.
+func (c *Cache) Rejections() int64 {
+	return c.cache.Stats().RejectedSets()
+}
+
+// Close stops the goroutines of the cache.
+func (c *Cache) Close() {
+	c.cache.Close()
+}
@@ -0,0 +1,3 @@
+// Package ristrettocache adapts a ristretto cache to the cache.LocalCache interface, so it can be the L1 tier
+// of a cache.TieredCache with its TinyLFU admission policy and cost-based eviction.
+package ristrettocache
@@ -0,0 +1,74 @@
+package ristrettocache
+
+import (
+	"log"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/dgraph-io/ristretto/v2"
+)
+
+// Cache is a cache.LocalCache backed by ristretto. Every user costs 1, so the cache holds up to its maximum
+// number of users, and its TinyLFU policy may reject a user rather than evict a more frequently used one.
+//
+// Ristretto applies writes asynchronously: a user set may not be readable at once, and may be dropped under
+// contention. A TieredCache then reads it from Redis again, which only costs the hit.
+type Cache struct {
+	cache *ristretto.Cache[string, cache.User]
+	ttl   time.Duration
+}
+
+// New creates a Cache holding up to maxItems users, whose entries expire ttl after they are set, or never if ttl is 0.
+func New(maxItems int64, ttl time.Duration) (*Cache, error) {
+	c, err := ristretto.NewCache(&ristretto.Config[string, cache.User]{
+		// Ristretto recommends ten frequency counters per item.
+		NumCounters: 10 * maxItems,
+		MaxCost:     maxItems,
+		BufferItems: 64,
+		Metrics:     true,
+		// Without it, ristretto adds the size of its own bookkeeping to the cost of 1 of every user.
+		IgnoreInternalCost: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{cache: c, ttl: ttl}, nil
+}
+
+// Get returns the user cached under key, if any.
+func (c *Cache) Get(key string) (cache.User, bool) {
+	return c.cache.Get(key)
+}
+
+// Set caches user under key, unless the admission policy rejects it or the write is dropped.
+func (c *Cache) Set(key string, user cache.User) {
+	if !c.cache.SetWithTTL(key, user, 1, c.ttl) {
+		log.Printf("Write of key: %s dropped by ristretto", key)
+	}
+}
+
+// Delete removes the user cached under key, if any.
+func (c *Cache) Delete(key string) {
+	c.cache.Del(key)
+}
+
+// Len returns the number of users cached, as the users added minus the users evicted. It counts users deleted
+// or expired since, so it is an upper bound.
+func (c *Cache) Len() int {
+	return int(c.cache.Metrics.KeysAdded() - c.cache.Metrics.KeysEvicted())
+}
+
+// Evictions returns the number of users evicted to make room for others.
+func (c *Cache) Evictions() int64 {
+	return int64(c.cache.Metrics.KeysEvicted())
+}
+
+// Rejections returns the number of users rejected by the admission policy or dropped under contention.
+func (c *Cache) Rejections() int64 {
+	return int64(c.cache.Metrics.SetsRejected() + c.cache.Metrics.SetsDropped())
+}
+
+// Close stops the goroutines of the cache.
+func (c *Cache) Close() {
+	c.cache.Close()
+}
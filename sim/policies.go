@@ -0,0 +1,187 @@
+package sim
+
+import (
+	"container/heap"
+	"container/list"
+	"math/rand"
+)
+
+// fifoPolicy evicts keys in the order they were admitted. Writes and hits do not change the order.
+type fifoPolicy struct {
+	queue    *list.List
+	elements map[string]*list.Element
+}
+
+func newFIFOPolicy() *fifoPolicy {
+	return &fifoPolicy{queue: list.New(), elements: make(map[string]*list.Element)}
+}
+
+func (p *fifoPolicy) admit(key string) {
+	p.elements[key] = p.queue.PushBack(key)
+}
+
+func (p *fifoPolicy) access(key string) {}
+
+func (p *fifoPolicy) update(key string) {}
+
+func (p *fifoPolicy) victim() string {
+	return p.queue.Front().Value.(string)
+}
+
+func (p *fifoPolicy) remove(key string) {
+	if e, ok := p.elements[key]; ok {
+		p.queue.Remove(e)
+		delete(p.elements, key)
+	}
+}
+
+// lruPolicy evicts the least recently read or written key.
+type lruPolicy struct {
+	fifoPolicy
+}
+
+func newLRUPolicy() *lruPolicy {
+	return &lruPolicy{fifoPolicy: *newFIFOPolicy()}
+}
+
+func (p *lruPolicy) access(key string) {
+	p.queue.MoveToBack(p.elements[key])
+}
+
+func (p *lruPolicy) update(key string) {
+	p.access(key)
+}
+
+// lfuEntry is a key of the LFU heap with its frequency.
+type lfuEntry struct {
+	key       string
+	frequency int64
+	index     int
+}
+
+// lfuHeap orders keys by frequency, then lexicographically, like a Redis sorted set.
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].frequency != h[j].frequency {
+		return h[i].frequency < h[j].frequency
+	}
+	return h[i].key < h[j].key
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	e := x.(*lfuEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// lfuPolicy evicts the least frequently read key. Admission counts as the first access,
+// and writes of a cached key preserve its frequency.
+type lfuPolicy struct {
+	heap    lfuHeap
+	entries map[string]*lfuEntry
+}
+
+func newLFUPolicy() *lfuPolicy {
+	return &lfuPolicy{entries: make(map[string]*lfuEntry)}
+}
+
+func (p *lfuPolicy) admit(key string) {
+	e := &lfuEntry{key: key, frequency: 1}
+	p.entries[key] = e
+	heap.Push(&p.heap, e)
+}
+
+func (p *lfuPolicy) access(key string) {
+	e := p.entries[key]
+	e.frequency++
+	heap.Fix(&p.heap, e.index)
+}
+
+func (p *lfuPolicy) update(key string) {}
+
+func (p *lfuPolicy) victim() string {
+	return p.heap[0].key
+}
+
+func (p *lfuPolicy) remove(key string) {
+	if e, ok := p.entries[key]; ok {
+		heap.Remove(&p.heap, e.index)
+		delete(p.entries, key)
+	}
+}
+
+// approxLRUPolicy evicts the least recently used of a random sample of keys. Access times are
+// logical ticks rather than clock readings, so simulations are deterministic for a given seed.
+type approxLRUPolicy struct {
+	sampleSize int
+	rng        *rand.Rand
+	tick       int64
+	keys       []string
+	indexes    map[string]int
+	accessedAt map[string]int64
+}
+
+func newApproxLRUPolicy(sampleSize int, seed int64) *approxLRUPolicy {
+	return &approxLRUPolicy{
+		sampleSize: sampleSize,
+		rng:        rand.New(rand.NewSource(seed)),
+		indexes:    make(map[string]int),
+		accessedAt: make(map[string]int64),
+	}
+}
+
+func (p *approxLRUPolicy) admit(key string) {
+	p.indexes[key] = len(p.keys)
+	p.keys = append(p.keys, key)
+	p.access(key)
+}
+
+func (p *approxLRUPolicy) access(key string) {
+	p.tick++
+	p.accessedAt[key] = p.tick
+}
+
+func (p *approxLRUPolicy) update(key string) {
+	p.access(key)
+}
+
+// victim samples up to sampleSize distinct keys, as HRANDFIELD does, and returns the least recently used.
+func (p *approxLRUPolicy) victim() string {
+	victim := ""
+	for _, i := range p.rng.Perm(len(p.keys))[:min(p.sampleSize, len(p.keys))] {
+		key := p.keys[i]
+		if victim == "" || p.accessedAt[key] < p.accessedAt[victim] {
+			victim = key
+		}
+	}
+	return victim
+}
+
+func (p *approxLRUPolicy) remove(key string) {
+	i, ok := p.indexes[key]
+	if !ok {
+		return
+	}
+	last := p.keys[len(p.keys)-1]
+	p.keys[i] = last
+	p.indexes[last] = i
+	p.keys = p.keys[:len(p.keys)-1]
+	delete(p.indexes, key)
+	delete(p.accessedAt, key)
+}
@@ -0,0 +1,220 @@
+// Package sim simulates the eviction policies of the cache package in process, without Redis,
+// so their behavior and hit ratios can be explored quickly on large workloads. The Redis-backed
+// caches remain the reference: the simulated caches follow their admission and eviction rules,
+// but none of their other features.
+package sim
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/workload"
+	"github.com/redis/go-redis/v9"
+)
+
+// policy orders the keys of a simulated cache for eviction.
+type policy interface {
+	// admit adds a key that was not cached.
+	admit(key string)
+	// access records a hit on a cached key.
+	access(key string)
+	// update records a write of a cached key.
+	update(key string)
+	// victim returns the key to evict.
+	victim() string
+	// remove removes a cached key.
+	remove(key string)
+}
+
+// Cache is a simulated cache. It implements workload.Cache, and like the Redis-backed caches,
+// Get returns redis.Nil on a miss. It is safe for concurrent use.
+type Cache struct {
+	mu         sync.Mutex
+	capacity   int
+	policy     policy
+	values     map[string]cache.User
+	expiration time.Duration
+	expiresAt  map[string]time.Time
+	clock      cache.Clock
+	hits       int64
+	misses     int64
+	sets       int64
+	evictions  int64
+}
+
+// NewFIFO creates a simulated FIFO cache with the given capacity.
+func NewFIFO(capacity int) *Cache {
+	return newCache(capacity, newFIFOPolicy())
+}
+
+// NewLRU creates a simulated LRU cache with the given capacity.
+func NewLRU(capacity int) *Cache {
+	return newCache(capacity, newLRUPolicy())
+}
+
+// NewLFU creates a simulated LFU cache with the given capacity. As in Redis, ties between
+// keys with the same frequency are broken by evicting the lexicographically smallest key.
+func NewLFU(capacity int) *Cache {
+	return newCache(capacity, newLFUPolicy())
+}
+
+// NewApproxLRU creates a simulated approximated LRU cache with the given capacity, which evicts
+// the least recently used of sampleSize random keys. The sampling is seeded with seed, so runs can be replayed.
+// A sampleSize of 0 defaults to 5, as in the Redis-backed cache.
+func NewApproxLRU(capacity, sampleSize int, seed int64) *Cache {
+	if sampleSize <= 0 {
+		sampleSize = 5
+	}
+	return newCache(capacity, newApproxLRUPolicy(sampleSize, seed))
+}
+
+// NewTTL creates a simulated TTL cache, whose entries expire after expiration on clock and are never evicted.
+// A nil clock is the system clock.
+func NewTTL(expiration time.Duration, clock cache.Clock) *Cache {
+	c := newCache(0, nil)
+	c.expiration = expiration
+	c.expiresAt = make(map[string]time.Time)
+	c.clock = clock
+	return c
+}
+
+// New creates a simulated cache with the given policy, one of workload.Policies. The capacity is ignored
+// by the TTL cache, the expiration only applies to it, and the seed only applies to the approximated LRU.
+func New(policy string, capacity int, expiration time.Duration, seed int64) (workload.Cache, error) {
+	switch policy {
+	case "fifo":
+		return NewFIFO(capacity), nil
+	case "lru":
+		return NewLRU(capacity), nil
+	case "lfu":
+		return NewLFU(capacity), nil
+	case "approx-lru":
+		return NewApproxLRU(capacity, 0, seed), nil
+	case "ttl":
+		return NewTTL(expiration, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown policy: %s", policy)
+	}
+}
+
+// newCache creates a simulated cache ordered by policy.
+func newCache(capacity int, policy policy) *Cache {
+	return &Cache{
+		capacity: capacity,
+		policy:   policy,
+		values:   make(map[string]cache.User),
+	}
+}
+
+// Get returns the cached user with id, or redis.Nil if it is not cached.
+func (c *Cache) Get(id string) (cache.User, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	user, ok := c.values[id]
+	if ok && c.expired(id) {
+		c.delete(id)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return cache.User{}, redis.Nil
+	}
+
+	c.hits++
+	if c.policy != nil {
+		c.policy.access(id)
+	}
+	return user, nil
+}
+
+// Set caches a user, evicting according to the policy if the cache is full.
+func (c *Cache) Set(user cache.User) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sets++
+	if _, ok := c.values[user.Id]; ok && !c.expired(user.Id) {
+		c.values[user.Id] = user
+		c.expire(user.Id)
+		if c.policy != nil {
+			c.policy.update(user.Id)
+		}
+		return nil
+	}
+	c.delete(user.Id)
+
+	if c.policy != nil {
+		for c.capacity > 0 && len(c.values) >= c.capacity {
+			c.delete(c.policy.victim())
+			c.evictions++
+		}
+		c.policy.admit(user.Id)
+	}
+	c.values[user.Id] = user
+	c.expire(user.Id)
+	return nil
+}
+
+// Delete removes a user from the cache.
+func (c *Cache) Delete(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delete(id)
+}
+
+// CacheSize returns the number of users in the cache, including expired ones not read since.
+func (c *Cache) CacheSize() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.values)
+}
+
+// Stats returns the size, capacity and counters of the cache. The other fields are always zero.
+func (c *Cache) Stats() (cache.Stats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return cache.Stats{
+		Items:     len(c.values),
+		Capacity:  c.capacity,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Sets:      c.sets,
+		Evictions: c.evictions,
+	}, nil
+}
+
+// delete removes a key from the values and the policy, if it is cached.
+func (c *Cache) delete(id string) {
+	if _, ok := c.values[id]; !ok {
+		return
+	}
+	delete(c.values, id)
+	delete(c.expiresAt, id)
+	if c.policy != nil {
+		c.policy.remove(id)
+	}
+}
+
+// expire sets the expiration of a key written now, if the cache expires its entries.
+func (c *Cache) expire(id string) {
+	if c.expiresAt != nil {
+		c.expiresAt[id] = c.now().Add(c.expiration)
+	}
+}
+
+// expired reports whether a cached key has expired.
+func (c *Cache) expired(id string) bool {
+	expiresAt, ok := c.expiresAt[id]
+	return ok && !c.now().Before(expiresAt)
+}
+
+// now returns the current time of the clock of the cache.
+func (c *Cache) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
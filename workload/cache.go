@@ -0,0 +1,43 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+)
+
+// Cache is implemented by every cache type.
+type Cache interface {
+	Get(id string) (cache.User, error)
+	Set(user cache.User) error
+	Stats() (cache.Stats, error)
+}
+
+// Policies lists the policies accepted by NewCache.
+var Policies = []string{"fifo", "lru", "lfu", "approx-lru", "ttl"}
+
+// NewCache creates a cache with the given policy, one of Policies. The capacity is ignored by the TTL cache,
+// and the expiration only applies to it.
+func NewCache(ctx context.Context, client cache.Client, policy string, capacity int, expiration time.Duration, keyPrefix string, opts ...cache.Option) (Cache, error) {
+	switch policy {
+	case "fifo":
+		c := cache.NewFIFO(ctx, client, capacity, keyPrefix, opts...)
+		return &c, nil
+	case "lru":
+		c := cache.NewLRU(ctx, client, capacity, keyPrefix, opts...)
+		return &c, nil
+	case "lfu":
+		c := cache.NewLFU(ctx, client, capacity, keyPrefix, opts...)
+		return &c, nil
+	case "approx-lru":
+		c := cache.NewApproxLRU(ctx, client, capacity, 0, keyPrefix, opts...)
+		return &c, nil
+	case "ttl":
+		c := cache.NewTTL(ctx, client, expiration, keyPrefix, opts...)
+		return &c, nil
+	default:
+		return nil, fmt.Errorf("unknown policy: %s", policy)
+	}
+}
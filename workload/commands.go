@@ -0,0 +1,70 @@
+package workload
+
+import (
+	"context"
+	"maps"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CommandCounter is a go-redis hook counting the commands a client sends to Redis,
+// including the commands of pipelines and transactions. Add it with client.AddHook.
+type CommandCounter struct {
+	commands atomic.Int64
+
+	mu     sync.Mutex
+	byName map[string]int64
+}
+
+// Commands returns the number of commands sent since the counter was added.
+func (c *CommandCounter) Commands() int64 {
+	return c.commands.Load()
+}
+
+// Counts returns the number of commands sent since the counter was added by lowercase command name.
+// A script counts as one EVALSHA or EVAL, whatever commands it runs in Redis.
+func (c *CommandCounter) Counts() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.byName)
+}
+
+// count counts cmds.
+func (c *CommandCounter) count(cmds ...redis.Cmder) {
+	c.commands.Add(int64(len(cmds)))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byName == nil {
+		c.byName = make(map[string]int64)
+	}
+	for _, cmd := range cmds {
+		c.byName[cmd.Name()]++
+	}
+}
+
+// DialHook returns next unchanged.
+func (c *CommandCounter) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+// ProcessHook counts a single command.
+func (c *CommandCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		c.count(cmd)
+		return next(ctx, cmd)
+	}
+}
+
+// ProcessPipelineHook counts the commands of a pipeline or transaction.
+func (c *CommandCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		c.count(cmds...)
+		return next(ctx, cmds)
+	}
+}
@@ -0,0 +1,56 @@
+package workload
+
+import "fmt"
+
+// CurvePoint is the outcome of replaying a workload against a cache of one capacity.
+type CurvePoint struct {
+	Policy    string  `json:"policy"`
+	Capacity  int     `json:"capacity"`
+	Requests  int     `json:"requests"`
+	Hits      int     `json:"hits"`
+	Misses    int     `json:"misses"`
+	HitRatio  float64 `json:"hit_ratio"`
+	MissRatio float64 `json:"miss_ratio"`
+}
+
+// MissRatioCurve replays keys against a fresh cache of each capacity, created by newCache, and returns
+// the hit and miss ratios of the policy at each capacity, in the order of capacities.
+func MissRatioCurve(policy string, capacities []int, keys []string, newCache func(capacity int) (Cache, error)) ([]CurvePoint, error) {
+	points := make([]CurvePoint, 0, len(capacities))
+	for _, capacity := range capacities {
+		c, err := newCache(capacity)
+		if err != nil {
+			return nil, err
+		}
+		result := Replay(c, keys)
+		if result.Errors > 0 {
+			return nil, fmt.Errorf("%d accesses failed at capacity %d", result.Errors, capacity)
+		}
+
+		point := CurvePoint{
+			Policy:   policy,
+			Capacity: capacity,
+			Requests: result.Requests,
+			Hits:     result.Hits,
+			Misses:   result.Misses,
+			HitRatio: result.HitRatio(),
+		}
+		if result.Hits+result.Misses > 0 {
+			point.MissRatio = 1 - point.HitRatio
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// Capacities returns the capacities from first to last, both included, spaced by step.
+func Capacities(first, last, step int) ([]int, error) {
+	if first <= 0 || last < first || step <= 0 {
+		return nil, fmt.Errorf("invalid capacity range: %d to %d by %d", first, last, step)
+	}
+	var capacities []int
+	for capacity := first; capacity <= last; capacity += step {
+		capacities = append(capacities, capacity)
+	}
+	return capacities, nil
+}
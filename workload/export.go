@@ -0,0 +1,192 @@
+package workload
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Run is the result of a replay or a benchmark with the metadata needed to compare it with other runs.
+type Run struct {
+	// Time is when the run started.
+	Time time.Time
+	// Policy and Capacity describe the cache.
+	Policy   string
+	Capacity int
+	// Workload is the distribution of the keys, or the path of the replayed trace.
+	Workload string
+	// Keys is the size of the key space of a generated workload.
+	Keys int
+	// Seed is the seed of a generated workload.
+	Seed int64
+	// Goroutines is the number of concurrent clients, and Rate their total requests per second, or 0 if unpaced.
+	Goroutines int
+	Rate       float64
+	// Warmup is the duration of the warm-up before the run, whose accesses are not part of the result.
+	Warmup time.Duration
+	// Result summarizes the accesses.
+	Result Result
+	// Evictions is the number of evictions reported by the cache.
+	Evictions int64
+	// Commands is the number of Redis commands sent, if they were counted.
+	Commands int64
+	// MemoryBytes is the memory the cache took in Redis at the end of the run, if it was measured.
+	MemoryBytes int64
+}
+
+// runRecord is the flat form of a Run written by WriteJSON and WriteCSV. Durations are in microseconds.
+type runRecord struct {
+	Time        time.Time `json:"time"`
+	Policy      string    `json:"policy"`
+	Capacity    int       `json:"capacity"`
+	Workload    string    `json:"workload"`
+	Keys        int       `json:"keys,omitempty"`
+	Seed        int64     `json:"seed"`
+	Goroutines  int       `json:"goroutines"`
+	Rate        float64   `json:"rate"`
+	WarmupUs    float64   `json:"warmup_us"`
+	Requests    int       `json:"requests"`
+	Hits        int       `json:"hits"`
+	Misses      int       `json:"misses"`
+	Errors      int       `json:"errors"`
+	HitRatio    float64   `json:"hit_ratio"`
+	Evictions   int64     `json:"evictions"`
+	Commands    int64     `json:"commands"`
+	MemoryBytes int64     `json:"memory_bytes"`
+	ElapsedUs   float64   `json:"elapsed_us"`
+	Throughput  float64   `json:"throughput"`
+	P50Us       float64   `json:"p50_us"`
+	P95Us       float64   `json:"p95_us"`
+	P99Us       float64   `json:"p99_us"`
+	P999Us      float64   `json:"p999_us"`
+}
+
+// csvHeader is the header row written by WriteCSV, in the order of the fields of runRecord.
+var csvHeader = []string{
+	"time", "policy", "capacity", "workload", "keys", "seed", "goroutines", "rate", "warmup_us",
+	"requests", "hits", "misses", "errors", "hit_ratio", "evictions", "commands", "memory_bytes",
+	"elapsed_us", "throughput", "p50_us", "p95_us", "p99_us", "p999_us",
+}
+
+// record returns the flat form of the run.
+func (r Run) record() runRecord {
+	return runRecord{
+		Time:        r.Time,
+		Policy:      r.Policy,
+		Capacity:    r.Capacity,
+		Workload:    r.Workload,
+		Keys:        r.Keys,
+		Seed:        r.Seed,
+		Goroutines:  r.Goroutines,
+		Rate:        r.Rate,
+		WarmupUs:    microseconds(r.Warmup),
+		Requests:    r.Result.Requests,
+		Hits:        r.Result.Hits,
+		Misses:      r.Result.Misses,
+		Errors:      r.Result.Errors,
+		HitRatio:    r.Result.HitRatio(),
+		Evictions:   r.Evictions,
+		Commands:    r.Commands,
+		MemoryBytes: r.MemoryBytes,
+		ElapsedUs:   microseconds(r.Result.Elapsed),
+		Throughput:  r.Result.Throughput(),
+		P50Us:       microseconds(r.Result.P50),
+		P95Us:       microseconds(r.Result.P95),
+		P99Us:       microseconds(r.Result.P99),
+		P999Us:      microseconds(r.Result.P999),
+	}
+}
+
+// microseconds returns d in fractional microseconds, so the sub-microsecond latencies of simulated caches are kept.
+func microseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
+
+// WriteJSON writes runs to w as an indented JSON array.
+func WriteJSON(w io.Writer, runs []Run) error {
+	records := make([]runRecord, len(runs))
+	for i, r := range runs {
+		records[i] = r.record()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// WriteCSV writes runs to w as CSV with a header row.
+func WriteCSV(w io.Writer, runs []Run) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, r := range runs {
+		rec := r.record()
+		cw.Write([]string{
+			rec.Time.Format(time.RFC3339Nano),
+			rec.Policy,
+			strconv.Itoa(rec.Capacity),
+			rec.Workload,
+			strconv.Itoa(rec.Keys),
+			strconv.FormatInt(rec.Seed, 10),
+			strconv.Itoa(rec.Goroutines),
+			strconv.FormatFloat(rec.Rate, 'f', -1, 64),
+			strconv.FormatFloat(rec.WarmupUs, 'f', 3, 64),
+			strconv.Itoa(rec.Requests),
+			strconv.Itoa(rec.Hits),
+			strconv.Itoa(rec.Misses),
+			strconv.Itoa(rec.Errors),
+			strconv.FormatFloat(rec.HitRatio, 'f', 4, 64),
+			strconv.FormatInt(rec.Evictions, 10),
+			strconv.FormatInt(rec.Commands, 10),
+			strconv.FormatInt(rec.MemoryBytes, 10),
+			strconv.FormatFloat(rec.ElapsedUs, 'f', 3, 64),
+			strconv.FormatFloat(rec.Throughput, 'f', 1, 64),
+			strconv.FormatFloat(rec.P50Us, 'f', 3, 64),
+			strconv.FormatFloat(rec.P95Us, 'f', 3, 64),
+			strconv.FormatFloat(rec.P99Us, 'f', 3, 64),
+			strconv.FormatFloat(rec.P999Us, 'f', 3, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportFile writes runs to the file at path, as JSON if its extension is .json and as CSV if it is .csv.
+func ExportFile(path string, runs []Run) error {
+	write, err := exportWriter(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, runs); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// CheckExportPath returns an error if ExportFile cannot infer the format of path from its extension,
+// so commands can reject it before running.
+func CheckExportPath(path string) error {
+	_, err := exportWriter(path)
+	return err
+}
+
+// exportWriter returns the writer of the format of path.
+func exportWriter(path string) (func(io.Writer, []Run) error, error) {
+	switch filepath.Ext(path) {
+	case ".json":
+		return WriteJSON, nil
+	case ".csv":
+		return WriteCSV, nil
+	default:
+		return nil, fmt.Errorf("unknown export format of file: %s: use .json or .csv", path)
+	}
+}
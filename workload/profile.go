@@ -0,0 +1,100 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// Footprint is the memory a cache takes in Redis.
+type Footprint struct {
+	// Keys is the number of keys of the cache, including its indexes and metadata.
+	Keys int
+	// Bytes is the sum of the MEMORY USAGE of the keys, or of their serialized length if the command is unavailable.
+	Bytes int64
+}
+
+// MeasureFootprint sums the memory taken by every key of the cache under prefix, see cache.NamespaceKeys.
+// It scans the keyspace, so it is meant for benchmarks rather than production servers.
+func MeasureFootprint(ctx context.Context, client redis.UniversalClient, prefix string) (Footprint, error) {
+	keys, err := cache.NamespaceKeys(ctx, client, prefix)
+	if err != nil {
+		return Footprint{}, err
+	}
+
+	cmds := make([]*redis.IntCmd, len(keys))
+	_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.MemoryUsage(ctx, key)
+		}
+		return nil
+	})
+
+	footprint := Footprint{Keys: len(keys)}
+	if err != nil && err != redis.Nil {
+		// MEMORY USAGE is unavailable on some deployments; fall back to the length of the serialized keys.
+		dumps := make([]*redis.StringCmd, len(keys))
+		if _, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range keys {
+				dumps[i] = pipe.Dump(ctx, key)
+			}
+			return nil
+		}); err != nil && err != redis.Nil {
+			return Footprint{}, err
+		}
+		for _, dump := range dumps {
+			footprint.Bytes += int64(len(dump.Val()))
+		}
+		return footprint, nil
+	}
+
+	for _, cmd := range cmds {
+		footprint.Bytes += cmd.Val()
+	}
+	return footprint, nil
+}
+
+// CommandStats returns the number of calls of each command from INFO commandstats, by lowercase command name,
+// since the server started or its stats were reset. Unlike a CommandCounter, it counts the commands run by
+// Lua scripts, but also those of every other client of the server.
+func CommandStats(ctx context.Context, client redis.UniversalClient) (map[string]int64, error) {
+	info, err := client.Info(ctx, "commandstats").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]int64)
+	for _, line := range strings.Split(info, "\n") {
+		name, fields, found := strings.Cut(strings.TrimSpace(line), ":")
+		if !found || !strings.HasPrefix(name, "cmdstat_") {
+			continue
+		}
+		for _, field := range strings.Split(fields, ",") {
+			if value, found := strings.CutPrefix(field, "calls="); found {
+				calls, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("malformed commandstats line: %s", line)
+				}
+				stats[strings.TrimPrefix(name, "cmdstat_")] = calls
+			}
+		}
+	}
+	return stats, nil
+}
+
+// SubtractCommandStats returns the calls of each command made between the before and after snapshots of CommandStats,
+// leaving out INFO, which took the snapshots, and the commands without calls.
+func SubtractCommandStats(after, before map[string]int64) map[string]int64 {
+	diff := make(map[string]int64)
+	for name, calls := range after {
+		if name == "info" || calls-before[name] <= 0 {
+			continue
+		}
+		diff[name] = calls - before[name]
+	}
+	return diff
+}
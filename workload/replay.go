@@ -0,0 +1,112 @@
+package workload
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// Result summarizes the accesses of a replay or a benchmark.
+type Result struct {
+	// Requests is the number of keys accessed.
+	Requests int
+	// Hits and Misses count the reads that found and did not find the key in the cache.
+	Hits   int
+	Misses int
+	// Errors counts the reads and writes that failed for another reason than a miss.
+	Errors int
+	// Elapsed is the duration of the accesses.
+	Elapsed time.Duration
+	// P50, P95, P99 and P999 are percentiles of the latency of an access, including the Set after a miss.
+	P50  time.Duration
+	P95  time.Duration
+	P99  time.Duration
+	P999 time.Duration
+}
+
+// Throughput returns the number of accesses per second.
+func (r Result) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Elapsed.Seconds()
+}
+
+// HitRatio returns the share of reads that were hits, or 0 if nothing was read.
+func (r Result) HitRatio() float64 {
+	if r.Hits+r.Misses == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Hits+r.Misses)
+}
+
+// Recorder accesses a cache as a read-through cache would and records the outcomes and latencies.
+// A Recorder is not safe for concurrent use; concurrent clients should each have their own and merge them.
+type Recorder struct {
+	result    Result
+	latencies []time.Duration
+}
+
+// Access reads key with Get and, on a miss, writes a user with that id with Set. The database is left out,
+// so the latencies measure the cache alone.
+func (r *Recorder) Access(c Cache, key string) {
+	start := time.Now()
+	_, err := c.Get(key)
+	switch {
+	case err == nil:
+		r.result.Hits++
+	case errors.Is(err, redis.Nil):
+		r.result.Misses++
+		if err := c.Set(cache.User{Id: key}); err != nil {
+			r.result.Errors++
+		}
+	default:
+		r.result.Errors++
+	}
+	r.result.Requests++
+	r.latencies = append(r.latencies, time.Since(start))
+}
+
+// Merge adds the accesses recorded by other.
+func (r *Recorder) Merge(other *Recorder) {
+	r.result.Requests += other.result.Requests
+	r.result.Hits += other.result.Hits
+	r.result.Misses += other.result.Misses
+	r.result.Errors += other.result.Errors
+	r.latencies = append(r.latencies, other.latencies...)
+}
+
+// Result returns the summary of the recorded accesses, which took elapsed.
+func (r *Recorder) Result(elapsed time.Duration) Result {
+	result := r.result
+	result.Elapsed = elapsed
+
+	latencies := slices.Clone(r.latencies)
+	slices.Sort(latencies)
+	result.P50 = percentile(latencies, 0.50)
+	result.P95 = percentile(latencies, 0.95)
+	result.P99 = percentile(latencies, 0.99)
+	result.P999 = percentile(latencies, 0.999)
+	return result
+}
+
+// Replay accesses keys in order with a Recorder and returns the result.
+func Replay(c Cache, keys []string) Result {
+	r := &Recorder{latencies: make([]time.Duration, 0, len(keys))}
+	start := time.Now()
+	for _, key := range keys {
+		r.Access(c, key)
+	}
+	return r.Result(time.Since(start))
+}
+
+// percentile returns the q-th quantile of sorted latencies, or 0 if there are none.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[min(int(q*float64(len(sorted))), len(sorted)-1)]
+}
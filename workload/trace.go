@@ -0,0 +1,114 @@
+package workload
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TraceFormat is the format of an access trace.
+type TraceFormat string
+
+const (
+	// CSVTrace is a CSV file with the key of each access in its first column. Lines starting with # are comments,
+	// and a first row whose first column is "key" is a header.
+	CSVTrace TraceFormat = "csv"
+	// ARCTrace is the format of the traces published with the ARC paper: each line holds a starting block,
+	// a number of blocks and two ignored fields, and stands for an access to each of the blocks in turn.
+	ARCTrace TraceFormat = "arc"
+	// LIRSTrace is the format of the traces published with the LIRS paper: each line holds the number of an accessed block.
+	// Lines that are not numbers, such as the * separators of some traces, are skipped.
+	LIRSTrace TraceFormat = "lirs"
+)
+
+// ParseTraceFormat returns the TraceFormat named s, for use with command line flags.
+func ParseTraceFormat(s string) (TraceFormat, error) {
+	switch f := TraceFormat(s); f {
+	case CSVTrace, ARCTrace, LIRSTrace:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown trace format: %s", s)
+	}
+}
+
+// ReadTrace reads the keys of an access trace in the given format, in the order they were accessed.
+func ReadTrace(r io.Reader, format TraceFormat) ([]string, error) {
+	switch format {
+	case CSVTrace:
+		return readCSVTrace(r)
+	case ARCTrace:
+		return readARCTrace(r)
+	case LIRSTrace:
+		return readLIRSTrace(r)
+	default:
+		return nil, fmt.Errorf("unknown trace format: %s", format)
+	}
+}
+
+// readCSVTrace reads a trace in CSVTrace format.
+func readCSVTrace(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+
+	var keys []string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		key := strings.TrimSpace(record[0])
+		if key == "" || (len(keys) == 0 && key == "key") {
+			continue
+		}
+		keys = append(keys, key)
+	}
+}
+
+// readARCTrace reads a trace in ARCTrace format.
+func readARCTrace(r io.Reader) ([]string, error) {
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected a starting block and a number of blocks", line)
+		}
+		start, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid starting block: %w", line, err)
+		}
+		blocks, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid number of blocks: %w", line, err)
+		}
+		for block := start; block < start+blocks; block++ {
+			keys = append(keys, strconv.FormatInt(block, 10))
+		}
+	}
+	return keys, scanner.Err()
+}
+
+// readLIRSTrace reads a trace in LIRSTrace format.
+func readLIRSTrace(r io.Reader) ([]string, error) {
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		field := strings.TrimSpace(scanner.Text())
+		if _, err := strconv.ParseInt(field, 10, 64); err != nil {
+			continue
+		}
+		keys = append(keys, field)
+	}
+	return keys, scanner.Err()
+}
@@ -0,0 +1,45 @@
+package workload
+
+import (
+	"math"
+
+	"github.com/AkifhanIlgaz/redis-caching-algorithms/cache"
+)
+
+// DefaultSteadyReads is the number of reads an interval needs for SteadyState to compare its hit ratio.
+const DefaultSteadyReads = 100
+
+// SteadyState detects when a cache warmed up: when the hit ratio of consecutive intervals of a workload
+// stops changing. The intervals are delimited by the calls of Observe with the stats of the cache.
+type SteadyState struct {
+	// Tolerance is the largest difference between the hit ratios of consecutive intervals of a steady cache.
+	// A cache is never steady with a Tolerance of 0.
+	Tolerance float64
+	// MinReads is the number of reads an interval needs to be compared. It defaults to DefaultSteadyReads.
+	MinReads int64
+
+	hits     int64
+	misses   int64
+	last     float64
+	observed bool
+}
+
+// Observe takes the stats of the cache at the end of an interval and reports whether the hit ratio of the interval
+// is within Tolerance of the one of the previous interval. An interval with too few reads is merged into the next one.
+func (s *SteadyState) Observe(stats cache.Stats) bool {
+	minReads := s.MinReads
+	if minReads <= 0 {
+		minReads = DefaultSteadyReads
+	}
+
+	hits, misses := stats.Hits-s.hits, stats.Misses-s.misses
+	if hits+misses < minReads {
+		return false
+	}
+	s.hits, s.misses = stats.Hits, stats.Misses
+
+	ratio := float64(hits) / float64(hits+misses)
+	steady := s.observed && s.Tolerance > 0 && math.Abs(ratio-s.last) <= s.Tolerance
+	s.last, s.observed = ratio, true
+	return steady
+}
@@ -0,0 +1,150 @@
+// Package workload generates streams of cache keys with configurable access distributions,
+// so demos and benchmarks can stress the caches with realistic skew.
+package workload
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+)
+
+// Distribution is the probability distribution keys are drawn from.
+type Distribution string
+
+const (
+	// Uniform draws every key with the same probability.
+	Uniform Distribution = "uniform"
+	// Zipf draws the key of rank k with a probability proportional to 1/(k+1)^s, so a few keys get most of the traffic.
+	Zipf Distribution = "zipf"
+	// Hotspot sends a share of the requests to a small set of hot keys, and the rest uniformly to the other keys.
+	Hotspot Distribution = "hotspot"
+	// Sequential cycles through the key space in order, the worst case of LRU and FIFO when it exceeds the capacity.
+	Sequential Distribution = "sequential"
+)
+
+// Defaults of the distribution parameters left at zero in a Config.
+const (
+	DefaultZipfS       = 1.1
+	DefaultHotFraction = 0.2
+	DefaultHotRate     = 0.8
+)
+
+// Config describes a workload.
+type Config struct {
+	// Distribution is the distribution of the keys.
+	Distribution Distribution
+	// Keys is the size of the key space. Keys are the decimal numbers from 0 to Keys-1.
+	Keys int
+	// Requests is the number of keys to generate, or 0 for an endless stream.
+	Requests int
+	// Seed seeds the random source, so a workload can be replayed.
+	Seed int64
+	// ZipfS is the skew of the Zipf distribution. It must be greater than 1 and defaults to DefaultZipfS.
+	ZipfS float64
+	// HotFraction is the share of the key space that is hot in the hotspot distribution. It defaults to DefaultHotFraction.
+	HotFraction float64
+	// HotRate is the share of the requests sent to the hot keys in the hotspot distribution. It defaults to DefaultHotRate.
+	HotRate float64
+}
+
+// Generator generates the keys of a workload. A Generator is not safe for concurrent use;
+// concurrent clients should each have their own, with different seeds.
+type Generator struct {
+	config    Config
+	rng       *rand.Rand
+	zipf      *rand.Zipf
+	hotKeys   int
+	generated int
+}
+
+// ParseDistribution returns the Distribution named s, for use with command line flags.
+func ParseDistribution(s string) (Distribution, error) {
+	switch d := Distribution(s); d {
+	case Uniform, Zipf, Hotspot, Sequential:
+		return d, nil
+	default:
+		return "", fmt.Errorf("unknown distribution: %s", s)
+	}
+}
+
+// New creates a Generator for config, filling in the defaults of its distribution.
+func New(config Config) (*Generator, error) {
+	if config.Keys <= 0 {
+		return nil, fmt.Errorf("key space must not be empty: %d", config.Keys)
+	}
+	if config.Requests < 0 {
+		return nil, fmt.Errorf("number of requests must not be negative: %d", config.Requests)
+	}
+
+	g := &Generator{
+		config: config,
+		rng:    rand.New(rand.NewSource(config.Seed)),
+	}
+	switch config.Distribution {
+	case Uniform, Sequential:
+	case Zipf:
+		if g.config.ZipfS == 0 {
+			g.config.ZipfS = DefaultZipfS
+		}
+		if g.config.ZipfS <= 1 {
+			return nil, fmt.Errorf("zipf skew must be greater than 1: %v", g.config.ZipfS)
+		}
+		g.zipf = rand.NewZipf(g.rng, g.config.ZipfS, 1, uint64(config.Keys-1))
+	case Hotspot:
+		if g.config.HotFraction == 0 {
+			g.config.HotFraction = DefaultHotFraction
+		}
+		if g.config.HotRate == 0 {
+			g.config.HotRate = DefaultHotRate
+		}
+		if g.config.HotFraction < 0 || g.config.HotFraction > 1 || g.config.HotRate < 0 || g.config.HotRate > 1 {
+			return nil, fmt.Errorf("hot fraction and hot rate must be between 0 and 1: %v, %v", g.config.HotFraction, g.config.HotRate)
+		}
+		g.hotKeys = max(int(float64(config.Keys)*g.config.HotFraction), 1)
+	default:
+		return nil, fmt.Errorf("unknown distribution: %s", config.Distribution)
+	}
+	return g, nil
+}
+
+// Next returns the next key of the workload, or false once Requests keys have been generated.
+func (g *Generator) Next() (string, bool) {
+	if g.config.Requests > 0 && g.generated >= g.config.Requests {
+		return "", false
+	}
+	key := g.nextKey()
+	g.generated++
+	return strconv.Itoa(key), true
+}
+
+// nextKey draws the next key from the distribution.
+func (g *Generator) nextKey() int {
+	switch g.config.Distribution {
+	case Zipf:
+		return int(g.zipf.Uint64())
+	case Hotspot:
+		if g.hotKeys == g.config.Keys || g.rng.Float64() < g.config.HotRate {
+			return g.rng.Intn(g.hotKeys)
+		}
+		return g.hotKeys + g.rng.Intn(g.config.Keys-g.hotKeys)
+	case Sequential:
+		return g.generated % g.config.Keys
+	default:
+		return g.rng.Intn(g.config.Keys)
+	}
+}
+
+// All returns every remaining key of the workload, or nil if the workload is endless.
+func (g *Generator) All() []string {
+	if g.config.Requests == 0 {
+		return nil
+	}
+	keys := make([]string, 0, g.config.Requests-g.generated)
+	for {
+		key, ok := g.Next()
+		if !ok {
+			return keys
+		}
+		keys = append(keys, key)
+	}
+}